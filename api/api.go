@@ -0,0 +1,379 @@
+// Package api provides JSON HTTP API over one or more registered repositories
+// It serves branches, commits, paginated history, file listings and file contents
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/kalyabin/vcsview"
+)
+
+const (
+	// Default number of commits per history page
+	defaultPageSize = 30
+
+	// Maximum number of commits per history page
+	maxPageSize = 100
+
+	// Default revision of file listings and contents
+	defaultRevision = "HEAD"
+
+	// Content encodings of the file content response
+	EncodingUTF8 = "utf-8"
+	EncodingBase64 = "base64"
+)
+
+// JSON API server
+// Routes relative to the server prefix:
+// GET /repositories                                   - registered repositories
+// GET /repositories/<name>                            - single repository
+// GET /repositories/<name>/branches                   - repository branches
+// GET /repositories/<name>/commits?branch=&path=      - paginated history (page and per_page query params)
+// GET /repositories/<name>/commits?cursor=&path=      - history page after the cursor (next field of the previous page, empty for the first one)
+// GET /repositories/<name>/commits/<id>               - single commit
+// GET /repositories/<name>/tree/<path>?rev=           - directory listing at revision
+// GET /repositories/<name>/content/<path>?rev=        - file content at revision
+type Server struct {
+	// URL path prefix where server is mounted (without trailing slash)
+	prefix string
+
+	// Registered repositories by names
+	repositories map[string]vcsview.Repository
+
+	// Guards repositories
+	mu sync.RWMutex
+
+	// Default number of commits per history page
+	PageSize int
+}
+
+// Repository response
+type Repository struct {
+	Name string `json:"name"`
+}
+
+// Paginated commits response
+type Commits struct {
+	Commits []vcsview.Commit `json:"commits"`
+	Page int `json:"page"`
+	PerPage int `json:"per_page"`
+	HasNext bool `json:"has_next"`
+
+	// Cursor of the next page (cursor pagination only)
+	Next string `json:"next,omitempty"`
+}
+
+// File content response
+// Text content is encoded as is, binary content is encoded by base64
+type Content struct {
+	File vcsview.File `json:"file"`
+	Id string `json:"id"`
+	Encoding string `json:"encoding"`
+	Content string `json:"content"`
+}
+
+// Error response
+type Error struct {
+	Error string `json:"error"`
+}
+
+// Create new API server
+// Prefix is a URL path prefix where server is mounted, for example /api
+// Server strips prefix itself, so it shouldn't be wrapped by http.StripPrefix
+func NewServer(prefix string) *Server {
+	return &Server{
+		prefix: strings.TrimRight(prefix, "/"),
+		repositories: make(map[string]vcsview.Repository),
+		PageSize: defaultPageSize,
+	}
+}
+
+// Register repository by name
+// Returns error if name is empty, contains slash or already registered
+func (s *Server) Register(name string, r vcsview.Repository) error {
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("Invalid repository name %q", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.repositories[name]; ok {
+		return fmt.Errorf("Repository %s already registered", name)
+	}
+
+	s.repositories[name] = r
+
+	return nil
+}
+
+// Get registered repository by name
+func (s *Server) repository(name string) (vcsview.Repository, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.repositories[name]
+
+	return r, ok
+}
+
+// Returns sorted names of the registered repositories
+func (s *Server) names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.repositories))
+	for name := range s.repositories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Write JSON response
+func (s *Server) write(w http.ResponseWriter, status int, data interface{}) {
+	buf := new(bytes.Buffer)
+
+	if err := json.NewEncoder(buf).Encode(data); err != nil {
+		status = http.StatusInternalServerError
+		buf.Reset()
+		json.NewEncoder(buf).Encode(Error{err.Error()})
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// Write JSON error response
+func (s *Server) fail(w http.ResponseWriter, status int, err error) {
+	s.write(w, status, Error{err.Error()})
+}
+
+// Returns response status of the repository error
+// Missing repository, revision or path is not found, ambiguous revision is a bad request
+func errorStatus(err error) int {
+	switch vcsview.ErrorKind(err) {
+	case vcsview.ErrRepositoryNotFound, vcsview.ErrRevisionNotFound, vcsview.ErrPathNotFound:
+		return http.StatusNotFound
+	case vcsview.ErrAmbiguousRevision:
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Returns revision query param or default revision
+func revision(req *http.Request) string {
+	if rev := req.URL.Query().Get("rev"); rev != "" {
+		return rev
+	}
+
+	return defaultRevision
+}
+
+// Returns positive integer query param or default value
+// Returns error if param is not a positive integer
+func positiveParam(req *http.Request, name string, value int) (int, error) {
+	param := req.URL.Query().Get(name)
+	if param == "" {
+		return value, nil
+	}
+
+	i, err := strconv.Atoi(param)
+	if err != nil || i < 1 {
+		return 0, fmt.Errorf("Invalid %s param %q", name, param)
+	}
+
+	return i, nil
+}
+
+// Serve HTTP request
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		s.fail(w, http.StatusMethodNotAllowed, fmt.Errorf("Method %s is not allowed", req.Method))
+		return
+	}
+
+	p := strings.TrimPrefix(req.URL.Path, s.prefix)
+
+	// repositories/<name>/<route>/<pathname>
+	parts := strings.SplitN(strings.Trim(p, "/"), "/", 4)
+
+	if !strings.HasPrefix(req.URL.Path, s.prefix) || !strings.HasPrefix(p, "/") || parts[0] != "repositories" {
+		s.fail(w, http.StatusNotFound, fmt.Errorf("Route %s not found", req.URL.Path))
+		return
+	}
+
+	if len(parts) == 1 {
+		s.list(w, req)
+		return
+	}
+
+	r, ok := s.repository(parts[1])
+	if !ok {
+		s.fail(w, http.StatusNotFound, fmt.Errorf("Repository %s not found", parts[1]))
+		return
+	}
+
+	route, pathname := "", ""
+	if len(parts) > 2 {
+		route = parts[2]
+	}
+	if len(parts) > 3 {
+		pathname = strings.Trim(path.Clean("/"+parts[3]), "/")
+	}
+
+	switch {
+	case route == "":
+		s.write(w, http.StatusOK, Repository{parts[1]})
+	case route == "branches" && pathname == "":
+		s.branches(w, req, r)
+	case route == "commits" && pathname == "":
+		s.history(w, req, r)
+	case route == "commits":
+		s.commit(w, req, r, pathname)
+	case route == "tree":
+		s.tree(w, req, r, pathname)
+	case route == "content" && pathname != "":
+		s.content(w, req, r, pathname)
+	default:
+		s.fail(w, http.StatusNotFound, fmt.Errorf("Route %s not found", req.URL.Path))
+	}
+}
+
+// Serve registered repositories list
+func (s *Server) list(w http.ResponseWriter, req *http.Request) {
+	result := make([]Repository, 0)
+
+	for _, name := range s.names() {
+		result = append(result, Repository{name})
+	}
+
+	s.write(w, http.StatusOK, result)
+}
+
+// Serve branches list
+func (s *Server) branches(w http.ResponseWriter, req *http.Request, r vcsview.Repository) {
+	branches, err := r.Branches(req.Context())
+	if err != nil {
+		s.fail(w, errorStatus(err), err)
+		return
+	}
+
+	s.write(w, http.StatusOK, branches)
+}
+
+// Serve paginated history
+func (s *Server) history(w http.ResponseWriter, req *http.Request, r vcsview.Repository) {
+	pageSize := s.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	page, err := positiveParam(req, "page", 1)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, err)
+		return
+	}
+
+	perPage, err := positiveParam(req, "per_page", pageSize)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if perPage > maxPageSize {
+		perPage = maxPageSize
+	}
+
+	query := req.URL.Query()
+	pathname := strings.Trim(path.Clean("/"+query.Get("path")), "/")
+
+	if cursor, ok := query["cursor"]; ok {
+		commits, next, err := r.HistoryPage(req.Context(), pathname, query.Get("branch"), cursor[0], perPage)
+		if err != nil {
+			s.fail(w, errorStatus(err), err)
+			return
+		}
+
+		s.write(w, http.StatusOK, Commits{commits, 0, perPage, next != "", next})
+		return
+	}
+
+	// read one more commit to know there is the next page
+	commits, err := r.History(req.Context(), pathname, query.Get("branch"), (page-1)*perPage, perPage+1)
+	if err != nil {
+		s.fail(w, errorStatus(err), err)
+		return
+	}
+
+	result := Commits{commits, page, perPage, false, ""}
+
+	if len(commits) > perPage {
+		result.Commits = commits[:perPage]
+		result.HasNext = true
+	}
+
+	s.write(w, http.StatusOK, result)
+}
+
+// Serve single commit
+func (s *Server) commit(w http.ResponseWriter, req *http.Request, r vcsview.Repository, commitId string) {
+	commit, err := r.Commit(req.Context(), commitId)
+	if err != nil {
+		s.fail(w, errorStatus(err), err)
+		return
+	}
+
+	s.write(w, http.StatusOK, commit)
+}
+
+// Serve directory listing
+func (s *Server) tree(w http.ResponseWriter, req *http.Request, r vcsview.Repository, pathname string) {
+	rev := revision(req)
+
+	files, err := r.ReadTree(req.Context(), rev, pathname, false)
+	if err != nil {
+		s.fail(w, errorStatus(err), err)
+		return
+	}
+
+	if len(files) == 0 && pathname != "" {
+		s.fail(w, http.StatusNotFound, fmt.Errorf("Path %s not found at %s", pathname, rev))
+		return
+	}
+
+	s.write(w, http.StatusOK, files)
+}
+
+// Serve file content
+func (s *Server) content(w http.ResponseWriter, req *http.Request, r vcsview.Repository, pathname string) {
+	blob, err := r.ReadBlob(req.Context(), revision(req), pathname)
+	if err != nil {
+		s.fail(w, errorStatus(err), err)
+		return
+	}
+
+	result := Content{blob.File, blob.Id(), EncodingUTF8, string(blob.Content())}
+
+	if blob.IsBinary() || !utf8.Valid(blob.Content()) {
+		result.Encoding = EncodingBase64
+		result.Content = base64.StdEncoding.EncodeToString(blob.Content())
+	}
+
+	s.write(w, http.StatusOK, result)
+}
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kalyabin/vcsview"
+)
+
+const (
+	gitRepositoryPath = "../testdata/git"
+)
+
+func makeServer(t *testing.T) *Server {
+	r, err := vcsview.NewRepository(gitRepositoryPath, vcsview.NewGit())
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+
+	s := NewServer("/api/")
+	if err := s.Register("git", r); err != nil {
+		t.Fatalf("Server.Register(git) got error: %v", err)
+	}
+
+	return s
+}
+
+func get(t *testing.T, s *Server, target string, status int, v interface{}) {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+	if w.Code != status {
+		t.Fatalf("GET %s status = %d, want: %d. Body: %s", target, w.Code, status, w.Body.String())
+	}
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/json; charset=utf-8" {
+		t.Errorf("GET %s Content-Type = %v, want: application/json; charset=utf-8", target, contentType)
+	}
+
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("GET %s got invalid JSON: %v", target, err)
+	}
+}
+
+func TestServer_Register(t *testing.T) {
+	s := makeServer(t)
+
+	cases := []struct{
+		name string
+		gotError bool
+	}{
+		{"git", true},
+		{"", true},
+		{"some/name", true},
+		{"other", false},
+	}
+
+	for key, testCase := range cases {
+		err := s.Register(testCase.name, vcsview.Repository{})
+
+		if err != nil && !testCase.gotError {
+			t.Errorf("[%d] Server.Register(%s) = %v, want no errors", key, testCase.name, err)
+		}
+
+		if err == nil && testCase.gotError {
+			t.Errorf("[%d] Server.Register(%s) = nil, want errors", key, testCase.name)
+		}
+	}
+
+	var repositories []Repository
+	get(t, s, "/api/repositories", http.StatusOK, &repositories)
+
+	if len(repositories) != 2 || repositories[0].Name != "git" || repositories[1].Name != "other" {
+		t.Errorf("GET /api/repositories = %v, want: [git other]", repositories)
+	}
+}
+
+func TestServer_NotFound(t *testing.T) {
+	s := makeServer(t)
+
+	cases := []string{
+		"/api",
+		"/api/unknown",
+		"/api/repositoriesX",
+		"/api/repositories/unknown",
+		"/api/repositories/git/unknown",
+		"/api/repositories/git/commits/non-existent-commit",
+		"/api/repositories/git/tree/non-existent-path",
+		"/api/repositories/git/content/non-existent.txt",
+		"/api/repositories/git/tree?rev=non-existent-revision",
+		"/other/repositories",
+	}
+
+	for _, target := range cases {
+		var e Error
+		get(t, s, target, http.StatusNotFound, &e)
+
+		if e.Error == "" {
+			t.Errorf("GET %s error message is empty", target)
+		}
+	}
+}
+
+func TestServer_Branches(t *testing.T) {
+	s := makeServer(t)
+
+	var branches []vcsview.Branch
+	get(t, s, "/api/repositories/git/branches", http.StatusOK, &branches)
+
+	found := false
+	for _, b := range branches {
+		found = found || b.Id() == "master"
+	}
+
+	if !found {
+		t.Errorf("GET /api/repositories/git/branches = %v, want master branch", branches)
+	}
+}
+
+func TestServer_Commits(t *testing.T) {
+	s := makeServer(t)
+
+	var first Commits
+	get(t, s, "/api/repositories/git/commits?per_page=2", http.StatusOK, &first)
+
+	if len(first.Commits) != 2 || first.Page != 1 || first.PerPage != 2 || !first.HasNext {
+		t.Fatalf("GET /api/repositories/git/commits?per_page=2 = %v, want 2 commits and the next page", first)
+	}
+
+	var second Commits
+	get(t, s, "/api/repositories/git/commits?per_page=2&page=2", http.StatusOK, &second)
+
+	if len(second.Commits) == 0 || second.Commits[0].Id() == first.Commits[0].Id() {
+		t.Errorf("GET /api/repositories/git/commits?per_page=2&page=2 = %v, want the next commits", second)
+	}
+
+	// cursor pages follow the same history
+	var cursorFirst, cursorSecond Commits
+	get(t, s, "/api/repositories/git/commits?per_page=2&cursor=", http.StatusOK, &cursorFirst)
+
+	if len(cursorFirst.Commits) != 2 || cursorFirst.Next == "" || !cursorFirst.HasNext || cursorFirst.Commits[0].Id() != first.Commits[0].Id() {
+		t.Fatalf("GET /api/repositories/git/commits?per_page=2&cursor= = %v, want: %v and the next cursor", cursorFirst, first.Commits)
+	}
+
+	get(t, s, "/api/repositories/git/commits?per_page=2&cursor="+cursorFirst.Next, http.StatusOK, &cursorSecond)
+
+	if len(cursorSecond.Commits) != len(second.Commits) || cursorSecond.Commits[0].Id() != second.Commits[0].Id() {
+		t.Errorf("GET /api/repositories/git/commits?per_page=2&cursor=%s = %v, want: %v", cursorFirst.Next, cursorSecond.Commits, second.Commits)
+	}
+
+	var invalid Error
+	get(t, s, "/api/repositories/git/commits?cursor=invalid", http.StatusNotFound, &invalid)
+
+	var commit vcsview.Commit
+	get(t, s, "/api/repositories/git/commits/"+first.Commits[0].Id(), http.StatusOK, &commit)
+
+	if commit.Id() != first.Commits[0].Id() || commit.Message() != first.Commits[0].Message() {
+		t.Errorf("GET /api/repositories/git/commits/%s = %v, want: %v", first.Commits[0].Id(), commit, first.Commits[0])
+	}
+
+	for _, target := range []string{"/api/repositories/git/commits?page=0", "/api/repositories/git/commits?per_page=x"} {
+		var e Error
+		get(t, s, target, http.StatusBadRequest, &e)
+	}
+}
+
+func TestServer_Tree(t *testing.T) {
+	s := makeServer(t)
+
+	var files []vcsview.File
+	get(t, s, "/api/repositories/git/tree/testpath?rev=master", http.StatusOK, &files)
+
+	found := false
+	for _, f := range files {
+		found = found || f.Pathname() == "testpath/empty.txt"
+	}
+
+	if !found {
+		t.Errorf("GET /api/repositories/git/tree/testpath = %v, want testpath/empty.txt", files)
+	}
+}
+
+func TestServer_Content(t *testing.T) {
+	s := makeServer(t)
+
+	var content Content
+	get(t, s, "/api/repositories/git/content/testpath/empty.txt", http.StatusOK, &content)
+
+	if content.File.Pathname() != "testpath/empty.txt" || content.Encoding != EncodingUTF8 || content.Content != "" {
+		t.Errorf("GET /api/repositories/git/content/testpath/empty.txt = %v, want empty text file", content)
+	}
+}
+
+func TestServer_MethodNotAllowed(t *testing.T) {
+	s := makeServer(t)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/repositories", nil))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /api/repositories status = %d, want: %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	cases := []struct{
+		err error
+		want int
+	}{
+		{vcsview.ErrRepositoryNotFound, http.StatusNotFound},
+		{vcsview.ErrRevisionNotFound, http.StatusNotFound},
+		{vcsview.ErrPathNotFound, http.StatusNotFound},
+		{vcsview.ErrAmbiguousRevision, http.StatusBadRequest},
+		{fmt.Errorf("Command failed"), http.StatusInternalServerError},
+	}
+
+	for key, testCase := range cases {
+		if status := errorStatus(testCase.err); status != testCase.want {
+			t.Errorf("[%d] errorStatus(%v) = %d, want: %d", key, testCase.err, status, testCase.want)
+		}
+	}
+}
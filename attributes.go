@@ -0,0 +1,197 @@
+package vcsview
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// Attributes file name
+	attributesFilename = ".gitattributes"
+
+	// Value of the set attribute (like "text")
+	AttributeSet = "set"
+
+	// Value of the unset attribute (like "-text")
+	AttributeUnset = "unset"
+)
+
+// Attribute macros which are always defined
+var builtinAttributeMacros = map[string][]string{
+	"binary": {"-diff", "-merge", "-text"},
+}
+
+// Single pattern line of attributes file
+type attributesRule struct {
+	// Relative directory of the attributes file
+	dir string
+
+	// Path pattern
+	pattern string
+
+	// Attribute values by names
+	values map[string]string
+}
+
+// Set of path attributes collected from .gitattributes files of the project
+// Later and deeper rules override earlier and shallower ones like git does
+type Attributes struct {
+	// Rules sorted by directory depth
+	rules []attributesRule
+
+	// Custom macros defined by [attr] lines
+	macros map[string][]string
+}
+
+// Parse attribute assignment like text, -text, !text or eol=lf
+// Macros are expanded to the attributes they contain
+func (a *Attributes) assign(values map[string]string, token string) {
+	name, value := token, AttributeSet
+
+	switch {
+	case strings.HasPrefix(token, "-"):
+		name, value = token[1:], AttributeUnset
+	case strings.HasPrefix(token, "!"):
+		name, value = token[1:], ""
+	case strings.Contains(token, "="):
+		pos := strings.Index(token, "=")
+		name, value = token[:pos], token[pos+1:]
+	}
+
+	values[name] = value
+
+	if value != AttributeSet {
+		return
+	}
+
+	macro, ok := a.macros[name]
+	if !ok {
+		macro, ok = builtinAttributeMacros[name]
+	}
+
+	if ok {
+		for _, t := range macro {
+			a.assign(values, t)
+		}
+	}
+}
+
+// Add attributes file content located at relative directory dir
+// Root attributes file has empty dir
+func (a *Attributes) Add(dir string, content string) {
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if strings.HasPrefix(fields[0], "[attr]") {
+			if a.macros == nil {
+				a.macros = make(map[string][]string)
+			}
+			a.macros[strings.TrimPrefix(fields[0], "[attr]")] = fields[1:]
+			continue
+		}
+
+		rule := attributesRule{dir, fields[0], make(map[string]string)}
+
+		for _, token := range fields[1:] {
+			a.assign(rule.values, token)
+		}
+
+		a.rules = append(a.rules, rule)
+	}
+
+	sort.SliceStable(a.rules, func(i, j int) bool {
+		return attributesDirDepth(a.rules[i].dir) < attributesDirDepth(a.rules[j].dir)
+	})
+}
+
+// Get attribute value for relative pathname
+// Returns AttributeSet, AttributeUnset, the attribute value or empty string if attribute is unspecified
+func (a Attributes) Get(pathname string, name string) string {
+	pathname = strings.Trim(path.Clean("/"+pathname), "/")
+
+	value := ""
+
+	for _, rule := range a.rules {
+		v, ok := rule.values[name]
+		if !ok {
+			continue
+		}
+
+		if matchAttributesPattern(rule.dir, rule.pattern, pathname) {
+			value = v
+		}
+	}
+
+	return value
+}
+
+// Returns true if attribute is set for relative pathname
+func (a Attributes) IsSet(pathname string, name string) bool {
+	return a.Get(pathname, name) == AttributeSet
+}
+
+// Returns true if attribute is unset for relative pathname
+func (a Attributes) IsUnset(pathname string, name string) bool {
+	return a.Get(pathname, name) == AttributeUnset
+}
+
+// Returns directory depth, root directory has zero depth
+func attributesDirDepth(dir string) int {
+	if dir == "" {
+		return 0
+	}
+
+	return strings.Count(dir, "/") + 1
+}
+
+// Check pathname matches the pattern of attributes file located at dir
+// Pattern without slash matches file name at any depth,
+// otherwise pattern matches pathname relative to dir and supports ** wildcards
+func matchAttributesPattern(dir string, pattern string, pathname string) bool {
+	if dir != "" {
+		if !strings.HasPrefix(pathname, dir+"/") {
+			return false
+		}
+		pathname = strings.TrimPrefix(pathname, dir+"/")
+	}
+
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(pathname))
+		return matched
+	}
+
+	return matchPathSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(pathname, "/"))
+}
+
+// Match path segments by pattern segments, ** segment matches zero or more path segments
+func matchPathSegments(pattern []string, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchPathSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+
+		if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+			return false
+		}
+
+		pattern, segments = pattern[1:], segments[1:]
+	}
+
+	return len(segments) == 0
+}
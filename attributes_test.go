@@ -0,0 +1,82 @@
+package vcsview
+
+import "testing"
+
+func TestAttributes_Get(t *testing.T) {
+	a := Attributes{}
+	a.Add("", "# comment\n*.png binary\n*.txt text eol=lf\n/docs/** -text\n[attr]generated -diff linguist-generated\n*.min.js generated\n")
+	a.Add("testpath", "*.txt -text\nsub/*.dat text\n")
+
+	cases := []struct{
+		pathname string
+		name string
+		value string
+	}{
+		{"image.png", "text", AttributeUnset},
+		{"image.png", "diff", AttributeUnset},
+		{"image.png", "binary", AttributeSet},
+		{"some/deep/image.png", "text", AttributeUnset},
+		{"readme.txt", "text", AttributeSet},
+		{"readme.txt", "eol", "lf"},
+		{"readme.txt", "diff", ""},
+		{"docs/readme.md", "text", AttributeUnset},
+		{"docs/deep/readme.md", "text", AttributeUnset},
+		{"other/docs/readme.md", "text", ""},
+		{"testpath/empty.txt", "text", AttributeUnset},
+		{"testpath/deep/empty.txt", "text", AttributeUnset},
+		{"testpath/sub/data.dat", "text", AttributeSet},
+		{"testpath/data.dat", "text", ""},
+		{"app.min.js", "linguist-generated", AttributeSet},
+		{"app.min.js", "diff", AttributeUnset},
+	}
+
+	for key, testCase := range cases {
+		if value := a.Get(testCase.pathname, testCase.name); value != testCase.value {
+			t.Errorf("[%d] Attributes.Get(%s, %s) = %v, want: %v", key, testCase.pathname, testCase.name, value, testCase.value)
+		}
+	}
+}
+
+func TestAttributes_AddOrder(t *testing.T) {
+	a := Attributes{}
+	a.Add("testpath", "*.txt text\n")
+	a.Add("", "*.txt -text\n*.txt !eol\n")
+
+	if !a.IsSet("testpath/empty.txt", "text") {
+		t.Errorf("Attributes.IsSet(testpath/empty.txt, text) = false, want: true")
+	}
+
+	if !a.IsUnset("empty.txt", "text") {
+		t.Errorf("Attributes.IsUnset(empty.txt, text) = false, want: true")
+	}
+
+	if value := a.Get("empty.txt", "eol"); value != "" {
+		t.Errorf("Attributes.Get(empty.txt, eol) = %v, want empty value", value)
+	}
+}
+
+func TestMatchAttributesPattern(t *testing.T) {
+	cases := []struct{
+		dir string
+		pattern string
+		pathname string
+		matched bool
+	}{
+		{"", "*.go", "main.go", true},
+		{"", "*.go", "cmd/main.go", true},
+		{"", "/*.go", "cmd/main.go", false},
+		{"", "cmd/*.go", "cmd/main.go", true},
+		{"", "**/vendor/**", "vendor/lib/a.go", true},
+		{"", "**/vendor/**", "src/vendor/lib/a.go", true},
+		{"", "**/vendor/**", "src/lib/a.go", false},
+		{"src", "*.go", "src/main.go", true},
+		{"src", "*.go", "main.go", false},
+		{"src", "lib/*.go", "src/lib/a.go", true},
+	}
+
+	for key, testCase := range cases {
+		if matched := matchAttributesPattern(testCase.dir, testCase.pattern, testCase.pathname); matched != testCase.matched {
+			t.Errorf("[%d] matchAttributesPattern(%v) = %v, want: %v", key, testCase, matched, testCase.matched)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package vcsview
+
+// Represents a file content at some revision
+type Blob struct {
+	File
+
+	// Blob object identifier
+	id string
+
+	// File content
+	content []byte
+}
+
+// Get blob object identifier
+func (b Blob) Id() string {
+	return b.id
+}
+
+// Get file content
+func (b Blob) Content() []byte {
+	return b.content
+}
+
+// Create blob for relative pathname and detect its content kind
+func NewBlob(id string, pathname string, content []byte) Blob {
+	b := Blob{
+		File: NewFileFromTree(pathname, false, int64(len(content)), 0),
+		id: id,
+		content: content,
+	}
+	b.detectContent(content)
+
+	return b
+}
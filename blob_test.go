@@ -0,0 +1,59 @@
+package vcsview
+
+import "testing"
+
+func TestBlob_Id(t *testing.T) {
+	expectedId := "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+
+	b := Blob{}
+	b.id = expectedId
+
+	if id := b.Id(); id != expectedId {
+		t.Errorf("Blob.Id() = %v, want: %v", id, expectedId)
+	}
+}
+
+func TestBlob_Content(t *testing.T) {
+	expectedContent := "testing content"
+
+	b := Blob{}
+	b.content = []byte(expectedContent)
+
+	if content := string(b.Content()); content != expectedContent {
+		t.Errorf("Blob.Content() = %v, want: %v", content, expectedContent)
+	}
+}
+
+func TestNewBlob(t *testing.T) {
+	cases := []struct{
+		pathname string
+		content string
+		name string
+		path string
+		kind ContentKind
+	}{
+		{"testpath/empty.txt", "", "empty.txt", "testpath", ContentText},
+		{"README.md", "# readme", "README.md", "", ContentText},
+		{"a/b/data.bin", "\x00\x01\x02", "data.bin", "a/b", ContentBinary},
+	}
+
+	for key, testCase := range cases {
+		b := NewBlob("id", testCase.pathname, []byte(testCase.content))
+
+		if name := b.Name(); name != testCase.name {
+			t.Errorf("[%d] NewBlob(%s).Name() = %v, want: %v", key, testCase.pathname, name, testCase.name)
+		}
+
+		if path := b.Path(); path != testCase.path {
+			t.Errorf("[%d] NewBlob(%s).Path() = %v, want: %v", key, testCase.pathname, path, testCase.path)
+		}
+
+		if size := b.Size(); size != int64(len(testCase.content)) {
+			t.Errorf("[%d] NewBlob(%s).Size() = %v, want: %v", key, testCase.pathname, size, len(testCase.content))
+		}
+
+		if kind := b.ContentKind(); kind != testCase.kind {
+			t.Errorf("[%d] NewBlob(%s).ContentKind() = %v, want: %v", key, testCase.pathname, kind, testCase.kind)
+		}
+	}
+}
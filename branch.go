@@ -1,5 +1,14 @@
 package vcsview
 
+import "encoding/json"
+
+// JSON representation of the branch
+type branchJSON struct {
+	Id string `json:"id"`
+	Head string `json:"head"`
+	IsCurrent bool `json:"is_current"`
+}
+
 // Represents VCS branch model
 type Branch struct {
 	// Branch identifier
@@ -12,6 +21,11 @@ type Branch struct {
 	isCurrent bool
 }
 
+// Create branch model
+func NewBranch(id string, head string, isCurrent bool) Branch {
+	return Branch{id, head, isCurrent}
+}
+
 // Get branch identifier
 func (b Branch) Id() string {
 	return b.id
@@ -27,3 +41,20 @@ func (b Branch) IsCurrent() bool {
 	return b.isCurrent
 }
 
+// Encode branch to JSON object with id, head and is_current keys
+func (b Branch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(branchJSON{b.id, b.head, b.isCurrent})
+}
+
+// Decode branch from JSON object
+func (b *Branch) UnmarshalJSON(data []byte) error {
+	var v branchJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	b.id, b.head, b.isCurrent = v.Id, v.Head, v.IsCurrent
+
+	return nil
+}
@@ -1,6 +1,9 @@
 package vcsview
 
-import "testing"
+import (
+	"encoding/json"
+	"testing"
+)
 
 func TestBranch_Id(t *testing.T) {
 	expectedId := "testing_branch"
@@ -31,4 +34,26 @@ func TestBranch_IsCurrent(t *testing.T) {
 	if !b.IsCurrent() {
 		t.Errorf("Branch.IsCurrent() = false, want: true")
 	}
-}
\ No newline at end of file
+}
+
+func TestBranch_MarshalJSON(t *testing.T) {
+	cases := []struct{
+		branch Branch
+		want string
+	}{
+		{Branch{}, `{"id":"","head":"","is_current":false}`},
+		{Branch{"master", "60a470f", true}, `{"id":"master","head":"60a470f","is_current":true}`},
+	}
+
+	for key, testCase := range cases {
+		data, err := json.Marshal(testCase.branch)
+		if err != nil || string(data) != testCase.want {
+			t.Errorf("[%d] Branch.MarshalJSON() = %s, %v, want: %s", key, data, err, testCase.want)
+		}
+
+		var b Branch
+		if err := json.Unmarshal(data, &b); err != nil || b != testCase.branch {
+			t.Errorf("[%d] Branch.UnmarshalJSON(%s) = %v, %v, want: %v", key, data, b, err, testCase.branch)
+		}
+	}
+}
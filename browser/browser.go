@@ -0,0 +1,458 @@
+// Package browser provides read-only web interface of the repository
+// It serves branches and tags lists, paginated history, commits with diffs, tree and blob views and raw files
+package browser
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kalyabin/vcsview"
+)
+
+const (
+	// Default number of commits per history page
+	defaultPageSize = 50
+
+	// Default revision to browse
+	defaultRevision = "HEAD"
+)
+
+// Read-only web browser of the single repository
+// Routes relative to the handler prefix:
+// /                     - tree of the project root with README
+// /tree/<path>?rev=     - directory tree at revision
+// /blob/<path>?rev=     - file view at revision
+// /raw/<path>?rev=      - file content download
+// /log/<path>?branch=   - paginated history of the project or path (page query param)
+// /commit/<id>          - commit with diffs
+// /branches, /tags      - branches and tags lists
+type Handler struct {
+	// Browsed repository
+	repository vcsview.Repository
+
+	// URL path prefix where handler is mounted (without trailing slash)
+	prefix string
+
+	// Parsed page templates
+	templates map[string]*template.Template
+
+	// Number of commits per history page
+	PageSize int
+
+	// Repository name shown in pages header
+	Name string
+}
+
+// Path part of the page
+type breadcrumb struct {
+	Name string
+	Pathname string
+}
+
+// Common page data
+type page struct {
+	Repository string
+	Title string
+	Revision string
+	Breadcrumbs []breadcrumb
+}
+
+// Create new repository browser
+// Prefix is a URL path prefix where handler is mounted, for example /repos/project
+// Handler strips prefix itself, so it shouldn't be wrapped by http.StripPrefix
+func NewHandler(r vcsview.Repository, prefix string) *Handler {
+	h := &Handler{
+		repository: r,
+		prefix: strings.TrimRight(prefix, "/"),
+		PageSize: defaultPageSize,
+		Name: filepath.Base(r.ProjectPath()),
+	}
+
+	h.templates = parseTemplates(template.FuncMap{
+		"url": h.url,
+		"inc": func(i int) int {
+			return i + 1
+		},
+		"short": func(id string) string {
+			if len(id) > 7 {
+				return id[:7]
+			}
+			return id
+		},
+		"lines": func(s string) []string {
+			return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+		},
+		"diffClass": diffLineClass,
+	})
+
+	return h
+}
+
+// Build URL of the handler page
+// Kind is a page name (tree, blob, raw, log, commit, branches, tags)
+// For log page revision is a branch name
+func (h *Handler) url(kind string, pathname string, revision string) string {
+	u := h.prefix + "/" + kind
+
+	if pathname != "" {
+		u += "/" + (&url.URL{Path: strings.TrimLeft(pathname, "/")}).EscapedPath()
+	}
+
+	switch {
+	case revision == "":
+	case kind == "log":
+		u += "?branch=" + url.QueryEscape(revision)
+	default:
+		u += "?rev=" + url.QueryEscape(revision)
+	}
+
+	return u
+}
+
+// Returns CSS class of the diff line
+func diffLineClass(line string) string {
+	switch {
+	case strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "--- "):
+		return ""
+	case strings.HasPrefix(line, "+"):
+		return "add"
+	case strings.HasPrefix(line, "-"):
+		return "del"
+	case strings.HasPrefix(line, "@@"):
+		return "hunk"
+	}
+
+	return ""
+}
+
+// Returns breadcrumbs of the project path
+func breadcrumbs(pathname string) []breadcrumb {
+	result := make([]breadcrumb, 0)
+
+	if pathname == "" {
+		return result
+	}
+
+	parts := strings.Split(pathname, "/")
+	for key, name := range parts {
+		result = append(result, breadcrumb{name, strings.Join(parts[:key+1], "/")})
+	}
+
+	return result
+}
+
+// Render page template
+func (h *Handler) render(w http.ResponseWriter, name string, data interface{}) {
+	buf := new(bytes.Buffer)
+
+	if err := h.templates[name].ExecuteTemplate(buf, "layout", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// Write error page
+func (h *Handler) fail(w http.ResponseWriter, status int, err error) {
+	http.Error(w, fmt.Sprintf("%s: %v", http.StatusText(status), err), status)
+}
+
+// Returns page status of the repository error
+// Missing repository, revision or path is not found, ambiguous revision is a bad request
+func errorStatus(err error) int {
+	switch vcsview.ErrorKind(err) {
+	case vcsview.ErrRepositoryNotFound, vcsview.ErrRevisionNotFound, vcsview.ErrPathNotFound:
+		return http.StatusNotFound
+	case vcsview.ErrAmbiguousRevision:
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Returns revision query param or default revision
+func revision(req *http.Request) string {
+	if rev := req.URL.Query().Get("rev"); rev != "" {
+		return rev
+	}
+
+	return defaultRevision
+}
+
+// Serve HTTP request
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		h.fail(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", req.Method))
+		return
+	}
+
+	if !strings.HasPrefix(req.URL.Path, h.prefix) {
+		http.NotFound(w, req)
+		return
+	}
+
+	p := strings.TrimPrefix(req.URL.Path, h.prefix)
+	if p != "" && !strings.HasPrefix(p, "/") {
+		http.NotFound(w, req)
+		return
+	}
+
+	route, pathname := p, ""
+	if parts := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2); len(parts) == 2 {
+		route, pathname = "/"+parts[0], strings.Trim(path.Clean("/"+parts[1]), "/")
+	}
+
+	switch route {
+	case "", "/", "/tree":
+		h.tree(w, req, pathname)
+	case "/blob":
+		h.blob(w, req, pathname)
+	case "/raw":
+		h.raw(w, req, pathname)
+	case "/log":
+		h.log(w, req, pathname)
+	case "/commit":
+		h.commit(w, req, pathname)
+	case "/branches":
+		h.branches(w, req)
+	case "/tags":
+		h.tags(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// Serve directory tree with README
+func (h *Handler) tree(w http.ResponseWriter, req *http.Request, pathname string) {
+	rev := revision(req)
+
+	files, err := h.repository.ReadTree(req.Context(), rev, pathname, false)
+	if err != nil {
+		h.fail(w, errorStatus(err), err)
+		return
+	}
+
+	if len(files) == 0 && pathname != "" {
+		h.fail(w, http.StatusNotFound, fmt.Errorf("path %s not found at %s", pathname, rev))
+		return
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].IsDir() != files[j].IsDir() {
+			return files[i].IsDir()
+		}
+		return files[i].Name() < files[j].Name()
+	})
+
+	links := vcsview.LinkBuilderFunc(func(kind vcsview.LinkKind, revision string, pathname string) string {
+		return h.url(string(kind), pathname, revision)
+	})
+
+	data := struct{
+		page
+		Files []vcsview.File
+		Readme template.HTML
+		ReadmeName string
+	}{
+		page: page{h.Name, "/" + pathname, rev, breadcrumbs(pathname)},
+		Files: files,
+	}
+
+	if readme, html, err := h.repository.RenderReadme(req.Context(), rev, pathname, links); err == nil {
+		// rendered README is escaped and sanitized by renderer
+		data.Readme = template.HTML(html)
+		data.ReadmeName = readme.Name()
+	}
+
+	h.render(w, "tree", data)
+}
+
+// Serve file view
+func (h *Handler) blob(w http.ResponseWriter, req *http.Request, pathname string) {
+	rev := revision(req)
+
+	blob, err := h.repository.ReadBlob(req.Context(), rev, pathname)
+	if err != nil {
+		h.fail(w, errorStatus(err), err)
+		return
+	}
+
+	data := struct{
+		page
+		Blob vcsview.Blob
+		Lines []string
+	}{
+		page: page{h.Name, blob.Pathname(), rev, breadcrumbs(pathname)},
+		Blob: blob,
+	}
+
+	if !blob.IsBinary() && len(blob.Content()) > 0 {
+		data.Lines = strings.Split(strings.TrimSuffix(string(blob.Content()), "\n"), "\n")
+	}
+
+	h.render(w, "blob", data)
+}
+
+// Serve raw file content
+// Only images are served with their content type, text files are served as plain text and other ones as attachments
+func (h *Handler) raw(w http.ResponseWriter, req *http.Request, pathname string) {
+	blob, err := h.repository.ReadBlob(req.Context(), revision(req), pathname)
+	if err != nil {
+		h.fail(w, errorStatus(err), err)
+		return
+	}
+
+	header := w.Header()
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+
+	switch {
+	case blob.IsImage():
+		header.Set("Content-Type", blob.MimeType())
+	case blob.IsBinary():
+		header.Set("Content-Type", "application/octet-stream")
+		header.Set("Content-Disposition", "attachment; filename="+strconv.Quote(blob.Name()))
+	default:
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(blob.Content())))
+
+	if req.Method != http.MethodHead {
+		w.Write(blob.Content())
+	}
+}
+
+// Serve paginated history
+func (h *Handler) log(w http.ResponseWriter, req *http.Request, pathname string) {
+	branch := req.URL.Query().Get("branch")
+
+	pageNumber, _ := strconv.Atoi(req.URL.Query().Get("page"))
+	if pageNumber < 1 {
+		pageNumber = 1
+	}
+
+	pageSize := h.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	// read one more commit to know there is the next page
+	commits, err := h.repository.History(req.Context(), pathname, branch, (pageNumber-1)*pageSize, pageSize+1)
+	if err != nil {
+		h.fail(w, errorStatus(err), err)
+		return
+	}
+
+	pageURL := func(number int) string {
+		q := url.Values{}
+		if branch != "" {
+			q.Set("branch", branch)
+		}
+		q.Set("page", strconv.Itoa(number))
+		return h.url("log", pathname, "") + "?" + q.Encode()
+	}
+
+	data := struct{
+		page
+		Path string
+		Commits []vcsview.Commit
+		PrevPage string
+		NextPage string
+	}{
+		page: page{h.Name, "log", branch, nil},
+		Path: pathname,
+		Commits: commits,
+	}
+
+	if data.Title = "Log"; branch != "" {
+		data.Title = "Log of " + branch
+	}
+
+	if len(commits) > pageSize {
+		data.Commits = commits[:pageSize]
+		data.NextPage = pageURL(pageNumber + 1)
+	}
+
+	if pageNumber > 1 {
+		data.PrevPage = pageURL(pageNumber - 1)
+	}
+
+	h.render(w, "log", data)
+}
+
+// Serve commit with diffs
+func (h *Handler) commit(w http.ResponseWriter, req *http.Request, commitId string) {
+	commit, err := h.repository.Commit(req.Context(), commitId)
+	if err != nil {
+		h.fail(w, errorStatus(err), err)
+		return
+	}
+
+	diffs, err := h.repository.Diff(req.Context(), commit.Id())
+	if err != nil {
+		h.fail(w, errorStatus(err), err)
+		return
+	}
+
+	data := struct{
+		page
+		Commit vcsview.Commit
+		Diffs []vcsview.FileDiff
+	}{
+		page: page{h.Name, "Commit " + commit.Id(), commit.Id(), nil},
+		Commit: commit,
+		Diffs: diffs,
+	}
+
+	h.render(w, "commit", data)
+}
+
+// Serve branches list
+func (h *Handler) branches(w http.ResponseWriter, req *http.Request) {
+	branches, err := h.repository.Branches(req.Context())
+	if err != nil {
+		h.fail(w, errorStatus(err), err)
+		return
+	}
+
+	data := struct{
+		page
+		Branches []vcsview.Branch
+	}{
+		page: page{h.Name, "Branches", "", nil},
+		Branches: branches,
+	}
+
+	h.render(w, "branches", data)
+}
+
+// Serve tags list
+func (h *Handler) tags(w http.ResponseWriter, req *http.Request) {
+	tags, err := h.repository.Tags(req.Context())
+	if err != nil {
+		h.fail(w, errorStatus(err), err)
+		return
+	}
+
+	data := struct{
+		page
+		Tags []vcsview.Tag
+	}{
+		page: page{h.Name, "Tags", "", nil},
+		Tags: tags,
+	}
+
+	h.render(w, "tags", data)
+}
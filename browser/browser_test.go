@@ -0,0 +1,165 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kalyabin/vcsview"
+)
+
+const (
+	gitRepositoryPath = "../testdata/git"
+)
+
+func makeHandler(t *testing.T) *Handler {
+	r, err := vcsview.NewRepository(gitRepositoryPath, vcsview.NewGit())
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+
+	return NewHandler(r, "/repo/")
+}
+
+func serve(h http.Handler, method string, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestHandler_Pages(t *testing.T) {
+	h := makeHandler(t)
+
+	cases := []struct{
+		target string
+		status int
+		contains string
+	}{
+		{"/repo", http.StatusOK, "<table>"},
+		{"/repo/", http.StatusOK, "<table>"},
+		{"/repo/tree/testpath", http.StatusOK, "/repo/blob/testpath/empty.txt?rev=HEAD"},
+		{"/repo/tree/testpath?rev=master", http.StatusOK, "/repo/blob/testpath/empty.txt?rev=master"},
+		{"/repo/tree/non-existent-path", http.StatusNotFound, ""},
+		{"/repo/tree/?rev=non-existent-revision", http.StatusNotFound, ""},
+		{"/repo/blob/testpath/empty.txt", http.StatusOK, "/repo/raw/testpath/empty.txt?rev=HEAD"},
+		{"/repo/blob/non-existent.txt", http.StatusNotFound, ""},
+		{"/repo/log", http.StatusOK, "/repo/commit/"},
+		{"/repo/log/testpath?page=1", http.StatusOK, "/repo/commit/"},
+		{"/repo/branches", http.StatusOK, "master"},
+		{"/repo/tags", http.StatusOK, "<table>"},
+		{"/repo/commit/non-existent-commit", http.StatusNotFound, ""},
+		{"/repo/unknown", http.StatusNotFound, ""},
+		{"/other", http.StatusNotFound, ""},
+	}
+
+	for key, testCase := range cases {
+		w := serve(h, http.MethodGet, testCase.target)
+
+		if w.Code != testCase.status {
+			t.Errorf("[%d] GET %s status = %d, want: %d", key, testCase.target, w.Code, testCase.status)
+			continue
+		}
+
+		if body := w.Body.String(); !strings.Contains(body, testCase.contains) {
+			t.Errorf("[%d] GET %s body doesn't contain %s:\n%s", key, testCase.target, testCase.contains, body)
+		}
+	}
+}
+
+func TestHandler_Commit(t *testing.T) {
+	h := makeHandler(t)
+
+	commits, err := h.repository.History(context.Background(), "", "", 0, 1)
+	if err != nil || len(commits) == 0 {
+		t.Fatalf("Repository.History() = %v, %v, want commits", commits, err)
+	}
+
+	target := "/repo/commit/" + commits[0].Id()
+	w := serve(h, http.MethodGet, target)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d, want: %d", target, w.Code, http.StatusOK)
+	}
+
+	if body := w.Body.String(); !strings.Contains(body, commits[0].Id()) {
+		t.Errorf("GET %s body doesn't contain commit identifier", target)
+	}
+}
+
+func TestHandler_Raw(t *testing.T) {
+	h := makeHandler(t)
+
+	w := serve(h, http.MethodGet, "/repo/raw/testpath/empty.txt")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /repo/raw/testpath/empty.txt status = %d, want: %d", w.Code, http.StatusOK)
+	}
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+		t.Errorf("GET /repo/raw/testpath/empty.txt Content-Type = %v, want: text/plain; charset=utf-8", contentType)
+	}
+
+	if nosniff := w.Header().Get("X-Content-Type-Options"); nosniff != "nosniff" {
+		t.Errorf("GET /repo/raw/testpath/empty.txt X-Content-Type-Options = %v, want: nosniff", nosniff)
+	}
+}
+
+func TestHandler_MethodNotAllowed(t *testing.T) {
+	h := makeHandler(t)
+
+	w := serve(h, http.MethodPost, "/repo/")
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /repo/ status = %d, want: %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestDiffLineClass(t *testing.T) {
+	cases := map[string]string{
+		"+++ b/main.go": "",
+		"--- a/main.go": "",
+		"+added": "add",
+		"-deleted": "del",
+		"@@ -1 +1 @@": "hunk",
+		" context": "",
+	}
+
+	for line, class := range cases {
+		if got := diffLineClass(line); got != class {
+			t.Errorf("diffLineClass(%s) = %v, want: %v", line, got, class)
+		}
+	}
+}
+
+func TestBreadcrumbs(t *testing.T) {
+	crumbs := breadcrumbs("a/b/c")
+
+	if len(crumbs) != 3 || crumbs[2].Name != "c" || crumbs[2].Pathname != "a/b/c" || crumbs[0].Pathname != "a" {
+		t.Errorf("breadcrumbs(a/b/c) = %v, want a, a/b, a/b/c", crumbs)
+	}
+
+	if crumbs := breadcrumbs(""); len(crumbs) != 0 {
+		t.Errorf("breadcrumbs() = %v, want empty", crumbs)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	cases := []struct{
+		err error
+		want int
+	}{
+		{vcsview.ErrRevisionNotFound, http.StatusNotFound},
+		{vcsview.ErrPathNotFound, http.StatusNotFound},
+		{vcsview.ErrAmbiguousRevision, http.StatusBadRequest},
+		{fmt.Errorf("Command failed"), http.StatusInternalServerError},
+	}
+
+	for key, testCase := range cases {
+		if status := errorStatus(testCase.err); status != testCase.want {
+			t.Errorf("[%d] errorStatus(%v) = %d, want: %d", key, testCase.err, status, testCase.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package browser
+
+import "html/template"
+
+// Layout of each one page
+const layoutTemplate = `{{define "layout"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} - {{.Repository}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 0 2em; }
+a { color: #0366d6; text-decoration: none; }
+nav a { margin-right: 1em; }
+table { border-collapse: collapse; }
+td, th { padding: 2px 8px; text-align: left; vertical-align: top; }
+pre, code, .code td { font-family: monospace; }
+.code td.line { color: #999; text-align: right; user-select: none; }
+.diff .add { background: #e6ffed; }
+.diff .del { background: #ffeef0; }
+.diff .hunk { color: #999; }
+.muted { color: #999; }
+</style>
+</head>
+<body>
+<h1><a href="{{url "tree" "" ""}}">{{.Repository}}</a></h1>
+<nav>
+<a href="{{url "tree" "" .Revision}}">tree</a>
+<a href="{{url "log" "" ""}}">log</a>
+<a href="{{url "branches" "" ""}}">branches</a>
+<a href="{{url "tags" "" ""}}">tags</a>
+</nav>
+<h2>{{.Title}}</h2>
+{{template "content" .}}
+</body>
+</html>{{end}}`
+
+// Breadcrumbs of the project path
+const breadcrumbsTemplate = `{{define "breadcrumbs"}}<p><a href="{{url "tree" "" .Revision}}">{{.Repository}}</a>{{range .Breadcrumbs}} / <a href="{{url "tree" .Pathname $.Revision}}">{{.Name}}</a>{{end}} <span class="muted">@ {{.Revision}}</span></p>{{end}}`
+
+// Page templates by names
+var pageTemplates = map[string]string{
+	"tree": `{{define "content"}}{{template "breadcrumbs" .}}
+<table>
+{{range .Files}}<tr>
+<td>{{if .IsDir}}<a href="{{url "tree" .Pathname $.Revision}}">{{.Name}}/</a>{{else}}<a href="{{url "blob" .Pathname $.Revision}}">{{.Name}}</a>{{end}}</td>
+<td class="muted">{{if not .IsDir}}{{.Size}}{{end}}</td>
+<td><a href="{{url "log" .Pathname ""}}">log</a></td>
+</tr>{{end}}
+</table>
+{{if .Readme}}<h3>{{.ReadmeName}}</h3>
+<div class="readme">{{.Readme}}</div>{{end}}{{end}}`,
+
+	"blob": `{{define "content"}}{{template "breadcrumbs" .}}
+<p class="muted">{{.Blob.Size}} bytes, {{.Blob.MimeType}} &middot; <a href="{{url "raw" .Blob.Pathname .Revision}}">raw</a> &middot; <a href="{{url "log" .Blob.Pathname ""}}">log</a></p>
+{{if .Blob.IsImage}}<img src="{{url "raw" .Blob.Pathname .Revision}}" alt="{{.Blob.Name}}">
+{{else if .Blob.IsBinary}}<p>Binary file is not shown.</p>
+{{else}}<table class="code">
+{{range $key, $line := .Lines}}<tr><td class="line" id="L{{inc $key}}"><a href="#L{{inc $key}}">{{inc $key}}</a></td><td><pre>{{$line}}</pre></td></tr>
+{{end}}</table>{{end}}{{end}}`,
+
+	"log": `{{define "content"}}{{if .Path}}<p>History of <a href="{{url "tree" .Path ""}}">{{.Path}}</a></p>{{end}}
+<table>
+<tr><th>Date</th><th>Message</th><th>Author</th><th>Commit</th></tr>
+{{range .Commits}}<tr>
+<td class="muted">{{.Date.Format "2006-01-02 15:04"}}</td>
+<td><a href="{{url "commit" .Id ""}}">{{.Message}}</a></td>
+<td>{{.Author.Name}}</td>
+<td><code>{{short .Id}}</code></td>
+</tr>{{end}}
+</table>
+<p>{{if .PrevPage}}<a href="{{.PrevPage}}">&larr; newer</a>{{end}} {{if .NextPage}}<a href="{{.NextPage}}">older &rarr;</a>{{end}}</p>{{end}}`,
+
+	"commit": `{{define "content"}}<table>
+<tr><th>commit</th><td><code>{{.Commit.Id}}</code> (<a href="{{url "tree" "" .Commit.Id}}">tree</a>)</td></tr>
+<tr><th>author</th><td>{{.Commit.Author}}</td></tr>
+<tr><th>date</th><td>{{.Commit.Date}}</td></tr>
+{{range .Commit.Parents}}{{if .}}<tr><th>parent</th><td><a href="{{url "commit" . ""}}"><code>{{.}}</code></a></td></tr>{{end}}{{end}}
+</table>
+<p>{{.Commit.Message}}</p>
+{{range .Diffs}}<h3>{{.Status}} {{if .PreviousPathname}}{{.PreviousPathname}} &rarr; {{end}}{{.Pathname}}</h3>
+{{if .IsBinary}}<p class="muted">Binary file</p>{{else}}<table class="code diff">
+{{range lines .Diff}}<tr class="{{diffClass .}}"><td><pre>{{.}}</pre></td></tr>
+{{end}}</table>{{end}}{{end}}{{end}}`,
+
+	"branches": `{{define "content"}}<table>
+<tr><th>Branch</th><th>Head</th></tr>
+{{range .Branches}}<tr>
+<td><a href="{{url "log" "" .Id}}">{{.Id}}</a>{{if .IsCurrent}} <span class="muted">(current)</span>{{end}}</td>
+<td><a href="{{url "commit" .Head ""}}"><code>{{.Head}}</code></a></td>
+</tr>{{end}}
+</table>{{end}}`,
+
+	"tags": `{{define "content"}}<table>
+<tr><th>Tag</th><th>Date</th><th>Message</th><th>Commit</th></tr>
+{{range .Tags}}<tr>
+<td><a href="{{url "tree" "" .Id}}">{{.Id}}</a></td>
+<td class="muted">{{.Date.Format "2006-01-02"}}</td>
+<td>{{.Message}}</td>
+<td><a href="{{url "commit" .Head ""}}"><code>{{short .Head}}</code></a></td>
+</tr>{{end}}
+</table>{{end}}`,
+}
+
+// Parse page templates with layout
+// Returns templates by page names
+func parseTemplates(funcs template.FuncMap) map[string]*template.Template {
+	result := make(map[string]*template.Template)
+
+	for name, content := range pageTemplates {
+		t := template.New(name).Funcs(funcs)
+		template.Must(t.Parse(layoutTemplate))
+		template.Must(t.Parse(breadcrumbsTemplate))
+		template.Must(t.Parse(content))
+		result[name] = t
+	}
+
+	return result
+}
@@ -0,0 +1,342 @@
+package vcsview
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// Default maximum total size of the cached values in bytes
+const defaultCacheMaxBytes = 64 << 20
+
+// Estimated size of the cached value besides its contents (identifiers, names, dates, etc.)
+const cacheValueOverhead = 256
+
+// Full object identifier (sha1 or sha256), short identifiers and refs could point to different objects later
+var fullObjectIdPattern = regexp.MustCompile(`^(?:[0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// Cache usage statistics
+type CacheStats struct {
+	// Number of requests served from cache
+	hits uint64
+
+	// Number of requests passed to VCS
+	misses uint64
+
+	// Number of entries removed to free space
+	evictions uint64
+
+	// Number of entries in the cache
+	size int
+
+	// Estimated total size of the cached values in bytes
+	bytes int64
+}
+
+// Get number of requests served from cache
+func (s CacheStats) Hits() uint64 {
+	return s.hits
+}
+
+// Get number of requests passed to VCS
+func (s CacheStats) Misses() uint64 {
+	return s.misses
+}
+
+// Get number of entries removed to free space
+func (s CacheStats) Evictions() uint64 {
+	return s.evictions
+}
+
+// Get number of entries in the cache
+func (s CacheStats) Size() int {
+	return s.size
+}
+
+// Get estimated total size of the cached values in bytes
+func (s CacheStats) Bytes() int64 {
+	return s.bytes
+}
+
+// Cache entry of the LRU list
+type lruEntry struct {
+	key string
+	value interface{}
+
+	// Estimated size of the value in bytes
+	size int64
+}
+
+// Least recently used cache bounded by number of entries and total size of the values
+// Safe for concurrent use
+type lruCache struct {
+	mu sync.Mutex
+
+	// Maximum number of entries
+	capacity int
+
+	// Maximum total size of the values in bytes
+	maxBytes int64
+
+	// Total size of the values in bytes
+	bytes int64
+
+	// Entries from the most to the least recently used
+	entries *list.List
+
+	// List elements by keys
+	items map[string]*list.Element
+
+	stats CacheStats
+}
+
+// Create cache with maximum number of entries and maximum total size of the values
+func newLRUCache(capacity int, maxBytes int64) *lruCache {
+	return &lruCache{
+		capacity: capacity,
+		maxBytes: maxBytes,
+		entries: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Get value by key and count hit or miss
+func (c *lruCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.stats.misses++
+		return nil, false
+	}
+
+	c.stats.hits++
+	c.entries.MoveToFront(el)
+
+	return el.Value.(*lruEntry).value, true
+}
+
+// Put value of the estimated size by key and evict the least recently used entries over capacity or maximum size
+// Value larger than maximum size isn't cached at all
+func (c *lruCache) put(key string, value interface{}, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+
+	if size > c.maxBytes {
+		return
+	}
+
+	c.items[key] = c.entries.PushFront(&lruEntry{key, value, size})
+	c.bytes += size
+
+	for c.entries.Len() > c.capacity || c.bytes > c.maxBytes {
+		c.remove(c.entries.Back())
+		c.stats.evictions++
+	}
+}
+
+// Remove entry from the cache, the cache should be locked
+func (c *lruCache) remove(el *list.Element) {
+	entry := el.Value.(*lruEntry)
+
+	c.entries.Remove(el)
+	delete(c.items, entry.key)
+	c.bytes -= entry.size
+}
+
+// Get cache statistics
+func (c *lruCache) statistics() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.size = c.entries.Len()
+	stats.bytes = c.bytes
+
+	return stats
+}
+
+// VCS decorator which memoises commits, trees and blobs addressed by full commit identifiers
+// Lookups by branch names, tags, HEAD or short identifiers are never cached, because they could point to other objects later
+// Cached values are shared between readers and shouldn't be modified (for example, blob content)
+type CachedVcs struct {
+	Vcs
+
+	// Cached results by object keys
+	cache *lruCache
+
+	// Debug function which fixes the log messages
+	Debugger DebugFunc
+
+	// Receiver of the execution events (nil if disabled)
+	Hooks Hooks
+}
+
+// Create VCS decorator with LRU cache of maximum size entries
+// Total size of the cached values is limited by 64 MB
+func NewCachedVcs(vcs Vcs, size int) *CachedVcs {
+	return NewCachedVcsWithLimits(vcs, size, defaultCacheMaxBytes)
+}
+
+// Create VCS decorator with LRU cache of maximum size entries and maximum total size of the values in bytes
+// Blobs larger than maximum size are read from VCS every time
+func NewCachedVcsWithLimits(vcs Vcs, size int, maxBytes int64) *CachedVcs {
+	if size < 1 {
+		size = 1
+	}
+
+	return &CachedVcs{Vcs: vcs, cache: newLRUCache(size, maxBytes)}
+}
+
+// Get cache hit and miss statistics
+func (c *CachedVcs) Stats() CacheStats {
+	return c.cache.statistics()
+}
+
+// Create executor of the cache lookup function
+func (c *CachedVcs) funcExecutor(ctx context.Context, operation string, projectPath string, name string, run func() error) *Executor {
+	return NewFuncExecutor(ctx, name, run, c.Debugger).observe(c.Hooks, operation, projectPath)
+}
+
+// Fetch commit by identifier from cache or VCS
+// Only commits addressed by full identifier are cached
+func (c *CachedVcs) ReadCommit(ctx context.Context, projectPath string, commitId string, result chan Commit) *Executor {
+	if !fullObjectIdPattern.MatchString(commitId) {
+		return c.Vcs.ReadCommit(ctx, projectPath, commitId, result)
+	}
+
+	key := fmt.Sprintf("commit\x00%s\x00%s", projectPath, commitId)
+
+	return c.funcExecutor(ctx, "cached commit", projectPath, "cached commit "+commitId, func() error {
+		if v, ok := c.cache.get(key); ok {
+			result <- v.(Commit)
+			return nil
+		}
+
+		commits := make(chan Commit)
+		done := make(chan interface{})
+		found := make([]Commit, 0, 1)
+
+		go func() {
+			for commit := range commits {
+				found = append(found, commit)
+				result <- commit
+			}
+			close(done)
+		}()
+
+		err := c.Vcs.ReadCommit(ctx, projectPath, commitId, commits).Run()
+
+		close(commits)
+		<-done
+
+		if err == nil && len(found) == 1 {
+			c.cache.put(key, found[0], cacheValueOverhead+int64(len(found[0].Message())))
+		}
+
+		return err
+	})
+}
+
+// Fetch file content at revision from cache or VCS
+// Only contents at full commit identifier are cached
+func (c *CachedVcs) ReadBlob(ctx context.Context, projectPath string, revision string, pathname string, result chan Blob) *Executor {
+	if !fullObjectIdPattern.MatchString(revision) {
+		return c.Vcs.ReadBlob(ctx, projectPath, revision, pathname, result)
+	}
+
+	key := fmt.Sprintf("blob\x00%s\x00%s\x00%s", projectPath, revision, pathname)
+
+	return c.funcExecutor(ctx, "cached blob", projectPath, "cached blob "+revision+":"+pathname, func() error {
+		if v, ok := c.cache.get(key); ok {
+			result <- v.(Blob)
+			return nil
+		}
+
+		blobs := make(chan Blob)
+		done := make(chan interface{})
+		found := make([]Blob, 0, 1)
+
+		go func() {
+			for blob := range blobs {
+				found = append(found, blob)
+				result <- blob
+			}
+			close(done)
+		}()
+
+		err := c.Vcs.ReadBlob(ctx, projectPath, revision, pathname, blobs).Run()
+
+		close(blobs)
+		<-done
+
+		if err == nil && len(found) == 1 {
+			c.cache.put(key, found[0], cacheValueOverhead+int64(len(found[0].Content())))
+		}
+
+		return err
+	})
+}
+
+// Fetch the first bytes of the file from VCS, they aren't cached
+// VCS which can't read the first bytes only sends the whole content
+func (c *CachedVcs) readBlobHead(ctx context.Context, projectPath string, revision string, pathname string, size int64, result chan Blob) *Executor {
+	if reader, ok := c.Vcs.(blobHeadReader); ok {
+		return reader.readBlobHead(ctx, projectPath, revision, pathname, size, result)
+	}
+
+	return c.ReadBlob(ctx, projectPath, revision, pathname, result)
+}
+
+// Fetch files tree at revision from cache or VCS
+// Only trees at full commit identifier are cached
+func (c *CachedVcs) ReadTree(ctx context.Context, projectPath string, revision string, path string, recursive bool, result chan File) *Executor {
+	if !fullObjectIdPattern.MatchString(revision) {
+		return c.Vcs.ReadTree(ctx, projectPath, revision, path, recursive, result)
+	}
+
+	key := fmt.Sprintf("tree\x00%s\x00%s\x00%s\x00%v", projectPath, revision, path, recursive)
+
+	return c.funcExecutor(ctx, "cached tree", projectPath, "cached tree "+revision+":"+path, func() error {
+		if v, ok := c.cache.get(key); ok {
+			for _, f := range v.([]File) {
+				result <- f
+			}
+			return nil
+		}
+
+		files := make(chan File)
+		done := make(chan interface{})
+		found := make([]File, 0)
+
+		go func() {
+			for f := range files {
+				found = append(found, f)
+				result <- f
+			}
+			close(done)
+		}()
+
+		err := c.Vcs.ReadTree(ctx, projectPath, revision, path, recursive, files).Run()
+
+		close(files)
+		<-done
+
+		if err == nil {
+			size := int64(0)
+			for _, f := range found {
+				size += cacheValueOverhead + int64(len(f.Pathname()))
+			}
+			c.cache.put(key, found, size)
+		}
+
+		return err
+	})
+}
@@ -0,0 +1,173 @@
+package vcsview
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCacheStats(t *testing.T) {
+	s := CacheStats{1, 2, 3, 4, 5}
+
+	if s.Hits() != 1 || s.Misses() != 2 || s.Evictions() != 3 || s.Size() != 4 || s.Bytes() != 5 {
+		t.Errorf("CacheStats = %v, want: {1 2 3 4 5}", s)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(2, 100)
+
+	c.put("a", 1, 10)
+	c.put("b", 2, 10)
+
+	// a becomes the most recently used
+	if v, ok := c.get("a"); !ok || v != 1 {
+		t.Errorf("lruCache.get(a) = %v, %v, want: 1, true", v, ok)
+	}
+
+	c.put("c", 3, 10)
+
+	if _, ok := c.get("b"); ok {
+		t.Errorf("lruCache.get(b) found evicted entry")
+	}
+
+	cases := map[string]interface{}{
+		"a": 1,
+		"c": 3,
+	}
+
+	for key, want := range cases {
+		if v, ok := c.get(key); !ok || v != want {
+			t.Errorf("lruCache.get(%s) = %v, %v, want: %v, true", key, v, ok, want)
+		}
+	}
+
+	stats := c.statistics()
+	if stats.Hits() != 3 || stats.Misses() != 1 || stats.Evictions() != 1 || stats.Size() != 2 || stats.Bytes() != 20 {
+		t.Errorf("lruCache.statistics() = %v, want: {3 1 1 2 20}", stats)
+	}
+}
+
+func TestLRUCache_MaxBytes(t *testing.T) {
+	c := newLRUCache(10, 100)
+
+	c.put("a", 1, 40)
+	c.put("b", 2, 40)
+
+	// value larger than maximum size isn't cached and doesn't evict others
+	c.put("large", 3, 101)
+
+	// a is evicted to free space for c
+	c.put("c", 4, 40)
+
+	// replaced value frees its previous size
+	c.put("b", 5, 10)
+
+	cases := []struct{
+		key string
+		want interface{}
+		found bool
+	}{
+		{"a", nil, false},
+		{"large", nil, false},
+		{"b", 5, true},
+		{"c", 4, true},
+	}
+
+	for key, testCase := range cases {
+		if v, ok := c.get(testCase.key); v != testCase.want || ok != testCase.found {
+			t.Errorf("[%d] lruCache.get(%s) = %v, %v, want: %v, %v", key, testCase.key, v, ok, testCase.want, testCase.found)
+		}
+	}
+
+	if stats := c.statistics(); stats.Size() != 2 || stats.Bytes() != 50 || stats.Evictions() != 1 {
+		t.Errorf("lruCache.statistics() = %v, want 2 entries of 50 bytes and 1 eviction", stats)
+	}
+}
+
+func TestCachedVcs(t *testing.T) {
+	vcs := NewCachedVcs(MakeGitMock(t), 10)
+
+	r, err := NewRepository(gitRepositoryPath, vcs)
+	if err != nil {
+		t.Fatalf("NewRepository(%s, ...) got error: %v", gitRepositoryPath, err)
+	}
+
+	commits, err := r.History(context.Background(), "", "", 0, 1)
+	if err != nil || len(commits) != 1 {
+		t.Fatalf("Repository.History() = %v, %v, want one commit", commits, err)
+	}
+	commitId := commits[0].Id()
+
+	// mutable lookups aren't cached
+	for i := 0; i < 2; i++ {
+		if _, err := r.Commit(context.Background(), "HEAD"); err != nil {
+			t.Fatalf("Repository.Commit(HEAD) got error: %v", err)
+		}
+		if _, err := r.ReadTree(context.Background(), "HEAD", "", false); err != nil {
+			t.Fatalf("Repository.ReadTree(HEAD) got error: %v", err)
+		}
+	}
+
+	if stats := vcs.Stats(); stats.Hits() != 0 || stats.Misses() != 0 {
+		t.Errorf("CachedVcs.Stats() after HEAD lookups = %v, want no hits and misses", stats)
+	}
+
+	for i := 0; i < 2; i++ {
+		commit, err := r.Commit(context.Background(), commitId)
+		if err != nil || commit.Id() != commitId {
+			t.Errorf("[%d] Repository.Commit(%s) = %v, %v, want the same commit", i, commitId, commit, err)
+		}
+
+		files, err := r.ReadTree(context.Background(), commitId, "testpath", false)
+		if err != nil || len(files) == 0 {
+			t.Errorf("[%d] Repository.ReadTree(%s, testpath) = %v, %v, want files", i, commitId, files, err)
+		}
+
+		blob, err := r.readBlob(context.Background(), commitId, "testpath/empty.txt")
+		if err != nil || blob.Pathname() != "testpath/empty.txt" {
+			t.Errorf("[%d] Repository.readBlob(%s, testpath/empty.txt) = %v, %v, want the blob", i, commitId, blob, err)
+		}
+	}
+
+	if stats := vcs.Stats(); stats.Hits() != 3 || stats.Misses() != 3 || stats.Size() != 3 {
+		t.Errorf("CachedVcs.Stats() = %v, want: {3 3 0 3}", stats)
+	}
+
+	// not found objects aren't cached
+	for i := 0; i < 2; i++ {
+		if _, err := r.readBlob(context.Background(), commitId, "non-existent.txt"); err == nil {
+			t.Errorf("[%d] Repository.readBlob(%s, non-existent.txt) = nil, want error", i, commitId)
+		}
+	}
+
+	if stats := vcs.Stats(); stats.Misses() != 5 || stats.Size() != 3 {
+		t.Errorf("CachedVcs.Stats() after not found blob = %v, want: {3 5 0 3}", stats)
+	}
+}
+
+func TestCachedVcs_MaxBytes(t *testing.T) {
+	vcs := NewCachedVcsWithLimits(MakeGitMock(t), 10, cacheValueOverhead+10)
+
+	r, err := NewRepository(gitRepositoryPath, vcs)
+	if err != nil {
+		t.Fatalf("NewRepository(%s, ...) got error: %v", gitRepositoryPath, err)
+	}
+
+	commit, err := r.Commit(context.Background(), "HEAD")
+	if err != nil {
+		t.Fatalf("Repository.Commit(HEAD) got error: %v", err)
+	}
+
+	// only empty blob fits into the cache
+	for i := 0; i < 2; i++ {
+		for _, pathname := range []string{"main.go", "testpath/empty.txt"} {
+			if _, err := r.readBlob(context.Background(), commit.Id(), pathname); err != nil {
+				t.Errorf("[%d] Repository.readBlob(%s, %s) got error: %v", i, commit.Id(), pathname, err)
+			}
+		}
+	}
+
+	if stats := vcs.Stats(); stats.Hits() != 1 || stats.Misses() != 3 || stats.Size() != 1 || stats.Bytes() != cacheValueOverhead {
+		t.Errorf("CachedVcs.Stats() = %v, want: {1 3 0 1 %d}", stats, cacheValueOverhead)
+	}
+}
@@ -0,0 +1,512 @@
+package vcsview
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// cat-file modes: object headers with contents or headers only
+	catFileBatch = "--batch"
+	catFileBatchCheck = "--batch-check"
+
+	// Maximum number of cat-file processes per repository and mode
+	catFileMaxProcesses = 4
+
+	// Idle cat-file processes are stopped after this timeout
+	catFileIdleTimeout = time.Minute
+
+	// Kind of the not found object which name is ambiguous short identifier
+	catFileAmbiguous = "ambiguous"
+)
+
+// Object read by cat-file
+type catFileObject struct {
+	id string
+	kind string
+	size int64
+
+	// Object content (empty for --batch-check mode)
+	data []byte
+}
+
+// Process I/O error, the process should be restarted
+type catFileError struct {
+	err error
+}
+
+func (e *catFileError) Error() string {
+	return fmt.Sprintf("cat-file process failed: %v", e.err)
+}
+
+// Persistent cat-file process which reads object names line by line
+type catFileProcess struct {
+	cmd *exec.Cmd
+	stdin io.WriteCloser
+	stdout *bufio.Reader
+
+	// Captured stderr to detect why the process crashed
+	stderr *stderrBuffer
+
+	// Mode: catFileBatch or catFileBatchCheck
+	mode string
+
+	// Pool key of the process: repository path, mode and command settings
+	key string
+
+	// Time when the process was released to the pool last time
+	lastUsed time.Time
+
+	// Frees the scheduler slot of the pooled process when it stops
+	release func()
+}
+
+// Start cat-file process in the project path
+// Context cancellation kills the process, pooled processes should use background context
+func startCatFile(ctx context.Context, c Cli, projectPath string, mode string) (*catFileProcess, error) {
+	cmd := c.command(ctx, projectPath, "--no-pager", "cat-file", mode)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stderr := &stderrBuffer{size: maxStderrSize}
+	cmd.Stderr = stderr
+
+	if c.Debugger != nil {
+		c.Debugger(fmt.Sprintf("start process: %s", strings.Join(cmd.Args, " ")))
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, commandError(cmd, err, "", gitErrorKind)
+	}
+
+	return &catFileProcess{cmd, stdin, bufio.NewReader(stdout), stderr, mode, projectPath + "\x00" + mode + "\x00" + c.settingsKey(), time.Now(), nil}, nil
+}
+
+// Stop the process and free its scheduler slot
+func (p *catFileProcess) stop() {
+	p.stdin.Close()
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+
+	if p.release != nil {
+		p.release()
+		p.release = nil
+	}
+}
+
+// Create error of the crashed process with its stderr
+// The process should be stopped before
+func (p *catFileProcess) fail(err error) error {
+	return commandError(p.cmd, err, p.stderr.String(), gitErrorKind)
+}
+
+// Look up objects by names like HEAD^{commit}, HEAD:README.md or object identifiers
+// Handle function gets the object or false if object not found, not found object has catFileAmbiguous kind if its name is ambiguous
+// All responses are read even if handle function fails, so the process could be reused
+func (p *catFileProcess) lookup(names []string, handle func(i int, o catFileObject, found bool) error) error {
+	return p.lookupLimit(names, -1, handle)
+}
+
+// Look up objects like lookup does, but keep only the first limit bytes of the contents
+// The rest of the contents is skipped without loading to memory, negative limit means whole contents
+func (p *catFileProcess) lookupLimit(names []string, limit int64, handle func(i int, o catFileObject, found bool) error) error {
+	written := make(chan error, 1)
+
+	go func() {
+		w := bufio.NewWriter(p.stdin)
+		for _, name := range names {
+			// names with line breaks can't be sent, empty line gets missing response
+			if strings.ContainsAny(name, "\r\n") {
+				name = ""
+			}
+			w.WriteString(name + "\n")
+		}
+		written <- w.Flush()
+	}()
+
+	var handleErr error
+
+	for i := range names {
+		header, err := p.stdout.ReadString('\n')
+		if err != nil {
+			return &catFileError{err}
+		}
+
+		// object goes by such header: <id> <type> <size>, or <name> missing, or <name> ambiguous
+		header = strings.TrimSuffix(header, "\n")
+		if strings.HasSuffix(header, " missing") || strings.HasSuffix(header, " ambiguous") {
+			o := catFileObject{}
+			if strings.HasSuffix(header, " ambiguous") {
+				o.kind = catFileAmbiguous
+			}
+
+			if handleErr == nil {
+				handleErr = handle(i, o, false)
+			}
+			continue
+		}
+
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return &catFileError{fmt.Errorf("Unexpected header %q", header)}
+		}
+
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return &catFileError{err}
+		}
+
+		o := catFileObject{fields[0], fields[1], size, nil}
+
+		if p.mode == catFileBatch {
+			n := size
+			if limit >= 0 && limit < n {
+				n = limit
+			}
+
+			o.data = make([]byte, n)
+			if _, err := io.ReadFull(p.stdout, o.data); err != nil {
+				return &catFileError{err}
+			}
+
+			// content is followed by line break
+			if _, err := io.CopyN(ioutil.Discard, p.stdout, size-n+1); err != nil {
+				return &catFileError{err}
+			}
+		}
+
+		if handleErr == nil {
+			handleErr = handle(i, o, true)
+		}
+	}
+
+	if err := <-written; err != nil {
+		return &catFileError{err}
+	}
+
+	return handleErr
+}
+
+// Look up one object by name
+func (p *catFileProcess) object(name string) (catFileObject, bool, error) {
+	var (
+		result catFileObject
+		isFound bool
+	)
+
+	err := p.lookup([]string{name}, func(i int, o catFileObject, found bool) error {
+		result, isFound = o, found
+		return nil
+	})
+
+	return result, isFound, err
+}
+
+// Look up the revision object peeled to the kind (commit or tree)
+// Returns ErrRevisionNotFound or ErrAmbiguousRevision error if the revision isn't resolved
+func (p *catFileProcess) revision(revision string, kind string) (catFileObject, error) {
+	var (
+		result catFileObject
+		isFound bool
+		isAmbiguous bool
+	)
+
+	// peeled name reports ambiguous short identifier as missing, so the name is looked up as is too
+	err := p.lookup([]string{revision + "^{" + kind + "}", revision}, func(i int, o catFileObject, found bool) error {
+		if i == 0 {
+			result, isFound = o, found
+		} else {
+			isAmbiguous = o.kind == catFileAmbiguous
+		}
+		return nil
+	})
+
+	switch {
+	case err != nil:
+		return result, err
+	case isFound:
+		return result, nil
+	case isAmbiguous:
+		return result, newVcsError(ErrAmbiguousRevision, "Short object identifier %s is ambiguous", revision)
+	}
+
+	return result, newVcsError(ErrRevisionNotFound, "Revision %s not found", revision)
+}
+
+// Pool of persistent cat-file processes
+// Each one repository and mode gets up to size processes, callers wait for released ones
+// Idle processes are stopped after idleTimeout
+type catFilePool struct {
+	size int
+	idleTimeout time.Duration
+
+	mu sync.Mutex
+
+	// Processes by repository path and mode
+	groups map[string]*catFileGroup
+
+	// Idle processes cleaner is running
+	isCleaning bool
+}
+
+// Processes of the one repository and mode
+type catFileGroup struct {
+	idle []*catFileProcess
+
+	// Semaphore of the busy processes, its capacity is the pool size
+	slots chan struct{}
+
+	// Number of idle and busy processes and callers waiting for them, the group is removed without them
+	refs int
+}
+
+// Create pool of cat-file processes
+func newCatFilePool(size int, idleTimeout time.Duration) *catFilePool {
+	return &catFilePool{size: size, idleTimeout: idleTimeout, groups: make(map[string]*catFileGroup)}
+}
+
+// Remove reference of the group and the group itself if it has no references, the pool should be locked
+func (p *catFilePool) unref(key string, g *catFileGroup) {
+	if g.refs--; g.refs == 0 {
+		delete(p.groups, key)
+	}
+}
+
+// Get idle process or start new one
+// Waits for released process if the repository has maximum number of busy processes
+// New process takes the scheduler slot of the Cli until the pool stops it
+// Returns the context error if the context is done while waiting
+func (p *catFilePool) acquire(ctx context.Context, c Cli, projectPath string, mode string) (*catFileProcess, error) {
+	key := projectPath + "\x00" + mode + "\x00" + c.settingsKey()
+
+	p.mu.Lock()
+	g, ok := p.groups[key]
+	if !ok {
+		g = &catFileGroup{slots: make(chan struct{}, p.size)}
+		p.groups[key] = g
+	}
+	g.refs++
+	p.mu.Unlock()
+
+	select {
+	case g.slots <- struct{}{}:
+	case <-ctx.Done():
+		p.mu.Lock()
+		p.unref(key, g)
+		p.mu.Unlock()
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	if len(g.idle) > 0 {
+		proc := g.idle[len(g.idle)-1]
+		g.idle = g.idle[:len(g.idle)-1]
+		// the process takes reference of the caller
+		g.refs--
+		p.mu.Unlock()
+		return proc, nil
+	}
+	p.mu.Unlock()
+
+	release, err := p.schedule(ctx, c.Scheduler, projectPath)
+
+	var proc *catFileProcess
+	if err == nil {
+		if proc, err = startCatFile(context.Background(), c, projectPath, mode); err != nil {
+			release()
+		} else {
+			proc.release = release
+		}
+	}
+
+	if err != nil {
+		p.mu.Lock()
+		p.unref(key, g)
+		p.mu.Unlock()
+		<-g.slots
+	}
+
+	return proc, err
+}
+
+// Get the scheduler slot for the new process of the repository
+// If the scheduler has no free slots, idle processes of the repository are stopped first, so they don't keep the slots
+func (p *catFilePool) schedule(ctx context.Context, s *Scheduler, projectPath string) (func(), error) {
+	if release, ok := s.tryAcquire(projectPath); ok {
+		return release, nil
+	}
+
+	p.evict(projectPath)
+
+	release, _, err := s.acquire(ctx, projectPath)
+
+	return release, err
+}
+
+// Stop all idle processes of the repository, so they don't keep the scheduler slots
+func (p *catFilePool) evict(projectPath string) {
+	p.stopIdle(time.Now().Add(time.Hour), projectPath)
+}
+
+// Return process to the pool
+// Broken process is stopped before return, so the next caller starts new one
+func (p *catFilePool) release(proc *catFileProcess, isBroken bool) {
+	p.mu.Lock()
+
+	g := p.groups[proc.key]
+	if g == nil {
+		p.mu.Unlock()
+		proc.stop()
+		return
+	}
+
+	if isBroken {
+		p.unref(proc.key, g)
+	} else {
+		proc.lastUsed = time.Now()
+		g.idle = append(g.idle, proc)
+
+		if !p.isCleaning {
+			p.isCleaning = true
+			go p.clean()
+		}
+	}
+
+	p.mu.Unlock()
+
+	if isBroken {
+		proc.stop()
+	}
+
+	<-g.slots
+}
+
+// Stop expired idle processes until the pool has no processes
+func (p *catFilePool) clean() {
+	for {
+		time.Sleep(p.idleTimeout / 2)
+
+		if !p.stopIdle(time.Now().Add(-p.idleTimeout), "") {
+			return
+		}
+	}
+}
+
+// Stop idle processes released before the time
+// Not empty project path stops only processes of the repository
+// Returns false and marks cleaner stopped if the pool has no processes
+func (p *catFilePool) stopIdle(before time.Time, projectPath string) bool {
+	p.mu.Lock()
+
+	stopped := make([]*catFileProcess, 0)
+
+	for key, g := range p.groups {
+		if projectPath != "" && !strings.HasPrefix(key, projectPath+"\x00") {
+			continue
+		}
+
+		idle := g.idle[:0]
+		for _, proc := range g.idle {
+			if proc.lastUsed.Before(before) {
+				stopped = append(stopped, proc)
+				p.unref(key, g)
+			} else {
+				idle = append(idle, proc)
+			}
+		}
+		g.idle = idle
+	}
+
+	running := len(p.groups) > 0
+	if !running {
+		p.isCleaning = false
+	}
+
+	p.mu.Unlock()
+
+	for _, proc := range stopped {
+		proc.stop()
+	}
+
+	return running
+}
+
+// Run function with cat-file process of the repository
+// Crashed process is restarted once, so the function should be safe to repeat
+// Without pool the process is started for the one call, persistent processes are counted by the scheduler until the pool stops them
+// Pooled processes aren't killed by context cancellation, so long functions should check the context itself
+func (g *Git) catFile(ctx context.Context, projectPath string, mode string, run func(p *catFileProcess) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if g.batch == nil {
+		release, _, err := g.Scheduler.acquire(ctx, projectPath)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		proc, err := startCatFile(ctx, g.Cli, projectPath, mode)
+		if err != nil {
+			return err
+		}
+
+		err = run(proc)
+		proc.stop()
+
+		if _, isBroken := err.(*catFileError); isBroken {
+			return proc.fail(err)
+		}
+
+		return err
+	}
+
+	var (
+		proc *catFileProcess
+		err error
+	)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if proc, err = g.batch.acquire(ctx, g.Cli, projectPath, mode); err != nil {
+			return err
+		}
+
+		err = run(proc)
+
+		_, isBroken := err.(*catFileError)
+		g.batch.release(proc, isBroken)
+
+		if !isBroken {
+			return err
+		}
+	}
+
+	return proc.fail(err)
+}
+
+// Stop persistent cat-file processes of all repositories
+// Git could be used after that, processes are started again on demand
+func (g Git) Close() error {
+	if g.batch != nil {
+		g.batch.stopIdle(time.Now().Add(time.Hour), "")
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package vcsview
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCatFileProcess_Lookup(t *testing.T) {
+	g := MakeGitMock(t)
+
+	names := []string{"HEAD^{commit}", "unknown", "HEAD:testpath/empty.txt", "HEAD:testpath", "HEAD\nHEAD"}
+
+	cases := []struct{
+		mode string
+		wantFound []bool
+		wantKinds []string
+		wantData bool
+	}{
+		{catFileBatch, []bool{true, false, true, true, false}, []string{"commit", "", "blob", "tree", ""}, true},
+		{catFileBatchCheck, []bool{true, false, true, true, false}, []string{"commit", "", "blob", "tree", ""}, false},
+	}
+
+	for key, testCase := range cases {
+		p, err := startCatFile(context.Background(), g.Cli, gitRepositoryPath, testCase.mode)
+		if err != nil {
+			t.Fatalf("[%d] startCatFile(%s) got error: %v", key, testCase.mode, err)
+		}
+
+		// the process is reused for the next lookups
+		for attempt := 0; attempt < 2; attempt++ {
+			err := p.lookup(names, func(i int, o catFileObject, found bool) error {
+				if found != testCase.wantFound[i] || o.kind != testCase.wantKinds[i] {
+					t.Errorf("[%d] catFileProcess.lookup(%q) = %v, %v, want: %v, %v", key, names[i], o, found, testCase.wantKinds[i], testCase.wantFound[i])
+				}
+				if found && (o.data != nil) != testCase.wantData {
+					t.Errorf("[%d] catFileProcess.lookup(%q) got data: %v, want: %v", key, names[i], o.data != nil, testCase.wantData)
+				}
+				if found && testCase.wantData && int64(len(o.data)) != o.size {
+					t.Errorf("[%d] catFileProcess.lookup(%q) got %d bytes, want: %d", key, names[i], len(o.data), o.size)
+				}
+				return nil
+			})
+
+			if err != nil {
+				t.Errorf("[%d] catFileProcess.lookup(%q) got error: %v", key, names, err)
+			}
+		}
+
+		p.stop()
+
+		if _, _, err := p.object("HEAD"); err == nil {
+			t.Errorf("[%d] catFileProcess.object() of stopped process got no errors, want error", key)
+		} else if _, ok := err.(*catFileError); !ok {
+			t.Errorf("[%d] catFileProcess.object() of stopped process got error: %v, want catFileError", key, err)
+		}
+	}
+}
+
+func TestCatFileProcess_LookupLimit(t *testing.T) {
+	g := MakeGitMock(t)
+
+	p, err := startCatFile(context.Background(), g.Cli, gitRepositoryPath, catFileBatch)
+	if err != nil {
+		t.Fatalf("startCatFile(%s) got error: %v", catFileBatch, err)
+	}
+	defer p.stop()
+
+	cases := []struct{
+		limit int64
+		want string
+	}{
+		{0, ""},
+		{7, "package"},
+		{-1, "package main\n\nfunc hello() {\n\tprintln(\"v12\")\n}\n"},
+		{1024, "package main\n\nfunc hello() {\n\tprintln(\"v12\")\n}\n"},
+	}
+
+	for key, testCase := range cases {
+		// the rest of the content is skipped, so the next object is read from its header
+		names := []string{"HEAD:main.go", "HEAD^{commit}"}
+		kinds := []string{"blob", "commit"}
+
+		err := p.lookupLimit(names, testCase.limit, func(i int, o catFileObject, found bool) error {
+			if !found || o.kind != kinds[i] {
+				t.Errorf("[%d] catFileProcess.lookupLimit(%q, %d) = %v, %v, want: %v", key, names[i], testCase.limit, o, found, kinds[i])
+			}
+			if i == 0 && string(o.data) != testCase.want {
+				t.Errorf("[%d] catFileProcess.lookupLimit(%q, %d) got data: %q, want: %q", key, names[i], testCase.limit, o.data, testCase.want)
+			}
+			return nil
+		})
+
+		if err != nil {
+			t.Errorf("[%d] catFileProcess.lookupLimit(%q, %d) got error: %v", key, names, testCase.limit, err)
+		}
+	}
+}
+
+func TestCatFileProcess_Revision(t *testing.T) {
+	g := MakeGitMock(t)
+
+	ambiguousPath, prefix, cleanup := makeAmbiguousRepository(t)
+	defer cleanup()
+
+	cases := []struct{
+		repoPath string
+		revision string
+		kind string
+		wantKind string
+		wantErr error
+	}{
+		{gitRepositoryPath, "HEAD", "commit", "commit", nil},
+		{gitRepositoryPath, "HEAD", "tree", "tree", nil},
+		{gitRepositoryPath, "non-existent-revision", "commit", "", ErrRevisionNotFound},
+		{ambiguousPath, prefix, "commit", "", ErrAmbiguousRevision},
+		{ambiguousPath, prefix, "tree", "", ErrAmbiguousRevision},
+	}
+
+	for key, testCase := range cases {
+		p, err := startCatFile(context.Background(), g.Cli, testCase.repoPath, catFileBatch)
+		if err != nil {
+			t.Fatalf("[%d] startCatFile(%s) got error: %v", key, catFileBatch, err)
+		}
+
+		o, err := p.revision(testCase.revision, testCase.kind)
+		if o.kind != testCase.wantKind || ErrorKind(err) != testCase.wantErr {
+			t.Errorf("[%d] catFileProcess.revision(%s, %s) = %v, %v, want: %v, %v", key, testCase.revision, testCase.kind, o, err, testCase.wantKind, testCase.wantErr)
+		}
+
+		p.stop()
+	}
+}
+
+func TestCatFilePool(t *testing.T) {
+	g := MakeGitMock(t)
+	pool := newCatFilePool(1, 100 * time.Millisecond)
+
+	first, err := pool.acquire(context.Background(), g.Cli, gitRepositoryPath, catFileBatch)
+	if err != nil {
+		t.Fatalf("catFilePool.acquire() got error: %v", err)
+	}
+
+	// the repository has maximum number of processes, so the next caller waits
+	acquired := make(chan *catFileProcess)
+	go func() {
+		p, _ := pool.acquire(context.Background(), g.Cli, gitRepositoryPath, catFileBatch)
+		acquired <- p
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("catFilePool.acquire() got process over the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// cancelled caller stops waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 20 * time.Millisecond)
+	if p, err := pool.acquire(ctx, g.Cli, gitRepositoryPath, catFileBatch); p != nil || err != context.DeadlineExceeded {
+		t.Errorf("catFilePool.acquire() with cancelled context = %v, %v, want: %v", p, err, context.DeadlineExceeded)
+	}
+	cancel()
+
+	pool.release(first, false)
+
+	if p := <-acquired; p != first {
+		t.Errorf("catFilePool.acquire() = %v, want released process %v", p, first)
+	}
+
+	// broken process is replaced with the new one
+	pool.release(first, true)
+
+	second, err := pool.acquire(context.Background(), g.Cli, gitRepositoryPath, catFileBatch)
+	if err != nil || second == first {
+		t.Errorf("catFilePool.acquire() after broken process = %v, %v, want new process", second, err)
+	}
+	pool.release(second, false)
+
+	// other mode has own processes
+	check, err := pool.acquire(context.Background(), g.Cli, gitRepositoryPath, catFileBatchCheck)
+	if err != nil || check.mode != catFileBatchCheck {
+		t.Errorf("catFilePool.acquire(%s) = %v, %v, want process", catFileBatchCheck, check, err)
+	}
+	pool.release(check, false)
+
+	// idle processes are stopped
+	time.Sleep(300 * time.Millisecond)
+
+	pool.mu.Lock()
+	groups, isCleaning := len(pool.groups), pool.isCleaning
+	pool.mu.Unlock()
+
+	if groups != 0 || isCleaning {
+		t.Errorf("catFilePool has %d groups and cleaning: %v after idle timeout, want nothing", groups, isCleaning)
+	}
+}
+
+func TestGit_CatFile(t *testing.T) {
+	g := NewGit()
+	g.Debugger = MakeGitMock(t).Debugger
+	defer g.Close()
+
+	head := make(chan Commit, 1)
+	if err := g.ReadCommit(context.Background(), gitRepositoryPath, "HEAD", head).Run(); err != nil {
+		t.Fatalf("Git.ReadCommit(HEAD) got error: %v", err)
+	}
+	want := <-head
+
+	// crashed process is restarted
+	p, err := g.batch.acquire(context.Background(), g.Cli, gitRepositoryPath, catFileBatch)
+	if err != nil {
+		t.Fatalf("catFilePool.acquire() got error: %v", err)
+	}
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+	g.batch.release(p, false)
+
+	for _, revision := range []string{"HEAD", want.Id(), want.Id()[:7]} {
+		result := make(chan Commit, 1)
+		if err := g.ReadCommit(context.Background(), gitRepositoryPath, revision, result).Run(); err != nil {
+			t.Errorf("Git.ReadCommit(%s) got error: %v", revision, err)
+			continue
+		}
+
+		if c := <-result; c.Id() != want.Id() || c.Message() != want.Message() {
+			t.Errorf("Git.ReadCommit(%s) = %v, want: %v", revision, c, want)
+		}
+	}
+
+	if err := g.ReadCommit(context.Background(), noRepositoryPath, "HEAD", make(chan Commit, 1)).Run(); err == nil {
+		t.Errorf("Git.ReadCommit(%s) got no errors, want error", noRepositoryPath)
+	}
+
+	if err := g.Close(); err != nil {
+		t.Errorf("Git.Close() got error: %v", err)
+	}
+
+	if len(g.batch.groups) != 0 {
+		t.Errorf("Git.Close() left %d processes groups, want nothing", len(g.batch.groups))
+	}
+
+	// processes are started again after closing
+	result := make(chan Blob, 1)
+	if err := g.ReadBlob(context.Background(), gitRepositoryPath, "HEAD", "testpath/empty.txt", result).Run(); err != nil || len(result) != 1 {
+		t.Errorf("Git.ReadBlob() after Git.Close() got error: %v, want blob", err)
+	}
+}
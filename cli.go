@@ -1,7 +1,9 @@
 package vcsview
 
 import (
+	"context"
 	"os/exec"
+	"strings"
 )
 
 // Common command line interface for each one VCS
@@ -11,17 +13,77 @@ type Cli struct {
 
 	// Debug function which fixes the log messages
 	Debugger DebugFunc
+
+	// Limiter of concurrent processes, could be shared by several Cli (nil means no limits)
+	Scheduler *Scheduler
+
+	// Receiver of the commands execution events (nil if disabled)
+	Hooks Hooks
+
+	// Environment and common arguments of the commands (nil means the server environment without arguments)
+	settings *cliSettings
+}
+
+// Process settings of the commands
+// Settings aren't changed after creation, so Cli copies could share them
+type cliSettings struct {
+	// Environment as KEY=value list (nil means the server environment)
+	env []string
+
+	// Arguments before the command params (like -c key=value for git)
+	args []string
+}
+
+// Returns copy of the settings with additional arguments
+func (s *cliSettings) withArgs(args ...string) *cliSettings {
+	result := &cliSettings{}
+	if s != nil {
+		result.env = s.env
+		result.args = append(result.args, s.args...)
+	}
+	result.args = append(result.args, args...)
+
+	return result
 }
 
 // Create a command to execute in specified path with command line params
-func (c Cli) command(dir string, params ...string) *exec.Cmd {
-	cmd := exec.Command(c.cmd, params...)
+// Context cancellation kills the command with its children
+func (c Cli) command(ctx context.Context, dir string, params ...string) *exec.Cmd {
+	if c.settings != nil && len(c.settings.args) > 0 {
+		params = append(append([]string{}, c.settings.args...), params...)
+	}
+
+	cmd := exec.CommandContext(ctx, c.cmd, params...)
 	cmd.Dir = dir
+	setProcessGroup(cmd)
+
+	if c.settings != nil && c.settings.env != nil {
+		cmd.Env = c.settings.env
+	}
 
 	return cmd
 }
 
+// Returns key of the commands settings: commands with the same key run the same way
+func (c Cli) settingsKey() string {
+	if c.settings == nil {
+		return c.cmd
+	}
+
+	return c.cmd + "\x00" + strings.Join(c.settings.args, "\x00")
+}
+
+// Create executor which runs in-process function with the debugger and hooks
+// Operation and path describe the function in the execution events
+func (c Cli) funcExecutor(ctx context.Context, operation string, path string, name string, run func() error) *Executor {
+	return NewFuncExecutor(ctx, name, run, c.Debugger).observe(c.Hooks, operation, path)
+}
+
 // Create executor instance will execute the command
-func (c *Cli) executor(cmd *exec.Cmd, reader cmdReaderFunc) *Executor {
-	return NewExecutor(cmd, reader, c.Debugger)
+func (c *Cli) executor(ctx context.Context, cmd *exec.Cmd, reader cmdReaderFunc) *Executor {
+	e := NewExecutor(ctx, cmd, reader, c.Debugger)
+	e.scheduler = c.Scheduler
+	e.hooks = c.Hooks
+
+	return e
 }
\ No newline at end of file
@@ -1,6 +1,7 @@
 package vcsview
 
 import (
+	"context"
 	"strings"
 	"testing"
 )
@@ -12,7 +13,7 @@ func TestCli_Command(t *testing.T) {
 	wantArgs := "git --version"
 	wantDir := gitRepoRealPath
 
-	cmd := c.command(gitRepoRealPath, "--version")
+	cmd := c.command(context.Background(), gitRepoRealPath, "--version")
 
 	if dir := cmd.Dir; dir != wantDir {
 		t.Errorf("Cli.CreateCommand(%s, %s).Dir = %v, want: %v", gitRepoRealPath, "--version", dir, wantDir)
@@ -22,3 +23,21 @@ func TestCli_Command(t *testing.T) {
 		t.Errorf("Cli.CreateCommand(%s, %s).Args = %v, want: %v", gitRepoRealPath, "--version", args, wantArgs)
 	}
 }
+
+func TestCli_CommandSettings(t *testing.T) {
+	c := Cli{"git", nil, nil, nil, (&cliSettings{[]string{"LC_ALL=C"}, nil}).withArgs("-c", "a.b=c")}
+
+	cmd := c.command(context.Background(), gitRepoRealPath, "--version")
+
+	if args := strings.Join(cmd.Args, " "); args != "git -c a.b=c --version" {
+		t.Errorf("Cli.command().Args = %v, want: %v", args, "git -c a.b=c --version")
+	}
+
+	if env := strings.Join(cmd.Env, " "); env != "LC_ALL=C" {
+		t.Errorf("Cli.command().Env = %v, want: %v", env, "LC_ALL=C")
+	}
+
+	if key := c.settingsKey(); key == (Cli{cmd: "git"}).settingsKey() {
+		t.Errorf("Cli.settingsKey() = %q, want differs from the command without settings", key)
+	}
+}
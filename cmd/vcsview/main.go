@@ -0,0 +1,376 @@
+// Command vcsview provides command line access to the repositories using vcsview parsers
+//
+// Usage:
+//
+//	vcsview <command> [--repo=path] [--format=json|text] [flags] [args]
+//
+// Commands:
+//
+//	branches                               list branches
+//	log [--branch=] [--offset=] [--limit=] [path]  commits history of the project or path
+//	show <commit>                          commit with changes
+//	ls [--rev=] [--recursive] [path]       files tree at revision
+//	status                                 working copy status
+//	version                                vcsview and git versions
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kalyabin/vcsview"
+)
+
+const (
+	// Output formats
+	formatText = "text"
+	formatJSON = "json"
+
+	// Default number of commits in log
+	defaultLimit = 20
+)
+
+// Program version, could be set at build time by -ldflags "-X main.version=..."
+var version = "dev"
+
+// Output printer of the selected format
+type printer struct {
+	w io.Writer
+	format string
+}
+
+// Print value as indented JSON or as text using text function
+func (p printer) print(v interface{}, text func(w io.Writer)) error {
+	if p.format == formatJSON {
+		e := json.NewEncoder(p.w)
+		e.SetIndent("", "  ")
+		return e.Encode(v)
+	}
+
+	text(p.w)
+
+	return nil
+}
+
+// Command runner gets output printer, repository path and positional arguments
+type runFunc func(p printer, repo string, args []string) error
+
+// Subcommand description
+type command struct {
+	// Arguments synopsis
+	usage string
+
+	// Short description
+	description string
+
+	// Register command specific flags and returns the command runner
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+// Available subcommands by names
+var commands = map[string]command{
+	"branches": {"", "list branches", setupBranches},
+	"log": {"[--branch=] [--offset=] [--limit=] [path]", "commits history of the project or path", setupLog},
+	"show": {"<commit>", "commit with changes", setupShow},
+	"ls": {"[--rev=] [--recursive] [path]", "files tree at revision", setupLs},
+	"status": {"", "working copy status", setupStatus},
+	"version": {"", "vcsview and git versions", setupVersion},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Print program usage
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Usage: vcsview <command> [--repo=path] [--format=json|text] [flags] [args]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-9s %s\n", name, commands[name].description)
+	}
+}
+
+// Run command line and returns exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	name := args[0]
+	cmd, ok := commands[name]
+	if !ok {
+		if name == "-h" || name == "--help" || name == "help" {
+			usage(stdout)
+			return 0
+		}
+		fmt.Fprintf(stderr, "vcsview: unknown command %q\n", name)
+		usage(stderr)
+		return 2
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: vcsview %s [--repo=path] [--format=json|text] %s\n", name, cmd.usage)
+		fs.PrintDefaults()
+	}
+
+	repo := fs.String("repo", ".", "project path")
+	format := fs.String("format", formatText, "output format: json or text")
+	runner := cmd.setup(fs)
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if *format != formatText && *format != formatJSON {
+		fmt.Fprintf(stderr, "vcsview: unknown format %q\n", *format)
+		return 2
+	}
+
+	if err := runner(printer{stdout, *format}, *repo, fs.Args()); err != nil {
+		fmt.Fprintf(stderr, "vcsview: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// Open git repository at the project path
+func openRepository(repo string) (vcsview.Repository, error) {
+	return vcsview.NewRepository(repo, vcsview.NewGit())
+}
+
+// Returns single optional positional argument
+func optionalArg(args []string) (string, error) {
+	switch len(args) {
+	case 0:
+		return "", nil
+	case 1:
+		return args[0], nil
+	}
+
+	return "", fmt.Errorf("Too many arguments: %s", strings.Join(args, " "))
+}
+
+// Returns the first line of the message
+func subject(message string) string {
+	return strings.SplitN(strings.TrimSpace(message), "\n", 2)[0]
+}
+
+func setupBranches(fs *flag.FlagSet) runFunc {
+	return func(p printer, repo string, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("Unexpected arguments: %s", strings.Join(args, " "))
+		}
+
+		r, err := openRepository(repo)
+		if err != nil {
+			return err
+		}
+
+		branches, err := r.Branches(context.Background())
+		if err != nil {
+			return err
+		}
+
+		return p.print(branches, func(w io.Writer) {
+			for _, b := range branches {
+				mark := " "
+				if b.IsCurrent() {
+					mark = "*"
+				}
+				fmt.Fprintf(w, "%s %s %s\n", mark, b.Id(), b.Head())
+			}
+		})
+	}
+}
+
+func setupLog(fs *flag.FlagSet) runFunc {
+	branch := fs.String("branch", "", "branch identifier")
+	offset := fs.Int("offset", 0, "number of skipped commits")
+	limit := fs.Int("limit", defaultLimit, "maximum number of commits")
+
+	return func(p printer, repo string, args []string) error {
+		path, err := optionalArg(args)
+		if err != nil {
+			return err
+		}
+
+		r, err := openRepository(repo)
+		if err != nil {
+			return err
+		}
+
+		commits, err := r.History(context.Background(), path, *branch, *offset, *limit)
+		if err != nil {
+			return err
+		}
+
+		return p.print(commits, func(w io.Writer) {
+			for _, c := range commits {
+				fmt.Fprintf(w, "%s %s %s %s\n", c.Id(), c.Date().Format("2006-01-02"), c.Author().Name(), subject(c.Message()))
+			}
+		})
+	}
+}
+
+func setupShow(fs *flag.FlagSet) runFunc {
+	return func(p printer, repo string, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("Expected exactly one commit identifier")
+		}
+
+		r, err := openRepository(repo)
+		if err != nil {
+			return err
+		}
+
+		commit, err := r.Commit(context.Background(), args[0])
+		if err != nil {
+			return err
+		}
+
+		diffs, err := r.Diff(context.Background(), commit.Id())
+		if err != nil {
+			return err
+		}
+
+		result := struct{
+			Commit vcsview.Commit `json:"commit"`
+			Diffs []vcsview.FileDiff `json:"diffs"`
+		}{commit, diffs}
+
+		return p.print(result, func(w io.Writer) {
+			fmt.Fprintf(w, "commit %s\n", commit.Id())
+			for _, parent := range commit.Parents() {
+				fmt.Fprintf(w, "parent %s\n", parent)
+			}
+			fmt.Fprintf(w, "Author: %s\n", commit.Author())
+			fmt.Fprintf(w, "Date:   %s\n\n", commit.Date().Format("Mon Jan 2 15:04:05 2006 -0700"))
+			for _, line := range strings.Split(strings.TrimRight(commit.Message(), "\n"), "\n") {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+			for _, d := range diffs {
+				fmt.Fprintf(w, "\n%s", d.Diff())
+			}
+		})
+	}
+}
+
+func setupLs(fs *flag.FlagSet) runFunc {
+	rev := fs.String("rev", "HEAD", "branch or commit identifier")
+	recursive := fs.Bool("recursive", false, "list files of all subdirectories")
+
+	return func(p printer, repo string, args []string) error {
+		path, err := optionalArg(args)
+		if err != nil {
+			return err
+		}
+
+		r, err := openRepository(repo)
+		if err != nil {
+			return err
+		}
+
+		files, err := r.ReadTree(context.Background(), *rev, path, *recursive)
+		if err != nil {
+			return err
+		}
+
+		return p.print(files, func(w io.Writer) {
+			for _, f := range files {
+				pathname := f.Pathname()
+				if f.IsDir() {
+					pathname += "/"
+				}
+				fmt.Fprintf(w, "%s %8d %s\n", f.Mode(), f.Size(), pathname)
+			}
+		})
+	}
+}
+
+// Working copy file status
+type fileStatus struct {
+	// Two letters status of the short format (index and working tree)
+	Status string `json:"status"`
+
+	// Relative file pathname
+	Pathname string `json:"pathname"`
+
+	// Relative file pathname before rename or copy
+	PreviousPathname string `json:"previous_pathname,omitempty"`
+}
+
+// Parse short status output like "R  old -> new"
+func parseStatus(output string) []fileStatus {
+	result := make([]fileStatus, 0)
+
+	for _, line := range strings.Split(output, "\n") {
+		if len(line) < 4 {
+			continue
+		}
+
+		s := fileStatus{Status: line[:2], Pathname: line[3:]}
+		if parts := strings.SplitN(s.Pathname, " -> ", 2); len(parts) == 2 {
+			s.PreviousPathname, s.Pathname = parts[0], parts[1]
+		}
+
+		result = append(result, s)
+	}
+
+	return result
+}
+
+func setupStatus(fs *flag.FlagSet) runFunc {
+	return func(p printer, repo string, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("Unexpected arguments: %s", strings.Join(args, " "))
+		}
+
+		r, err := openRepository(repo)
+		if err != nil {
+			return err
+		}
+
+		output, err := r.Cmd().StatusRepository(context.Background(), r.ProjectPath())
+		if err != nil {
+			return err
+		}
+
+		return p.print(parseStatus(output), func(w io.Writer) {
+			io.WriteString(w, output)
+		})
+	}
+}
+
+func setupVersion(fs *flag.FlagSet) runFunc {
+	return func(p printer, repo string, args []string) error {
+		git, err := vcsview.NewGit().Version(context.Background())
+		if err != nil {
+			return err
+		}
+
+		result := struct{
+			Version string `json:"version"`
+			Git string `json:"git"`
+		}{version, git}
+
+		return p.print(result, func(w io.Writer) {
+			fmt.Fprintf(w, "vcsview %s\ngit %s\n", version, git)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kalyabin/vcsview"
+)
+
+const (
+	gitRepositoryPath = "../../testdata/git"
+)
+
+func runArgs(args ...string) (int, string, string) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	code := run(args, stdout, stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Text(t *testing.T) {
+	cases := []struct{
+		args []string
+		contains string
+	}{
+		{[]string{"branches", "--repo=" + gitRepositoryPath}, "master"},
+		{[]string{"log", "--repo=" + gitRepositoryPath, "--limit=1", "testpath"}, ""},
+		{[]string{"ls", "--repo=" + gitRepositoryPath, "testpath"}, "testpath/empty.txt"},
+		{[]string{"ls", "--repo=" + gitRepositoryPath, "--recursive"}, "testpath/empty.txt"},
+		{[]string{"status", "--repo=" + gitRepositoryPath}, ""},
+		{[]string{"version"}, "vcsview " + version},
+	}
+
+	for key, testCase := range cases {
+		code, stdout, stderr := runArgs(testCase.args...)
+
+		if code != 0 {
+			t.Errorf("[%d] run(%v) = %d, want: 0. Stderr: %s", key, testCase.args, code, stderr)
+			continue
+		}
+
+		if !strings.Contains(stdout, testCase.contains) {
+			t.Errorf("[%d] run(%v) output doesn't contain %s:\n%s", key, testCase.args, testCase.contains, stdout)
+		}
+	}
+}
+
+func TestRun_JSON(t *testing.T) {
+	code, stdout, stderr := runArgs("log", "--repo="+gitRepositoryPath, "--format=json", "--limit=2")
+	if code != 0 {
+		t.Fatalf("run(log) = %d, want: 0. Stderr: %s", code, stderr)
+	}
+
+	var commits []vcsview.Commit
+	if err := json.Unmarshal([]byte(stdout), &commits); err != nil || len(commits) != 2 {
+		t.Fatalf("run(log) output = %v, %v, want 2 commits", commits, err)
+	}
+
+	code, stdout, stderr = runArgs("show", "--repo="+gitRepositoryPath, "--format=json", commits[0].Id())
+	if code != 0 {
+		t.Fatalf("run(show %s) = %d, want: 0. Stderr: %s", commits[0].Id(), code, stderr)
+	}
+
+	var show struct{
+		Commit vcsview.Commit `json:"commit"`
+		Diffs []vcsview.FileDiff `json:"diffs"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &show); err != nil || show.Commit.Id() != commits[0].Id() {
+		t.Errorf("run(show %s) output = %v, %v, want the same commit", commits[0].Id(), show, err)
+	}
+
+	code, stdout, stderr = runArgs("show", "--repo="+gitRepositoryPath, commits[0].Id())
+	if code != 0 || !strings.HasPrefix(stdout, "commit "+commits[0].Id()) {
+		t.Errorf("run(show %s) = %d, %s, want commit header. Stderr: %s", commits[0].Id(), code, stdout, stderr)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	cases := []struct{
+		args []string
+		code int
+	}{
+		{[]string{}, 2},
+		{[]string{"unknown"}, 2},
+		{[]string{"log", "--unknown-flag"}, 2},
+		{[]string{"log", "--format=xml"}, 2},
+		{[]string{"log", "--repo=" + gitRepositoryPath, "a", "b"}, 1},
+		{[]string{"show", "--repo=" + gitRepositoryPath}, 1},
+		{[]string{"show", "--repo=" + gitRepositoryPath, "non-existent-commit"}, 1},
+		{[]string{"branches", "--repo=/non/existent/path"}, 1},
+	}
+
+	for key, testCase := range cases {
+		if code, _, stderr := runArgs(testCase.args...); code != testCase.code {
+			t.Errorf("[%d] run(%v) = %d, want: %d. Stderr: %s", key, testCase.args, code, testCase.code, stderr)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	output := " M testing.txt\nR  old.txt -> new.txt\n?? untracked.txt\n"
+
+	want := []fileStatus{
+		{" M", "testing.txt", ""},
+		{"R ", "new.txt", "old.txt"},
+		{"??", "untracked.txt", ""},
+	}
+
+	if got := parseStatus(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseStatus(%q) = %v, want: %v", output, got, want)
+	}
+}
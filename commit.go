@@ -1,6 +1,18 @@
 package vcsview
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+// JSON representation of the commit
+type commitJSON struct {
+	Id string `json:"id"`
+	Date time.Time `json:"date"`
+	Author Contributor `json:"author"`
+	Message string `json:"message"`
+	Parents []string `json:"parents"`
+}
 
 // Represents a commit model
 type Commit struct {
@@ -20,6 +32,11 @@ type Commit struct {
 	parents []string
 }
 
+// Create commit model, root commit has single empty parent like the Git backend returns
+func NewCommit(id string, date time.Time, author Contributor, message string, parents []string) Commit {
+	return Commit{id, date, author, message, parents}
+}
+
 // Get commit identifier
 func (c Commit) Id() string {
 	return c.id
@@ -44,3 +61,81 @@ func (c Commit) Message() string {
 func (c Commit) Parents() []string {
 	return c.parents
 }
+
+// Encode commit to JSON object
+// Date is encoded in RFC 3339 format, parents are always encoded as array
+func (c Commit) MarshalJSON() ([]byte, error) {
+	parents := c.parents
+	if parents == nil {
+		parents = []string{}
+	}
+
+	return json.Marshal(commitJSON{c.id, c.date, c.author, c.message, parents})
+}
+
+// Decode commit from JSON object
+func (c *Commit) UnmarshalJSON(data []byte) error {
+	var v commitJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	c.id, c.date, c.author, c.message, c.parents = v.Id, v.Date, v.Author, v.Message, v.Parents
+
+	return nil
+}
+
+// Sort commits like git log --topo-order does: no parent goes before all its children
+// and commits of the one line of history aren't intermixed with other lines
+// Commits should go in the walk order from the newest one, parents out of the commits are ignored
+func SortCommitsTopo(commits []Commit) []Commit {
+	// number of children plus one, like git counts it
+	indegree := make(map[string]int, len(commits))
+	for _, c := range commits {
+		indegree[c.Id()] = 1
+	}
+
+	for _, c := range commits {
+		for _, parent := range c.Parents() {
+			if indegree[parent] > 0 {
+				indegree[parent]++
+			}
+		}
+	}
+
+	byId := make(map[string]Commit, len(commits))
+	stack := make([]Commit, 0, len(commits))
+
+	// tips go first in the walk order, so they are pushed in the reverse one
+	for i := len(commits) - 1; i >= 0; i-- {
+		byId[commits[i].Id()] = commits[i]
+
+		if indegree[commits[i].Id()] == 1 {
+			stack = append(stack, commits[i])
+		}
+	}
+
+	sorted := make([]Commit, 0, len(commits))
+
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for _, parent := range c.Parents() {
+			if indegree[parent] == 0 {
+				continue
+			}
+
+			// the last child of the parent is shown, so the parent goes next
+			if indegree[parent]--; indegree[parent] == 1 {
+				stack = append(stack, byId[parent])
+			}
+		}
+
+		indegree[c.Id()] = 0
+		sorted = append(sorted, c)
+	}
+
+	return sorted
+}
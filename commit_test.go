@@ -1,6 +1,9 @@
 package vcsview
 
 import (
+	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -77,4 +80,81 @@ func TestCommit_Parents(t *testing.T) {
 			t.Errorf("[%d] Commit.Parents() = %d commits, want: %d", key, len(parents), len(testCase))
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestCommit_MarshalJSON(t *testing.T) {
+	date := time.Date(2019, 2, 24, 10, 47, 0, 0, time.FixedZone("", 3*60*60))
+
+	cases := []struct{
+		commit Commit
+		want string
+	}{
+		{
+			Commit{id: "60a470f", date: date, author: Contributor{"name", "test@email.ltd"}, message: "testing", parents: []string{"1", "2"}},
+			`{"id":"60a470f","date":"2019-02-24T10:47:00+03:00","author":{"name":"name","email":"test@email.ltd"},"message":"testing","parents":["1","2"]}`,
+		},
+		{
+			Commit{id: "60a470f", date: date, parents: []string{}},
+			`{"id":"60a470f","date":"2019-02-24T10:47:00+03:00","author":{"name":"","email":""},"message":"","parents":[]}`,
+		},
+	}
+
+	for key, testCase := range cases {
+		data, err := json.Marshal(testCase.commit)
+		if err != nil || string(data) != testCase.want {
+			t.Errorf("[%d] Commit.MarshalJSON() = %s, %v, want: %s", key, data, err, testCase.want)
+		}
+
+		var c Commit
+		if err := json.Unmarshal(data, &c); err != nil {
+			t.Errorf("[%d] Commit.UnmarshalJSON(%s) got error: %v", key, data, err)
+			continue
+		}
+
+		if c.id != testCase.commit.id || !c.date.Equal(date) || c.author != testCase.commit.author ||
+			c.message != testCase.commit.message || !reflect.DeepEqual(c.parents, testCase.commit.parents) {
+			t.Errorf("[%d] Commit.UnmarshalJSON(%s) = %v, want: %v", key, data, c, testCase.commit)
+		}
+	}
+
+	if data, _ := json.Marshal(Commit{}); !strings.Contains(string(data), `"parents":[]`) {
+		t.Errorf("Commit.MarshalJSON() for commit without parents = %s, want empty parents array", data)
+	}
+}
+
+func TestSortCommitsTopo(t *testing.T) {
+	// commit with id and parents separated by spaces
+	commit := func(spec string) Commit {
+		fields := strings.Fields(spec)
+		return NewCommit(fields[0], time.Time{}, Contributor{}, "", fields[1:])
+	}
+
+	cases := []struct{
+		commits []string
+		want string
+	}{
+		// walk by date intermixes the lines of merge, topological order doesn't
+		{[]string{"merge m2 t2", "m2 m1", "t2 t1", "m1 a", "t1 a", "a"}, "merge,t2,t1,m2,m1,a"},
+		// tips go in the walk order, parent goes after all its children
+		{[]string{"o1 m2", "merge m2 t1", "t1 a", "m2 a", "a"}, "o1,merge,t1,m2,a"},
+		// parents out of the commits are ignored
+		{[]string{"b a", "c x"}, "b,c"},
+		{[]string{}, ""},
+	}
+
+	for key, testCase := range cases {
+		commits := make([]Commit, 0, len(testCase.commits))
+		for _, spec := range testCase.commits {
+			commits = append(commits, commit(spec))
+		}
+
+		ids := make([]string, 0, len(commits))
+		for _, c := range SortCommitsTopo(commits) {
+			ids = append(ids, c.Id())
+		}
+
+		if got := strings.Join(ids, ","); got != testCase.want {
+			t.Errorf("[%d] SortCommitsTopo(%v) = %s, want: %s", key, testCase.commits, got, testCase.want)
+		}
+	}
+}
@@ -1,5 +1,13 @@
 package vcsview
 
+import "encoding/json"
+
+// JSON representation of the contributor
+type contributorJSON struct {
+	Name string `json:"name"`
+	Email string `json:"email"`
+}
+
 // Represents commit author model
 type Contributor struct {
 	// Contributor name (if exists)
@@ -9,6 +17,11 @@ type Contributor struct {
 	email string
 }
 
+// Create contributor model
+func NewContributor(name string, email string) Contributor {
+	return Contributor{name, email}
+}
+
 // Get contributor name
 func (c Contributor) Name() string {
 	return c.name
@@ -26,3 +39,21 @@ func (c Contributor) String() string {
 
 	return c.name
 }
+
+// Encode contributor to JSON object with name and email keys
+func (c Contributor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(contributorJSON{c.name, c.email})
+}
+
+// Decode contributor from JSON object
+func (c *Contributor) UnmarshalJSON(data []byte) error {
+	var v contributorJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	c.name, c.email = v.Name, v.Email
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package vcsview
 
 import (
+	"encoding/json"
 	"testing"
 )
 
@@ -58,3 +59,25 @@ func TestContributor_String(t *testing.T) {
 		}
 	}
 }
+
+func TestContributor_MarshalJSON(t *testing.T) {
+	cases := []struct{
+		contributor Contributor
+		want string
+	}{
+		{Contributor{}, `{"name":"","email":""}`},
+		{Contributor{"test", "email@email.ltd"}, `{"name":"test","email":"email@email.ltd"}`},
+	}
+
+	for key, testCase := range cases {
+		data, err := json.Marshal(testCase.contributor)
+		if err != nil || string(data) != testCase.want {
+			t.Errorf("[%d] Contributor.MarshalJSON() = %s, %v, want: %s", key, data, err, testCase.want)
+		}
+
+		var c Contributor
+		if err := json.Unmarshal(data, &c); err != nil || c != testCase.contributor {
+			t.Errorf("[%d] Contributor.UnmarshalJSON(%s) = %v, %v, want: %v", key, data, c, err, testCase.contributor)
+		}
+	}
+}
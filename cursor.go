@@ -0,0 +1,352 @@
+package vcsview
+
+import (
+	"context"
+	"reflect"
+)
+
+// Common part of the results cursors
+// The cursor runs the executor in background and gets its results one-by-one by Next:
+//
+//	c := NewCommitCursor(ctx, func(ctx context.Context, result chan Commit) *Executor {
+//		return git.ReadHistory(ctx, projectPath, "", "", 0, 10, result)
+//	})
+//	defer c.Close()
+//	for c.Next() {
+//		fmt.Println(c.Commit().Id())
+//	}
+//	err := c.Err()
+//
+// The results channel is closed after the executor finished, Close stops the command if consumer breaks early
+// Typed cursors add only the accessor of the current value
+// Cursors aren't safe for concurrent use
+type cursor struct {
+	// Consumer context
+	parent context.Context
+
+	// Cursor context, cancelled by Close
+	ctx context.Context
+	cancel context.CancelFunc
+
+	// Closed after the executor finished
+	done chan struct{}
+
+	// Executor error
+	err error
+
+	// Cursor was closed by consumer
+	closed bool
+
+	// Results channel of the executor
+	result reflect.Value
+
+	// Current value
+	value interface{}
+}
+
+// Run the executor in background
+// Result is the results channel passed to the executor by the read function, it's closed after the executor finished
+func (c *cursor) start(ctx context.Context, result interface{}, read func(ctx context.Context) *Executor) {
+	c.parent = ctx
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	c.result = reflect.ValueOf(result)
+
+	e := read(c.ctx)
+
+	go func() {
+		// reader sends all results before Run returns, so the channel is closed after it
+		c.err = e.Run()
+		c.cancel()
+		close(c.done)
+		c.result.Close()
+	}()
+}
+
+// Move to the next value
+// Returns false after the last value or if the reading failed (see Err)
+func (c *cursor) Next() bool {
+	value, ok := c.result.Recv()
+	if ok {
+		c.value = value.Interface()
+	}
+
+	return ok
+}
+
+// Returns error of the executor after Next returned false
+// Stopping by Close isn't an error
+func (c *cursor) Err() error {
+	select {
+	case <-c.done:
+	default:
+		return nil
+	}
+
+	if c.closed && c.err == context.Canceled && c.parent.Err() == nil {
+		return nil
+	}
+
+	return c.err
+}
+
+// Stop reading: the command is killed and the rest results are skipped
+// Should be called if consumer stops before Next returned false, it's safe to call Close several times
+func (c *cursor) Close() error {
+	select {
+	case <-c.done:
+	default:
+		c.closed = true
+	}
+
+	c.cancel()
+
+	// the rest results are read until the executor finished
+	for {
+		if _, ok := c.result.Recv(); !ok {
+			break
+		}
+	}
+
+	return c.Err()
+}
+
+// Cursor of branches
+type BranchCursor struct {
+	cursor
+}
+
+// Create cursor of branches read by the executor
+// Read function should create the executor using the context and the result channel
+func NewBranchCursor(ctx context.Context, read func(ctx context.Context, result chan Branch) *Executor) *BranchCursor {
+	c := &BranchCursor{}
+	result := make(chan Branch)
+
+	c.start(ctx, result, func(ctx context.Context) *Executor {
+		return read(ctx, result)
+	})
+
+	return c
+}
+
+// Returns current value
+func (c *BranchCursor) Branch() Branch {
+	value, _ := c.value.(Branch)
+	return value
+}
+
+// Cursor of tags
+type TagCursor struct {
+	cursor
+}
+
+// Create cursor of tags read by the executor
+// Read function should create the executor using the context and the result channel
+func NewTagCursor(ctx context.Context, read func(ctx context.Context, result chan Tag) *Executor) *TagCursor {
+	c := &TagCursor{}
+	result := make(chan Tag)
+
+	c.start(ctx, result, func(ctx context.Context) *Executor {
+		return read(ctx, result)
+	})
+
+	return c
+}
+
+// Returns current value
+func (c *TagCursor) Tag() Tag {
+	value, _ := c.value.(Tag)
+	return value
+}
+
+// Cursor of commits
+type CommitCursor struct {
+	cursor
+}
+
+// Create cursor of commits read by the executor
+// Read function should create the executor using the context and the result channel
+func NewCommitCursor(ctx context.Context, read func(ctx context.Context, result chan Commit) *Executor) *CommitCursor {
+	c := &CommitCursor{}
+	result := make(chan Commit)
+
+	c.start(ctx, result, func(ctx context.Context) *Executor {
+		return read(ctx, result)
+	})
+
+	return c
+}
+
+// Returns current value
+func (c *CommitCursor) Commit() Commit {
+	value, _ := c.value.(Commit)
+	return value
+}
+
+// Cursor of files
+type FileCursor struct {
+	cursor
+}
+
+// Create cursor of files read by the executor
+// Read function should create the executor using the context and the result channel
+func NewFileCursor(ctx context.Context, read func(ctx context.Context, result chan File) *Executor) *FileCursor {
+	c := &FileCursor{}
+	result := make(chan File)
+
+	c.start(ctx, result, func(ctx context.Context) *Executor {
+		return read(ctx, result)
+	})
+
+	return c
+}
+
+// Returns current value
+func (c *FileCursor) File() File {
+	value, _ := c.value.(File)
+	return value
+}
+
+// Cursor of files changes
+type FileDiffCursor struct {
+	cursor
+}
+
+// Create cursor of files changes read by the executor
+// Read function should create the executor using the context and the result channel
+func NewFileDiffCursor(ctx context.Context, read func(ctx context.Context, result chan FileDiff) *Executor) *FileDiffCursor {
+	c := &FileDiffCursor{}
+	result := make(chan FileDiff)
+
+	c.start(ctx, result, func(ctx context.Context) *Executor {
+		return read(ctx, result)
+	})
+
+	return c
+}
+
+// Returns current value
+func (c *FileDiffCursor) FileDiff() FileDiff {
+	value, _ := c.value.(FileDiff)
+	return value
+}
+
+// Cursor of file history commits
+type FileCommitCursor struct {
+	cursor
+}
+
+// Create cursor of file history commits read by the executor
+// Read function should create the executor using the context and the result channel
+func NewFileCommitCursor(ctx context.Context, read func(ctx context.Context, result chan FileCommit) *Executor) *FileCommitCursor {
+	c := &FileCommitCursor{}
+	result := make(chan FileCommit)
+
+	c.start(ctx, result, func(ctx context.Context) *Executor {
+		return read(ctx, result)
+	})
+
+	return c
+}
+
+// Returns current value
+func (c *FileCommitCursor) FileCommit() FileCommit {
+	value, _ := c.value.(FileCommit)
+	return value
+}
+
+// Cursor of lines history commits
+type LineCommitCursor struct {
+	cursor
+}
+
+// Create cursor of lines history commits read by the executor
+// Read function should create the executor using the context and the result channel
+func NewLineCommitCursor(ctx context.Context, read func(ctx context.Context, result chan LineCommit) *Executor) *LineCommitCursor {
+	c := &LineCommitCursor{}
+	result := make(chan LineCommit)
+
+	c.start(ctx, result, func(ctx context.Context) *Executor {
+		return read(ctx, result)
+	})
+
+	return c
+}
+
+// Returns current value
+func (c *LineCommitCursor) LineCommit() LineCommit {
+	value, _ := c.value.(LineCommit)
+	return value
+}
+
+// Cursor of found commits
+type MatchedCommitCursor struct {
+	cursor
+}
+
+// Create cursor of found commits read by the executor
+// Read function should create the executor using the context and the result channel
+func NewMatchedCommitCursor(ctx context.Context, read func(ctx context.Context, result chan MatchedCommit) *Executor) *MatchedCommitCursor {
+	c := &MatchedCommitCursor{}
+	result := make(chan MatchedCommit)
+
+	c.start(ctx, result, func(ctx context.Context) *Executor {
+		return read(ctx, result)
+	})
+
+	return c
+}
+
+// Returns current value
+func (c *MatchedCommitCursor) MatchedCommit() MatchedCommit {
+	value, _ := c.value.(MatchedCommit)
+	return value
+}
+
+// Cursor of grep matches
+type GrepMatchCursor struct {
+	cursor
+}
+
+// Create cursor of grep matches read by the executor
+// Read function should create the executor using the context and the result channel
+func NewGrepMatchCursor(ctx context.Context, read func(ctx context.Context, result chan GrepMatch) *Executor) *GrepMatchCursor {
+	c := &GrepMatchCursor{}
+	result := make(chan GrepMatch)
+
+	c.start(ctx, result, func(ctx context.Context) *Executor {
+		return read(ctx, result)
+	})
+
+	return c
+}
+
+// Returns current value
+func (c *GrepMatchCursor) GrepMatch() GrepMatch {
+	value, _ := c.value.(GrepMatch)
+	return value
+}
+
+// Cursor of patches
+type PatchCursor struct {
+	cursor
+}
+
+// Create cursor of patches read by the executor
+// Read function should create the executor using the context and the result channel
+func NewPatchCursor(ctx context.Context, read func(ctx context.Context, result chan Patch) *Executor) *PatchCursor {
+	c := &PatchCursor{}
+	result := make(chan Patch)
+
+	c.start(ctx, result, func(ctx context.Context) *Executor {
+		return read(ctx, result)
+	})
+
+	return c
+}
+
+// Returns current value
+func (c *PatchCursor) Patch() Patch {
+	value, _ := c.value.(Patch)
+	return value
+}
@@ -0,0 +1,143 @@
+package vcsview
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCommitCursor(t *testing.T) {
+	g := MakeGitMock(t)
+	ctx := context.Background()
+
+	c := NewCommitCursor(ctx, func(ctx context.Context, result chan Commit) *Executor {
+		return g.ReadHistory(ctx, gitRepositoryPath, "", "", 0, 5, result)
+	})
+
+	ids := make([]string, 0)
+	for c.Next() {
+		ids = append(ids, c.Commit().Id())
+	}
+
+	if err := c.Err(); err != nil || len(ids) != 5 {
+		t.Errorf("CommitCursor got %d commits, error: %v, want: 5 commits", len(ids), err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Errorf("CommitCursor.Close() after the last value = %v, want: nil", err)
+	}
+
+	if c.Next() {
+		t.Errorf("CommitCursor.Next() after Close() = true, want: false")
+	}
+}
+
+func TestCommitCursor_Errors(t *testing.T) {
+	g := MakeGitMock(t)
+
+	cases := []struct{
+		revision string
+		wantKind error
+	}{
+		{"non-existent-revision", ErrRevisionNotFound},
+		{"HEAD", nil},
+	}
+
+	for key, testCase := range cases {
+		c := NewCommitCursor(context.Background(), func(ctx context.Context, result chan Commit) *Executor {
+			return g.ReadCommit(ctx, gitRepositoryPath, testCase.revision, result)
+		})
+
+		found := 0
+		for c.Next() {
+			found++
+		}
+
+		if err := c.Err(); ErrorKind(err) != testCase.wantKind || (testCase.wantKind == nil && (err != nil || found != 1)) {
+			t.Errorf("[%d] CommitCursor(ReadCommit(%s)) got %d commits, error: %v, want: %v", key, testCase.revision, found, err, testCase.wantKind)
+		}
+
+		c.Close()
+	}
+}
+
+func TestCursor_Close(t *testing.T) {
+	// executor which streams values until the context is done
+	infinite := func(ctx context.Context, result chan Branch) *Executor {
+		return NewFuncExecutor(ctx, "infinite branches", func() error {
+			for ctx.Err() == nil {
+				result <- Branch{}
+			}
+			return nil
+		}, nil)
+	}
+
+	c := NewBranchCursor(context.Background(), infinite)
+
+	if !c.Next() || !c.Next() {
+		t.Fatalf("BranchCursor.Next() = false, want: true")
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- c.Close()
+	}()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("BranchCursor.Close() = %v, want: nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("BranchCursor.Close() doesn't stop the executor")
+	}
+
+	if err := c.Err(); err != nil {
+		t.Errorf("BranchCursor.Err() after Close() = %v, want: nil", err)
+	}
+
+	// consumer context cancellation is an error
+	ctx, cancel := context.WithCancel(context.Background())
+	c = NewBranchCursor(ctx, infinite)
+	c.Next()
+	cancel()
+
+	for c.Next() {
+	}
+
+	if err := c.Err(); err != context.Canceled {
+		t.Errorf("BranchCursor.Err() with cancelled context = %v, want: %v", err, context.Canceled)
+	}
+
+	if err := c.Close(); err != context.Canceled {
+		t.Errorf("BranchCursor.Close() with cancelled context = %v, want: %v", err, context.Canceled)
+	}
+}
+
+func TestCursor_CloseCommand(t *testing.T) {
+	g := MakeGitMock(t)
+
+	c := NewFileCursor(context.Background(), func(ctx context.Context, result chan File) *Executor {
+		return g.ReadTree(ctx, gitRepositoryPath, "HEAD", "", true, result)
+	})
+
+	if !c.Next() {
+		t.Fatalf("FileCursor.Next() = false, want: true")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Errorf("FileCursor.Close() = %v, want: nil", err)
+	}
+
+	c2 := NewCommitCursor(context.Background(), func(ctx context.Context, result chan Commit) *Executor {
+		return g.ReadHistory(ctx, gitRepositoryPath, "", "", 0, 1000, result)
+	})
+
+	if !c2.Next() {
+		t.Fatalf("CommitCursor.Next() = false, want: true")
+	}
+
+	if err := c2.Close(); err != nil {
+		t.Errorf("CommitCursor.Close() of running command = %v, want: nil", err)
+	}
+}
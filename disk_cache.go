@@ -0,0 +1,172 @@
+package vcsview
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Version of the cache entries format, entries of other versions are ignored
+	diskCacheVersion = "v1"
+
+	// Entries which aren't used for this time are removed automatically
+	diskCacheMaxAge = 30 * 24 * time.Hour
+
+	// Minimum interval between automatic removals of unused entries
+	diskCachePruneInterval = time.Hour
+
+	// Temporary files older than that are left by interrupted writes, writes take much less time
+	diskCacheTempMaxAge = 10 * time.Minute
+)
+
+// Persistent cache of the expensive query results stored as JSON files in the directory
+// Entries are keyed by immutable object identifiers, so moved refs invalidate them automatically
+// Entries which aren't used for 30 days are removed while the cache is written, all entries could be removed by Clear
+// Safe for concurrent use by many processes sharing the same directory
+type DiskCache struct {
+	// Cache directory
+	dir string
+
+	mu sync.Mutex
+
+	// Time of the last automatic removal of unused entries
+	pruned time.Time
+}
+
+// Create persistent cache in the directory
+// Directory is created if it doesn't exist
+func NewDiskCache(dir string) (*DiskCache, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, diskCacheVersion), 0755); err != nil {
+		return nil, err
+	}
+
+	return &DiskCache{dir: dir, pruned: time.Now()}, nil
+}
+
+// Get cache directory
+func (c *DiskCache) Dir() string {
+	return c.dir
+}
+
+// Returns entry file pathname for the kind of entry and key parts
+func (c *DiskCache) pathname(kind string, parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	name := hex.EncodeToString(sum[:])
+
+	return filepath.Join(c.dir, diskCacheVersion, kind, name[:2], name+".json")
+}
+
+// Read entry into v
+// Returns false if entry not found or it's broken
+// Modification time of the read entry is updated, so used entries aren't pruned
+func (c *DiskCache) get(v interface{}, kind string, parts ...string) bool {
+	pathname := c.pathname(kind, parts...)
+
+	data, err := ioutil.ReadFile(pathname)
+	if err != nil || json.Unmarshal(data, v) != nil {
+		return false
+	}
+
+	now := time.Now()
+	os.Chtimes(pathname, now, now)
+
+	return true
+}
+
+// Write entry atomically, so concurrent readers never get partial entries
+func (c *DiskCache) put(v interface{}, kind string, parts ...string) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	pathname := c.pathname(kind, parts...)
+	if err := os.MkdirAll(filepath.Dir(pathname), 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(pathname), ".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), pathname); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	if time.Since(c.pruned) >= diskCachePruneInterval {
+		c.pruned = time.Now()
+		go c.Prune(diskCacheMaxAge)
+	}
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Remove entries which aren't read or written for maxAge
+// Temporary files of the interrupted writes are removed after 10 minutes or maxAge if it's shorter
+func (c *DiskCache) Prune(maxAge time.Duration) error {
+	before := time.Now().Add(-maxAge)
+
+	tempBefore := time.Now().Add(-diskCacheTempMaxAge)
+	if tempBefore.Before(before) {
+		tempBefore = before
+	}
+
+	return filepath.Walk(filepath.Join(c.dir, diskCacheVersion), func(pathname string, info os.FileInfo, err error) error {
+		if err != nil {
+			// entries could be removed concurrently by other processes
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		expired := before
+		if strings.HasPrefix(info.Name(), ".tmp") {
+			expired = tempBefore
+		}
+
+		if !info.IsDir() && info.ModTime().Before(expired) {
+			if err := os.Remove(pathname); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Remove all cache entries
+func (c *DiskCache) Clear() error {
+	if err := os.RemoveAll(filepath.Join(c.dir, diskCacheVersion)); err != nil {
+		return err
+	}
+
+	return os.MkdirAll(filepath.Join(c.dir, diskCacheVersion), 0755)
+}
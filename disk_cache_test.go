@@ -0,0 +1,187 @@
+package vcsview
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func makeDiskCache(t *testing.T) (*DiskCache, func()) {
+	dir, err := ioutil.TempDir("", "vcsview-cache")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() got error: %v", err)
+	}
+
+	c, err := NewDiskCache(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("NewDiskCache(%s) got error: %v", dir, err)
+	}
+
+	return c, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	c, cleanup := makeDiskCache(t)
+	defer cleanup()
+
+	var v []string
+	if c.get(&v, "testing", "a", "b") {
+		t.Errorf("DiskCache.get(testing, a, b) found entry in the empty cache")
+	}
+
+	want := []string{"first", "second"}
+	if err := c.put(want, "testing", "a", "b"); err != nil {
+		t.Fatalf("DiskCache.put(testing, a, b) got error: %v", err)
+	}
+
+	if !c.get(&v, "testing", "a", "b") || !reflect.DeepEqual(v, want) {
+		t.Errorf("DiskCache.get(testing, a, b) = %v, want: %v", v, want)
+	}
+
+	if c.get(&v, "testing", "ab") || c.get(&v, "other", "a", "b") {
+		t.Errorf("DiskCache.get() found entry by other key")
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatalf("DiskCache.Clear() got error: %v", err)
+	}
+
+	if c.get(&v, "testing", "a", "b") {
+		t.Errorf("DiskCache.get(testing, a, b) found entry after clear")
+	}
+}
+
+func TestDiskCache_Prune(t *testing.T) {
+	c, cleanup := makeDiskCache(t)
+	defer cleanup()
+
+	for _, key := range []string{"old", "used", "new"} {
+		if err := c.put(key, "testing", key); err != nil {
+			t.Fatalf("DiskCache.put(testing, %s) got error: %v", key, err)
+		}
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, key := range []string{"old", "used"} {
+		if err := os.Chtimes(c.pathname("testing", key), old, old); err != nil {
+			t.Fatalf("os.Chtimes() got error: %v", err)
+		}
+	}
+
+	var v string
+	if !c.get(&v, "testing", "used") {
+		t.Fatalf("DiskCache.get(testing, used) found no entry")
+	}
+
+	// temporary files of the interrupted writes are removed sooner than entries
+	dir := filepath.Dir(c.pathname("testing", "new"))
+	interrupted, stale := filepath.Join(dir, ".tmp1"), time.Now().Add(-20 * time.Minute)
+	for _, pathname := range []string{interrupted, filepath.Join(dir, ".tmp2")} {
+		if err := ioutil.WriteFile(pathname, []byte("{"), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%s) got error: %v", pathname, err)
+		}
+	}
+	if err := os.Chtimes(interrupted, stale, stale); err != nil {
+		t.Fatalf("os.Chtimes() got error: %v", err)
+	}
+
+	if err := c.Prune(time.Hour); err != nil {
+		t.Fatalf("DiskCache.Prune() got error: %v", err)
+	}
+
+	if _, err := os.Stat(interrupted); !os.IsNotExist(err) {
+		t.Errorf("DiskCache.Prune() kept stale temporary file %s", interrupted)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".tmp2")); err != nil {
+		t.Errorf("DiskCache.Prune() removed new temporary file: %v", err)
+	}
+
+	cases := []struct{
+		key string
+		found bool
+	}{
+		{"old", false},
+		{"used", true},
+		{"new", true},
+	}
+
+	for key, testCase := range cases {
+		if found := c.get(&v, "testing", testCase.key); found != testCase.found {
+			t.Errorf("[%d] DiskCache.get(testing, %s) after prune found: %v, want: %v", key, testCase.key, found, testCase.found)
+		}
+	}
+}
+
+func TestRepository_WithDiskCache(t *testing.T) {
+	c, cleanup := makeDiskCache(t)
+	defer cleanup()
+
+	r, err := NewRepository(gitRepositoryPath, MakeGitMock(t))
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+	cached := r.WithDiskCache(c)
+
+	want, err := r.History(context.Background(), "", "", 0, 3)
+	if err != nil {
+		t.Fatalf("Repository.History() got error: %v", err)
+	}
+
+	commits, err := cached.History(context.Background(), "", "", 0, 3)
+	if err != nil || len(commits) != len(want) {
+		t.Fatalf("Repository.History() with cache = %v, %v, want: %v", commits, err, want)
+	}
+
+	// replace cached entries to make sure they are read instead of the repository
+	heads, _ := r.historyHeads(context.Background(), "", "")
+	sort.Strings(heads)
+	page := fmt.Sprintf("%s\x00%d\x00%d", "", 0, 3)
+	fake := []Commit{{id: "fake"}}
+	c.put(fake, "history", r.ProjectPath(), strings.Join(heads, ","), page)
+
+	if commits, err := cached.History(context.Background(), "", "", 0, 3); err != nil || len(commits) != 1 || commits[0].Id() != "fake" {
+		t.Errorf("Repository.History() with cache = %v, %v, want cached entry", commits, err)
+	}
+
+	commitId := want[0].Id()
+	if commit, err := cached.Commit(context.Background(), commitId); err != nil || commit.Id() != commitId || commit.Message() != want[0].Message() {
+		t.Errorf("Repository.Commit(%s) with cache = %v, %v, want: %v", commitId, commit, err, want[0])
+	}
+
+	c.put(Commit{id: commitId, message: "cached"}, "commit", r.ProjectPath(), commitId)
+	if commit, err := cached.Commit(context.Background(), commitId); err != nil || commit.Message() != "cached" {
+		t.Errorf("Repository.Commit(%s) with cache = %v, %v, want cached entry", commitId, commit, err)
+	}
+
+	// mutable revisions aren't cached
+	head, err := r.Commit(context.Background(), "HEAD")
+	if err != nil {
+		t.Fatalf("Repository.Commit(HEAD) got error: %v", err)
+	}
+
+	c.put(Commit{id: head.Id(), message: "cached"}, "commit", r.ProjectPath(), "HEAD")
+	if commit, err := cached.Commit(context.Background(), "HEAD"); err != nil || commit.Id() != head.Id() || commit.Message() == "cached" {
+		t.Errorf("Repository.Commit(HEAD) with cache = %v, %v, want: %v", commit, err, head)
+	}
+
+	languages, err := cached.Languages(context.Background(), "HEAD")
+	if err != nil {
+		t.Fatalf("Repository.Languages(HEAD) with cache got error: %v", err)
+	}
+
+	var stored []LanguageStat
+	if !c.get(&stored, "languages", r.ProjectPath(), head.Id()) || !reflect.DeepEqual(stored, languages) {
+		t.Errorf("Repository.Languages(HEAD) stored %v, want: %v", stored, languages)
+	}
+}
@@ -0,0 +1,157 @@
+package vcsview
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Kinds of the common VCS failures
+// Errors returned by Vcs and Repository methods match them by ErrorKind or errors.Is
+var (
+	// Project path has no repository
+	ErrRepositoryNotFound = errors.New("Repository not found")
+
+	// Branch, tag or commit identifier doesn't exist
+	ErrRevisionNotFound = errors.New("Revision not found")
+
+	// File or directory doesn't exist at revision
+	ErrPathNotFound = errors.New("Path not found")
+
+	// Short commit identifier or ref name matches several objects
+	ErrAmbiguousRevision = errors.New("Ambiguous revision")
+)
+
+// Error of the VCS command or lookup
+type VcsError struct {
+	// One of Err* kinds or nil for other failures
+	kind error
+
+	// Error description
+	message string
+
+	// Captured command stderr (empty for in-process lookups)
+	stderr string
+
+	// Command exit status or -1 if the command didn't exit
+	status int
+
+	// Underlying error like *exec.ExitError
+	err error
+}
+
+// Create error of the kind without command
+func newVcsError(kind error, format string, args ...interface{}) *VcsError {
+	return &VcsError{kind, fmt.Sprintf(format, args...), "", -1, nil}
+}
+
+// Create error of the failed command
+// ErrorKind detects the error kind by stderr, the command in not existent directory fails with ErrRepositoryNotFound
+func commandError(cmd *exec.Cmd, err error, stderr string, errorKind errorKindFunc) *VcsError {
+	var kind error
+
+	status := processStatus(cmd)
+
+	if _, statErr := os.Stat(cmd.Dir); cmd.Dir != "" && os.IsNotExist(statErr) {
+		kind = ErrRepositoryNotFound
+	} else if errorKind != nil && stderr != "" {
+		kind = errorKind(stderr)
+	}
+
+	return &VcsError{kind, fmt.Sprintf("Command %s failed: %v", strings.Join(cmd.Args, " "), err), stderr, status, err}
+}
+
+// Returns exit status of the finished command or -1 if it didn't exit
+func processStatus(cmd *exec.Cmd) int {
+	if cmd.ProcessState != nil {
+		if ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+			return ws.ExitStatus()
+		}
+	}
+
+	return -1
+}
+
+// Error message with the command stderr
+func (e *VcsError) Error() string {
+	if stderr := strings.TrimSpace(e.stderr); stderr != "" {
+		return e.message + ": " + stderr
+	}
+
+	return e.message
+}
+
+// Returns one of Err* kinds or nil for other failures
+func (e *VcsError) Kind() error {
+	return e.kind
+}
+
+// Returns captured stderr of the command
+func (e *VcsError) Stderr() string {
+	return e.stderr
+}
+
+// Returns command exit status or -1 if the command didn't exit
+func (e *VcsError) Status() int {
+	return e.status
+}
+
+// Check the error kind (used by errors.Is)
+func (e *VcsError) Is(target error) bool {
+	return e.kind != nil && e.kind == target
+}
+
+// Returns underlying error (used by errors.Is and errors.As)
+func (e *VcsError) Unwrap() error {
+	return e.err
+}
+
+// Returns kind of the error: one of Err* values or nil for other failures
+func ErrorKind(err error) error {
+	if e, ok := err.(*VcsError); ok {
+		return e.kind
+	}
+
+	switch err {
+	case ErrRepositoryNotFound, ErrRevisionNotFound, ErrPathNotFound, ErrAmbiguousRevision:
+		return err
+	}
+
+	return nil
+}
+
+// Check the error means missing repository, revision or path
+func IsNotFound(err error) bool {
+	switch ErrorKind(err) {
+	case ErrRepositoryNotFound, ErrRevisionNotFound, ErrPathNotFound:
+		return true
+	}
+
+	return false
+}
+
+// Buffer which keeps the first size bytes of the command stderr
+type stderrBuffer struct {
+	data []byte
+	size int
+}
+
+func (b *stderrBuffer) Write(p []byte) (int, error) {
+	if free := b.size - len(b.data); free > 0 {
+		if len(p) > free {
+			b.data = append(b.data, p[:free]...)
+		} else {
+			b.data = append(b.data, p...)
+		}
+	}
+
+	// the rest is discarded, so the command doesn't fail on write
+	return len(p), nil
+}
+
+func (b *stderrBuffer) String() string {
+	return string(b.data)
+}
@@ -0,0 +1,107 @@
+package vcsview
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestVcsError(t *testing.T) {
+	exitError := fmt.Errorf("exit status 128")
+
+	cases := []struct{
+		err *VcsError
+		wantMessage string
+		wantKind error
+	}{
+		{newVcsError(ErrRevisionNotFound, "Revision %s not found", "x"), "Revision x not found", ErrRevisionNotFound},
+		{&VcsError{ErrPathNotFound, "Command git show failed", "fatal: path 'x' does not exist in 'HEAD'\n", 128, exitError}, "Command git show failed: fatal: path 'x' does not exist in 'HEAD'", ErrPathNotFound},
+		{&VcsError{nil, "Command git show failed", "", 1, exitError}, "Command git show failed", nil},
+	}
+
+	for key, testCase := range cases {
+		if message := testCase.err.Error(); message != testCase.wantMessage {
+			t.Errorf("[%d] VcsError.Error() = %q, want: %q", key, message, testCase.wantMessage)
+		}
+
+		if kind := ErrorKind(testCase.err); kind != testCase.wantKind {
+			t.Errorf("[%d] ErrorKind(%v) = %v, want: %v", key, testCase.err, kind, testCase.wantKind)
+		}
+
+		for _, target := range []error{ErrRepositoryNotFound, ErrRevisionNotFound, ErrPathNotFound, ErrAmbiguousRevision} {
+			if is := testCase.err.Is(target); is != (target == testCase.wantKind) {
+				t.Errorf("[%d] VcsError.Is(%v) = %v, want: %v", key, target, is, !is)
+			}
+		}
+
+		if unwrapped := testCase.err.Unwrap(); unwrapped != testCase.err.err {
+			t.Errorf("[%d] VcsError.Unwrap() = %v, want: %v", key, unwrapped, testCase.err.err)
+		}
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	cases := []struct{
+		err error
+		wantKind error
+		wantNotFound bool
+	}{
+		{nil, nil, false},
+		{fmt.Errorf("Some error"), nil, false},
+		{ErrRepositoryNotFound, ErrRepositoryNotFound, true},
+		{newVcsError(ErrPathNotFound, "File not found"), ErrPathNotFound, true},
+		{newVcsError(ErrAmbiguousRevision, "Ambiguous"), ErrAmbiguousRevision, false},
+		{newVcsError(nil, "Unknown failure"), nil, false},
+	}
+
+	for key, testCase := range cases {
+		if kind := ErrorKind(testCase.err); kind != testCase.wantKind {
+			t.Errorf("[%d] ErrorKind(%v) = %v, want: %v", key, testCase.err, kind, testCase.wantKind)
+		}
+
+		if isNotFound := IsNotFound(testCase.err); isNotFound != testCase.wantNotFound {
+			t.Errorf("[%d] IsNotFound(%v) = %v, want: %v", key, testCase.err, isNotFound, testCase.wantNotFound)
+		}
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	cmd := exec.Command("git", "--version")
+	cmd.Dir = "non-existent-directory"
+
+	if err := commandError(cmd, fmt.Errorf("chdir failed"), "", nil); err.Kind() != ErrRepositoryNotFound || err.Status() != -1 {
+		t.Errorf("commandError() in non-existent directory = %v (%v, %d), want: %v", err, err.Kind(), err.Status(), ErrRepositoryNotFound)
+	}
+
+	cmd = exec.Command("sh", "-c", "echo 'fatal: failed' >&2; exit 3")
+	stderr := &stderrBuffer{size: maxStderrSize}
+	cmd.Stderr = stderr
+	runErr := cmd.Run()
+
+	err := commandError(cmd, runErr, stderr.String(), func(stderr string) error {
+		return ErrRevisionNotFound
+	})
+
+	if err.Kind() != ErrRevisionNotFound || err.Status() != 3 || err.Stderr() != "fatal: failed\n" || err.Unwrap() != runErr {
+		t.Errorf("commandError() = %v (%v, %d, %q), want: %v, 3, fatal: failed", err, err.Kind(), err.Status(), err.Stderr(), ErrRevisionNotFound)
+	}
+
+	if !strings.HasPrefix(err.Error(), "Command sh -c") {
+		t.Errorf("commandError().Error() = %q, want command description", err.Error())
+	}
+}
+
+func TestStderrBuffer(t *testing.T) {
+	b := &stderrBuffer{size: 5}
+
+	for _, data := range []string{"abc", "defgh", "ij"} {
+		if n, err := b.Write([]byte(data)); n != len(data) || err != nil {
+			t.Errorf("stderrBuffer.Write(%q) = %d, %v, want: %d, nil", data, n, err, len(data))
+		}
+	}
+
+	if s := b.String(); s != "abcde" {
+		t.Errorf("stderrBuffer.String() = %q, want: %q", s, "abcde")
+	}
+}
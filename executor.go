@@ -2,34 +2,96 @@ package vcsview
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os/exec"
 	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
 // Function for read stdout of the command
 type cmdReaderFunc func(s *bufio.Scanner)
 
+// Function for read raw stdout of the command, returned error fails the execution
+type cmdStreamFunc func(r io.Reader) error
+
 // Function for debug messages
 type DebugFunc func(m string)
 
+// Function which detects error kind (one of Err* values or nil) by the command stderr
+type errorKindFunc func(stderr string) error
+
+// Maximum size of captured stderr
+const maxStderrSize = 64 * 1024
+
+// Split function for reader of NUL-separated stdout records (-z option)
+func scanNull(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
 // Command line executor
+// Executor could also run in-process function instead of the command (for example, to send cached results)
 type Executor struct {
 	// Already created command line
 	cmd *exec.Cmd
 
+	// In-process function which runs instead of the command
+	run func() error
+
 	// Reader of stdout
 	reader cmdReaderFunc
 
+	// Reader of raw stdout which is used instead of the reader and its error
+	stream cmdStreamFunc
+	streamErr error
+
 	// Debug function
 	debugger DebugFunc
 
+	// Captured stderr of the command
+	stderr *stderrBuffer
+
+	// Detects error kind of the failed command (nil if unknown)
+	errorKind errorKindFunc
+
+	// Exit status which means empty result if stderr is empty, like git grep status 1 (0 if none)
+	emptyStatus int
+
+	// Limiter of concurrent processes (nil means no limits)
+	scheduler *Scheduler
+
+	// Stops idle persistent processes of the repository which keep its scheduler slots (nil if there are none)
+	stopIdle func(repository string)
+
+	// Receiver of the execution events (nil if disabled)
+	hooks Hooks
+
+	// Operation and repository path of the execution events
+	operation string
+	path string
+
+	// Number of stdout bytes read
+	bytes int64
+
 	// Text representation of command
 	cmdTxt string
 
-	// Command context
+	// Caller context, its cancellation kills the command with its children
+	parent context.Context
+
+	// Command context, done after the command output was read
 	ctx context.Context
 
 	// Function to stop context
@@ -70,17 +132,133 @@ func (e *Executor) read(sch chan interface{}) {
 
 	<-sch
 
-	e.reader(bufio.NewScanner(out))
+	r := countingReader{out, &e.bytes}
+	if e.stream != nil {
+		e.streamErr = e.stream(r)
+	} else {
+		e.reader(bufio.NewScanner(r))
+	}
 	e.cancel()
 }
 
+// Create error of the failed command with its stderr
+// Returns the context error if the command was cancelled
+func (e *Executor) fail(err error) error {
+	if ctxErr := e.parent.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	stderr := ""
+	if e.stderr != nil {
+		stderr = e.stderr.String()
+	}
+
+	return commandError(e.cmd, err, stderr, e.errorKind)
+}
+
+// Check if the finished command has no results instead of failure (see emptyStatus)
+func (e *Executor) empty() bool {
+	if e.emptyStatus == 0 || e.parent.Err() != nil || processStatus(e.cmd) != e.emptyStatus {
+		return false
+	}
+
+	return e.stderr != nil && strings.TrimSpace(e.stderr.String()) == ""
+}
+
+// Kill the command with its children when the caller context is done
+// Returns function to stop watching after the command exits
+func (e *Executor) watch() func() {
+	stop := make(chan struct{})
+
+	go func() {
+		select {
+		case <-e.parent.Done():
+			e.log(fmt.Sprintf("Command %s cancelled: %v", e.cmdTxt, e.parent.Err()))
+			killProcessGroup(e.cmd)
+		case <-stop:
+		}
+	}()
+
+	return func() {
+		close(stop)
+	}
+}
+
+// Send the start event to hooks
+// Returns function which sends the finish event with the execution error
+func (e *Executor) start(wait time.Duration) func(err error) {
+	if e.hooks == nil {
+		return func(err error) {}
+	}
+
+	event := CommandEvent{e.operation, e.cmdTxt, e.path, time.Now(), wait, 0, -1, 0, nil}
+	ctx := e.hooks.CommandStart(e.parent, event)
+
+	return func(err error) {
+		event.duration = time.Since(event.started)
+		event.bytes = atomic.LoadInt64(&e.bytes)
+		event.err = err
+		if e.cmd != nil {
+			event.status = processStatus(e.cmd)
+		}
+
+		e.hooks.CommandFinish(ctx, event)
+	}
+}
+
+// Set receiver of the execution events
+// Operation and path describe the execution in events, for commands they are set by NewExecutor
+func (e *Executor) observe(hooks Hooks, operation string, path string) *Executor {
+	e.hooks = hooks
+	e.operation = operation
+	e.path = path
+
+	return e
+}
+
 // Run command execution
 // This method run async stdout reader and start the command
 // To run command async start this method in goroutine
 // If command cannot by started or if command fails - returns error
+// If the caller context is done - the command is killed and its error is returned (context.Canceled or context.DeadlineExceeded)
+// Failed command returns *VcsError with captured stderr
+// Run doesn't close the result channel: all results are sent before Run returns, so the caller closes it after that
 func (e *Executor) Run() error {
+	if e.run != nil {
+		e.log(fmt.Sprintf("execute function: %s", e.cmdTxt))
+		defer e.cancel()
+
+		if err := e.parent.Err(); err != nil {
+			return err
+		}
+
+		finish := e.start(0)
+
+		err := e.run()
+		if ctxErr := e.parent.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+
+		finish(err)
+
+		return err
+	}
+
+	release, wait, err := e.schedule()
+	if err != nil {
+		e.log(fmt.Sprintf("Command %s cancelled after %v in queue: %v", e.cmdTxt, wait, err))
+		return err
+	}
+	defer release()
+
+	if wait > 0 {
+		e.log(fmt.Sprintf("Command %s waited %v in queue", e.cmdTxt, wait))
+	}
+
 	e.log(fmt.Sprintf("execute command: %s", e.cmdTxt))
 
+	finish := e.start(wait)
+
 	sch := make(chan interface{})
 
 	go e.read(sch)
@@ -90,25 +268,72 @@ func (e *Executor) Run() error {
 	if err := e.cmd.Start(); err != nil {
 		e.logCmdNonZeroStatus(err)
 		<- sch
+		err = e.fail(err)
+		finish(err)
 		return err
 	}
 
+	stop := e.watch()
+	defer stop()
+
 	<-sch
 
-	if err := e.cmd.Wait(); err != nil {
+	if err := e.cmd.Wait(); err != nil && !e.empty() {
 		e.logCmdNonZeroStatus(err)
+		err = e.fail(err)
+		finish(err)
 		return err
 	}
 
-	return nil
+	finish(e.streamErr)
+
+	return e.streamErr
+}
+
+// Get the scheduler slot for the command
+// If the scheduler has no free slots, idle processes of the repository are stopped first, so they don't keep the slots
+func (e *Executor) schedule() (func(), time.Duration, error) {
+	if e.stopIdle != nil {
+		if release, ok := e.scheduler.tryAcquire(e.cmd.Dir); ok {
+			return release, 0, nil
+		}
+
+		e.stopIdle(e.cmd.Dir)
+	}
+
+	return e.scheduler.acquire(e.parent, e.cmd.Dir)
 }
 
-func NewExecutor(cmd *exec.Cmd, reader cmdReaderFunc, debugger DebugFunc) *Executor {
+// Create executor of the command
+// The command should be created with the same context (see Cli.command), its cancellation kills the command
+func NewExecutor(ctx context.Context, cmd *exec.Cmd, reader cmdReaderFunc, debugger DebugFunc) *Executor {
 	e := new(Executor)
 	e.cmd = cmd
 	e.reader = reader
 	e.debugger = debugger
 	e.cmdTxt = strings.Join(cmd.Args, " ")
-	e.ctx, e.cancel = context.WithCancel(context.Background())
+	e.operation = commandOperation(cmd.Args)
+	e.path = cmd.Dir
+	e.parent = ctx
+	e.ctx, e.cancel = context.WithCancel(ctx)
+
+	if cmd.Stderr == nil {
+		e.stderr = &stderrBuffer{size: maxStderrSize}
+		cmd.Stderr = e.stderr
+	}
+
+	return e
+}
+
+// Create executor which runs in-process function instead of the command
+// Name is a text representation of the function for debug messages
+// Function should check the context itself to stop early
+func NewFuncExecutor(ctx context.Context, name string, run func() error, debugger DebugFunc) *Executor {
+	e := new(Executor)
+	e.run = run
+	e.debugger = debugger
+	e.cmdTxt = name
+	e.parent = ctx
+	e.ctx, e.cancel = context.WithCancel(ctx)
 	return e
-}
\ No newline at end of file
+}
@@ -3,9 +3,11 @@ package vcsview
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"os/exec"
 	"regexp"
 	"testing"
+	"time"
 )
 
 func TestNewExecutor(t *testing.T) {
@@ -27,7 +29,7 @@ func TestNewExecutor(t *testing.T) {
 		debugResult += msg
 	})
 
-	e := NewExecutor(cmd, reader, debugger)
+	e := NewExecutor(context.Background(), cmd, reader, debugger)
 
 	buf := new(bytes.Buffer)
 	s := bufio.NewScanner(buf)
@@ -84,10 +86,10 @@ func TestExecutor_LogCmdNonZeroStatus(t *testing.T) {
 
 		})
 
-		c := Cli{v.cmd, debugger}
-		cmd := c.command(".", v.args...)
+		c := Cli{v.cmd, debugger, nil, nil, nil}
+		cmd := c.command(context.Background(), ".", v.args...)
 
-		e := NewExecutor(cmd, reader, debugger)
+		e := NewExecutor(context.Background(), cmd, reader, debugger)
 
 		e.logCmdNonZeroStatus(v.err)
 
@@ -147,10 +149,10 @@ func TestExecutor_Run(t *testing.T) {
 			gotLog += msg
 		})
 
-		c := Cli{testCase.cmd, debugger}
-		cmd := c.command(testCase.dir, testCase.params...)
+		c := Cli{testCase.cmd, debugger, nil, nil, nil}
+		cmd := c.command(context.Background(), testCase.dir, testCase.params...)
 
-		e := NewExecutor(cmd, reader, debugger)
+		e := NewExecutor(context.Background(), cmd, reader, debugger)
 
 		err := e.Run()
 
@@ -185,3 +187,98 @@ func TestExecutor_Run(t *testing.T) {
 		}
 	}
 }
+
+func TestNewFuncExecutor(t *testing.T) {
+	debugResult := ""
+	debugger := DebugFunc(func(msg string) {
+		debugResult += msg
+	})
+
+	calls := 0
+	e := NewFuncExecutor(context.Background(), "testing", func() error {
+		calls++
+		return nil
+	}, debugger)
+
+	if err := e.Run(); err != nil {
+		t.Errorf("Executor.Run() = %v, want no errors", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("Executor.Run() called function %d times, want: 1", calls)
+	}
+
+	if debugResult != "execute function: testing" {
+		t.Errorf("debugResult = %v, want: execute function: testing", debugResult)
+	}
+
+	select {
+	case <-e.ctx.Done():
+	default:
+		t.Errorf("Executor.Run() didn't cancel context")
+	}
+}
+
+func TestExecutor_RunCancel(t *testing.T) {
+	cases := []struct{
+		timeout time.Duration
+		params []string
+		want error
+	}{
+		// children hold stdout, so the reader gets EOF only if they are killed too
+		{100 * time.Millisecond, []string{"-c", "sleep 10 | cat"}, context.DeadlineExceeded},
+		{100 * time.Millisecond, []string{"-c", "sleep 10"}, context.DeadlineExceeded},
+		{0, []string{"-c", "echo ok"}, context.DeadlineExceeded},
+		{10 * time.Second, []string{"-c", "echo ok"}, nil},
+	}
+
+	for key, testCase := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), testCase.timeout)
+
+		c := Cli{"sh", nil, nil, nil, nil}
+		cmd := c.command(ctx, ".", testCase.params...)
+
+		e := NewExecutor(ctx, cmd, cmdReaderFunc(func(s *bufio.Scanner) {
+			for s.Scan() {
+			}
+		}), nil)
+
+		start := time.Now()
+		err := e.Run()
+
+		if err != testCase.want {
+			t.Errorf("[%d] Executor.Run(%v) = %v, want: %v", key, testCase.params, err, testCase.want)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("[%d] Executor.Run(%v) took %v, want the command killed", key, testCase.params, elapsed)
+		}
+
+		cancel()
+	}
+}
+
+func TestNewFuncExecutor_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	e := NewFuncExecutor(ctx, "testing", func() error {
+		calls++
+		return nil
+	}, nil)
+
+	if err := e.Run(); err != context.Canceled || calls != 0 {
+		t.Errorf("Executor.Run() with cancelled context = %v and %d calls, want: %v and no calls", err, calls, context.Canceled)
+	}
+
+	// function which stops on cancellation gets the context error
+	ctx, cancel = context.WithCancel(context.Background())
+	e = NewFuncExecutor(ctx, "testing", func() error {
+		cancel()
+		return nil
+	}, nil)
+
+	if err := e.Run(); err != context.Canceled {
+		t.Errorf("Executor.Run() cancelled while running = %v, want: %v", err, context.Canceled)
+	}
+}
@@ -1,12 +1,26 @@
 package vcsview
 
 import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
 const (
 	pathSeparator = string(os.PathSeparator)
+
+	// Number of first content bytes to detect binary files (the same as git does)
+	binarySniffLength = 8000
+
+	// Content type of unknown binary content
+	binaryMimeType = "application/octet-stream"
+
+	// Content type of text content forced by attributes
+	textMimeType = "text/plain; charset=utf-8"
 )
 
 type FileStatus string
@@ -22,6 +36,29 @@ const(
 	FileUnknownStatus FileStatus = "X"
 )
 
+// Kind of file content
+type ContentKind string
+
+const(
+	ContentUnknown ContentKind = ""
+	ContentText ContentKind = "text"
+	ContentBinary ContentKind = "binary"
+	ContentImage ContentKind = "image"
+)
+
+// JSON representation of the file
+type fileJSON struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Pathname string `json:"pathname"`
+	IsDir bool `json:"is_dir"`
+	IsExists bool `json:"is_exists"`
+	Size int64 `json:"size"`
+	Mode uint32 `json:"mode"`
+	ContentKind ContentKind `json:"content_kind,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+}
+
 // Project file with relative path
 type File struct {
 	// File name
@@ -41,6 +78,12 @@ type File struct {
 
 	// File access mode
 	mode os.FileMode
+
+	// Kind of file content (empty for directories and not sniffed files)
+	contentKind ContentKind
+
+	// MIME type of file content
+	mimeType string
 }
 
 // Get file name (without file path)
@@ -82,6 +125,135 @@ func (f File) Mode() os.FileMode {
 	return f.mode
 }
 
+// Returns kind of file content (text, binary or image)
+// Returns ContentUnknown for directories and files which content wasn't read
+func (f File) ContentKind() ContentKind {
+	return f.contentKind
+}
+
+// Returns MIME type of file content
+func (f File) MimeType() string {
+	return f.mimeType
+}
+
+// Returns true if file content is binary (images are binary too)
+func (f File) IsBinary() bool {
+	return f.contentKind == ContentBinary || f.contentKind == ContentImage
+}
+
+// Returns true if file content is an image
+func (f File) IsImage() bool {
+	return f.contentKind == ContentImage
+}
+
+// Detect file content kind and MIME type by the first content bytes
+func (f *File) detectContent(data []byte) {
+	f.contentKind, f.mimeType = detectContent(f.name, data)
+}
+
+// Override detected content kind by text, -text and binary attributes
+// Text with value (like text=auto) keeps the detected content kind as well as git decides itself
+func (f *File) applyAttributes(a Attributes) {
+	if f.isDir || f.contentKind == ContentUnknown {
+		return
+	}
+
+	switch {
+	case a.IsUnset(f.Pathname(), "text") && f.contentKind == ContentText:
+		f.contentKind = ContentBinary
+		if strings.HasPrefix(f.mimeType, "text/plain") {
+			f.mimeType = binaryMimeType
+		}
+	case a.IsSet(f.Pathname(), "text") && f.contentKind == ContentBinary:
+		f.contentKind = ContentText
+		if f.mimeType == binaryMimeType {
+			f.mimeType = textMimeType
+		}
+	}
+}
+
+// Detect content kind and MIME type by file name and the first content bytes
+// Content with NUL byte is binary as well as git decides
+// Content type by extension is preferred for text files (css, js, svg, etc.)
+func detectContent(name string, data []byte) (ContentKind, string) {
+	sniffed := http.DetectContentType(data)
+	byExt := mime.TypeByExtension(filepath.Ext(name))
+
+	if strings.HasPrefix(sniffed, "image/") {
+		return ContentImage, sniffed
+	}
+
+	if strings.HasPrefix(byExt, "image/") {
+		return ContentImage, byExt
+	}
+
+	if len(data) > binarySniffLength {
+		data = data[:binarySniffLength]
+	}
+
+	if bytes.IndexByte(data, 0) >= 0 {
+		if byExt != "" && !strings.HasPrefix(byExt, "text/") {
+			return ContentBinary, byExt
+		}
+		if strings.HasPrefix(sniffed, "text/") {
+			return ContentBinary, binaryMimeType
+		}
+		return ContentBinary, sniffed
+	}
+
+	if byExt != "" {
+		return ContentText, byExt
+	}
+
+	if !strings.HasPrefix(sniffed, "text/") && sniffed != binaryMimeType {
+		// documents which has text signature like pdf or postscript
+		return ContentBinary, sniffed
+	}
+
+	return ContentText, textMimeType
+}
+
+// Encode file to JSON object
+// Mode is encoded as a number with permission and type bits of os.FileMode,
+// pathname is included for convenience and ignored while decoding
+func (f File) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fileJSON{
+		f.name,
+		f.path,
+		f.Pathname(),
+		f.isDir,
+		f.isExists,
+		f.size,
+		uint32(f.mode),
+		f.contentKind,
+		f.mimeType,
+	})
+}
+
+// Decode file from JSON object
+func (f *File) UnmarshalJSON(data []byte) error {
+	var v fileJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*f = File{v.Name, v.Path, v.IsDir, v.IsExists, v.Size, os.FileMode(v.Mode), v.ContentKind, v.MimeType}
+
+	return nil
+}
+
+// Create new file for revision tree list
+// Pathname is a relative file pathname separated by slashes
+func NewFileFromTree(pathname string, isDir bool, size int64, mode os.FileMode) File {
+	dir, name := "", pathname
+	if pos := strings.LastIndex(pathname, "/"); pos >= 0 {
+		dir, name = pathname[:pos], pathname[pos+1:]
+	}
+
+	return File{name, dir, isDir, true, size, mode, ContentUnknown, ""}
+}
+
 // Create new file for project repository list
 // In this case file should exist on the disk
 // relativePath is relative path, where file located
@@ -90,6 +262,6 @@ func NewFileFromProjectList(i os.FileInfo, relativePath string) File {
 		relativePath = ""
 	}
 
-	f := File{i.Name(), relativePath, i.IsDir(), true, i.Size(), i.Mode()}
+	f := File{i.Name(), relativePath, i.IsDir(), true, i.Size(), i.Mode(), ContentUnknown, ""}
 	return f
 }
\ No newline at end of file
@@ -0,0 +1,31 @@
+package vcsview
+
+// Represents a commit of the file history together with the file state in this commit
+type FileCommit struct {
+	Commit
+
+	// File status in the commit (added, modified, renamed, etc.)
+	status FileStatus
+
+	// Relative file pathname in the commit
+	pathname string
+
+	// Relative file pathname before the commit (only for renamed and copied files)
+	previousPathname string
+}
+
+// Get file status in the commit
+func (c FileCommit) Status() FileStatus {
+	return c.status
+}
+
+// Get relative file pathname the file had in the commit
+func (c FileCommit) Pathname() string {
+	return c.pathname
+}
+
+// Get relative file pathname the file had before the commit
+// Returns empty string if file wasn't renamed or copied in the commit
+func (c FileCommit) PreviousPathname() string {
+	return c.previousPathname
+}
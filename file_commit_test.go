@@ -0,0 +1,36 @@
+package vcsview
+
+import "testing"
+
+func TestFileCommit_Status(t *testing.T) {
+	c := FileCommit{}
+	c.status = FileRenamed
+
+	if status := c.Status(); status != FileRenamed {
+		t.Errorf("FileCommit.Status() = %v, want: %v", status, FileRenamed)
+	}
+}
+
+func TestFileCommit_Pathname(t *testing.T) {
+	cases := []struct{
+		pathname string
+		previousPathname string
+	}{
+		{"testpath/empty.txt", ""},
+		{"testpath/moved.txt", "testpath/empty.txt"},
+	}
+
+	for key, testCase := range cases {
+		c := FileCommit{}
+		c.pathname = testCase.pathname
+		c.previousPathname = testCase.previousPathname
+
+		if pathname := c.Pathname(); pathname != testCase.pathname {
+			t.Errorf("[%d] FileCommit.Pathname() = %v, want: %v", key, pathname, testCase.pathname)
+		}
+
+		if pathname := c.PreviousPathname(); pathname != testCase.previousPathname {
+			t.Errorf("[%d] FileCommit.PreviousPathname() = %v, want: %v", key, pathname, testCase.previousPathname)
+		}
+	}
+}
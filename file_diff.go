@@ -0,0 +1,116 @@
+package vcsview
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// JSON representation of the file changes
+type fileDiffJSON struct {
+	Status FileStatus `json:"status"`
+	Pathname string `json:"pathname"`
+	PreviousPathname string `json:"previous_pathname,omitempty"`
+	IsBinary bool `json:"is_binary"`
+	Diff string `json:"diff"`
+}
+
+// Represents changes of the single file in the commit
+type FileDiff struct {
+	// File status in the commit (added, modified, renamed, etc.)
+	status FileStatus
+
+	// Relative file pathname after the commit
+	pathname string
+
+	// Relative file pathname before the commit (only for renamed and copied files)
+	previousPathname string
+
+	// True if file is binary and diff has no hunks
+	isBinary bool
+
+	// Unified diff of the file (headers and hunks)
+	diff string
+}
+
+// Create file changes with unified diff of the file contents like git show does
+// Empty previous pathname means added file, empty pathname means deleted one, different pathnames mean renamed file
+// Files are regular ones (100644 mode), binary contents have no hunks
+func NewFileDiffFromContents(previousPathname string, pathname string, previousContent []byte, content []byte) FileDiff {
+	c := nativeChange{FileModified, previousPathname, pathname, "100644", "100644", GitBlobId(previousContent), GitBlobId(content), 0}
+
+	switch {
+	case previousPathname == "":
+		c.status, c.oldMode, c.oldId = FileAdded, "", ""
+	case pathname == "":
+		c.status, c.newMode, c.newId = FileDeleted, "", ""
+	case previousPathname != pathname:
+		c.status, c.similarity = FileRenamed, nativeSimilarity(previousContent, content)
+	}
+
+	var patch bytes.Buffer
+	writeNativePatch(&patch, c, previousContent, content)
+
+	// patch has the same format as git output, so it's parsed the same way
+	result := make(chan FileDiff, 1)
+	(&Git{}).readDiffPipe(bufio.NewScanner(&patch), result)
+
+	return <-result
+}
+
+// Returns git object identifier of the blob content
+func GitBlobId(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get file status in the commit
+func (d FileDiff) Status() FileStatus {
+	return d.status
+}
+
+// Get relative file pathname after the commit
+// For deleted files returns pathname before the commit
+func (d FileDiff) Pathname() string {
+	return d.pathname
+}
+
+// Get relative file pathname before the commit
+// Returns empty string if file wasn't renamed or copied
+func (d FileDiff) PreviousPathname() string {
+	return d.previousPathname
+}
+
+// Returns true if file is binary
+func (d FileDiff) IsBinary() bool {
+	return d.isBinary
+}
+
+// Get unified diff of the file
+func (d FileDiff) Diff() string {
+	return d.diff
+}
+
+// Encode file changes to JSON object
+func (d FileDiff) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fileDiffJSON{d.status, d.pathname, d.previousPathname, d.isBinary, d.diff})
+}
+
+// Decode file changes from JSON object
+func (d *FileDiff) UnmarshalJSON(data []byte) error {
+	var v fileDiffJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*d = FileDiff{v.Status, v.Pathname, v.PreviousPathname, v.IsBinary, v.Diff}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package vcsview
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFileDiff(t *testing.T) {
+	d := FileDiff{}
+	d.status = FileRenamed
+	d.pathname = "testpath/moved.txt"
+	d.previousPathname = "testpath/random.txt"
+	d.isBinary = true
+	d.diff = "diff --git a/testpath/random.txt b/testpath/moved.txt\n"
+
+	if status := d.Status(); status != FileRenamed {
+		t.Errorf("FileDiff.Status() = %v, want: %v", status, FileRenamed)
+	}
+
+	if pathname := d.Pathname(); pathname != "testpath/moved.txt" {
+		t.Errorf("FileDiff.Pathname() = %v, want: %v", pathname, "testpath/moved.txt")
+	}
+
+	if pathname := d.PreviousPathname(); pathname != "testpath/random.txt" {
+		t.Errorf("FileDiff.PreviousPathname() = %v, want: %v", pathname, "testpath/random.txt")
+	}
+
+	if !d.IsBinary() {
+		t.Errorf("FileDiff.IsBinary() = false, want: true")
+	}
+
+	if diff := d.Diff(); diff != "diff --git a/testpath/random.txt b/testpath/moved.txt\n" {
+		t.Errorf("FileDiff.Diff() = %v, want: %v", diff, "diff --git a/testpath/random.txt b/testpath/moved.txt\n")
+	}
+}
+
+func TestFileDiff_MarshalJSON(t *testing.T) {
+	cases := []struct{
+		diff FileDiff
+		want string
+	}{
+		{
+			FileDiff{FileModified, "testing.txt", "", false, "@@ -1 +1 @@\n"},
+			`{"status":"M","pathname":"testing.txt","is_binary":false,"diff":"@@ -1 +1 @@\n"}`,
+		},
+		{
+			FileDiff{FileRenamed, "testpath/moved.txt", "testpath/random.txt", true, ""},
+			`{"status":"R","pathname":"testpath/moved.txt","previous_pathname":"testpath/random.txt","is_binary":true,"diff":""}`,
+		},
+	}
+
+	for key, testCase := range cases {
+		data, err := json.Marshal(testCase.diff)
+		if err != nil || string(data) != testCase.want {
+			t.Errorf("[%d] FileDiff.MarshalJSON() = %s, %v, want: %s", key, data, err, testCase.want)
+		}
+
+		var d FileDiff
+		if err := json.Unmarshal(data, &d); err != nil || d != testCase.diff {
+			t.Errorf("[%d] FileDiff.UnmarshalJSON(%s) = %v, %v, want: %v", key, data, d, err, testCase.diff)
+		}
+	}
+}
+
+func TestNewFileDiffFromContents(t *testing.T) {
+	cases := []struct{
+		previousPathname string
+		pathname string
+		previousContent string
+		content string
+		want FileDiff
+	}{
+		{
+			"", "new.txt", "", "first\n",
+			FileDiff{FileAdded, "new.txt", "", false, "diff --git a/new.txt b/new.txt\nnew file mode 100644\nindex 0000000..9c59e24\n--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+first\n"},
+		},
+		{
+			"old.txt", "", "first\n", "",
+			FileDiff{FileDeleted, "old.txt", "", false, "diff --git a/old.txt b/old.txt\ndeleted file mode 100644\nindex 9c59e24..0000000\n--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-first\n"},
+		},
+		{
+			"file.txt", "file.txt", "first\nsecond\n", "first\nthird",
+			FileDiff{FileModified, "file.txt", "", false, "diff --git a/file.txt b/file.txt\nindex 66a52ee..45bacb1 100644\n--- a/file.txt\n+++ b/file.txt\n@@ -1,2 +1,2 @@\n first\n-second\n+third\n\\ No newline at end of file\n"},
+		},
+		{
+			"old.txt", "new.txt", "first\n", "first\n",
+			FileDiff{FileRenamed, "new.txt", "old.txt", false, "diff --git a/old.txt b/new.txt\nsimilarity index 100%\nrename from old.txt\nrename to new.txt\n"},
+		},
+		{
+			"image.png", "image.png", "\x00\x01", "\x00\x02",
+			FileDiff{FileModified, "image.png", "", true, "diff --git a/image.png b/image.png\nindex bdc955b..8835708 100644\nBinary files a/image.png and b/image.png differ\n"},
+		},
+	}
+
+	for key, testCase := range cases {
+		d := NewFileDiffFromContents(testCase.previousPathname, testCase.pathname, []byte(testCase.previousContent), []byte(testCase.content))
+		if d != testCase.want {
+			t.Errorf("[%d] NewFileDiffFromContents(%q, %q) = %#v, want: %#v", key, testCase.previousPathname, testCase.pathname, d, testCase.want)
+		}
+	}
+}
+
+func TestGitBlobId(t *testing.T) {
+	cases := []struct{
+		content string
+		want string
+	}{
+		{"", "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"},
+		{"first\n", "9c59e24b8393179a5d712de4f990178df5734d99"},
+	}
+
+	for key, testCase := range cases {
+		if id := GitBlobId([]byte(testCase.content)); id != testCase.want {
+			t.Errorf("[%d] GitBlobId(%q) = %v, want: %v", key, testCase.content, id, testCase.want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package vcsview
 
 import (
+	"context"
+	"encoding/json"
 	"os"
 	"testing"
 )
@@ -191,7 +193,7 @@ func TestGit_StatusRepository(t *testing.T) {
 	}
 
 	for key, testCase := range cases {
-		result, err := git.StatusRepository(testCase.projectPath)
+		result, err := git.StatusRepository(context.Background(), testCase.projectPath)
 
 		if err != nil && !testCase.gotError {
 			t.Errorf("[%d] Git.StatusRepository(%s) = %v, %v, want no errors", key, testCase.projectPath, result, err)
@@ -202,3 +204,122 @@ func TestGit_StatusRepository(t *testing.T) {
 		}
 	}
 }
+
+func TestFile_ContentKind(t *testing.T) {
+	cases := []struct{
+		kind ContentKind
+		isBinary bool
+		isImage bool
+	}{
+		{ContentUnknown, false, false},
+		{ContentText, false, false},
+		{ContentBinary, true, false},
+		{ContentImage, true, true},
+	}
+
+	for key, testCase := range cases {
+		f := File{}
+		f.contentKind = testCase.kind
+		f.mimeType = "testing/type"
+
+		if kind := f.ContentKind(); kind != testCase.kind {
+			t.Errorf("[%d] File.ContentKind() = %v, want: %v", key, kind, testCase.kind)
+		}
+		if isBinary := f.IsBinary(); isBinary != testCase.isBinary {
+			t.Errorf("[%d] File.IsBinary() = %v, want: %v", key, isBinary, testCase.isBinary)
+		}
+		if isImage := f.IsImage(); isImage != testCase.isImage {
+			t.Errorf("[%d] File.IsImage() = %v, want: %v", key, isImage, testCase.isImage)
+		}
+		if mimeType := f.MimeType(); mimeType != "testing/type" {
+			t.Errorf("[%d] File.MimeType() = %v, want: %v", key, mimeType, "testing/type")
+		}
+	}
+}
+
+func TestDetectContent(t *testing.T) {
+	png := "\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR"
+
+	cases := []struct{
+		name string
+		data string
+		kind ContentKind
+		mimeType string
+	}{
+		{"empty.txt", "", ContentText, "text/plain; charset=utf-8"},
+		{"Makefile", "all:\n\tgo build\n", ContentText, "text/plain; charset=utf-8"},
+		{"image", png, ContentImage, "image/png"},
+		{"image.png", png, ContentImage, "image/png"},
+		{"logo.svg", "<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>", ContentImage, "image/svg+xml"},
+		{"data.bin", "abc\x00def", ContentBinary, "application/octet-stream"},
+		{"index.html", "<html></html>", ContentText, "text/html; charset=utf-8"},
+	}
+
+	for key, testCase := range cases {
+		kind, mimeType := detectContent(testCase.name, []byte(testCase.data))
+
+		if kind != testCase.kind {
+			t.Errorf("[%d] detectContent(%s) kind = %v, want: %v", key, testCase.name, kind, testCase.kind)
+		}
+
+		if mimeType != testCase.mimeType {
+			t.Errorf("[%d] detectContent(%s) mime type = %v, want: %v", key, testCase.name, mimeType, testCase.mimeType)
+		}
+	}
+}
+
+func TestFile_ApplyAttributes(t *testing.T) {
+	a := Attributes{}
+	a.Add("", "* text=auto\n*.dat binary\n*.bin text\n")
+
+	cases := []struct{
+		name string
+		data string
+		kind ContentKind
+	}{
+		{"data.dat", "text content", ContentBinary},
+		{"data.bin", "abc\x00def", ContentText},
+		{"data.txt", "abc\x00def", ContentBinary},
+		{"data.txt", "text content", ContentText},
+		{"archive.zip", "PK\x03\x04\x14\x00\x00\x00\x08\x00", ContentBinary},
+	}
+
+	for key, testCase := range cases {
+		f := File{}
+		f.name = testCase.name
+		f.detectContent([]byte(testCase.data))
+		f.applyAttributes(a)
+
+		if kind := f.ContentKind(); kind != testCase.kind {
+			t.Errorf("[%d] File.applyAttributes() for %s kind = %v, want: %v", key, testCase.name, kind, testCase.kind)
+		}
+	}
+}
+
+func TestFile_MarshalJSON(t *testing.T) {
+	cases := []struct{
+		file File
+		want string
+	}{
+		{
+			File{"empty.txt", "testpath", false, true, 0, 0644, ContentText, "text/plain; charset=utf-8"},
+			`{"name":"empty.txt","path":"testpath","pathname":"testpath/empty.txt","is_dir":false,"is_exists":true,"size":0,"mode":420,"content_kind":"text","mime_type":"text/plain; charset=utf-8"}`,
+		},
+		{
+			File{"testpath", "", true, true, 0, os.ModeDir | 0755, ContentUnknown, ""},
+			`{"name":"testpath","path":"","pathname":"testpath","is_dir":true,"is_exists":true,"size":0,"mode":2147484141}`,
+		},
+	}
+
+	for key, testCase := range cases {
+		data, err := json.Marshal(testCase.file)
+		if err != nil || string(data) != testCase.want {
+			t.Errorf("[%d] File.MarshalJSON() = %s, %v, want: %s", key, data, err, testCase.want)
+		}
+
+		var f File
+		if err := json.Unmarshal(data, &f); err != nil || f != testCase.file {
+			t.Errorf("[%d] File.UnmarshalJSON(%s) = %v, %v, want: %v", key, data, f, err, testCase.file)
+		}
+	}
+}
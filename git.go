@@ -2,11 +2,17 @@ package vcsview
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/mail"
 	"os"
 	"os/exec"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -14,16 +20,167 @@ import (
 const (
 	gitLogFormat = "%H%n%P%n%an%n%ae%n%ad%n%s"
 	gitLogDateLayout = "Mon Jan 2 15:04:05 2006 -0700"
+
+	// Separates commits in the log output which contains diffs (%x1e format placeholder)
+	gitRecordSeparator = "\x1e"
+
+	// Maximum line length of the output which contains file contents (minified sources could have a very long lines)
+	gitMaxLineSize = 10 * 1024 * 1024
+)
+
+var (
+	// log --name-status line: status letter, similarity score, pathname and new pathname for renames and copies
+	gitNameStatusPattern = regexp.MustCompile(`^([ACDMRTUX])(\d*)\t([^\t]+)(?:\t([^\t]+))?$`)
+
+	// format-patch writes such line before each patch of the mbox stream
+	gitPatchSeparatorPattern = regexp.MustCompile(`^From ([a-fA-F0-9]{40,64}) Mon Sep 17 00:00:00 2001$`)
+
+	// [PATCH], [PATCH 1/3] or [PATCH v2 1/3] subject prefixes
+	gitPatchSubjectPrefixPattern = regexp.MustCompile(`^\[PATCH[^\]]*\]\s*`)
+
+	// stderr messages of the failed commands by error kinds (checked in order)
+	gitErrorPatterns = []struct{
+		kind error
+		pattern *regexp.Regexp
+	}{
+		{ErrRepositoryNotFound, regexp.MustCompile(`(?i)not a git repository`)},
+		{ErrAmbiguousRevision, regexp.MustCompile(`short (SHA1|object ID) \S+ is ambiguous`)},
+		{ErrPathNotFound, regexp.MustCompile(`path '.*' (does not exist|exists on disk, but not) in|There is no path \S+ in the commit`)},
+		{ErrRevisionNotFound, regexp.MustCompile(`(?i)unknown revision|bad revision|bad object|invalid object name|not a valid object name|needed a single revision|ambiguous argument`)},
+	}
 )
 
 // CLI wrapper for GIT
 type Git struct {
 	Cli
+
+	// Persistent cat-file processes for commits, trees and blobs lookups
+	// Without pool each one lookup starts its own process
+	batch *catFilePool
+}
+
+// Options of the git commands
+type GitOptions struct {
+	// Path of the git executable (empty means git from PATH)
+	Path string
+
+	// Additional environment variables as KEY=value list, override the controlled ones
+	Env []string
+
+	// Configuration overrides as key=value list passed by -c to each command
+	// E.g. safe.directory=* allows repositories of other owners
+	Overrides []string
+
+	// Run commands with the server environment and user configuration instead of the controlled ones
+	InheritEnv bool
+}
+
+// Create CLI wrapper for GIT using git command from PATH
+// Commits, trees and blobs are read by persistent cat-file processes, use Close to stop them
+func NewGit() Git {
+	return NewGitWithOptions(GitOptions{})
+}
+
+// Create CLI wrapper for GIT with options
+// By default commands run with C locale, without optional locks, system and user configuration,
+// so the output is parsed the same way everywhere and reading never takes repository locks
+func NewGitWithOptions(options GitOptions) Git {
+	cmd := options.Path
+	if cmd == "" {
+		cmd = "git"
+	}
+
+	settings := &cliSettings{}
+	if !options.InheritEnv {
+		settings.env = gitEnvironment(options.Env)
+	} else if len(options.Env) > 0 {
+		settings.env = append(os.Environ(), options.Env...)
+	}
+
+	g := Git{Cli{cmd, nil, nil, nil, settings}, newCatFilePool(catFileMaxProcesses, catFileIdleTimeout)}
+
+	return g.WithOverrides(options.Overrides...)
+}
+
+// Returns copy of the wrapper which passes configuration overrides (key=value) to each command
+// The copy shares cat-file pool, debugger and scheduler with the original
+func (g Git) WithOverrides(overrides ...string) Git {
+	if len(overrides) == 0 {
+		return g
+	}
+
+	args := make([]string, 0, len(overrides) * 2)
+	for _, o := range overrides {
+		args = append(args, "-c", o)
+	}
+	g.settings = g.settings.withArgs(args...)
+
+	return g
+}
+
+// Controlled environment of the git commands with extra variables
+// Only variables required to start processes are taken from the server environment
+func gitEnvironment(extra []string) []string {
+	env := make([]string, 0, 16)
+
+	for _, name := range []string{"PATH", "TMPDIR", "SYSTEMROOT"} {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name + "=" + value)
+		}
+	}
+
+	env = append(env,
+		// messages and dates aren't translated
+		"LC_ALL=C",
+		"LANG=C",
+		// status and diff don't refresh the index, so reading doesn't take index.lock
+		"GIT_OPTIONAL_LOCKS=0",
+		// no system and user configuration, so aliases, pagers and formats are defaults
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL=" + os.DevNull,
+		"HOME=" + os.DevNull,
+		"XDG_CONFIG_HOME=" + os.DevNull,
+		// never wait for credentials
+		"GIT_TERMINAL_PROMPT=0",
+	)
+
+	return append(env, extra...)
+}
+
+// Detect error kind by stderr of the failed git command
+func gitErrorKind(stderr string) error {
+	for _, p := range gitErrorPatterns {
+		if p.pattern.MatchString(stderr) {
+			return p.kind
+		}
+	}
+
+	return nil
+}
+
+// Create executor which detects error kinds of the failed command
+// The command waiting for the scheduler slot stops idle cat-file processes of the repository
+func (g *Git) executor(ctx context.Context, cmd *exec.Cmd, reader cmdReaderFunc) *Executor {
+	e := g.Cli.executor(ctx, cmd, reader)
+	e.errorKind = gitErrorKind
+	if g.batch != nil {
+		e.stopIdle = g.batch.evict
+	}
+
+	return e
+}
+
+// Create executor instance which reads raw stdout of the command
+func (g *Git) streamExecutor(ctx context.Context, cmd *exec.Cmd, stream cmdStreamFunc) *Executor {
+	e := g.executor(ctx, cmd, nil)
+	e.stream = stream
+
+	return e
 }
 
 // add specific params to command
-func (g *Git) createCommand(dir string, params ...string) *exec.Cmd {
-	return g.Cli.command(dir, append([]string{"--no-pager"}, params...)...)
+func (g *Git) createCommand(ctx context.Context, dir string, params ...string) *exec.Cmd {
+	return g.Cli.command(ctx, dir, append([]string{"--no-pager"}, params...)...)
 }
 
 // Returns repository settings pathname
@@ -34,7 +191,7 @@ func (g Git) RepositoryPathname() string {
 
 // Check Git version
 // returns error if git command not found, or it hasn't version arguments
-func (g Git) Version() (string, error) {
+func (g Git) Version(ctx context.Context) (string, error) {
 	versionPattern := regexp.MustCompile(`([\d]+\.?([\d]+)?\.([\d]+)?)`)
 
 	var (
@@ -42,7 +199,7 @@ func (g Git) Version() (string, error) {
 		done = make(chan interface{}, 1)
 	)
 
-	cmd := g.createCommand(".", "--version")
+	cmd := g.createCommand(ctx, ".", "--version")
 	reader := cmdReaderFunc(func(s *bufio.Scanner) {
 		for s.Scan() {
 			result += s.Text() + "\n"
@@ -51,7 +208,7 @@ func (g Git) Version() (string, error) {
 		done <- struct{}{}
 	})
 
-	e := g.executor(cmd, reader)
+	e := g.executor(ctx, cmd, reader)
 
 	err := e.Run()
 
@@ -72,11 +229,11 @@ func (g Git) CheckRepository(projectPath string) error {
 	stats, err := os.Stat(repoPath)
 
 	if err != nil {
-		return err
+		return &VcsError{ErrRepositoryNotFound, fmt.Sprintf("Git repository not found here: %s", projectPath), "", -1, err}
 	}
 
 	if !stats.IsDir() {
-		return fmt.Errorf("Git repository not found here: %s", projectPath)
+		return newVcsError(ErrRepositoryNotFound, "Git repository not found here: %s", projectPath)
 	}
 
 	return nil
@@ -84,13 +241,13 @@ func (g Git) CheckRepository(projectPath string) error {
 
 // Check the repository status
 // Throws an error if repository doesnt exists at the path
-func (g Git) StatusRepository(projectPath string) (string, error) {
+func (g Git) StatusRepository(ctx context.Context, projectPath string) (string, error) {
 	var (
 		result string
 		done = make(chan interface{}, 1)
 	)
 
-	cmd := g.createCommand(projectPath, "status", "--short")
+	cmd := g.createCommand(ctx, projectPath, "status", "--short")
 	reader := cmdReaderFunc(func(s *bufio.Scanner) {
 		for s.Scan() {
 			result += s.Text()+"\n"
@@ -99,7 +256,7 @@ func (g Git) StatusRepository(projectPath string) (string, error) {
 		done <- struct{}{}
 	})
 
-	e := g.executor(cmd, reader)
+	e := g.executor(ctx, cmd, reader)
 
 	err := e.Run()
 
@@ -108,11 +265,11 @@ func (g Git) StatusRepository(projectPath string) (string, error) {
 
 // Fetch repository branches asynchronously
 // ProjectPath is the absolute path to project with Git repository
-func (g Git) ReadBranches(projectPath string, result chan Branch) *Executor {
+func (g Git) ReadBranches(ctx context.Context, projectPath string, result chan Branch) *Executor {
 	// pattern to read branches line by line
 	p := regexp.MustCompile(`^\*?[\s+|\t]+(?P<id>[^\s]+)[\s+|\t]+(?P<head>[a-fA-F0-9]+)[\s+|\t]+(?P<message>.*)$`)
 
-	cmd := g.createCommand(projectPath, "branch", "-a", "-v")
+	cmd := g.createCommand(ctx, projectPath, "branch", "-a", "-v")
 	reader := cmdReaderFunc(func(s *bufio.Scanner) {
 		for s.Scan() {
 			line := s.Bytes()
@@ -131,7 +288,173 @@ func (g Git) ReadBranches(projectPath string, result chan Branch) *Executor {
 		}
 	})
 
-	return g.executor(cmd, reader)
+	return g.executor(ctx, cmd, reader)
+}
+
+// Create commit from gitLogFormat lines
+func (g *Git) parseCommit(data []string) Commit {
+	date, _ := time.Parse(gitLogDateLayout, data[4])
+
+	return Commit{
+		id: data[0],
+		parents: strings.Split(data[1], " "),
+		author: Contributor{
+			name: data[2],
+			email: data[3],
+		},
+		date: date,
+		message: data[5],
+	}
+}
+
+// Fetch repository tags asynchronously
+// ProjectPath is the absolute path to project with Git repository
+func (g Git) ReadTags(ctx context.Context, projectPath string, result chan Tag) *Executor {
+	cmd := g.createCommand(
+		ctx,
+		projectPath,
+		"for-each-ref",
+		"--sort=-creatordate",
+		"--format=%(refname:short)%00%(objectname)%00%(*objectname)%00%(creatordate:iso-strict)%00%(contents:subject)",
+		"refs/tags")
+	reader := cmdReaderFunc(func(s *bufio.Scanner) {
+		for s.Scan() {
+			// name, object, peeled object for annotated tags, date and subject
+			fields := strings.SplitN(s.Text(), "\x00", 5)
+			if len(fields) != 5 {
+				continue
+			}
+
+			head := fields[1]
+			if fields[2] != "" {
+				head = fields[2]
+			}
+
+			date, _ := time.Parse(time.RFC3339, fields[3])
+
+			result <- Tag{fields[0], head, date, fields[4]}
+		}
+	})
+
+	return g.executor(ctx, cmd, reader)
+}
+
+// Strip a/ or b/ prefix of the diff pathname
+// Returns empty string for /dev/null
+func gitDiffPathname(pathname string, prefix string) string {
+	if pathname == "/dev/null" {
+		return ""
+	}
+
+	return strings.TrimPrefix(pathname, prefix)
+}
+
+// Wrapper for read file changes from show --patch stdout
+// Each one file goes by such lines:
+// diff --git a/testpath/random.txt b/testpath/moved.txt
+// similarity index 90%
+// rename from testpath/random.txt
+// rename to testpath/moved.txt
+// --- a/testpath/random.txt
+// +++ b/testpath/moved.txt
+// @@ -10,3 +10,4 @@
+func (g *Git) readDiffPipe(s *bufio.Scanner, result chan FileDiff) {
+	s.Buffer(make([]byte, bufio.MaxScanTokenSize), gitMaxLineSize)
+
+	var (
+		diff *FileDiff
+		lines []string
+		inHunks bool
+	)
+
+	flush := func() {
+		if diff != nil {
+			if diff.status != FileRenamed && diff.status != FileCopied {
+				diff.previousPathname = ""
+			}
+			diff.diff = strings.Join(lines, "\n") + "\n"
+
+			result <- *diff
+
+			runtime.Gosched()
+		}
+
+		diff = nil
+		lines = nil
+		inHunks = false
+	}
+
+	for s.Scan() {
+		str := s.Text()
+
+		if strings.HasPrefix(str, "diff --git ") {
+			flush()
+
+			diff = &FileDiff{status: FileModified}
+			if pos := strings.Index(str, " b/"); pos > 0 {
+				diff.previousPathname = gitDiffPathname(str[len("diff --git "):pos], "a/")
+				diff.pathname = str[pos+len(" b/"):]
+			}
+		}
+
+		if diff == nil {
+			continue
+		}
+
+		lines = append(lines, str)
+
+		if inHunks {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(str, "@@"):
+			inHunks = true
+		case strings.HasPrefix(str, "new file mode"):
+			diff.status = FileAdded
+		case strings.HasPrefix(str, "deleted file mode"):
+			diff.status = FileDeleted
+		case strings.HasPrefix(str, "rename from "):
+			diff.status = FileRenamed
+			diff.previousPathname = strings.TrimPrefix(str, "rename from ")
+		case strings.HasPrefix(str, "rename to "):
+			diff.pathname = strings.TrimPrefix(str, "rename to ")
+		case strings.HasPrefix(str, "copy from "):
+			diff.status = FileCopied
+			diff.previousPathname = strings.TrimPrefix(str, "copy from ")
+		case strings.HasPrefix(str, "copy to "):
+			diff.pathname = strings.TrimPrefix(str, "copy to ")
+		case strings.HasPrefix(str, "Binary files ") || str == "GIT binary patch":
+			diff.isBinary = true
+		case strings.HasPrefix(str, "--- "):
+			if pathname := gitDiffPathname(str[4:], "a/"); pathname != "" {
+				diff.previousPathname = pathname
+			}
+		case strings.HasPrefix(str, "+++ "):
+			if pathname := gitDiffPathname(str[4:], "b/"); pathname != "" {
+				diff.pathname = pathname
+			}
+		}
+	}
+
+	flush()
+}
+
+// Fetch changes of the commit comparing with its first parent asynchronously
+// ProjectPath is the absolute path to project with Git repository
+// CommitId is the sha256 commit identifier (or short copy)
+// Identifier which starts with a dash is rejected, otherwise git parses it as an option
+func (g Git) ReadDiff(ctx context.Context, projectPath string, commitId string, result chan FileDiff) *Executor {
+	if isGitOptionRevision(commitId) {
+		return g.invalidRevisionExecutor(ctx, "show", projectPath, commitId)
+	}
+
+	cmd := g.createCommand(ctx, projectPath, "show", "--format=", "--patch", "-M", "-m", "--first-parent", "--no-color", "--no-ext-diff", commitId, "--")
+	reader := cmdReaderFunc(func(s *bufio.Scanner) {
+		g.readDiffPipe(s, result)
+	})
+
+	return g.executor(ctx, cmd, reader)
 }
 
 // Wrapper for read commits from command line stdout
@@ -153,20 +476,7 @@ func (g *Git) readCommitsPipe(s *bufio.Scanner, result chan Commit) {
 		key++
 
 		if key == 6 {
-			time, _ := time.Parse(gitLogDateLayout, data[4])
-
-			commit := Commit{
-				id: data[0],
-				parents: strings.Split(data[1], " "),
-				author: Contributor{
-					name: data[2],
-					email: data[3],
-				},
-				date: time,
-				message: data[5],
-			}
-
-			result <- commit
+			result <- g.parseCommit(data)
 
 			runtime.Gosched()
 
@@ -178,14 +488,35 @@ func (g *Git) readCommitsPipe(s *bufio.Scanner, result chan Commit) {
 
 // Fetch repository commit by identifier asynchronously
 // ProjectPath is the absolute path to project with Git repository
-// CommitId is the sha256 commit identifier (or short copy)
-func (g Git) ReadCommit(projectPath string, commitId string, result chan Commit) *Executor {
-	cmd := g.createCommand(projectPath, "show", "--quiet", commitId, `--pretty=format:`+gitLogFormat)
-	reader := cmdReaderFunc(func(s *bufio.Scanner) {
-		g.readCommitsPipe(s, result)
+// CommitId is the sha256 commit identifier (or short copy), ref name or revision like HEAD~2
+// Tags are peeled to their commits
+func (g Git) ReadCommit(ctx context.Context, projectPath string, commitId string, result chan Commit) *Executor {
+	return g.funcExecutor(ctx, "cat-file", projectPath, "git cat-file commit "+commitId, func() error {
+		var o catFileObject
+
+		err := g.catFile(ctx, projectPath, catFileBatch, func(p *catFileProcess) (err error) {
+			o, err = p.revision(commitId, "commit")
+			return
+		})
+
+		if err != nil {
+			return err
+		}
+
+		result <- parseGitCommit(o.id, o.data).model()
+
+		return nil
 	})
+}
 
-	return g.executor(cmd, reader)
+// Returns log argument to filter commits by branch
+// Empty branch means all branches
+func gitBranchesArg(branch string) string {
+	if branch == "" {
+		return "--branches=*"
+	}
+
+	return "--branches=*"+branch+"*"
 }
 
 // Read commits history
@@ -193,13 +524,7 @@ func (g Git) ReadCommit(projectPath string, commitId string, result chan Commit)
 // path should contains relative path of file for history
 // If need provide whole repository history, path should be empty
 // Branch should contain branch identifier if need get specified branch results
-func (g Git) ReadHistory(projectPath string, path string, branch string, offset int, limit int, result chan Commit) *Executor {
-	if branch == "" {
-		branch = "*"
-	} else {
-		branch = "*"+branch+"*"
-	}
-
+func (g Git) ReadHistory(ctx context.Context, projectPath string, path string, branch string, offset int, limit int, result chan Commit) *Executor {
 	args := append(
 		make([]string, 0, 6),
 		"log",
@@ -207,16 +532,833 @@ func (g Git) ReadHistory(projectPath string, path string, branch string, offset
 		`-n`,
 		fmt.Sprintf("%d", limit),
 		fmt.Sprintf("--skip=%d", offset),
-		"--branches="+branch)
+		gitBranchesArg(branch))
 
 	if path != "" {
 		args = append(args, "--", path)
 	}
 
-	cmd := g.createCommand(projectPath, args...)
+	cmd := g.createCommand(ctx, projectPath, args...)
 	reader := cmdReaderFunc(func(s *bufio.Scanner) {
 		g.readCommitsPipe(s, result)
 	})
 
-	return g.executor(cmd, reader)
-}
\ No newline at end of file
+	return g.executor(ctx, cmd, reader)
+}
+
+// Read commits history starting from the commits in topological order
+// CommitIds should be full commit identifiers, result gets them and their ancestors
+// Path should contains relative path of file for history, empty path means whole project
+// If path isn't empty, parents are rewritten to the nearest ancestors which change the path
+func (g Git) ReadHistoryFrom(ctx context.Context, projectPath string, path string, commitIds []string, limit int, result chan Commit) *Executor {
+	for _, id := range commitIds {
+		if !fullObjectIdPattern.MatchString(id) {
+			return g.funcExecutor(ctx, "log", projectPath, "history from "+id, func() error {
+				return newVcsError(ErrRevisionNotFound, "Invalid commit identifier %s", id)
+			})
+		}
+	}
+
+	args := append(
+		make([]string, 0, len(commitIds) + 8),
+		"log",
+		`--format=`+gitLogFormat,
+		"--topo-order",
+		`-n`,
+		fmt.Sprintf("%d", limit))
+	if path != "" {
+		// parents are rewritten to the commits which change the path
+		args = append(args, "--parents")
+	}
+
+	args = append(args, commitIds...)
+	args = append(args, "--")
+
+	if path != "" {
+		args = append(args, path)
+	}
+
+	cmd := g.createCommand(ctx, projectPath, args...)
+	reader := cmdReaderFunc(func(s *bufio.Scanner) {
+		g.readCommitsPipe(s, result)
+	})
+
+	return g.executor(ctx, cmd, reader)
+}
+
+// Fetch file content at revision asynchronously
+// ProjectPath is the absolute path to project with Git repository
+// Revision is a branch or commit identifier (HEAD if empty)
+// Pathname is a relative file pathname
+// Result gets nothing if file not found at revision, not resolved revision fails the executor
+func (g Git) ReadBlob(ctx context.Context, projectPath string, revision string, pathname string, result chan Blob) *Executor {
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	pathname = strings.TrimLeft(pathname, "/")
+
+	return g.funcExecutor(ctx, "cat-file", projectPath, "git cat-file blob "+revision+":"+pathname, func() error {
+		var (
+			o catFileObject
+			found bool
+		)
+
+		err := g.catFile(ctx, projectPath, catFileBatch, func(p *catFileProcess) error {
+			tree, err := p.revision(revision, "tree")
+			if err != nil {
+				return err
+			}
+
+			o, found, err = p.object(tree.id + ":" + pathname)
+			return err
+		})
+
+		if err != nil || !found || o.kind != "blob" {
+			return err
+		}
+
+		result <- NewBlob(o.id, pathname, o.data)
+
+		return nil
+	})
+}
+
+// Fetch the first size bytes of the file at revision asynchronously
+// The rest of the content isn't loaded to memory, so blob of the result has the truncated content
+// Result gets nothing if file not found at revision, not resolved revision fails the executor
+func (g Git) readBlobHead(ctx context.Context, projectPath string, revision string, pathname string, size int64, result chan Blob) *Executor {
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	pathname = strings.TrimLeft(pathname, "/")
+
+	return g.funcExecutor(ctx, "cat-file", projectPath, "git cat-file blob head "+revision+":"+pathname, func() error {
+		var (
+			o catFileObject
+			found bool
+		)
+
+		err := g.catFile(ctx, projectPath, catFileBatch, func(p *catFileProcess) error {
+			tree, err := p.revision(revision, "tree")
+			if err != nil {
+				return err
+			}
+
+			return p.lookupLimit([]string{tree.id + ":" + pathname}, size, func(i int, obj catFileObject, isFound bool) error {
+				o, found = obj, isFound
+				return nil
+			})
+		})
+
+		if err != nil || !found || o.kind != "blob" {
+			return err
+		}
+
+		result <- NewBlob(o.id, pathname, o.data)
+
+		return nil
+	})
+}
+
+// Convert git object mode to file mode
+// Submodules are represented as directories
+func gitFileMode(mode string) os.FileMode {
+	switch mode {
+	case "040000", "160000":
+		return os.ModeDir | 0755
+	case "120000":
+		return os.ModeSymlink | 0777
+	case "100755":
+		return 0755
+	}
+
+	return 0644
+}
+
+// Tree entry with relative pathname
+type gitTreeFile struct {
+	pathname string
+	entry gitTreeEntry
+}
+
+// Read tree entries, subtrees are read recursively if need (without subtrees itself)
+func (g *Git) readTreeFiles(ctx context.Context, p *catFileProcess, treeId string, path string, recursive bool, files []gitTreeFile) ([]gitTreeFile, error) {
+	if err := ctx.Err(); err != nil {
+		return files, err
+	}
+
+	o, found, err := p.object(treeId)
+	if err != nil {
+		return files, err
+	}
+
+	if !found || o.kind != "tree" {
+		return files, fmt.Errorf("Tree %s not found", treeId)
+	}
+
+	entries, err := parseGitTree(treeId, o.data, len(treeId)/2)
+	if err != nil {
+		return files, err
+	}
+
+	for _, e := range entries {
+		pathname := e.name
+		if path != "" {
+			pathname = path + "/" + e.name
+		}
+
+		if e.isTree() && recursive {
+			if files, err = g.readTreeFiles(ctx, p, e.id, pathname, true, files); err != nil {
+				return files, err
+			}
+			continue
+		}
+
+		files = append(files, gitTreeFile{pathname, e})
+	}
+
+	return files, nil
+}
+
+// Fetch files tree at revision asynchronously
+// ProjectPath is the absolute path to project with Git repository
+// Revision is a branch or commit identifier (HEAD if empty)
+// Path is a relative directory path, empty path means the project root
+// If recursive is true, result gets files of all subdirectories (without directories itself)
+// Result gets nothing if the path is a file, not existent path fails the executor with ErrPathNotFound error
+// Trees are read by cat-file --batch and sizes of files by cat-file --batch-check
+func (g Git) ReadTree(ctx context.Context, projectPath string, revision string, path string, recursive bool, result chan File) *Executor {
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	path = strings.Trim(path, "/")
+
+	return g.funcExecutor(ctx, "cat-file", projectPath, "git cat-file tree "+revision+":"+path, func() error {
+		var files []gitTreeFile
+
+		err := g.catFile(ctx, projectPath, catFileBatch, func(p *catFileProcess) error {
+			files = files[:0]
+
+			root, err := p.revision(revision, "tree")
+			if err != nil {
+				return err
+			}
+
+			// file path has no files
+			tree := root
+			if path != "" {
+				var found bool
+				if tree, found, err = p.object(root.id + ":" + path); err != nil || (found && tree.kind != "tree") {
+					return err
+				}
+
+				if !found {
+					return newVcsError(ErrPathNotFound, "Path %s not found at %s", path, revision)
+				}
+			}
+
+			files, err = g.readTreeFiles(ctx, p, tree.id, path, recursive, files)
+
+			return err
+		})
+
+		if err != nil || len(files) == 0 {
+			return err
+		}
+
+		// submodules commits aren't stored in the repository, so they have no size
+		ids := make([]string, 0, len(files))
+		for _, f := range files {
+			if !f.entry.isTree() && f.entry.mode != "160000" {
+				ids = append(ids, f.entry.id)
+			}
+		}
+
+		sizes := make(map[string]int64, len(ids))
+
+		err = g.catFile(ctx, projectPath, catFileBatchCheck, func(p *catFileProcess) error {
+			return p.lookup(ids, func(i int, o catFileObject, found bool) error {
+				sizes[ids[i]] = o.size
+				return nil
+			})
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			isDir := f.entry.isTree() || f.entry.mode == "160000"
+
+			result <- NewFileFromTree(f.pathname, isDir, sizes[f.entry.id], gitFileMode(f.entry.mode))
+
+			runtime.Gosched()
+		}
+
+		return nil
+	})
+}
+
+// Wrapper for read file commits from log --name-status stdout
+// Each one commit goes by gitLogFormat lines, empty line and file status lines:
+// M	testpath/moved.txt
+// R100	testpath/random.txt	testpath/moved.txt
+func (g *Git) readFileCommitsPipe(s *bufio.Scanner, result chan FileCommit) {
+	var (
+		data = make([]string, 0, 6)
+		commit *FileCommit
+	)
+
+	flush := func() {
+		if commit != nil {
+			result <- *commit
+
+			runtime.Gosched()
+		}
+
+		commit = nil
+	}
+
+	for s.Scan() {
+		str := s.Text()
+
+		if commit != nil {
+			if str == "" {
+				continue
+			}
+
+			if matches := gitNameStatusPattern.FindStringSubmatch(str); matches != nil {
+				commit.status = FileStatus(matches[1])
+				if matches[4] != "" {
+					commit.previousPathname = matches[3]
+					commit.pathname = matches[4]
+				} else {
+					commit.pathname = matches[3]
+				}
+				continue
+			}
+
+			// next commit begins
+			flush()
+		}
+
+		data = append(data, str)
+
+		if len(data) == 6 {
+			commit = &FileCommit{Commit: g.parseCommit(data)}
+			data = make([]string, 0, 6)
+		}
+	}
+
+	flush()
+}
+
+// Read file history following renames
+// projectPath should contains absolute path to project with Git repository
+// path should contains relative pathname of the file
+// Branch should contain branch identifier if need get specified branch results
+// Each one result contains the commit and the pathname the file had in this commit
+func (g Git) ReadFileHistory(ctx context.Context, projectPath string, path string, branch string, offset int, limit int, result chan FileCommit) *Executor {
+	cmd := g.createCommand(
+		ctx,
+		projectPath,
+		"log",
+		"--follow",
+		"--name-status",
+		`--format=`+gitLogFormat,
+		`-n`,
+		fmt.Sprintf("%d", limit),
+		fmt.Sprintf("--skip=%d", offset),
+		gitBranchesArg(branch),
+		"--",
+		path)
+	reader := cmdReaderFunc(func(s *bufio.Scanner) {
+		g.readFileCommitsPipe(s, result)
+	})
+
+	return g.executor(ctx, cmd, reader)
+}
+
+// Wrapper for read line range commits from log -L stdout
+// Each one commit starts with gitRecordSeparator and goes by gitLogFormat lines,
+// then goes empty line and the diff of the line range:
+// diff --git a/main.go b/main.go
+// --- a/main.go
+// +++ b/main.go
+// @@ -3,3 +3,3 @@
+func (g *Git) readLineCommitsPipe(s *bufio.Scanner, result chan LineCommit) {
+	s.Buffer(make([]byte, bufio.MaxScanTokenSize), gitMaxLineSize)
+
+	var (
+		data []string
+		diff []string
+		commit *LineCommit
+	)
+
+	flush := func() {
+		if commit != nil {
+			commit.diff = strings.TrimRight(strings.Join(diff, "\n"), "\n") + "\n"
+			result <- *commit
+
+			runtime.Gosched()
+		}
+
+		commit = nil
+		diff = nil
+	}
+
+	for s.Scan() {
+		str := s.Text()
+
+		if strings.HasPrefix(str, gitRecordSeparator) {
+			flush()
+			data = append(make([]string, 0, 6), strings.TrimPrefix(str, gitRecordSeparator))
+			continue
+		}
+
+		if data != nil {
+			data = append(data, str)
+
+			if len(data) == 6 {
+				commit = &LineCommit{Commit: g.parseCommit(data)}
+				data = nil
+			}
+			continue
+		}
+
+		if commit == nil || (str == "" && len(diff) == 0) {
+			continue
+		}
+
+		if strings.HasPrefix(str, "+++ ") && commit.pathname == "" {
+			commit.pathname = strings.TrimPrefix(strings.TrimPrefix(str, "+++ "), "b/")
+		}
+
+		diff = append(diff, str)
+	}
+
+	flush()
+}
+
+// Check the revision starts with a dash, git parses such command line argument as an option
+// Both ends of the range like a..b or a...b are checked
+func isGitOptionRevision(revision string) bool {
+	for _, part := range strings.Split(revision, "..") {
+		if strings.HasPrefix(strings.TrimPrefix(part, "."), "-") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Create executor which fails with invalid revision error instead of the command
+// It's used for option-like revisions (see isGitOptionRevision)
+func (g *Git) invalidRevisionExecutor(ctx context.Context, operation string, projectPath string, revision string) *Executor {
+	return g.funcExecutor(ctx, operation, projectPath, operation+" "+revision, func() error {
+		return newVcsError(ErrRevisionNotFound, "Invalid revision %s", revision)
+	})
+}
+
+// Create executor of log command for the line range or function history
+// Lines is a value of log -L argument without the file name
+// Revision which starts with a dash is rejected, otherwise git parses it as an option
+func (g *Git) lineLogExecutor(ctx context.Context, projectPath string, path string, lines string, revision string, offset int, limit int, result chan LineCommit) *Executor {
+	// line range could be traced from the single revision only
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	if isGitOptionRevision(revision) {
+		return g.invalidRevisionExecutor(ctx, "log", projectPath, revision)
+	}
+
+	cmd := g.createCommand(
+		ctx,
+		projectPath,
+		"log",
+		`--format=%x1e`+gitLogFormat,
+		`-n`,
+		fmt.Sprintf("%d", limit),
+		fmt.Sprintf("--skip=%d", offset),
+		"-L",
+		lines+":"+path,
+		revision,
+		"--")
+	reader := cmdReaderFunc(func(s *bufio.Scanner) {
+		g.readLineCommitsPipe(s, result)
+	})
+
+	return g.executor(ctx, cmd, reader)
+}
+
+// Read history of the file line range
+// projectPath should contains absolute path to project with Git repository
+// path should contains relative pathname of the file
+// Start and end are the line numbers of the range (starting from 1)
+// Revision is a branch or commit identifier to start from (HEAD if empty)
+// Each one result contains the commit and the diff hunk of the line range
+func (g Git) ReadLineHistory(ctx context.Context, projectPath string, path string, start int, end int, revision string, offset int, limit int, result chan LineCommit) *Executor {
+	return g.lineLogExecutor(ctx, projectPath, path, fmt.Sprintf("%d,%d", start, end), revision, offset, limit, result)
+}
+
+// Read history of the function in the file
+// The function is resolved by the diff funcname rules (see gitattributes diff driver)
+// projectPath should contains absolute path to project with Git repository
+// path should contains relative pathname of the file
+// Funcname is a regular expression of the function name
+// Revision is a branch or commit identifier to start from (HEAD if empty)
+// Each one result contains the commit and the diff hunk of the function
+func (g Git) ReadFunctionHistory(ctx context.Context, projectPath string, path string, funcname string, revision string, offset int, limit int, result chan LineCommit) *Executor {
+	return g.lineLogExecutor(ctx, projectPath, path, ":"+funcname, revision, offset, limit, result)
+}
+
+// Wrapper for read matched commits from log --name-only stdout
+// Each one commit starts with gitRecordSeparator and goes by gitLogFormat lines,
+// then goes empty line and matched file pathnames line-by-line
+func (g *Git) readMatchedCommitsPipe(s *bufio.Scanner, result chan MatchedCommit) {
+	var (
+		data []string
+		commit *MatchedCommit
+	)
+
+	flush := func() {
+		if commit != nil {
+			result <- *commit
+
+			runtime.Gosched()
+		}
+
+		commit = nil
+	}
+
+	for s.Scan() {
+		str := s.Text()
+
+		if strings.HasPrefix(str, gitRecordSeparator) {
+			flush()
+			data = append(make([]string, 0, 6), strings.TrimPrefix(str, gitRecordSeparator))
+			continue
+		}
+
+		if data != nil {
+			data = append(data, str)
+
+			if len(data) == 6 {
+				commit = &MatchedCommit{Commit: g.parseCommit(data), files: make([]string, 0)}
+				data = nil
+			}
+			continue
+		}
+
+		if commit != nil && str != "" {
+			commit.files = append(commit.files, str)
+		}
+	}
+
+	flush()
+}
+
+// Search commits where the string or regular expression was added or removed
+// projectPath should contains absolute path to project with Git repository
+// Query is a searched string (-S pickaxe) or regular expression if isRegexp is true (-G pickaxe)
+// path should contains relative path to limit search, empty path means whole repository
+// Branch should contain branch identifier if need get specified branch results
+// Each one result contains the commit and the pathnames of the matched files
+func (g Git) SearchHistory(ctx context.Context, projectPath string, query string, isRegexp bool, path string, branch string, offset int, limit int, result chan MatchedCommit) *Executor {
+	pickaxe := "-S"+query
+	if isRegexp {
+		pickaxe = "-G"+query
+	}
+
+	args := []string{
+		"log",
+		`--format=%x1e`+gitLogFormat,
+		"--name-only",
+		pickaxe,
+		`-n`,
+		fmt.Sprintf("%d", limit),
+		fmt.Sprintf("--skip=%d", offset),
+		gitBranchesArg(branch),
+	}
+
+	if path != "" {
+		args = append(args, "--", path)
+	}
+
+	cmd := g.createCommand(ctx, projectPath, args...)
+	reader := cmdReaderFunc(func(s *bufio.Scanner) {
+		g.readMatchedCommitsPipe(s, result)
+	})
+
+	return g.executor(ctx, cmd, reader)
+}
+
+// Wrapper for read code search results from grep -z --column stdout
+// Match lines goes by NUL-separated fields: revision:pathname, line number, column number and line text
+// Context lines has no column number field
+// Context groups are separated by -- line
+func (g *Git) readGrepPipe(s *bufio.Scanner, revision string, result chan GrepMatch) {
+	s.Buffer(make([]byte, bufio.MaxScanTokenSize), gitMaxLineSize)
+
+	for s.Scan() {
+		fields := strings.SplitN(s.Text(), "\x00", 4)
+
+		if len(fields) < 3 {
+			continue
+		}
+
+		m := GrepMatch{
+			pathname: strings.TrimPrefix(fields[0], revision+":"),
+		}
+		m.line, _ = strconv.Atoi(fields[1])
+
+		if len(fields) == 4 {
+			m.column, _ = strconv.Atoi(fields[2])
+			m.text = fields[3]
+		} else {
+			m.text = fields[2]
+			m.isContext = true
+		}
+
+		result <- m
+
+		runtime.Gosched()
+	}
+}
+
+// Search the code in the tree of revision
+// projectPath should contains absolute path to project with Git repository
+// Revision is a branch or commit identifier to search in (HEAD if empty)
+// Pattern is a POSIX extended regular expression
+// Globs limit searched files by pathspec patterns like *.go, empty globs means whole tree
+// ContextLines is a number of context lines around each one match
+// Results goes line-by-line while the command runs
+// Executor returns no error and result gets nothing if nothing found
+// Revision which starts with a dash is rejected, otherwise git parses it as an option
+func (g Git) Grep(ctx context.Context, projectPath string, revision string, pattern string, ignoreCase bool, globs []string, contextLines int, result chan GrepMatch) *Executor {
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	if isGitOptionRevision(revision) {
+		return g.invalidRevisionExecutor(ctx, "grep", projectPath, revision)
+	}
+
+	args := []string{"grep", "-z", "-n", "--column", "--full-name", "-I", "-E"}
+
+	if ignoreCase {
+		args = append(args, "-i")
+	}
+
+	if contextLines > 0 {
+		args = append(args, fmt.Sprintf("--context=%d", contextLines))
+	}
+
+	args = append(args, "-e", pattern, revision, "--")
+	args = append(args, globs...)
+
+	cmd := g.createCommand(ctx, projectPath, args...)
+	reader := cmdReaderFunc(func(s *bufio.Scanner) {
+		g.readGrepPipe(s, revision, result)
+	})
+
+	// git grep exits with status 1 if nothing matches
+	e := g.executor(ctx, cmd, reader)
+	e.emptyStatus = 1
+
+	return e
+}
+
+// Returns format-patch arguments for the single commit or for the commits range
+// Revision like a..b or a...b is a range, otherwise it is a single commit identifier
+func gitPatchRevision(revision string) []string {
+	if strings.Contains(revision, "..") {
+		return []string{revision}
+	}
+
+	return []string{"-1", revision}
+}
+
+// Create executor of format-patch command for the single commit or for the commits range, stdout goes to the stream function
+// Revision which starts with a dash (or range with such end) is rejected, otherwise git parses it as an option
+func (g *Git) formatPatchExecutor(ctx context.Context, projectPath string, revision string, stream cmdStreamFunc) *Executor {
+	if isGitOptionRevision(revision) {
+		return g.invalidRevisionExecutor(ctx, "format-patch", projectPath, revision)
+	}
+
+	args := append([]string{"format-patch", "--stdout"}, gitPatchRevision(revision)...)
+	args = append(args, "--")
+
+	return g.streamExecutor(ctx, g.createCommand(ctx, projectPath, args...), stream)
+}
+
+// Fill patch fields from the mbox headers
+func (g *Git) parsePatchHeaders(p *Patch, headers map[string]string) {
+	if from, err := mail.ParseAddress(headers["From"]); err == nil {
+		p.author = Contributor{name: from.Name, email: from.Address}
+	}
+
+	if date, err := mail.ParseDate(headers["Date"]); err == nil {
+		p.date = date
+	}
+
+	subject := headers["Subject"]
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err == nil {
+		subject = decoded
+	}
+
+	p.subject = gitPatchSubjectPrefixPattern.ReplaceAllString(subject, "")
+}
+
+// Wrapper for read patches from format-patch mbox stream
+// Each one patch starts with such line:
+// From 313604a7f4ecd265e56102fa2e22de35726f4687 Mon Sep 17 00:00:00 2001
+// Then goes mail headers (From, Date, Subject), empty line, commit message and diff
+// Patch content keeps the lines as is (with CR of the CRLF files), lines length isn't limited
+func (g *Git) readPatchesPipe(r io.Reader, result chan Patch) error {
+	var (
+		patch *Patch
+		content strings.Builder
+		headers map[string]string
+		header string
+		number int
+	)
+
+	flush := func() {
+		if patch == nil {
+			return
+		}
+
+		if headers != nil {
+			// patch without commit message body
+			g.parsePatchHeaders(patch, headers)
+		}
+
+		patch.content = content.String()
+		if !strings.HasSuffix(patch.content, "\n") {
+			patch.content += "\n"
+		}
+
+		result <- *patch
+
+		runtime.Gosched()
+	}
+
+	b := bufio.NewReader(r)
+
+	for {
+		raw, err := b.ReadString('\n')
+		if raw == "" && err != nil {
+			flush()
+
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		line := strings.TrimSuffix(strings.TrimSuffix(raw, "\n"), "\r")
+
+		if matches := gitPatchSeparatorPattern.FindStringSubmatch(line); matches != nil {
+			flush()
+
+			number++
+			patch = &Patch{commitId: matches[1], number: number}
+			content.Reset()
+			content.WriteString(raw)
+			headers = make(map[string]string)
+			header = ""
+			continue
+		}
+
+		if patch == nil {
+			continue
+		}
+
+		content.WriteString(raw)
+
+		if headers == nil {
+			// headers already read
+			continue
+		}
+
+		switch {
+		case line == "":
+			g.parsePatchHeaders(patch, headers)
+			headers = nil
+		case (line[0] == ' ' || line[0] == '\t') && header != "":
+			// folded header value
+			headers[header] += " " + strings.TrimSpace(line)
+		default:
+			if pos := strings.Index(line, ":"); pos > 0 {
+				header = line[:pos]
+				headers[header] = strings.TrimSpace(line[pos+1:])
+			}
+		}
+	}
+}
+
+// Read patches of the commit or commits range asynchronously
+// ProjectPath is the absolute path to project with Git repository
+// Revision is a commit identifier or commits range like a..b
+// Result gets patches one-by-one in the format-patch order
+func (g Git) ReadPatches(ctx context.Context, projectPath string, revision string, result chan Patch) *Executor {
+	return g.formatPatchExecutor(ctx, projectPath, revision, func(r io.Reader) error {
+		return g.readPatchesPipe(r, result)
+	})
+}
+
+// Export patches of the commit or commits range as one mbox stream
+// ProjectPath is the absolute path to project with Git repository
+// Revision is a commit identifier or commits range like a..b
+// Mbox stream is copied to w as is while the executor runs, write and read errors fail the executor
+func (g Git) ExportMbox(ctx context.Context, projectPath string, revision string, w io.Writer) *Executor {
+	return g.formatPatchExecutor(ctx, projectPath, revision, func(r io.Reader) error {
+		if _, err := io.Copy(w, r); err != nil {
+			// keep reading after write error, otherwise format-patch hangs on full stdout pipe
+			io.Copy(ioutil.Discard, r)
+			return err
+		}
+
+		return nil
+	})
+}
+
+// Serve fetch or clone request of the smart protocol by upload-pack asynchronously
+// ProjectPath is the absolute path to project with Git repository
+// If advertise is true, refs advertisement is written and request is ignored
+// Protocol is a value of the Git-Protocol header like version=2 (could be empty)
+// Request is a client request body, response is written to w while the executor runs
+// Write errors fail the executor, the rest of the response is drained, so upload-pack finishes
+func (g Git) UploadPack(ctx context.Context, projectPath string, advertise bool, protocol string, request io.Reader, w io.Writer) *Executor {
+	params := []string{"upload-pack", "--stateless-rpc"}
+	if advertise {
+		params = append(params, "--advertise-refs")
+	}
+
+	cmd := g.createCommand(ctx, projectPath, append(params, ".")...)
+	if !advertise {
+		cmd.Stdin = request
+	}
+	if protocol != "" {
+		env := cmd.Env
+		if env == nil {
+			env = os.Environ()
+		}
+		cmd.Env = append(append([]string{}, env...), "GIT_PROTOCOL="+protocol)
+	}
+
+	return g.streamExecutor(ctx, cmd, func(r io.Reader) error {
+		if _, err := io.Copy(w, r); err != nil {
+			// keep reading after write error, otherwise upload-pack hangs on full stdout pipe
+			io.Copy(ioutil.Discard, r)
+			return err
+		}
+
+		return nil
+	})
+}
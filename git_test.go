@@ -1,6 +1,13 @@
 package vcsview
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -14,6 +21,7 @@ func MakeGitMockWithCmd(cmd string, t *testing.T) Git {
 				t.Log(msg)
 			}),
 		},
+		nil,
 	}
 	return g
 }
@@ -35,7 +43,7 @@ func TestGit_RepositoryPathname(t *testing.T) {
 func TestGit_Version(t *testing.T) {
 	g := MakeGitMock(t)
 
-	version, err := g.Version();
+	version, err := g.Version(context.Background());
 
 	if err != nil {
 		t.Errorf("Unexpected git version error: %v", err)
@@ -47,7 +55,7 @@ func TestGit_Version(t *testing.T) {
 
 	g = MakeGitMockWithCmd("non_git", t)
 
-	version, err = g.Version()
+	version, err = g.Version(context.Background())
 
 	if err == nil {
 		t.Errorf("Expected git version error, none given")
@@ -97,7 +105,7 @@ func TestGit_ReadBranchesFail(t *testing.T) {
 
 		result = make(chan Branch)
 
-		e := g.ReadBranches(testCase, result)
+		e := g.ReadBranches(context.Background(), testCase, result)
 
 		wg := sync.WaitGroup{}
 		wg.Add(2)
@@ -141,7 +149,7 @@ func TestGit_ReadBranchesOk(t *testing.T) {
 
 	projectPath := gitRepositoryPath
 
-	e := g.ReadBranches(projectPath, result)
+	e := g.ReadBranches(context.Background(), projectPath, result)
 
 	wg := &sync.WaitGroup{}
 	wg.Add(2)
@@ -227,7 +235,7 @@ func TestGit_ReadCommitFail(t *testing.T) {
 
 		result = make(chan Commit)
 
-		e := g.ReadCommit(testCase.repoPath, testCase.commitId, result)
+		e := g.ReadCommit(context.Background(), testCase.repoPath, testCase.commitId, result)
 
 		wg := sync.WaitGroup{}
 		wg.Add(2)
@@ -273,7 +281,7 @@ func TestGit_ReadCommitOk(t *testing.T) {
 	commit := make([]Commit, 0)
 	result := make(chan Commit)
 
-	e := g.ReadCommit(testCase.repoPath, testCase.commitId, result)
+	e := g.ReadCommit(context.Background(), testCase.repoPath, testCase.commitId, result)
 
 	wg := sync.WaitGroup{}
 	wg.Add(2)
@@ -353,7 +361,7 @@ func TestGit_ReadHistoryFail(t *testing.T) {
 
 		result = make(chan Commit)
 
-		e := g.ReadHistory(testCase.repoPath, testCase.path, testCase.branch, testCase.offset, testCase.limit, result)
+		e := g.ReadHistory(context.Background(), testCase.repoPath, testCase.path, testCase.branch, testCase.offset, testCase.limit, result)
 
 		wg := sync.WaitGroup{}
 		wg.Add(2)
@@ -418,7 +426,7 @@ func TestGit_ReadHistoryOk(t *testing.T) {
 		)
 
 		result := make(chan Commit, 1)
-		e := g.ReadHistory(testCase.repoPath, testCase.path, testCase.branch, testCase.offset, testCase.limit, result)
+		e := g.ReadHistory(context.Background(), testCase.repoPath, testCase.path, testCase.branch, testCase.offset, testCase.limit, result)
 		wg := sync.WaitGroup{}
 
 		wg.Add(1)
@@ -465,3 +473,1097 @@ func TestGit_ReadHistoryOk(t *testing.T) {
 		}
 	}
 }
+
+func TestGit_ReadPatches(t *testing.T) {
+	g := MakeGitMock(t)
+
+	cases := []struct{
+		repoPath string
+		revision string
+		wantPatches int
+		wantError bool
+	}{
+		{gitRepositoryPath, "HEAD", 1, false},
+		{gitRepositoryPath, "HEAD~2..HEAD", 2, false},
+		{noRepositoryPath, "HEAD", 0, true},
+	}
+
+	for key, testCase := range cases {
+		var patches []Patch
+
+		result := make(chan Patch)
+		e := g.ReadPatches(context.Background(), testCase.repoPath, testCase.revision, result)
+
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			loop: for {
+				select {
+				case <-e.ctx.Done():
+					close(result)
+					break loop
+				case patch := <-result:
+					patches = append(patches, patch)
+				}
+			}
+		}()
+
+		err := e.Run()
+
+		wg.Wait()
+
+		if testCase.wantError && err == nil {
+			t.Errorf("[%d] Git.ReadPatches(%v) has no errors, want error", key, testCase)
+			continue
+		} else if !testCase.wantError && err != nil {
+			t.Errorf("[%d] Git.ReadPatches(%v) has error: %v, want no errors", key, testCase, err)
+			continue
+		}
+
+		if len(patches) != testCase.wantPatches {
+			t.Errorf("[%d] Git.ReadPatches(%v) got %d patches, want: %d", key, testCase, len(patches), testCase.wantPatches)
+			continue
+		}
+
+		for number, patch := range patches {
+			if patch.CommitId() == "" {
+				t.Errorf("[%d] Git.ReadPatches(%v) patch %d has empty commit identifier", key, testCase, number)
+			}
+			if patch.Number() != number+1 {
+				t.Errorf("[%d] Git.ReadPatches(%v) patch %d has number %d, want: %d", key, testCase, number, patch.Number(), number+1)
+			}
+			if patch.Subject() == "" || strings.HasPrefix(patch.Subject(), "[PATCH") {
+				t.Errorf("[%d] Git.ReadPatches(%v) patch %d has unexpected subject: %v", key, testCase, number, patch.Subject())
+			}
+			if patch.Author().Email() == "" {
+				t.Errorf("[%d] Git.ReadPatches(%v) patch %d has empty author", key, testCase, number)
+			}
+			if patch.Date().IsZero() {
+				t.Errorf("[%d] Git.ReadPatches(%v) patch %d has empty date", key, testCase, number)
+			}
+			if !strings.HasPrefix(patch.Content(), "From "+patch.CommitId()) {
+				t.Errorf("[%d] Git.ReadPatches(%v) patch %d has unexpected content: %v", key, testCase, number, patch.Content())
+			}
+		}
+	}
+}
+
+func TestGit_ExportMbox(t *testing.T) {
+	g := MakeGitMock(t)
+
+	buf := new(bytes.Buffer)
+
+	if err := g.ExportMbox(context.Background(), gitRepositoryPath, "HEAD~2..HEAD", buf).Run(); err != nil {
+		t.Fatalf("Git.ExportMbox(%s, ...) has error: %v, want no errors", gitRepositoryPath, err)
+	}
+
+	mbox := buf.String()
+
+	patches := 0
+	for _, line := range strings.Split(mbox, "\n") {
+		if gitPatchSeparatorPattern.MatchString(line) {
+			patches++
+		}
+	}
+
+	if patches != 2 {
+		t.Errorf("Git.ExportMbox(%s, ...) got %d patches, want: 2", gitRepositoryPath, patches)
+	}
+}
+
+// Writer which always fails
+type failingWriter struct{}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("Write failed")
+}
+
+func TestGit_ExportMboxWriteError(t *testing.T) {
+	g := MakeGitMock(t)
+
+	// the rest of the output is drained, so the command doesn't hang on full stdout pipe
+	if err := g.ExportMbox(context.Background(), gitRepositoryPath, "HEAD~10..HEAD", failingWriter{}).Run(); err == nil || err.Error() != "Write failed" {
+		t.Errorf("Git.ExportMbox(%s, ...) to failing writer = %v, want: Write failed", gitRepositoryPath, err)
+	}
+}
+
+func TestGit_UploadPackWriteError(t *testing.T) {
+	g := MakeGitMock(t)
+
+	if err := g.UploadPack(context.Background(), gitRepositoryPath, true, "", nil, failingWriter{}).Run(); err == nil || err.Error() != "Write failed" {
+		t.Errorf("Git.UploadPack(%s, ...) to failing writer = %v, want: Write failed", gitRepositoryPath, err)
+	}
+}
+
+func TestGit_PatchesOptionRevision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcsview-patches")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() got error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	g := MakeGitMock(t)
+
+	// revisions are options which write the file if git parses them
+	output := filepath.Join(dir, "pwned")
+
+	for key, revision := range []string{"--output=" + output, "HEAD~1..--output=" + output, "--output=" + output + "...HEAD"} {
+		if err := g.ReadPatches(context.Background(), gitRepositoryPath, revision, make(chan Patch, 100)).Run(); ErrorKind(err) != ErrRevisionNotFound {
+			t.Errorf("[%d] Git.ReadPatches(%s) got error: %v, want: %v", key, revision, err, ErrRevisionNotFound)
+		}
+
+		if err := g.ExportMbox(context.Background(), gitRepositoryPath, revision, ioutil.Discard).Run(); ErrorKind(err) != ErrRevisionNotFound {
+			t.Errorf("[%d] Git.ExportMbox(%s) got error: %v, want: %v", key, revision, err, ErrRevisionNotFound)
+		}
+	}
+
+	if _, err := os.Stat(output); err == nil {
+		t.Errorf("Git.ReadPatches() with option revision created the output file")
+	}
+}
+
+func TestGit_ReadPatchesPipe(t *testing.T) {
+	g := MakeGitMock(t)
+
+	mbox := "From 313604a7f4ecd265e56102fa2e22de35726f4687 Mon Sep 17 00:00:00 2001\n" +
+		"From: Max Kalyabin <maksim@kalyabin.ru>\n" +
+		"Subject: [PATCH] CRLF file\n" +
+		"\n" +
+		"--- a/file.txt\n" +
+		"+++ b/file.txt\n" +
+		"@@ -1 +1 @@\n" +
+		"-first\r\n" +
+		"+" + strings.Repeat("long", bufio.MaxScanTokenSize) + "\r\n"
+
+	result := make(chan Patch, 1)
+	if err := g.readPatchesPipe(strings.NewReader(mbox), result); err != nil {
+		t.Fatalf("Git.readPatchesPipe() got error: %v", err)
+	}
+
+	if p := <-result; p.Content() != mbox || p.Subject() != "CRLF file" || p.Author().Email() != "maksim@kalyabin.ru" {
+		t.Errorf("Git.readPatchesPipe() = %q, %v, %v, want the same content", p.Content(), p.Subject(), p.Author())
+	}
+}
+
+func TestGit_ReadFileHistory(t *testing.T) {
+	g := MakeGitMock(t)
+
+	cases := []struct{
+		repoPath string
+		path string
+		wantError bool
+	}{
+		{gitRepositoryPath, "testpath/empty.txt", false},
+		{noRepositoryPath, "testpath/empty.txt", true},
+	}
+
+	for key, testCase := range cases {
+		var commits []FileCommit
+
+		result := make(chan FileCommit)
+		e := g.ReadFileHistory(context.Background(), testCase.repoPath, testCase.path, "", 0, 10, result)
+
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			loop: for {
+				select {
+				case <-e.ctx.Done():
+					close(result)
+					break loop
+				case commit := <-result:
+					commits = append(commits, commit)
+				}
+			}
+		}()
+
+		err := e.Run()
+
+		wg.Wait()
+
+		if testCase.wantError {
+			if err == nil {
+				t.Errorf("[%d] Git.ReadFileHistory(%v) has no errors, want error", key, testCase)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[%d] Git.ReadFileHistory(%v) has error: %v, want no errors", key, testCase, err)
+			continue
+		}
+
+		if len(commits) == 0 {
+			t.Errorf("[%d] Git.ReadFileHistory(%v) got no commits", key, testCase)
+			continue
+		}
+
+		for number, commit := range commits {
+			if commit.Id() == "" {
+				t.Errorf("[%d] Git.ReadFileHistory(%v) commit %d has empty identifier", key, testCase, number)
+			}
+			if commit.Pathname() == "" {
+				t.Errorf("[%d] Git.ReadFileHistory(%v) commit %d has empty pathname", key, testCase, number)
+			}
+			if commit.Status() == "" {
+				t.Errorf("[%d] Git.ReadFileHistory(%v) commit %d has empty status", key, testCase, number)
+			}
+		}
+
+		if pathname := commits[0].Pathname(); pathname != testCase.path {
+			t.Errorf("[%d] Git.ReadFileHistory(%v) last commit pathname = %v, want: %v", key, testCase, pathname, testCase.path)
+		}
+	}
+}
+
+func TestGit_ReadFileCommitsPipe(t *testing.T) {
+	g := MakeGitMock(t)
+
+	output := strings.Join([]string{
+		"090ec3daea8cefa77e430a592f4ecdb81abf3790",
+		"1ff22f108c0fab47fd883f879f63954c7613234c",
+		"Max Kalyabin",
+		"maksim@kalyabin.ru",
+		"Wed Feb 27 14:51:45 2019 +0300",
+		"edit moved file",
+		"",
+		"M\ttestpath/moved.txt",
+		"1ff22f108c0fab47fd883f879f63954c7613234c",
+		"83bfcec8e43d995fedfea0c26cd4102dbd998b15",
+		"Max Kalyabin",
+		"maksim@kalyabin.ru",
+		"Wed Feb 27 14:50:45 2019 +0300",
+		"move random file",
+		"",
+		"R100\ttestpath/random.txt\ttestpath/moved.txt",
+	}, "\n")
+
+	expected := []FileCommit{
+		{status: FileModified, pathname: "testpath/moved.txt"},
+		{status: FileRenamed, pathname: "testpath/moved.txt", previousPathname: "testpath/random.txt"},
+	}
+
+	result := make(chan FileCommit, len(expected))
+	g.readFileCommitsPipe(bufio.NewScanner(strings.NewReader(output)), result)
+	close(result)
+
+	key := 0
+	for commit := range result {
+		if key >= len(expected) {
+			t.Fatalf("Git.readFileCommitsPipe() got more than %d commits", len(expected))
+		}
+
+		e := expected[key]
+
+		if commit.Status() != e.status || commit.Pathname() != e.pathname || commit.PreviousPathname() != e.previousPathname {
+			t.Errorf("[%d] Git.readFileCommitsPipe() = %v %v %v, want: %v %v %v", key, commit.Status(), commit.PreviousPathname(), commit.Pathname(), e.status, e.previousPathname, e.pathname)
+		}
+
+		key++
+	}
+
+	if key != len(expected) {
+		t.Errorf("Git.readFileCommitsPipe() got %d commits, want: %d", key, len(expected))
+	}
+}
+
+func TestGit_ReadLineHistory(t *testing.T) {
+	g := MakeGitMock(t)
+
+	cases := []struct{
+		repoPath string
+		path string
+		start int
+		end int
+		revision string
+		wantCommits int
+		wantError bool
+	}{
+		{gitRepositoryPath, "testpath/empty.txt", 1, 1, "", 0, true},
+		{gitRepositoryPath, "non-existent.txt", 1, 1, "", 0, true},
+		{noRepositoryPath, "testpath/empty.txt", 1, 1, "", 0, true},
+		{gitRepositoryPath, "main.go", 1, 5, "--all", 0, true},
+		{gitRepositoryPath, "main.go", 1, 3, "", 1, false},
+		{gitRepositoryPath, "main.go", 1, 5, "HEAD", 10, false},
+	}
+
+	for key, testCase := range cases {
+		result := make(chan LineCommit)
+		e := g.ReadLineHistory(context.Background(), testCase.repoPath, testCase.path, testCase.start, testCase.end, testCase.revision, 0, 10, result)
+
+		commits := make([]LineCommit, 0)
+
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			loop: for {
+				select {
+				case <-e.ctx.Done():
+					close(result)
+					break loop
+				case c := <-result:
+					commits = append(commits, c)
+				}
+			}
+		}()
+
+		err := e.Run()
+
+		wg.Wait()
+
+		if testCase.wantError && err == nil {
+			t.Errorf("[%d] Git.ReadLineHistory(%v) has no errors, want error", key, testCase)
+		} else if !testCase.wantError && err != nil {
+			t.Errorf("[%d] Git.ReadLineHistory(%v) has error: %v, want no errors", key, testCase, err)
+		}
+
+		if len(commits) != testCase.wantCommits {
+			t.Errorf("[%d] Git.ReadLineHistory(%v) got %d commits, want: %d", key, testCase, len(commits), testCase.wantCommits)
+		}
+
+		for _, c := range commits {
+			if c.Pathname() != testCase.path || len(c.Diff()) == 0 {
+				t.Errorf("[%d] Git.ReadLineHistory(%v) got commit %v with pathname %q and %d diff lines, want hunk of %s", key, testCase, c.Id(), c.Pathname(), len(c.Diff()), testCase.path)
+			}
+		}
+	}
+}
+
+func TestGit_ReadLineCommitsPipe(t *testing.T) {
+	g := MakeGitMock(t)
+
+	output := strings.Join([]string{
+		gitRecordSeparator + "83bfcec8e43d995fedfea0c26cd4102dbd998b15",
+		"950fb7cd80e9a31a71fbc468c8a90aa8b64b427b",
+		"Max Kalyabin",
+		"maksim@kalyabin.ru",
+		"Wed Feb 27 14:51:45 2019 +0300",
+		"change hello",
+		"",
+		"diff --git a/main.go b/main.go",
+		"--- a/main.go",
+		"+++ b/main.go",
+		"@@ -3,3 +3,3 @@",
+		" func hello() {",
+		"-\tprintln(\"v1\")",
+		"+\tprintln(\"v2\")",
+		" }",
+		gitRecordSeparator + "950fb7cd80e9a31a71fbc468c8a90aa8b64b427b",
+		"",
+		"Max Kalyabin",
+		"maksim@kalyabin.ru",
+		"Wed Feb 27 14:50:45 2019 +0300",
+		"add hello",
+		"",
+		"diff --git a/hello.go b/hello.go",
+		"new file mode 100644",
+		"--- /dev/null",
+		"+++ b/hello.go",
+		"@@ -0,0 +1,3 @@",
+		"+func hello() {",
+		"+\tprintln(\"v1\")",
+		"+}",
+		"",
+	}, "\n")
+
+	expected := []LineCommit{
+		{
+			pathname: "main.go",
+			diff: "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -3,3 +3,3 @@\n func hello() {\n-\tprintln(\"v1\")\n+\tprintln(\"v2\")\n }\n",
+		},
+		{
+			pathname: "hello.go",
+			diff: "diff --git a/hello.go b/hello.go\nnew file mode 100644\n--- /dev/null\n+++ b/hello.go\n@@ -0,0 +1,3 @@\n+func hello() {\n+\tprintln(\"v1\")\n+}\n",
+		},
+	}
+
+	result := make(chan LineCommit, len(expected))
+	g.readLineCommitsPipe(bufio.NewScanner(strings.NewReader(output)), result)
+	close(result)
+
+	key := 0
+	for commit := range result {
+		if key >= len(expected) {
+			t.Fatalf("Git.readLineCommitsPipe() got more than %d commits", len(expected))
+		}
+
+		if commit.Id() == "" {
+			t.Errorf("[%d] Git.readLineCommitsPipe() commit has empty identifier", key)
+		}
+
+		if pathname := commit.Pathname(); pathname != expected[key].pathname {
+			t.Errorf("[%d] Git.readLineCommitsPipe() pathname = %v, want: %v", key, pathname, expected[key].pathname)
+		}
+
+		if diff := commit.Diff(); diff != expected[key].diff {
+			t.Errorf("[%d] Git.readLineCommitsPipe() diff = %q, want: %q", key, diff, expected[key].diff)
+		}
+
+		key++
+	}
+
+	if key != len(expected) {
+		t.Errorf("Git.readLineCommitsPipe() got %d commits, want: %d", key, len(expected))
+	}
+}
+
+func TestGit_SearchHistory(t *testing.T) {
+	g := MakeGitMock(t)
+
+	cases := []struct{
+		repoPath string
+		query string
+		isRegexp bool
+		path string
+		wantCommits bool
+		wantError bool
+	}{
+		{gitRepositoryPath, "non-existent-string-7bd1c0d6", false, "", false, false},
+		{gitRepositoryPath, "non-existent-[0-9]+-7bd1c0d6", true, "", false, false},
+		{gitRepositoryPath, "[a-z]", true, "", true, false},
+		{noRepositoryPath, "test", false, "", false, true},
+	}
+
+	for key, testCase := range cases {
+		var commits []MatchedCommit
+
+		result := make(chan MatchedCommit)
+		e := g.SearchHistory(context.Background(), testCase.repoPath, testCase.query, testCase.isRegexp, testCase.path, "", 0, 10, result)
+
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			loop: for {
+				select {
+				case <-e.ctx.Done():
+					close(result)
+					break loop
+				case commit := <-result:
+					commits = append(commits, commit)
+				}
+			}
+		}()
+
+		err := e.Run()
+
+		wg.Wait()
+
+		if testCase.wantError && err == nil {
+			t.Errorf("[%d] Git.SearchHistory(%v) has no errors, want error", key, testCase)
+			continue
+		} else if !testCase.wantError && err != nil {
+			t.Errorf("[%d] Git.SearchHistory(%v) has error: %v, want no errors", key, testCase, err)
+			continue
+		}
+
+		if testCase.wantCommits != (len(commits) > 0) {
+			t.Errorf("[%d] Git.SearchHistory(%v) got %d commits, want commits: %v", key, testCase, len(commits), testCase.wantCommits)
+		}
+
+		for number, commit := range commits {
+			if commit.Id() == "" {
+				t.Errorf("[%d] Git.SearchHistory(%v) commit %d has empty identifier", key, testCase, number)
+			}
+			if len(commit.Files()) == 0 {
+				t.Errorf("[%d] Git.SearchHistory(%v) commit %d has no matched files", key, testCase, number)
+			}
+		}
+	}
+}
+
+func TestIsGitOptionRevision(t *testing.T) {
+	cases := []struct{
+		revision string
+		want bool
+	}{
+		{"HEAD", false},
+		{"master~2", false},
+		{"a..b", false},
+		{"a...b", false},
+		{"..b", false},
+		{"--all", true},
+		{"-n1", true},
+		{"a..--output=x", true},
+		{"a...-b", true},
+		{"--output=x..b", true},
+	}
+
+	for key, testCase := range cases {
+		if got := isGitOptionRevision(testCase.revision); got != testCase.want {
+			t.Errorf("[%d] isGitOptionRevision(%s) = %v, want: %v", key, testCase.revision, got, testCase.want)
+		}
+	}
+}
+
+func TestGit_GrepOptionRevision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcsview-grep")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() got error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the revision is the option which runs the command if git parses it
+	marker := filepath.Join(dir, "pwned")
+	revision := "--open-files-in-pager=touch " + marker
+
+	err = MakeGitMock(t).Grep(context.Background(), gitRepositoryPath, revision, "[a-z]", false, nil, 0, make(chan GrepMatch, 1000)).Run()
+	if ErrorKind(err) != ErrRevisionNotFound {
+		t.Errorf("Git.Grep(%s) got error: %v, want: %v", revision, err, ErrRevisionNotFound)
+	}
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Errorf("Git.Grep(%s) ran the pager command", revision)
+	}
+}
+
+func TestGit_Grep(t *testing.T) {
+	g := MakeGitMock(t)
+
+	cases := []struct{
+		repoPath string
+		revision string
+		pattern string
+		wantMatches bool
+		wantError bool
+	}{
+		{gitRepositoryPath, "", "[a-z]", true, false},
+		{gitRepositoryPath, "HEAD", "[a-z]", true, false},
+		{gitRepositoryPath, "HEAD", "non-existent-[0-9]+-7bd1c0d6", false, false},
+		{gitRepositoryPath, "HEAD", "invalid-[", false, true},
+		{gitRepositoryPath, "non-existent-revision", "[a-z]", false, true},
+		{gitRepositoryPath, "--open-files-in-pager=true", "[a-z]", false, true},
+		{noRepositoryPath, "HEAD", "[a-z]", false, true},
+	}
+
+	for key, testCase := range cases {
+		var matches []GrepMatch
+
+		result := make(chan GrepMatch)
+		e := g.Grep(context.Background(), testCase.repoPath, testCase.revision, testCase.pattern, false, nil, 1, result)
+
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			loop: for {
+				select {
+				case <-e.ctx.Done():
+					close(result)
+					break loop
+				case m := <-result:
+					matches = append(matches, m)
+				}
+			}
+		}()
+
+		err := e.Run()
+
+		wg.Wait()
+
+		if testCase.wantError && err == nil {
+			t.Errorf("[%d] Git.Grep(%v) has no errors, want error", key, testCase)
+			continue
+		} else if !testCase.wantError && err != nil {
+			t.Errorf("[%d] Git.Grep(%v) has error: %v, want no errors", key, testCase, err)
+			continue
+		}
+
+		if testCase.wantMatches != (len(matches) > 0) {
+			t.Errorf("[%d] Git.Grep(%v) got %d matches, want matches: %v", key, testCase, len(matches), testCase.wantMatches)
+		}
+
+		for number, m := range matches {
+			if m.Pathname() == "" || strings.Contains(m.Pathname(), ":") {
+				t.Errorf("[%d] Git.Grep(%v) match %d has unexpected pathname: %v", key, testCase, number, m.Pathname())
+			}
+			if m.Line() <= 0 {
+				t.Errorf("[%d] Git.Grep(%v) match %d has unexpected line number: %v", key, testCase, number, m.Line())
+			}
+			if !m.IsContext() && m.Column() <= 0 {
+				t.Errorf("[%d] Git.Grep(%v) match %d has unexpected column number: %v", key, testCase, number, m.Column())
+			}
+		}
+	}
+}
+
+func TestGit_ReadGrepPipe(t *testing.T) {
+	g := MakeGitMock(t)
+
+	output := strings.Join([]string{
+		"HEAD:main.go\x003\x00func hello() {",
+		"HEAD:main.go\x004\x002\x00\tprintln(\"v12\")",
+		"HEAD:main.go\x005\x00}",
+		"--",
+		"HEAD:sub:dir/main.go\x0010\x002\x00\tprintln(\"a\x00b\")",
+	}, "\n")
+
+	expected := []GrepMatch{
+		{"main.go", 3, 0, "func hello() {", true},
+		{"main.go", 4, 2, "\tprintln(\"v12\")", false},
+		{"main.go", 5, 0, "}", true},
+		{"sub:dir/main.go", 10, 2, "\tprintln(\"a\x00b\")", false},
+	}
+
+	result := make(chan GrepMatch, len(expected)+1)
+	g.readGrepPipe(bufio.NewScanner(strings.NewReader(output)), "HEAD", result)
+	close(result)
+
+	key := 0
+	for m := range result {
+		if key >= len(expected) {
+			t.Fatalf("Git.readGrepPipe() got more than %d matches", len(expected))
+		}
+
+		if m != expected[key] {
+			t.Errorf("[%d] Git.readGrepPipe() = %v, want: %v", key, m, expected[key])
+		}
+
+		key++
+	}
+
+	if key != len(expected) {
+		t.Errorf("Git.readGrepPipe() got %d matches, want: %d", key, len(expected))
+	}
+}
+
+func TestGit_ReadBlob(t *testing.T) {
+	g := MakeGitMock(t)
+
+	cases := []struct{
+		repoPath string
+		revision string
+		pathname string
+		wantBlob bool
+		wantError bool
+	}{
+		{gitRepositoryPath, "", "testpath/empty.txt", true, false},
+		{gitRepositoryPath, "HEAD", "/testpath/empty.txt", true, false},
+		{gitRepositoryPath, "HEAD", "testpath", false, false},
+		{gitRepositoryPath, "HEAD", "non-existent.txt", false, false},
+		{noRepositoryPath, "HEAD", "testpath/empty.txt", false, true},
+	}
+
+	for key, testCase := range cases {
+		result := make(chan Blob, 1)
+
+		err := g.ReadBlob(context.Background(), testCase.repoPath, testCase.revision, testCase.pathname, result).Run()
+
+		if testCase.wantError && err == nil {
+			t.Errorf("[%d] Git.ReadBlob(%v) has no errors, want error", key, testCase)
+			continue
+		} else if !testCase.wantError && err != nil {
+			t.Errorf("[%d] Git.ReadBlob(%v) has error: %v, want no errors", key, testCase, err)
+			continue
+		}
+
+		select {
+		case blob := <-result:
+			if !testCase.wantBlob {
+				t.Errorf("[%d] Git.ReadBlob(%v) got blob, want nothing", key, testCase)
+			}
+			if blob.Id() == "" {
+				t.Errorf("[%d] Git.ReadBlob(%v) got blob with empty identifier", key, testCase)
+			}
+			if pathname := blob.Pathname(); pathname != "testpath/empty.txt" {
+				t.Errorf("[%d] Git.ReadBlob(%v) got blob pathname: %v, want: testpath/empty.txt", key, testCase, pathname)
+			}
+			if blob.ContentKind() != ContentText {
+				t.Errorf("[%d] Git.ReadBlob(%v) got blob content kind: %v, want: %v", key, testCase, blob.ContentKind(), ContentText)
+			}
+		default:
+			if testCase.wantBlob {
+				t.Errorf("[%d] Git.ReadBlob(%v) got nothing, want blob", key, testCase)
+			}
+		}
+	}
+}
+
+func TestGit_ReadTree(t *testing.T) {
+	g := MakeGitMock(t)
+
+	cases := []struct{
+		repoPath string
+		path string
+		recursive bool
+		wantPathname string
+		wantDir bool
+		wantError bool
+	}{
+		{gitRepositoryPath, "", false, "testpath", true, false},
+		{gitRepositoryPath, "", true, "testpath/empty.txt", false, false},
+		{gitRepositoryPath, "testpath", false, "testpath/empty.txt", false, false},
+		{gitRepositoryPath, "/testpath/", true, "testpath/empty.txt", false, false},
+		{noRepositoryPath, "", false, "", false, true},
+	}
+
+	for key, testCase := range cases {
+		var files []File
+
+		result := make(chan File)
+		e := g.ReadTree(context.Background(), testCase.repoPath, "HEAD", testCase.path, testCase.recursive, result)
+
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			loop: for {
+				select {
+				case <-e.ctx.Done():
+					close(result)
+					break loop
+				case f := <-result:
+					files = append(files, f)
+				}
+			}
+		}()
+
+		err := e.Run()
+
+		wg.Wait()
+
+		if testCase.wantError {
+			if err == nil {
+				t.Errorf("[%d] Git.ReadTree(%v) has no errors, want error", key, testCase)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[%d] Git.ReadTree(%v) has error: %v, want no errors", key, testCase, err)
+			continue
+		}
+
+		found := false
+		for _, f := range files {
+			if f.Pathname() != testCase.wantPathname {
+				continue
+			}
+
+			found = true
+
+			if f.IsDir() != testCase.wantDir {
+				t.Errorf("[%d] Git.ReadTree(%v) file %s is dir: %v, want: %v", key, testCase, f.Pathname(), f.IsDir(), testCase.wantDir)
+			}
+			if f.IsDir() != f.Mode().IsDir() {
+				t.Errorf("[%d] Git.ReadTree(%v) file %s has unexpected mode: %v", key, testCase, f.Pathname(), f.Mode())
+			}
+		}
+
+		if !found {
+			t.Errorf("[%d] Git.ReadTree(%v) = %v, want contains: %v", key, testCase, files, testCase.wantPathname)
+		}
+	}
+}
+
+func TestGitFileMode(t *testing.T) {
+	cases := []struct{
+		mode string
+		fileMode os.FileMode
+	}{
+		{"100644", 0644},
+		{"100755", 0755},
+		{"040000", os.ModeDir | 0755},
+		{"160000", os.ModeDir | 0755},
+		{"120000", os.ModeSymlink | 0777},
+	}
+
+	for key, testCase := range cases {
+		if fileMode := gitFileMode(testCase.mode); fileMode != testCase.fileMode {
+			t.Errorf("[%d] gitFileMode(%s) = %v, want: %v", key, testCase.mode, fileMode, testCase.fileMode)
+		}
+	}
+}
+
+func TestGit_ReadTags(t *testing.T) {
+	g := MakeGitMock(t)
+
+	result := make(chan Tag, 100)
+
+	if err := g.ReadTags(context.Background(), gitRepositoryPath, result).Run(); err != nil {
+		t.Fatalf("Git.ReadTags(%s) got error: %v, want no errors", gitRepositoryPath, err)
+	}
+
+	close(result)
+
+	for tag := range result {
+		if tag.Id() == "" || tag.Head() == "" {
+			t.Errorf("Git.ReadTags(%s) got unexpected tag: %v", gitRepositoryPath, tag)
+		}
+	}
+
+	if err := g.ReadTags(context.Background(), noRepositoryPath, make(chan Tag)).Run(); err == nil {
+		t.Errorf("Git.ReadTags(%s) got no errors, want error", noRepositoryPath)
+	}
+}
+
+func TestGit_ReadDiffOptionRevision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcsview-diff")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() got error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := NewRepository(gitRepositoryPath, MakeGitMock(t))
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+
+	// the commit identifier is the option which writes the file if git parses it
+	output := filepath.Join(dir, "pwned")
+	commitId := "--output=" + output
+
+	if diffs, err := r.Diff(context.Background(), commitId); ErrorKind(err) != ErrRevisionNotFound {
+		t.Errorf("Repository.Diff(%s) = %v, %v, want: %v", commitId, diffs, err, ErrRevisionNotFound)
+	}
+
+	if _, err := os.Stat(output); err == nil {
+		t.Errorf("Repository.Diff(%s) created the output file", commitId)
+	}
+}
+
+func TestGit_ReadDiffPipe(t *testing.T) {
+	g := MakeGitMock(t)
+
+	output := strings.Join([]string{
+		"diff --git a/testpath/random.txt b/testpath/moved.txt",
+		"similarity index 90%",
+		"rename from testpath/random.txt",
+		"rename to testpath/moved.txt",
+		"index 624b469..4b9f239 100644",
+		"--- a/testpath/random.txt",
+		"+++ b/testpath/moved.txt",
+		"@@ -1 +1,2 @@",
+		" line 1",
+		"+--- a/not a header",
+		"diff --git a/image.png b/image.png",
+		"new file mode 100644",
+		"index 0000000..4b9f239",
+		"Binary files /dev/null and b/image.png differ",
+		"diff --git a/testpath/empty.txt b/testpath/empty.txt",
+		"deleted file mode 100644",
+		"index e69de29..0000000",
+		"diff --git a/main.go b/main.go",
+		"index 624b469..4b9f239 100644",
+		"--- a/main.go",
+		"+++ b/main.go",
+		"@@ -1 +1 @@",
+		"-package a",
+		"+package main",
+	}, "\n")
+
+	expected := []FileDiff{
+		{FileRenamed, "testpath/moved.txt", "testpath/random.txt", false, ""},
+		{FileAdded, "image.png", "", true, ""},
+		{FileDeleted, "testpath/empty.txt", "", false, ""},
+		{FileModified, "main.go", "", false, ""},
+	}
+
+	result := make(chan FileDiff, len(expected)+1)
+	g.readDiffPipe(bufio.NewScanner(strings.NewReader(output)), result)
+	close(result)
+
+	key := 0
+	for d := range result {
+		if key >= len(expected) {
+			t.Fatalf("Git.readDiffPipe() got more than %d diffs", len(expected))
+		}
+
+		e := expected[key]
+
+		if d.Status() != e.status || d.Pathname() != e.pathname || d.PreviousPathname() != e.previousPathname || d.IsBinary() != e.isBinary {
+			t.Errorf("[%d] Git.readDiffPipe() = %v, want: %v", key, d, e)
+		}
+
+		if !strings.HasPrefix(d.Diff(), "diff --git ") {
+			t.Errorf("[%d] Git.readDiffPipe() diff = %v, want diff header", key, d.Diff())
+		}
+
+		key++
+	}
+
+	if key != len(expected) {
+		t.Errorf("Git.readDiffPipe() got %d diffs, want: %d", key, len(expected))
+	}
+}
+
+func TestNewGit(t *testing.T) {
+	g := NewGit()
+
+	if version, err := g.Version(context.Background()); err != nil || version == "" {
+		t.Errorf("NewGit().Version() = %v, %v, want version", version, err)
+	}
+}
+
+func TestGitEnvironment(t *testing.T) {
+	env := strings.Join(gitEnvironment([]string{"GIT_TRACE=0"}), "\n") + "\n"
+
+	for _, want := range []string{"LC_ALL=C\n", "GIT_OPTIONAL_LOCKS=0\n", "GIT_CONFIG_NOSYSTEM=1\n", "HOME=" + os.DevNull + "\n", "PATH=", "GIT_TRACE=0\n"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("gitEnvironment() = %q, want: %q", env, want)
+		}
+	}
+}
+
+func TestNewGitWithOptions(t *testing.T) {
+	home, err := ioutil.TempDir("", "vcsview-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	// user configuration defines alias which is unknown without it
+	if err := ioutil.WriteFile(home + "/.gitconfig", []byte("[alias]\n\tvcsviewtest = version\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldHome := os.Getenv("HOME")
+	os.Setenv("HOME", home)
+	defer os.Setenv("HOME", oldHome)
+
+	cases := []struct{
+		g Git
+		wantOk bool
+	}{
+		{NewGit(), false},
+		{NewGitWithOptions(GitOptions{InheritEnv: true}), true},
+		{NewGitWithOptions(GitOptions{Path: "git", Overrides: []string{"alias.vcsviewtest=version"}}), true},
+		{NewGit().WithOverrides("alias.vcsviewtest=version"), true},
+		{NewGitWithOptions(GitOptions{Env: []string{"GIT_CONFIG_GLOBAL=" + home + "/.gitconfig"}}), true},
+		{NewGitWithOptions(GitOptions{Path: "non-existent-git"}), false},
+	}
+
+	for key, testCase := range cases {
+		out, err := testCase.g.createCommand(context.Background(), ".", "vcsviewtest").Output()
+		if ok := err == nil && strings.HasPrefix(string(out), "git version"); ok != testCase.wantOk {
+			t.Errorf("[%d] Git.createCommand(vcsviewtest) = %q, %v, want ok: %v", key, out, err, testCase.wantOk)
+		}
+		testCase.g.Close()
+	}
+
+	// overrides don't change the original wrapper
+	g := NewGit()
+	g.WithOverrides("core.quotePath=false")
+	if args := g.command(context.Background(), ".", "status").Args; len(args) != 2 {
+		t.Errorf("Git.WithOverrides() changed the original: %v", args)
+	}
+	g.Close()
+}
+
+func TestGit_UploadPack(t *testing.T) {
+	g := MakeGitMock(t)
+
+	cases := []struct{
+		advertise bool
+		protocol string
+		contains string
+	}{
+		{true, "", "refs/heads/master"},
+		{true, "version=2", "version 2"},
+		{false, "", ""},
+	}
+
+	for key, testCase := range cases {
+		buf := new(bytes.Buffer)
+
+		// empty request of the client which wants nothing
+		request := strings.NewReader("0000")
+
+		if err := g.UploadPack(context.Background(), gitRepositoryPath, testCase.advertise, testCase.protocol, request, buf).Run(); err != nil {
+			t.Errorf("[%d] Git.UploadPack(%s, %v, %s) has error: %v, want no errors", key, gitRepositoryPath, testCase.advertise, testCase.protocol, err)
+			continue
+		}
+
+		if !strings.Contains(buf.String(), testCase.contains) {
+			t.Errorf("[%d] Git.UploadPack(%s, %v, %s) = %q, want contains: %s", key, gitRepositoryPath, testCase.advertise, testCase.protocol, buf.String(), testCase.contains)
+		}
+	}
+}
+
+func TestGitErrorKind(t *testing.T) {
+	cases := []struct{
+		stderr string
+		want error
+	}{
+		{"fatal: not a git repository (or any of the parent directories): .git\n", ErrRepositoryNotFound},
+		{"fatal: bad revision 'unknown'\n", ErrRevisionNotFound},
+		{"fatal: bad object unknown\n", ErrRevisionNotFound},
+		{"fatal: ambiguous argument 'unknown': unknown revision or path not in the working tree.\n", ErrRevisionNotFound},
+		{"fatal: invalid object name 'unknown'.\n", ErrRevisionNotFound},
+		{"error: short object ID 1a2 is ambiguous\nhint: The candidates are:\nfatal: ambiguous argument '1a2': unknown revision or path not in the working tree.\n", ErrAmbiguousRevision},
+		{"error: short SHA1 1a2 is ambiguous\n", ErrAmbiguousRevision},
+		{"fatal: path 'missing.txt' does not exist in 'HEAD'\n", ErrPathNotFound},
+		{"fatal: path 'file.txt' exists on disk, but not in 'HEAD'\n", ErrPathNotFound},
+		{"fatal: There is no path missing.txt in the commit\n", ErrPathNotFound},
+		{"fatal: unable to access config\n", nil},
+		{"", nil},
+	}
+
+	for key, testCase := range cases {
+		if kind := gitErrorKind(testCase.stderr); kind != testCase.want {
+			t.Errorf("[%d] gitErrorKind(%q) = %v, want: %v", key, testCase.stderr, kind, testCase.want)
+		}
+	}
+}
+
+func TestGit_ErrorKinds(t *testing.T) {
+	// repositories lookup doesn't go to the parent directories out of the temporary one
+	emptyPath, err := ioutil.TempDir("", "vcsview-empty")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() got error: %v", err)
+	}
+	defer os.RemoveAll(emptyPath)
+
+	ambiguousPath, prefix, cleanup := makeAmbiguousRepository(t)
+	defer cleanup()
+
+	pooled := NewGit()
+	pooled.Debugger = MakeGitMock(t).Debugger
+	defer pooled.Close()
+
+	ctx := context.Background()
+
+	for _, g := range []Git{MakeGitMock(t), pooled} {
+		cases := []struct{
+			name string
+			e *Executor
+			want error
+		}{
+			{"ReadHistory(empty)", g.ReadHistory(ctx, emptyPath, "", "", 0, 1, make(chan Commit, 1)), ErrRepositoryNotFound},
+			{"ReadHistory(non-existent)", g.ReadHistory(ctx, emptyPath+"/non-existent", "", "", 0, 1, make(chan Commit, 1)), ErrRepositoryNotFound},
+			{"ReadCommit(empty)", g.ReadCommit(ctx, emptyPath, "HEAD", make(chan Commit, 1)), ErrRepositoryNotFound},
+			{"ReadCommit(non-existent)", g.ReadCommit(ctx, emptyPath+"/non-existent", "HEAD", make(chan Commit, 1)), ErrRepositoryNotFound},
+			{"ReadCommit(unknown)", g.ReadCommit(ctx, gitRepositoryPath, "unknown", make(chan Commit, 1)), ErrRevisionNotFound},
+			{"ReadTree(unknown)", g.ReadTree(ctx, gitRepositoryPath, "unknown", "", false, make(chan File, 100)), ErrRevisionNotFound},
+			{"ReadTree(missing)", g.ReadTree(ctx, gitRepositoryPath, "HEAD", "missing", false, make(chan File, 100)), ErrPathNotFound},
+			{"ReadTree(ambiguous)", g.ReadTree(ctx, ambiguousPath, prefix, "", false, make(chan File, 100)), ErrAmbiguousRevision},
+			{"ReadBlob(unknown)", g.ReadBlob(ctx, gitRepositoryPath, "unknown", "main.go", make(chan Blob, 1)), ErrRevisionNotFound},
+			{"ReadBlob(ambiguous)", g.ReadBlob(ctx, ambiguousPath, prefix, "main.go", make(chan Blob, 1)), ErrAmbiguousRevision},
+			{"readBlobHead(unknown)", g.readBlobHead(ctx, gitRepositoryPath, "unknown", "main.go", 7, make(chan Blob, 1)), ErrRevisionNotFound},
+			{"ReadCommit(ambiguous)", g.ReadCommit(ctx, ambiguousPath, prefix, make(chan Commit, 1)), ErrAmbiguousRevision},
+			{"ReadDiff(unknown)", g.ReadDiff(ctx, gitRepositoryPath, "unknown", make(chan FileDiff, 100)), ErrRevisionNotFound},
+			{"ReadLineHistory(missing)", g.ReadLineHistory(ctx, gitRepositoryPath, "missing.txt", 1, 2, "HEAD", 0, 1, make(chan LineCommit, 1)), ErrPathNotFound},
+		}
+
+		for key, testCase := range cases {
+			err := testCase.e.Run()
+
+			if kind := ErrorKind(err); kind != testCase.want {
+				t.Errorf("[%d] Git.%s got error: %v (kind %v), want: %v", key, testCase.name, err, kind, testCase.want)
+			}
+		}
+	}
+
+	if err := MakeGitMock(t).CheckRepository(emptyPath); ErrorKind(err) != ErrRepositoryNotFound {
+		t.Errorf("Git.CheckRepository(%s) = %v, want: %v", emptyPath, err, ErrRepositoryNotFound)
+	}
+}
@@ -0,0 +1,199 @@
+// Package githttp serves the repository read-only over the git smart HTTP protocol
+// Clients could clone and fetch the repository, pushes and the dumb protocol are not supported
+package githttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/kalyabin/vcsview"
+)
+
+const (
+	// The only supported service
+	uploadPackService = "git-upload-pack"
+)
+
+// Allowed value of the Git-Protocol header like version=2 or version=2:object-format=sha1
+var protocolPattern = regexp.MustCompile(`^[A-Za-z0-9=:._-]+$`)
+
+// VCS which serves upload-pack requests (vcsview.Git does)
+type uploadPacker interface {
+	UploadPack(ctx context.Context, projectPath string, advertise bool, protocol string, request io.Reader, w io.Writer) *vcsview.Executor
+}
+
+// Read-only smart HTTP handler of the single repository
+// Routes relative to the handler prefix:
+// GET  /info/refs?service=git-upload-pack - refs advertisement
+// POST /git-upload-pack                  - fetch negotiation and pack
+type Handler struct {
+	// Served repository
+	repository vcsview.Repository
+
+	// URL path prefix where handler is mounted (without trailing slash)
+	prefix string
+}
+
+// Create new smart HTTP handler
+// Prefix is a URL path prefix where handler is mounted, for example /project.git
+// Handler strips prefix itself, so it shouldn't be wrapped by http.StripPrefix
+func NewHandler(r vcsview.Repository, prefix string) *Handler {
+	return &Handler{r, strings.TrimRight(prefix, "/")}
+}
+
+// Writer which flushes each write to the client to stream pack data without buffering
+type flushWriter struct {
+	w io.Writer
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return n, err
+}
+
+// Encode data as pkt-line
+func pktLine(data string) string {
+	return fmt.Sprintf("%04x%s", len(data)+4, data)
+}
+
+// Set headers which disable response caching
+func noCache(w http.ResponseWriter) {
+	header := w.Header()
+	header.Set("Expires", "Fri, 01 Jan 1980 00:00:00 GMT")
+	header.Set("Pragma", "no-cache")
+	header.Set("Cache-Control", "no-cache, max-age=0, must-revalidate")
+}
+
+// Returns Git-Protocol header value or empty string if it's missing or invalid
+func protocol(req *http.Request) string {
+	if p := req.Header.Get("Git-Protocol"); protocolPattern.MatchString(p) {
+		return p
+	}
+
+	return ""
+}
+
+// Get VCS which serves upload-pack requests
+// Cache decorators are unwrapped, because packs aren't cached
+func uploadPackerOf(vcs vcsview.Vcs) (uploadPacker, bool) {
+	for {
+		if u, ok := vcs.(uploadPacker); ok {
+			return u, true
+		}
+
+		cached, ok := vcs.(*vcsview.CachedVcs)
+		if !ok {
+			return nil, false
+		}
+
+		vcs = cached.Vcs
+	}
+}
+
+// Serve HTTP request
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !strings.HasPrefix(req.URL.Path, h.prefix) {
+		http.NotFound(w, req)
+		return
+	}
+
+	vcs, ok := uploadPackerOf(h.repository.Cmd())
+	if !ok {
+		http.Error(w, "Repository doesn't support smart HTTP protocol", http.StatusNotImplemented)
+		return
+	}
+
+	switch strings.TrimPrefix(req.URL.Path, h.prefix) {
+	case "/info/refs":
+		h.infoRefs(w, req, vcs)
+	case "/" + uploadPackService:
+		h.uploadPack(w, req, vcs)
+	case "/git-receive-pack":
+		http.Error(w, "Repository is read-only", http.StatusForbidden)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// Serve refs advertisement
+func (h *Handler) infoRefs(w http.ResponseWriter, req *http.Request, vcs uploadPacker) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	service := req.URL.Query().Get("service")
+	switch {
+	case service == "":
+		http.Error(w, "Dumb HTTP protocol is not supported", http.StatusForbidden)
+		return
+	case service != uploadPackService:
+		http.Error(w, "Repository is read-only", http.StatusForbidden)
+		return
+	}
+
+	p := protocol(req)
+
+	// advertisement is small, so it's buffered to report errors by status code
+	buf := new(bytes.Buffer)
+	if err := vcs.UploadPack(req.Context(), h.repository.ProjectPath(), true, p, nil, buf).Run(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	noCache(w)
+	w.Header().Set("Content-Type", "application/x-"+service+"-advertisement")
+
+	// protocol v2 capabilities go without service announcement
+	if !strings.Contains(p, "version=2") {
+		io.WriteString(w, pktLine("# service="+service+"\n"))
+		io.WriteString(w, "0000")
+	}
+
+	buf.WriteTo(w)
+}
+
+// Serve fetch negotiation and pack streaming
+func (h *Handler) uploadPack(w http.ResponseWriter, req *http.Request, vcs uploadPacker) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if req.Header.Get("Content-Type") != "application/x-"+uploadPackService+"-request" {
+		http.Error(w, "Unexpected content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body := io.Reader(req.Body)
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	noCache(w)
+	w.Header().Set("Content-Type", "application/x-"+uploadPackService+"-result")
+
+	// response is already started, so the failure aborts it and the client detects broken pack stream
+	if err := vcs.UploadPack(req.Context(), h.repository.ProjectPath(), false, protocol(req), body, flushWriter{w}).Run(); err != nil {
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,128 @@
+package githttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kalyabin/vcsview"
+)
+
+const (
+	gitRepositoryPath = "../testdata/git"
+)
+
+func makeHandler(t *testing.T) *Handler {
+	r, err := vcsview.NewRepository(gitRepositoryPath, vcsview.NewGit())
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+
+	return NewHandler(r, "/project.git/")
+}
+
+func TestHandler_InfoRefs(t *testing.T) {
+	h := makeHandler(t)
+
+	cases := []struct{
+		method string
+		target string
+		status int
+		contains string
+	}{
+		{http.MethodGet, "/project.git/info/refs?service=git-upload-pack", http.StatusOK, "001e# service=git-upload-pack\n0000"},
+		{http.MethodGet, "/project.git/info/refs?service=git-receive-pack", http.StatusForbidden, ""},
+		{http.MethodGet, "/project.git/info/refs", http.StatusForbidden, ""},
+		{http.MethodPost, "/project.git/info/refs?service=git-upload-pack", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "/project.git/git-upload-pack", http.StatusMethodNotAllowed, ""},
+		{http.MethodPost, "/project.git/git-receive-pack", http.StatusForbidden, ""},
+		{http.MethodGet, "/project.git/HEAD", http.StatusNotFound, ""},
+		{http.MethodGet, "/other.git/info/refs?service=git-upload-pack", http.StatusNotFound, ""},
+	}
+
+	for key, testCase := range cases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(testCase.method, testCase.target, nil))
+
+		if w.Code != testCase.status {
+			t.Errorf("[%d] %s %s status = %d, want: %d", key, testCase.method, testCase.target, w.Code, testCase.status)
+			continue
+		}
+
+		if body := w.Body.String(); !strings.Contains(body, testCase.contains) {
+			t.Errorf("[%d] %s %s body = %q, want contains: %q", key, testCase.method, testCase.target, body, testCase.contains)
+		}
+	}
+}
+
+func TestHandler_Vcs(t *testing.T) {
+	cases := []struct{
+		vcs vcsview.Vcs
+		status int
+	}{
+		{vcsview.NewGit(), http.StatusOK},
+		{vcsview.NewCachedVcs(vcsview.NewGit(), 10), http.StatusOK},
+		{vcsview.NewNativeGit(), http.StatusNotImplemented},
+		{vcsview.NewCachedVcs(vcsview.NewNativeGit(), 10), http.StatusNotImplemented},
+	}
+
+	for key, testCase := range cases {
+		r, err := vcsview.NewRepository(gitRepositoryPath, testCase.vcs)
+		if err != nil {
+			t.Fatalf("[%d] Can't create repository for %s. Got error: %v", key, gitRepositoryPath, err)
+		}
+
+		w := httptest.NewRecorder()
+		NewHandler(r, "/project.git/").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/project.git/info/refs?service=git-upload-pack", nil))
+
+		if w.Code != testCase.status {
+			t.Errorf("[%d] GET /project.git/info/refs status = %d, want: %d", key, w.Code, testCase.status)
+		}
+	}
+}
+
+func TestHandler_Clone(t *testing.T) {
+	server := httptest.NewServer(makeHandler(t))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "vcsview-clone")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() got error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, version := range []string{"0", "2"} {
+		target := filepath.Join(dir, "version"+version)
+
+		cmd := exec.Command("git", "-c", "protocol.version="+version, "clone", "--quiet", server.URL+"/project.git", target)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("git clone with protocol version %s got error: %v\n%s", version, err, output)
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Join(target, "testpath", "empty.txt")); err != nil {
+			t.Errorf("git clone with protocol version %s has no testpath/empty.txt: %v", version, err)
+		}
+	}
+}
+
+func TestHandler_UploadPackError(t *testing.T) {
+	h := makeHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/project.git/git-upload-pack", strings.NewReader("invalid request"))
+	req.Header.Set("Content-Type", "application/x-git-upload-pack-request")
+
+	// the response is already started, so the failed upload-pack aborts it
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler {
+			t.Errorf("POST /project.git/git-upload-pack with invalid request panics with %v, want: %v", r, http.ErrAbortHandler)
+		}
+	}()
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
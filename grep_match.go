@@ -0,0 +1,45 @@
+package vcsview
+
+// Represents a line of the code search result
+type GrepMatch struct {
+	// Relative file pathname
+	pathname string
+
+	// Line number (starting from 1)
+	line int
+
+	// Column number of the first match (starting from 1), zero for context lines
+	column int
+
+	// Line text without trailing line break
+	text string
+
+	// True if line is a context line around the match
+	isContext bool
+}
+
+// Get relative file pathname
+func (m GrepMatch) Pathname() string {
+	return m.pathname
+}
+
+// Get line number
+func (m GrepMatch) Line() int {
+	return m.line
+}
+
+// Get column number of the first match in the line
+// Returns zero for context lines
+func (m GrepMatch) Column() int {
+	return m.column
+}
+
+// Get line text
+func (m GrepMatch) Text() string {
+	return m.text
+}
+
+// Returns true if line is a context line and doesn't match the pattern
+func (m GrepMatch) IsContext() bool {
+	return m.isContext
+}
@@ -0,0 +1,48 @@
+package vcsview
+
+import "testing"
+
+func TestGrepMatch_Pathname(t *testing.T) {
+	expectedPathname := "testpath/empty.txt"
+
+	m := GrepMatch{}
+	m.pathname = expectedPathname
+
+	if pathname := m.Pathname(); pathname != expectedPathname {
+		t.Errorf("GrepMatch.Pathname() = %v, want: %v", pathname, expectedPathname)
+	}
+}
+
+func TestGrepMatch_LineAndColumn(t *testing.T) {
+	m := GrepMatch{}
+	m.line = 10
+	m.column = 4
+
+	if line := m.Line(); line != 10 {
+		t.Errorf("GrepMatch.Line() = %v, want: %v", line, 10)
+	}
+
+	if column := m.Column(); column != 4 {
+		t.Errorf("GrepMatch.Column() = %v, want: %v", column, 4)
+	}
+}
+
+func TestGrepMatch_Text(t *testing.T) {
+	expectedText := "\tprintln(\"testing\")"
+
+	m := GrepMatch{}
+	m.text = expectedText
+
+	if text := m.Text(); text != expectedText {
+		t.Errorf("GrepMatch.Text() = %v, want: %v", text, expectedText)
+	}
+}
+
+func TestGrepMatch_IsContext(t *testing.T) {
+	m := GrepMatch{}
+	m.isContext = true
+
+	if !m.IsContext() {
+		t.Errorf("GrepMatch.IsContext() = false, want: true")
+	}
+}
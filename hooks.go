@@ -0,0 +1,152 @@
+package vcsview
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Execution event of the VCS command or in-process function
+type CommandEvent struct {
+	// Operation like log, cat-file or cached commit
+	operation string
+
+	// Command line or function description
+	command string
+
+	// Repository path where the command runs (empty if unknown)
+	path string
+
+	// Start time (after waiting in the scheduler queue)
+	started time.Time
+
+	// Time of waiting in the scheduler queue
+	wait time.Duration
+
+	// Execution time (zero in the start event)
+	duration time.Duration
+
+	// Exit status of the command, -1 if it didn't exit or for functions
+	status int
+
+	// Number of stdout bytes read (zero for functions)
+	bytes int64
+
+	// Execution error
+	err error
+}
+
+// Get operation like log, cat-file or cached commit
+func (e CommandEvent) Operation() string {
+	return e.operation
+}
+
+// Get command line or function description
+func (e CommandEvent) Command() string {
+	return e.command
+}
+
+// Get repository path where the command runs (empty if unknown)
+func (e CommandEvent) Path() string {
+	return e.path
+}
+
+// Get start time
+func (e CommandEvent) Started() time.Time {
+	return e.started
+}
+
+// Get time of waiting in the scheduler queue
+func (e CommandEvent) Wait() time.Duration {
+	return e.wait
+}
+
+// Get execution time (zero in the start event)
+func (e CommandEvent) Duration() time.Duration {
+	return e.duration
+}
+
+// Get exit status of the command, -1 if it didn't exit or for functions
+func (e CommandEvent) Status() int {
+	return e.status
+}
+
+// Get number of stdout bytes read
+func (e CommandEvent) Bytes() int64 {
+	return e.bytes
+}
+
+// Get execution error (nil in the start event)
+func (e CommandEvent) Err() error {
+	return e.err
+}
+
+// Receiver of the execution events
+// Each started execution is finished once, so the pair could be used as a tracing span
+// Hooks are called synchronously from Executor.Run and should be safe for concurrent use
+type Hooks interface {
+	// Called before the execution starts
+	// Returned context is passed to CommandFinish of the same execution (for example, with tracing span)
+	CommandStart(ctx context.Context, e CommandEvent) context.Context
+
+	// Called after the execution finished
+	CommandFinish(ctx context.Context, e CommandEvent)
+}
+
+// Hooks which call several hooks in order
+type multiHooks []Hooks
+
+// Combine several hooks, nil hooks are skipped
+func MultiHooks(hooks ...Hooks) Hooks {
+	result := make(multiHooks, 0, len(hooks))
+	for _, h := range hooks {
+		if h != nil {
+			result = append(result, h)
+		}
+	}
+
+	return result
+}
+
+func (m multiHooks) CommandStart(ctx context.Context, e CommandEvent) context.Context {
+	for _, h := range m {
+		ctx = h.CommandStart(ctx, e)
+	}
+
+	return ctx
+}
+
+func (m multiHooks) CommandFinish(ctx context.Context, e CommandEvent) {
+	for _, h := range m {
+		h.CommandFinish(ctx, e)
+	}
+}
+
+// Returns git operation of the command arguments: the first one which isn't an option
+// Values of -c and -C options are skipped
+func commandOperation(args []string) string {
+	for i := 1; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-c" || arg == "-C":
+			i++
+		case len(arg) > 0 && arg[0] != '-':
+			return arg
+		}
+	}
+
+	return ""
+}
+
+// Reader which counts read bytes
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(c.n, int64(n))
+
+	return n, err
+}
@@ -0,0 +1,108 @@
+package vcsview
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type hooksKey struct{}
+
+// Hooks which record finished events and check the start context is passed to finish
+type recordingHooks struct {
+	mu sync.Mutex
+	started int
+	finished []CommandEvent
+	lostContext int
+}
+
+func (h *recordingHooks) CommandStart(ctx context.Context, e CommandEvent) context.Context {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.started++
+
+	return context.WithValue(ctx, hooksKey{}, e.Command())
+}
+
+func (h *recordingHooks) CommandFinish(ctx context.Context, e CommandEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if ctx.Value(hooksKey{}) != e.Command() {
+		h.lostContext++
+	}
+
+	h.finished = append(h.finished, e)
+}
+
+func TestCommandOperation(t *testing.T) {
+	cases := []struct{
+		args []string
+		want string
+	}{
+		{[]string{"git", "log", "-n", "1"}, "log"},
+		{[]string{"git", "-c", "core.quotePath=false", "-C", "/tmp", "--no-pager", "cat-file", "--batch"}, "cat-file"},
+		{[]string{"git", "--version"}, ""},
+		{[]string{"git"}, ""},
+	}
+
+	for key, testCase := range cases {
+		if operation := commandOperation(testCase.args); operation != testCase.want {
+			t.Errorf("[%d] commandOperation(%v) = %q, want: %q", key, testCase.args, operation, testCase.want)
+		}
+	}
+}
+
+func TestGit_Hooks(t *testing.T) {
+	h := &recordingHooks{}
+
+	g := MakeGitMock(t)
+	g.Hooks = h
+	ctx := context.Background()
+
+	if err := g.ReadHistory(ctx, gitRepositoryPath, "", "", 0, 2, make(chan Commit, 2)).Run(); err != nil {
+		t.Fatalf("Git.ReadHistory() got error: %v", err)
+	}
+
+	if err := g.ReadDiff(ctx, gitRepositoryPath, "non-existent-commit", make(chan FileDiff, 1)).Run(); err == nil {
+		t.Fatalf("Git.ReadDiff(non-existent-commit) got no errors, want error")
+	}
+
+	if err := g.ReadCommit(ctx, gitRepositoryPath, "HEAD", make(chan Commit, 1)).Run(); err != nil {
+		t.Fatalf("Git.ReadCommit(HEAD) got error: %v", err)
+	}
+
+	if h.started != 3 || len(h.finished) != 3 || h.lostContext != 0 {
+		t.Fatalf("Hooks got %d starts, %d finishes, %d lost contexts, want: 3, 3, 0", h.started, len(h.finished), h.lostContext)
+	}
+
+	history, diff, commit := h.finished[0], h.finished[1], h.finished[2]
+
+	if history.Operation() != "log" || history.Path() != gitRepositoryPath || history.Status() != 0 || history.Bytes() == 0 || history.Duration() <= 0 || history.Err() != nil || history.Started().IsZero() {
+		t.Errorf("Git.ReadHistory() event = %+v, want successful log with read bytes", history)
+	}
+
+	if diff.Operation() != "show" || diff.Status() <= 0 || ErrorKind(diff.Err()) != ErrRevisionNotFound {
+		t.Errorf("Git.ReadDiff(non-existent-commit) event = %+v, want failed show", diff)
+	}
+
+	if commit.Operation() != "cat-file" || commit.Path() != gitRepositoryPath || commit.Status() != -1 || commit.Err() != nil {
+		t.Errorf("Git.ReadCommit(HEAD) event = %+v, want cat-file function", commit)
+	}
+}
+
+func TestMultiHooks(t *testing.T) {
+	first, second := &recordingHooks{}, &recordingHooks{}
+
+	h := MultiHooks(first, nil, second)
+
+	ctx := h.CommandStart(context.Background(), CommandEvent{command: "test"})
+	h.CommandFinish(ctx, CommandEvent{command: "test"})
+
+	for key, r := range []*recordingHooks{first, second} {
+		if r.started != 1 || len(r.finished) != 1 || r.lostContext != 0 {
+			t.Errorf("[%d] MultiHooks() called %d starts, %d finishes, %d lost contexts, want: 1, 1, 0", key, r.started, len(r.finished), r.lostContext)
+		}
+	}
+}
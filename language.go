@@ -0,0 +1,238 @@
+package vcsview
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// Languages by file extensions (lower case)
+var languageExtensions = map[string]string{
+	".asm": "Assembly",
+	".bat": "Batchfile",
+	".c": "C",
+	".h": "C",
+	".cc": "C++",
+	".cpp": "C++",
+	".cxx": "C++",
+	".hh": "C++",
+	".hpp": "C++",
+	".cs": "C#",
+	".clj": "Clojure",
+	".coffee": "CoffeeScript",
+	".css": "CSS",
+	".dart": "Dart",
+	".ex": "Elixir",
+	".exs": "Elixir",
+	".elm": "Elm",
+	".erl": "Erlang",
+	".fs": "F#",
+	".go": "Go",
+	".groovy": "Groovy",
+	".gradle": "Groovy",
+	".hs": "Haskell",
+	".html": "HTML",
+	".htm": "HTML",
+	".java": "Java",
+	".js": "JavaScript",
+	".jsx": "JavaScript",
+	".mjs": "JavaScript",
+	".jl": "Julia",
+	".kt": "Kotlin",
+	".kts": "Kotlin",
+	".less": "Less",
+	".lua": "Lua",
+	".m": "Objective-C",
+	".mm": "Objective-C++",
+	".ml": "OCaml",
+	".pas": "Pascal",
+	".pl": "Perl",
+	".pm": "Perl",
+	".php": "PHP",
+	".ps1": "PowerShell",
+	".py": "Python",
+	".r": "R",
+	".rb": "Ruby",
+	".rs": "Rust",
+	".sass": "Sass",
+	".scala": "Scala",
+	".scss": "SCSS",
+	".sh": "Shell",
+	".bash": "Shell",
+	".zsh": "Shell",
+	".sql": "SQL",
+	".swift": "Swift",
+	".tcl": "Tcl",
+	".tex": "TeX",
+	".ts": "TypeScript",
+	".tsx": "TypeScript",
+	".vb": "Visual Basic",
+	".vue": "Vue",
+	".xslt": "XSLT",
+}
+
+// Languages by file names
+var languageFilenames = map[string]string{
+	"BUILD": "Starlark",
+	"CMakeLists.txt": "CMake",
+	"Dockerfile": "Dockerfile",
+	"GNUmakefile": "Makefile",
+	"Gemfile": "Ruby",
+	"Jenkinsfile": "Groovy",
+	"Makefile": "Makefile",
+	"Rakefile": "Ruby",
+	"makefile": "Makefile",
+}
+
+// Languages by shebang interpreters (without version suffix)
+var languageInterpreters = map[string]string{
+	"ash": "Shell",
+	"bash": "Shell",
+	"dash": "Shell",
+	"ksh": "Shell",
+	"lua": "Lua",
+	"node": "JavaScript",
+	"perl": "Perl",
+	"php": "PHP",
+	"python": "Python",
+	"ruby": "Ruby",
+	"sh": "Shell",
+	"tclsh": "Tcl",
+	"zsh": "Shell",
+}
+
+// Paths which are vendored by default (could be overridden by -linguist-vendored attribute)
+var languageVendoredPatterns = []string{
+	"**/vendor/**",
+	"**/node_modules/**",
+	"**/third_party/**",
+	"**/bower_components/**",
+}
+
+// JSON representation of the language usage
+type languageStatJSON struct {
+	Name string `json:"name"`
+	Bytes int64 `json:"bytes"`
+	Files int `json:"files"`
+}
+
+// Represents language usage in the project
+type LanguageStat struct {
+	// Language name
+	name string
+
+	// Summary size of the language files
+	bytes int64
+
+	// Number of the language files
+	files int
+}
+
+// Get language name
+func (l LanguageStat) Name() string {
+	return l.name
+}
+
+// Get summary bytes size of the language files
+func (l LanguageStat) Bytes() int64 {
+	return l.bytes
+}
+
+// Get number of the language files
+func (l LanguageStat) Files() int {
+	return l.files
+}
+
+// Encode language usage to JSON object
+func (l LanguageStat) MarshalJSON() ([]byte, error) {
+	return json.Marshal(languageStatJSON{l.name, l.bytes, l.files})
+}
+
+// Decode language usage from JSON object
+func (l *LanguageStat) UnmarshalJSON(data []byte) error {
+	var v languageStatJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*l = LanguageStat{v.Name, v.Bytes, v.Files}
+
+	return nil
+}
+
+// Detect language by file name or extension
+// Returns empty string if language is unknown
+func languageByPathname(pathname string) string {
+	name := path.Base(pathname)
+
+	if language, ok := languageFilenames[name]; ok {
+		return language
+	}
+
+	return languageExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// Maximum length of the shebang line which is read to detect language
+const shebangMaxLength = 1024
+
+// Detect language by shebang line like #!/usr/bin/env python3
+// Returns empty string if content has no shebang or interpreter is unknown
+func languageByShebang(content []byte) string {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return ""
+	}
+
+	line := content[2:]
+	if pos := bytes.IndexByte(line, '\n'); pos >= 0 {
+		line = line[:pos]
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		// skip env options like -S
+		interpreter = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				interpreter = path.Base(f)
+				break
+			}
+		}
+	}
+
+	// python3.8 -> python
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+
+	return languageInterpreters[interpreter]
+}
+
+// Returns true if file is vendored by linguist-vendored attribute or default vendored paths
+func isLanguageVendored(pathname string, a Attributes) bool {
+	switch a.Get(pathname, "linguist-vendored") {
+	case AttributeSet, "true":
+		return true
+	case AttributeUnset, "false":
+		return false
+	}
+
+	for _, pattern := range languageVendoredPatterns {
+		if matchPathSegments(strings.Split(pattern, "/"), strings.Split(pathname, "/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true if file is generated by linguist-generated attribute
+func isLanguageGenerated(pathname string, a Attributes) bool {
+	value := a.Get(pathname, "linguist-generated")
+
+	return value == AttributeSet || value == "true"
+}
@@ -0,0 +1,110 @@
+package vcsview
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLanguageStat(t *testing.T) {
+	l := LanguageStat{}
+	l.name = "Go"
+	l.bytes = 1024
+	l.files = 3
+
+	if name := l.Name(); name != "Go" {
+		t.Errorf("LanguageStat.Name() = %v, want: %v", name, "Go")
+	}
+
+	if bytes := l.Bytes(); bytes != 1024 {
+		t.Errorf("LanguageStat.Bytes() = %v, want: %v", bytes, 1024)
+	}
+
+	if files := l.Files(); files != 3 {
+		t.Errorf("LanguageStat.Files() = %v, want: %v", files, 3)
+	}
+}
+
+func TestLanguageStat_MarshalJSON(t *testing.T) {
+	l := LanguageStat{"Go", 1024, 3}
+	want := `{"name":"Go","bytes":1024,"files":3}`
+
+	data, err := json.Marshal(l)
+	if err != nil || string(data) != want {
+		t.Errorf("LanguageStat.MarshalJSON() = %s, %v, want: %s", data, err, want)
+	}
+
+	var decoded LanguageStat
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded != l {
+		t.Errorf("LanguageStat.UnmarshalJSON(%s) = %v, %v, want: %v", data, decoded, err, l)
+	}
+}
+
+func TestLanguageByPathname(t *testing.T) {
+	cases := []struct{
+		pathname string
+		language string
+	}{
+		{"main.go", "Go"},
+		{"src/Main.JAVA", "Java"},
+		{"build/Makefile", "Makefile"},
+		{"Dockerfile", "Dockerfile"},
+		{"testpath/empty.txt", ""},
+		{"script", ""},
+	}
+
+	for key, testCase := range cases {
+		if language := languageByPathname(testCase.pathname); language != testCase.language {
+			t.Errorf("[%d] languageByPathname(%s) = %v, want: %v", key, testCase.pathname, language, testCase.language)
+		}
+	}
+}
+
+func TestLanguageByShebang(t *testing.T) {
+	cases := []struct{
+		content string
+		language string
+	}{
+		{"#!/bin/sh\necho 1\n", "Shell"},
+		{"#!/usr/bin/env python3\nprint(1)\n", "Python"},
+		{"#!/usr/bin/env -S node --harmony\n", "JavaScript"},
+		{"#!/usr/bin/python2.7", "Python"},
+		{"#!/usr/bin/unknown\n", ""},
+		{"#!\n", ""},
+		{"echo 1\n", ""},
+		{"", ""},
+	}
+
+	for key, testCase := range cases {
+		if language := languageByShebang([]byte(testCase.content)); language != testCase.language {
+			t.Errorf("[%d] languageByShebang(%q) = %v, want: %v", key, testCase.content, language, testCase.language)
+		}
+	}
+}
+
+func TestIsLanguageVendoredAndGenerated(t *testing.T) {
+	a := Attributes{}
+	a.Add("", "lib/** linguist-vendored\nvendor/own/** -linguist-vendored\n*.pb.go linguist-generated=true\n")
+
+	cases := []struct{
+		pathname string
+		vendored bool
+		generated bool
+	}{
+		{"main.go", false, false},
+		{"lib/a.go", true, false},
+		{"vendor/github.com/a/a.go", true, false},
+		{"web/node_modules/a/a.js", true, false},
+		{"vendor/own/a.go", false, false},
+		{"api/api.pb.go", false, true},
+	}
+
+	for key, testCase := range cases {
+		if vendored := isLanguageVendored(testCase.pathname, a); vendored != testCase.vendored {
+			t.Errorf("[%d] isLanguageVendored(%s) = %v, want: %v", key, testCase.pathname, vendored, testCase.vendored)
+		}
+
+		if generated := isLanguageGenerated(testCase.pathname, a); generated != testCase.generated {
+			t.Errorf("[%d] isLanguageGenerated(%s) = %v, want: %v", key, testCase.pathname, generated, testCase.generated)
+		}
+	}
+}
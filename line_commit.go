@@ -0,0 +1,22 @@
+package vcsview
+
+// Represents a commit of the line range history together with the diff hunk of this range
+type LineCommit struct {
+	Commit
+
+	// Relative file pathname in the commit
+	pathname string
+
+	// Unified diff of the line range in the commit
+	diff string
+}
+
+// Get relative file pathname the file had in the commit
+func (c LineCommit) Pathname() string {
+	return c.pathname
+}
+
+// Get unified diff of the line range in the commit (headers and hunks)
+func (c LineCommit) Diff() string {
+	return c.diff
+}
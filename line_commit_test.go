@@ -0,0 +1,25 @@
+package vcsview
+
+import "testing"
+
+func TestLineCommit_Pathname(t *testing.T) {
+	expectedPathname := "testpath/empty.txt"
+
+	c := LineCommit{}
+	c.pathname = expectedPathname
+
+	if pathname := c.Pathname(); pathname != expectedPathname {
+		t.Errorf("LineCommit.Pathname() = %v, want: %v", pathname, expectedPathname)
+	}
+}
+
+func TestLineCommit_Diff(t *testing.T) {
+	expectedDiff := "@@ -3,3 +3,3 @@\n func hello() {\n-\tprintln(\"v1\")\n+\tprintln(\"v2\")\n }\n"
+
+	c := LineCommit{}
+	c.diff = expectedDiff
+
+	if diff := c.Diff(); diff != expectedDiff {
+		t.Errorf("LineCommit.Diff() = %v, want: %v", diff, expectedDiff)
+	}
+}
@@ -2,8 +2,10 @@ package vcsview
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"strings"
 	"testing"
 	"time"
 )
@@ -78,6 +80,44 @@ func checkRepo(repoType string, repoPath string) error {
 	return nil
 }
 
+// Create temporary Git repository with two blobs which identifiers have the same first 4 digits
+// Returns repository path, the ambiguous short identifier and function to remove the repository
+func makeAmbiguousRepository(t *testing.T) (string, string, func()) {
+	dir, err := ioutil.TempDir("", "vcsview-ambiguous")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() got error: %v", err)
+	}
+	cleanup := func() {
+		os.RemoveAll(dir)
+	}
+
+	run := func(stdin string, args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Stdin = strings.NewReader(stdin)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			cleanup()
+			t.Fatalf("git %v got error: %v: %s", args, err, out)
+		}
+	}
+
+	run("", "init", "-q")
+
+	contents := make(map[string]string)
+	for i := 0; ; i++ {
+		content := fmt.Sprintf("blob %d\n", i)
+		prefix := GitBlobId([]byte(content))[:4]
+
+		if other, ok := contents[prefix]; ok {
+			run(other, "hash-object", "-w", "--stdin")
+			run(content, "hash-object", "-w", "--stdin")
+			return dir, prefix, cleanup
+		}
+
+		contents[prefix] = content
+	}
+}
+
 func TestMain(m *testing.M) {
 	if err := checkRepo("git", gitRepositoryPath); err != nil {
 		panic(err)
@@ -0,0 +1,738 @@
+package vcsview
+
+import (
+	"bytes"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Function to rewrite link destinations of Markdown document
+// Dest is a link destination as it is written in the document
+// IsImage is true for image sources
+// Returns destination for HTML document
+type MarkdownLinkFunc func(dest string, isImage bool) string
+
+var (
+	markdownHeadingPattern = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	markdownFencePattern = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	markdownRulePattern = regexp.MustCompile(`^ {0,3}((?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	markdownListPattern = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])([ \t]+|$)`)
+	markdownSetextPattern = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	markdownReferencePattern = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:[ \t]*<?([^\s>]+)>?(?:[ \t]+["'(](.*)["')])?[ \t]*$`)
+	markdownTableDelimiterPattern = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	markdownAutolinkPattern = regexp.MustCompile(`^<((?:https?|ftp)://[^\s<>]+|mailto:[^\s<>]+)>`)
+)
+
+// URL schemes which are allowed in rendered documents
+var markdownAllowedSchemes = map[string]bool{
+	"http": true,
+	"https": true,
+	"ftp": true,
+	"mailto": true,
+}
+
+// Markdown document renderer
+// Raw HTML isn't supported and is escaped, so rendered document is safe to embed into the page
+type markdownRenderer struct {
+	// Link destination rewriter
+	link MarkdownLinkFunc
+
+	// Link reference definitions by lower case labels
+	references map[string]markdownReference
+
+	// Output buffer
+	out *bytes.Buffer
+}
+
+// Link reference definition: [label]: dest "title"
+type markdownReference struct {
+	dest string
+	title string
+}
+
+// Render Markdown document to HTML
+// Raw HTML of the document is escaped, link destinations with unsafe schemes are dropped
+// Link rewrites relative link destinations and image sources, could be nil
+func RenderMarkdown(source []byte, link MarkdownLinkFunc) string {
+	r := &markdownRenderer{
+		link: link,
+		references: make(map[string]markdownReference),
+		out: new(bytes.Buffer),
+	}
+
+	text := strings.Replace(string(source), "\r\n", "\n", -1)
+	text = strings.Replace(text, "\t", "    ", -1)
+
+	lines := r.collectReferences(strings.Split(text, "\n"))
+	r.renderBlocks(lines)
+
+	return r.out.String()
+}
+
+// Remove link reference definitions from the document and remember them
+func (r *markdownRenderer) collectReferences(lines []string) []string {
+	result := make([]string, 0, len(lines))
+	inFence := false
+
+	for _, line := range lines {
+		if markdownFencePattern.MatchString(line) {
+			inFence = !inFence
+		}
+
+		if !inFence {
+			if m := markdownReferencePattern.FindStringSubmatch(line); m != nil {
+				r.references[strings.ToLower(m[1])] = markdownReference{m[2], m[3]}
+				continue
+			}
+		}
+
+		result = append(result, line)
+	}
+
+	return result
+}
+
+// Returns true if line is blank
+func isBlankLine(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// Returns true if line starts a block which interrupts a paragraph
+func (r *markdownRenderer) isBlockStart(line string) bool {
+	return markdownHeadingPattern.MatchString(line) ||
+		markdownFencePattern.MatchString(line) ||
+		markdownRulePattern.MatchString(line) ||
+		markdownListPattern.MatchString(line) ||
+		strings.HasPrefix(strings.TrimLeft(line, " "), ">")
+}
+
+// Render block level elements
+func (r *markdownRenderer) renderBlocks(lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case isBlankLine(line):
+			i++
+		case markdownFencePattern.MatchString(line):
+			i = r.renderFence(lines, i)
+		case markdownHeadingPattern.MatchString(line):
+			m := markdownHeadingPattern.FindStringSubmatch(line)
+			r.renderHeading(len(m[1]), m[2])
+			i++
+		case markdownRulePattern.MatchString(line):
+			r.out.WriteString("<hr>\n")
+			i++
+		case strings.HasPrefix(strings.TrimLeft(line, " "), ">"):
+			i = r.renderBlockquote(lines, i)
+		case markdownListPattern.MatchString(line):
+			i = r.renderList(lines, i)
+		case strings.HasPrefix(line, "    "):
+			i = r.renderIndentedCode(lines, i)
+		case strings.Contains(line, "|") && i+1 < len(lines) && markdownTableDelimiterPattern.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-"):
+			i = r.renderTable(lines, i)
+		default:
+			i = r.renderParagraph(lines, i)
+		}
+	}
+}
+
+// Render fenced code block starting at line i
+// Returns the index of the line after the block
+func (r *markdownRenderer) renderFence(lines []string, i int) int {
+	m := markdownFencePattern.FindStringSubmatch(lines[i])
+	indent, fence, info := len(m[1]), m[2], m[3]
+
+	code := make([]string, 0)
+	i++
+
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+
+		line := lines[i]
+		for n := 0; n < indent && strings.HasPrefix(line, " "); n++ {
+			line = line[1:]
+		}
+		code = append(code, line)
+	}
+
+	r.out.WriteString("<pre><code")
+	if info != "" {
+		r.out.WriteString(` class="language-` + html.EscapeString(info) + `"`)
+	}
+	r.out.WriteString(">")
+	for _, line := range code {
+		r.out.WriteString(html.EscapeString(line) + "\n")
+	}
+	r.out.WriteString("</code></pre>\n")
+
+	return i
+}
+
+// Render indented code block starting at line i
+// Returns the index of the line after the block
+func (r *markdownRenderer) renderIndentedCode(lines []string, i int) int {
+	code := make([]string, 0)
+
+	for ; i < len(lines) && (strings.HasPrefix(lines[i], "    ") || isBlankLine(lines[i])); i++ {
+		code = append(code, strings.TrimPrefix(lines[i], "    "))
+	}
+
+	// trailing blank lines doesn't belong to code
+	for len(code) > 0 && isBlankLine(code[len(code)-1]) {
+		code = code[:len(code)-1]
+	}
+
+	r.out.WriteString("<pre><code>")
+	for _, line := range code {
+		r.out.WriteString(html.EscapeString(line) + "\n")
+	}
+	r.out.WriteString("</code></pre>\n")
+
+	return i
+}
+
+// Make heading anchor like GitHub does: lower case words separated by dashes
+func markdownSlug(text string) string {
+	slug := make([]rune, 0, len(text))
+
+	for _, c := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '-' || c == '_':
+			slug = append(slug, c)
+		case c == ' ':
+			slug = append(slug, '-')
+		}
+	}
+
+	return string(slug)
+}
+
+// Render heading of the level
+func (r *markdownRenderer) renderHeading(level int, text string) {
+	tag := string(rune('0' + level))
+	plain := markdownPlainText(text)
+
+	r.out.WriteString(`<h` + tag + ` id="` + html.EscapeString(markdownSlug(plain)) + `">`)
+	r.out.WriteString(r.renderInline(text))
+	r.out.WriteString("</h" + tag + ">\n")
+}
+
+// Render blockquote starting at line i
+// Returns the index of the line after the blockquote
+func (r *markdownRenderer) renderBlockquote(lines []string, i int) int {
+	quoted := make([]string, 0)
+
+	for ; i < len(lines) && !isBlankLine(lines[i]); i++ {
+		line := strings.TrimLeft(lines[i], " ")
+		if !strings.HasPrefix(line, ">") && len(quoted) > 0 && r.isBlockStart(line) {
+			break
+		}
+
+		line = strings.TrimPrefix(line, ">")
+		line = strings.TrimPrefix(line, " ")
+		quoted = append(quoted, line)
+	}
+
+	r.out.WriteString("<blockquote>\n")
+	r.renderBlocks(quoted)
+	r.out.WriteString("</blockquote>\n")
+
+	return i
+}
+
+// Render list starting at line i
+// Items contents are rendered recursively, so lists could be nested
+// Returns the index of the line after the list
+func (r *markdownRenderer) renderList(lines []string, i int) int {
+	m := markdownListPattern.FindStringSubmatch(lines[i])
+	ordered := !strings.ContainsAny(m[2], "-*+")
+	marker := m[2][len(m[2])-1:]
+
+	tag := "ul"
+	start := ""
+	if ordered {
+		tag = "ol"
+		if number := strings.TrimLeft(m[2][:len(m[2])-1], "0"); number != "1" {
+			if number == "" {
+				number = "0"
+			}
+			start = ` start="` + number + `"`
+		}
+	}
+
+	items := make([][]string, 0)
+	loose := false
+	blank := false
+
+	for i < len(lines) {
+		m := markdownListPattern.FindStringSubmatch(lines[i])
+		if m == nil || m[2][len(m[2])-1:] != marker || ordered == strings.ContainsAny(m[2], "-*+") {
+			break
+		}
+
+		if blank {
+			loose = true
+		}
+
+		width := len(m[0])
+		if m[3] == "" || len(m[3]) > 4 {
+			width = len(m[1]) + len(m[2]) + 1
+		}
+
+		item := []string{lines[i][minInt(width, len(lines[i])):]}
+		i++
+		blank = false
+
+		for ; i < len(lines); i++ {
+			line := lines[i]
+
+			if isBlankLine(line) {
+				blank = true
+				item = append(item, "")
+				continue
+			}
+
+			indent := len(line) - len(strings.TrimLeft(line, " "))
+
+			if indent >= width {
+				if blank {
+					loose = true
+					blank = false
+				}
+				item = append(item, line[width:])
+				continue
+			}
+
+			if blank || r.isBlockStart(line) {
+				break
+			}
+
+			// lazy paragraph continuation
+			item = append(item, strings.TrimLeft(line, " "))
+		}
+
+		for len(item) > 0 && isBlankLine(item[len(item)-1]) {
+			item = item[:len(item)-1]
+		}
+
+		items = append(items, item)
+	}
+
+	r.out.WriteString("<" + tag + start + ">\n")
+
+	for _, item := range items {
+		r.out.WriteString("<li>")
+
+		if loose {
+			r.out.WriteString("\n")
+			r.renderBlocks(item)
+		} else {
+			r.renderTightItem(item)
+		}
+
+		r.out.WriteString("</li>\n")
+	}
+
+	r.out.WriteString("</" + tag + ">\n")
+
+	return i
+}
+
+// Render list item without paragraphs wrapping
+func (r *markdownRenderer) renderTightItem(item []string) {
+	end := 0
+	for end < len(item) && !isBlankLine(item[end]) && (end == 0 || !r.isBlockStart(item[end])) {
+		end++
+	}
+
+	r.out.WriteString(r.renderInline(strings.Join(item[:end], "\n")))
+
+	if end < len(item) {
+		r.out.WriteString("\n")
+		r.renderBlocks(item[end:])
+	}
+}
+
+// Split table row to cells
+func markdownTableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	cells := make([]string, 0)
+	cell := ""
+
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell += "|"
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell))
+			cell = ""
+		default:
+			cell += line[i : i+1]
+		}
+	}
+
+	return append(cells, strings.TrimSpace(cell))
+}
+
+// Render table starting at line i (header, delimiter row and body rows)
+// Returns the index of the line after the table
+func (r *markdownRenderer) renderTable(lines []string, i int) int {
+	header := markdownTableCells(lines[i])
+	delimiters := markdownTableCells(lines[i+1])
+
+	aligns := make([]string, len(header))
+	for key := range aligns {
+		if key >= len(delimiters) {
+			break
+		}
+
+		d := delimiters[key]
+		switch {
+		case strings.HasPrefix(d, ":") && strings.HasSuffix(d, ":"):
+			aligns[key] = ` align="center"`
+		case strings.HasSuffix(d, ":"):
+			aligns[key] = ` align="right"`
+		case strings.HasPrefix(d, ":"):
+			aligns[key] = ` align="left"`
+		}
+	}
+
+	writeRow := func(cells []string, tag string) {
+		r.out.WriteString("<tr>")
+		for key := range header {
+			cell := ""
+			if key < len(cells) {
+				cell = cells[key]
+			}
+			r.out.WriteString("<" + tag + aligns[key] + ">" + r.renderInline(cell) + "</" + tag + ">")
+		}
+		r.out.WriteString("</tr>\n")
+	}
+
+	r.out.WriteString("<table>\n<thead>\n")
+	writeRow(header, "th")
+	r.out.WriteString("</thead>\n")
+
+	i += 2
+
+	if i < len(lines) && !isBlankLine(lines[i]) && !r.isBlockStart(lines[i]) {
+		r.out.WriteString("<tbody>\n")
+		for ; i < len(lines) && !isBlankLine(lines[i]) && !r.isBlockStart(lines[i]); i++ {
+			writeRow(markdownTableCells(lines[i]), "td")
+		}
+		r.out.WriteString("</tbody>\n")
+	}
+
+	r.out.WriteString("</table>\n")
+
+	return i
+}
+
+// Render paragraph starting at line i
+// Paragraph followed by === or --- line is a heading
+// Returns the index of the line after the paragraph
+func (r *markdownRenderer) renderParagraph(lines []string, i int) int {
+	text := []string{strings.TrimLeft(lines[i], " ")}
+	i++
+
+	for ; i < len(lines) && !isBlankLine(lines[i]); i++ {
+		if m := markdownSetextPattern.FindStringSubmatch(lines[i]); m != nil {
+			level := 1
+			if m[1][0] == '-' {
+				level = 2
+			}
+			r.renderHeading(level, strings.Join(text, " "))
+			return i + 1
+		}
+
+		if r.isBlockStart(lines[i]) {
+			break
+		}
+
+		text = append(text, strings.TrimLeft(lines[i], " "))
+	}
+
+	r.out.WriteString("<p>" + r.renderInline(strings.Join(text, "\n")) + "</p>\n")
+
+	return i
+}
+
+// Returns the lesser of two integers
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// Returns text without Markdown inline syntax for anchors and alt texts
+func markdownPlainText(text string) string {
+	r := strings.NewReplacer("*", "", "_", "", "`", "", "~", "", "[", "", "]", "")
+	return strings.TrimSpace(r.Replace(text))
+}
+
+// Returns destination for HTML document
+// Unsafe schemes (javascript, data, etc.) are dropped, relative destinations are rewritten
+func (r *markdownRenderer) destination(dest string, isImage bool) string {
+	dest = strings.TrimSpace(dest)
+
+	u, err := url.Parse(dest)
+	if err != nil {
+		return ""
+	}
+
+	if u.Scheme != "" {
+		if !markdownAllowedSchemes[strings.ToLower(u.Scheme)] {
+			return ""
+		}
+		return dest
+	}
+
+	if r.link == nil || strings.HasPrefix(dest, "#") || strings.HasPrefix(dest, "//") {
+		return dest
+	}
+
+	return r.link(dest, isImage)
+}
+
+// Find index of the bracket closing the one at text[start]
+// Returns -1 if bracket isn't closed
+func markdownClosingBracket(text string, start int, open byte, close byte) int {
+	depth := 0
+
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// Parse inline link destination with optional title: (dest "title")
+func markdownParseDestination(s string) (string, string) {
+	s = strings.TrimSpace(s)
+
+	if strings.HasPrefix(s, "<") {
+		if end := strings.Index(s, ">"); end > 0 {
+			return s[1:end], strings.Trim(strings.TrimSpace(s[end+1:]), `"'()`)
+		}
+	}
+
+	if pos := strings.IndexAny(s, " \t\n"); pos > 0 {
+		return s[:pos], strings.Trim(strings.TrimSpace(s[pos:]), `"'()`)
+	}
+
+	return s, ""
+}
+
+// Render link or image at text[start] ([label](dest), [label][ref] or [ref])
+// Returns rendered HTML and the length of the parsed text, zero length means it isn't a link
+func (r *markdownRenderer) renderLink(text string, start int, isImage bool) (string, int) {
+	open := start
+	if isImage {
+		open++
+	}
+
+	closeLabel := markdownClosingBracket(text, open, '[', ']')
+	if closeLabel < 0 {
+		return "", 0
+	}
+
+	label := text[open+1 : closeLabel]
+	end := closeLabel + 1
+
+	var dest, title string
+
+	switch {
+	case end < len(text) && text[end] == '(':
+		closeDest := markdownClosingBracket(text, end, '(', ')')
+		if closeDest < 0 {
+			return "", 0
+		}
+		dest, title = markdownParseDestination(text[end+1 : closeDest])
+		end = closeDest + 1
+	case end < len(text) && text[end] == '[':
+		closeRef := strings.IndexByte(text[end:], ']')
+		if closeRef < 0 {
+			return "", 0
+		}
+		ref := text[end+1 : end+closeRef]
+		if ref == "" {
+			ref = label
+		}
+		reference, ok := r.references[strings.ToLower(ref)]
+		if !ok {
+			return "", 0
+		}
+		dest, title = reference.dest, reference.title
+		end += closeRef + 1
+	default:
+		reference, ok := r.references[strings.ToLower(label)]
+		if !ok {
+			return "", 0
+		}
+		dest, title = reference.dest, reference.title
+	}
+
+	dest = r.destination(dest, isImage)
+
+	titleAttr := ""
+	if title != "" {
+		titleAttr = ` title="` + html.EscapeString(title) + `"`
+	}
+
+	if isImage {
+		return `<img src="` + html.EscapeString(dest) + `" alt="` + html.EscapeString(markdownPlainText(label)) + `"` + titleAttr + `>`, end - start
+	}
+
+	return `<a href="` + html.EscapeString(dest) + `"` + titleAttr + `>` + r.renderInline(label) + `</a>`, end - start
+}
+
+// Render emphasis delimited by delimiter at text[start]
+// Returns rendered HTML and the length of the parsed text, zero length means it isn't an emphasis
+func (r *markdownRenderer) renderEmphasis(text string, start int) (string, int) {
+	tags := []struct{
+		delimiter string
+		tag string
+	}{
+		{"**", "strong"},
+		{"__", "strong"},
+		{"~~", "del"},
+		{"*", "em"},
+		{"_", "em"},
+	}
+
+	for _, t := range tags {
+		if !strings.HasPrefix(text[start:], t.delimiter) {
+			continue
+		}
+
+		from := start + len(t.delimiter)
+		if from >= len(text) || text[from] == ' ' || text[from] == '\n' {
+			continue
+		}
+
+		// snake_case words aren't emphasized
+		if t.delimiter[0] == '_' && start > 0 && isMarkdownWordChar(text[start-1]) {
+			continue
+		}
+
+		for pos := from + 1; pos <= len(text)-len(t.delimiter); pos++ {
+			if text[pos-1] == '\\' || !strings.HasPrefix(text[pos:], t.delimiter) || text[pos-1] == ' ' {
+				continue
+			}
+
+			end := pos + len(t.delimiter)
+			if t.delimiter[0] == '_' && end < len(text) && isMarkdownWordChar(text[end]) {
+				continue
+			}
+
+			// single delimiter shouldn't close on double one
+			if len(t.delimiter) == 1 && end < len(text) && text[end] == t.delimiter[0] {
+				pos++
+				continue
+			}
+
+			return "<" + t.tag + ">" + r.renderInline(text[from:pos]) + "</" + t.tag + ">", end - start
+		}
+	}
+
+	return "", 0
+}
+
+// Returns true if char is a letter or digit
+func isMarkdownWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// Render inline elements: code spans, links, images, emphasis and line breaks
+// Any other text is escaped
+func (r *markdownRenderer) renderInline(text string) string {
+	out := new(bytes.Buffer)
+
+	for i := 0; i < len(text); {
+		c := text[i]
+
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte("\\`*_{}[]()#+-.!|~<>", text[i+1]) >= 0:
+			out.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			ticks := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+			delimiter := text[i : i+ticks]
+			if end := strings.Index(text[i+ticks:], delimiter); end >= 0 {
+				code := strings.Replace(text[i+ticks:i+ticks+end], "\n", " ", -1)
+				out.WriteString("<code>" + html.EscapeString(strings.TrimSpace(code)) + "</code>")
+				i += ticks + end + ticks
+				continue
+			}
+			out.WriteString(delimiter)
+			i += ticks
+			continue
+		case c == '!' && i+1 < len(text) && text[i+1] == '[':
+			if rendered, n := r.renderLink(text, i, true); n > 0 {
+				out.WriteString(rendered)
+				i += n
+				continue
+			}
+		case c == '[':
+			if rendered, n := r.renderLink(text, i, false); n > 0 {
+				out.WriteString(rendered)
+				i += n
+				continue
+			}
+		case c == '<':
+			if m := markdownAutolinkPattern.FindStringSubmatch(text[i:]); m != nil {
+				out.WriteString(`<a href="` + html.EscapeString(m[1]) + `">` + html.EscapeString(strings.TrimPrefix(m[1], "mailto:")) + `</a>`)
+				i += len(m[0])
+				continue
+			}
+		case c == '*' || c == '_' || c == '~':
+			if rendered, n := r.renderEmphasis(text, i); n > 0 {
+				out.WriteString(rendered)
+				i += n
+				continue
+			}
+		case c == '\n':
+			if strings.HasSuffix(out.String(), "  ") || strings.HasSuffix(text[:i], "\\") {
+				trimmed := strings.TrimRight(strings.TrimSuffix(out.String(), "\\"), " ")
+				out.Reset()
+				out.WriteString(trimmed + "<br>\n")
+			} else {
+				out.WriteString("\n")
+			}
+			i++
+			continue
+		}
+
+		out.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+
+	return out.String()
+}
@@ -0,0 +1,66 @@
+package vcsview
+
+import "testing"
+
+func TestRenderMarkdown(t *testing.T) {
+	cases := []struct{
+		source string
+		html string
+	}{
+		{"# Title\n", "<h1 id=\"title\">Title</h1>\n"},
+		{"Title\n=====\n\nSub title\n---\n", "<h1 id=\"title\">Title</h1>\n<h2 id=\"sub-title\">Sub title</h2>\n"},
+		{"## Hello *world* ##\n", "<h2 id=\"hello-world\">Hello <em>world</em></h2>\n"},
+		{"#hashtag\n", "<p>#hashtag</p>\n"},
+		{"first\nsecond\n\nthird\n", "<p>first\nsecond</p>\n<p>third</p>\n"},
+		{"line  \nbreak\n", "<p>line<br>\nbreak</p>\n"},
+		{"**bold** __bold__ *em* _em_ ~~del~~ snake_case_name\n", "<p><strong>bold</strong> <strong>bold</strong> <em>em</em> <em>em</em> <del>del</del> snake_case_name</p>\n"},
+		{"use `a < b` and ``x ` y``\n", "<p>use <code>a &lt; b</code> and <code>x ` y</code></p>\n"},
+		{"<script>alert(1)</script>\n", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"\\*not em\\*\n", "<p>*not em*</p>\n"},
+		{"```go\nfunc main() {\n\treturn\n}\n```\n", "<pre><code class=\"language-go\">func main() {\n    return\n}\n</code></pre>\n"},
+		{"    indented <code>\n", "<pre><code>indented &lt;code&gt;\n</code></pre>\n"},
+		{"> quote\n> **text**\n", "<blockquote>\n<p>quote\n<strong>text</strong></p>\n</blockquote>\n"},
+		{"- one\n- two\n  - nested\n", "<ul>\n<li>one</li>\n<li>two\n<ul>\n<li>nested</li>\n</ul>\n</li>\n</ul>\n"},
+		{"3. three\n4. four\n", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+		{"* one\n\n* two\n", "<ul>\n<li>\n<p>one</p>\n</li>\n<li>\n<p>two</p>\n</li>\n</ul>\n"},
+		{"---\n", "<hr>\n"},
+		{"| a | b |\n|:--|--:|\n| 1 | 2 |\n", "<table>\n<thead>\n<tr><th align=\"left\">a</th><th align=\"right\">b</th></tr>\n</thead>\n<tbody>\n<tr><td align=\"left\">1</td><td align=\"right\">2</td></tr>\n</tbody>\n</table>\n"},
+		{"[link](http://example.com \"Title\")\n", "<p><a href=\"http://example.com\" title=\"Title\">link</a></p>\n"},
+		{"[bad](javascript:alert(1))\n", "<p><a href=\"\">bad</a></p>\n"},
+		{"![logo](logo.png)\n", "<p><img src=\"logo.png\" alt=\"logo\"></p>\n"},
+		{"[![badge][img]][site]\n\n[img]: https://example.com/badge.svg\n[site]: https://example.com\n", "<p><a href=\"https://example.com\"><img src=\"https://example.com/badge.svg\" alt=\"badge\"></a></p>\n"},
+		{"<https://example.com> <mailto:me@example.com>\n", "<p><a href=\"https://example.com\">https://example.com</a> <a href=\"mailto:me@example.com\">me@example.com</a></p>\n"},
+		{"[not a link] [x]\n", "<p>[not a link] [x]</p>\n"},
+	}
+
+	for key, testCase := range cases {
+		if html := RenderMarkdown([]byte(testCase.source), nil); html != testCase.html {
+			t.Errorf("[%d] RenderMarkdown(%q) = %q, want: %q", key, testCase.source, html, testCase.html)
+		}
+	}
+}
+
+func TestRenderMarkdown_Links(t *testing.T) {
+	link := MarkdownLinkFunc(func(dest string, isImage bool) string {
+		if isImage {
+			return "/raw/" + dest
+		}
+		return "/blob/" + dest
+	})
+
+	cases := []struct{
+		source string
+		html string
+	}{
+		{"[doc](docs/index.md)", "<p><a href=\"/blob/docs/index.md\">doc</a></p>\n"},
+		{"![img](img/logo.png)", "<p><img src=\"/raw/img/logo.png\" alt=\"img\"></p>\n"},
+		{"[anchor](#usage)", "<p><a href=\"#usage\">anchor</a></p>\n"},
+		{"[site](https://example.com/a)", "<p><a href=\"https://example.com/a\">site</a></p>\n"},
+	}
+
+	for key, testCase := range cases {
+		if html := RenderMarkdown([]byte(testCase.source), link); html != testCase.html {
+			t.Errorf("[%d] RenderMarkdown(%q) = %q, want: %q", key, testCase.source, html, testCase.html)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package vcsview
+
+// Represents a commit found by content search together with the matched files
+type MatchedCommit struct {
+	Commit
+
+	// Relative pathnames of the files where the searched content was added or removed
+	files []string
+}
+
+// Get relative pathnames of the matched files
+func (c MatchedCommit) Files() []string {
+	return c.files
+}
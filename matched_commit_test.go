@@ -0,0 +1,21 @@
+package vcsview
+
+import "testing"
+
+func TestMatchedCommit_Files(t *testing.T) {
+	cases := [][]string{
+		{},
+		{"testpath/empty.txt", "README.md"},
+	}
+
+	for key, testCase := range cases {
+		c := MatchedCommit{}
+		c.files = testCase
+
+		files := c.Files()
+
+		if len(files) != len(testCase) {
+			t.Errorf("[%d] MatchedCommit.Files() = %d files, want: %d", key, len(files), len(testCase))
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Package metrics collects execution metrics of the VCS commands and exposes them in the Prometheus text format
+// Collector receives command events as vcsview.Hooks and reads statistics of the registered caches and schedulers
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kalyabin/vcsview"
+)
+
+const (
+	// Content type of the text exposition format
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// Default upper bounds of the duration histogram buckets in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Key of the command metrics
+type commandKey struct {
+	operation string
+	repository string
+}
+
+// Metrics of the commands with the same operation and repository
+type commandStats struct {
+	// Number of finished commands by status labels
+	statuses map[string]uint64
+
+	// Number of durations in each bucket (not cumulative), the last one is +Inf bucket
+	buckets []uint64
+
+	// Total duration and number of commands
+	sum float64
+	count uint64
+
+	// Total number of stdout bytes read
+	bytes int64
+
+	// Total time of waiting in the scheduler queue in seconds
+	wait float64
+}
+
+// Metrics collector and HTTP handler of the Prometheus text format
+// Set collector as Hooks of vcsview.Git, NativeGit or CachedVcs to count their commands
+// Safe for concurrent use
+type Collector struct {
+	// Upper bounds of the duration histogram buckets in seconds
+	buckets []float64
+
+	// Returns repository label by repository path (nil means the path itself)
+	// Set it before using the collector, for example, to replace paths with repository names
+	RepositoryLabel func(path string) string
+
+	mu sync.Mutex
+
+	// Commands metrics
+	commands map[commandKey]*commandStats
+
+	// Number of running commands by operations
+	inFlight map[string]int
+
+	// Registered caches and schedulers by names
+	caches map[string]*vcsview.CachedVcs
+	schedulers map[string]*vcsview.Scheduler
+}
+
+// Create collector with duration histogram buckets (DefaultBuckets if empty)
+func New(buckets ...float64) *Collector {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+
+	return &Collector{
+		buckets: sorted,
+		commands: make(map[commandKey]*commandStats),
+		inFlight: make(map[string]int),
+		caches: make(map[string]*vcsview.CachedVcs),
+		schedulers: make(map[string]*vcsview.Scheduler),
+	}
+}
+
+// Expose hits and misses of the cache with name label
+func (c *Collector) AddCache(name string, cache *vcsview.CachedVcs) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.caches[name] = cache
+}
+
+// Expose running and queued processes of the scheduler with name label
+func (c *Collector) AddScheduler(name string, s *vcsview.Scheduler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.schedulers[name] = s
+}
+
+// Count running command
+func (c *Collector) CommandStart(ctx context.Context, e vcsview.CommandEvent) context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight[e.Operation()]++
+
+	return ctx
+}
+
+// Count finished command with its duration, status and read bytes
+func (c *Collector) CommandFinish(ctx context.Context, e vcsview.CommandEvent) {
+	repository := e.Path()
+	if c.RepositoryLabel != nil {
+		repository = c.RepositoryLabel(repository)
+	}
+
+	key := commandKey{e.Operation(), repository}
+	seconds := e.Duration().Seconds()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.inFlight[e.Operation()]--; c.inFlight[e.Operation()] <= 0 {
+		delete(c.inFlight, e.Operation())
+	}
+
+	stats, ok := c.commands[key]
+	if !ok {
+		stats = &commandStats{statuses: make(map[string]uint64), buckets: make([]uint64, len(c.buckets) + 1)}
+		c.commands[key] = stats
+	}
+
+	stats.statuses[statusLabel(e)]++
+	stats.buckets[sort.SearchFloat64s(c.buckets, seconds)]++
+	stats.sum += seconds
+	stats.count++
+	stats.bytes += e.Bytes()
+	stats.wait += e.Wait().Seconds()
+}
+
+// Returns status label of the finished command: ok, canceled, exit_<status> or error
+func statusLabel(e vcsview.CommandEvent) string {
+	switch err := e.Err(); {
+	case err == nil:
+		return "ok"
+	case err == context.Canceled || err == context.DeadlineExceeded:
+		return "canceled"
+	case e.Status() > 0:
+		return "exit_" + strconv.Itoa(e.Status())
+	}
+
+	return "error"
+}
+
+// Write metrics in the Prometheus text format
+func (c *Collector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+
+	if req.Method == http.MethodHead {
+		return
+	}
+
+	b := bufio.NewWriter(w)
+	c.write(b)
+	b.Flush()
+}
+
+// Metrics writer which adds help and type lines once for each metric
+type exposition struct {
+	w *bufio.Writer
+	described map[string]bool
+}
+
+// Write metric sample with labels as name-value pairs
+func (x *exposition) sample(name string, kind string, help string, value string, labels ...string) {
+	family := name
+	if kind == "histogram" {
+		family = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(name, "_bucket"), "_sum"), "_count")
+	}
+
+	if !x.described[family] {
+		x.described[family] = true
+		fmt.Fprintf(x.w, "# HELP %s %s\n# TYPE %s %s\n", family, help, family, kind)
+	}
+
+	x.w.WriteString(name)
+
+	if len(labels) > 0 {
+		x.w.WriteByte('{')
+		for i := 0; i + 1 < len(labels); i += 2 {
+			if i > 0 {
+				x.w.WriteByte(',')
+			}
+			fmt.Fprintf(x.w, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		x.w.WriteByte('}')
+	}
+
+	x.w.WriteString(" " + value + "\n")
+}
+
+// Escape label value: backslash, double quote and line feed
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// Format float sample value
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Format integer sample value
+func formatInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+// Write all metrics sorted by labels
+func (c *Collector) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	x := &exposition{w, make(map[string]bool)}
+
+	keys := make([]commandKey, 0, len(c.commands))
+	for key := range c.commands {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].operation != keys[j].operation {
+			return keys[i].operation < keys[j].operation
+		}
+		return keys[i].repository < keys[j].repository
+	})
+
+	for _, key := range keys {
+		stats := c.commands[key]
+
+		statuses := make([]string, 0, len(stats.statuses))
+		for status := range stats.statuses {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+
+		for _, status := range statuses {
+			x.sample("vcsview_commands_total", "counter", "Number of finished commands.",
+				formatInt(int64(stats.statuses[status])), "operation", key.operation, "repository", key.repository, "status", status)
+		}
+	}
+
+	for _, key := range keys {
+		stats := c.commands[key]
+
+		var cumulative uint64
+		for i, count := range stats.buckets {
+			cumulative += count
+
+			le := "+Inf"
+			if i < len(c.buckets) {
+				le = formatFloat(c.buckets[i])
+			}
+
+			x.sample("vcsview_command_duration_seconds_bucket", "histogram", "Duration of the commands in seconds.",
+				formatInt(int64(cumulative)), "operation", key.operation, "repository", key.repository, "le", le)
+		}
+
+		x.sample("vcsview_command_duration_seconds_sum", "histogram", "", formatFloat(stats.sum), "operation", key.operation, "repository", key.repository)
+		x.sample("vcsview_command_duration_seconds_count", "histogram", "", formatInt(int64(stats.count)), "operation", key.operation, "repository", key.repository)
+	}
+
+	for _, key := range keys {
+		x.sample("vcsview_command_read_bytes_total", "counter", "Number of stdout bytes read from the commands.",
+			formatInt(c.commands[key].bytes), "operation", key.operation, "repository", key.repository)
+	}
+
+	for _, key := range keys {
+		x.sample("vcsview_command_wait_seconds_total", "counter", "Time of waiting for the process slot in seconds.",
+			formatFloat(c.commands[key].wait), "operation", key.operation, "repository", key.repository)
+	}
+
+	operations := make([]string, 0, len(c.inFlight))
+	for operation := range c.inFlight {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+
+	for _, operation := range operations {
+		x.sample("vcsview_commands_in_flight", "gauge", "Number of running commands.", formatInt(int64(c.inFlight[operation])), "operation", operation)
+	}
+
+	c.writeCaches(x)
+	c.writeSchedulers(x)
+}
+
+// Write statistics of the registered caches
+func (c *Collector) writeCaches(x *exposition) {
+	names := make([]string, 0, len(c.caches))
+	for name := range c.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		stats := c.caches[name].Stats()
+
+		x.sample("vcsview_cache_hits_total", "counter", "Number of requests served from the cache.", formatInt(int64(stats.Hits())), "cache", name)
+		x.sample("vcsview_cache_misses_total", "counter", "Number of requests passed to the VCS.", formatInt(int64(stats.Misses())), "cache", name)
+		x.sample("vcsview_cache_evictions_total", "counter", "Number of entries evicted from the cache.", formatInt(int64(stats.Evictions())), "cache", name)
+		x.sample("vcsview_cache_entries", "gauge", "Number of entries in the cache.", formatInt(int64(stats.Size())), "cache", name)
+		x.sample("vcsview_cache_bytes", "gauge", "Estimated size of the cached values in bytes.", formatInt(stats.Bytes()), "cache", name)
+	}
+}
+
+// Write statistics of the registered schedulers
+func (c *Collector) writeSchedulers(x *exposition) {
+	names := make([]string, 0, len(c.schedulers))
+	for name := range c.schedulers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		stats := c.schedulers[name].Stats()
+
+		x.sample("vcsview_scheduler_running", "gauge", "Number of running processes.", formatInt(int64(stats.Running())), "scheduler", name)
+		x.sample("vcsview_scheduler_queued", "gauge", "Number of processes waiting for start.", formatInt(int64(stats.Queued())), "scheduler", name)
+		x.sample("vcsview_scheduler_waits_total", "counter", "Number of processes which waited for start.", formatInt(int64(stats.Waits())), "scheduler", name)
+		x.sample("vcsview_scheduler_cancellations_total", "counter", "Number of processes cancelled while waiting.", formatInt(int64(stats.Cancellations())), "scheduler", name)
+		x.sample("vcsview_scheduler_wait_seconds_total", "counter", "Time of waiting for start in seconds.", formatFloat(stats.WaitTime().Seconds()), "scheduler", name)
+	}
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kalyabin/vcsview"
+)
+
+const (
+	gitRepositoryPath = "../testdata/git"
+)
+
+// Returns metrics page of the collector
+func scrape(t *testing.T, c *Collector) string {
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics status = %d, want: %d", w.Code, http.StatusOK)
+	}
+
+	if got := w.Header().Get("Content-Type"); got != contentType {
+		t.Errorf("GET /metrics Content-Type = %v, want: %v", got, contentType)
+	}
+
+	return w.Body.String()
+}
+
+func TestCollector(t *testing.T) {
+	c := New(0.5, 0.001)
+	c.RepositoryLabel = func(path string) string {
+		return filepath.Base(path)
+	}
+
+	scheduler := vcsview.NewScheduler(4, 2)
+
+	git := vcsview.NewGit()
+	defer git.Close()
+	git.Hooks = c
+	git.Scheduler = scheduler
+
+	cached := vcsview.NewCachedVcs(git, 10)
+	cached.Hooks = c
+
+	c.AddCache("objects", cached)
+	c.AddScheduler("git", scheduler)
+
+	r, err := vcsview.NewRepository(gitRepositoryPath, cached)
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+
+	commits, err := r.History(context.Background(), "", "", 0, 1)
+	if err != nil || len(commits) != 1 {
+		t.Fatalf("Repository.History() = %v, %v, want 1 commit", commits, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Commit(context.Background(), commits[0].Id()); err != nil {
+			t.Fatalf("Repository.Commit(%s) got error: %v", commits[0].Id(), err)
+		}
+	}
+
+	if _, err := r.Diff(context.Background(), "non-existent-commit"); err == nil {
+		t.Fatalf("Repository.Diff(non-existent-commit) got no errors, want error")
+	}
+
+	page := scrape(t, c)
+
+	for _, want := range []string{
+		"# TYPE vcsview_commands_total counter\n",
+		`vcsview_commands_total{operation="log",repository="git",status="ok"} 1` + "\n",
+		`vcsview_commands_total{operation="show",repository="git",status="exit_128"} 1` + "\n",
+		`vcsview_commands_total{operation="cached commit",repository="git",status="ok"} 2` + "\n",
+		`vcsview_commands_total{operation="cat-file",repository="git",status="ok"} 1` + "\n",
+		"# TYPE vcsview_command_duration_seconds histogram\n",
+		`vcsview_command_duration_seconds_bucket{operation="log",repository="git",le="+Inf"} 1` + "\n",
+		`vcsview_command_duration_seconds_count{operation="log",repository="git"} 1` + "\n",
+		`vcsview_command_read_bytes_total{operation="log",repository="git"} `,
+		`vcsview_cache_hits_total{cache="objects"} 1` + "\n",
+		`vcsview_cache_misses_total{cache="objects"} 1` + "\n",
+		// idle cat-file process keeps its slot
+		`vcsview_scheduler_running{scheduler="git"} 1` + "\n",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("GET /metrics doesn't contain %q:\n%s", want, page)
+		}
+	}
+
+	if strings.Contains(page, "vcsview_commands_in_flight") {
+		t.Errorf("GET /metrics contains running commands after all finished:\n%s", page)
+	}
+
+	if strings.Count(page, "# TYPE vcsview_commands_total") != 1 {
+		t.Errorf("GET /metrics describes vcsview_commands_total several times:\n%s", page)
+	}
+}
+
+func TestCollector_InFlight(t *testing.T) {
+	c := New()
+
+	ctx := c.CommandStart(context.Background(), vcsview.CommandEvent{})
+
+	if page := scrape(t, c); !strings.Contains(page, `vcsview_commands_in_flight{operation=""} 1`) {
+		t.Errorf("GET /metrics doesn't contain running command:\n%s", page)
+	}
+
+	c.CommandFinish(ctx, vcsview.CommandEvent{})
+
+	if page := scrape(t, c); !strings.Contains(page, `vcsview_command_duration_seconds_bucket{operation="",repository="",le="0.005"} 1`) {
+		t.Errorf("GET /metrics doesn't contain finished command:\n%s", page)
+	}
+}
+
+func TestCollector_Method(t *testing.T) {
+	w := httptest.NewRecorder()
+	New().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /metrics status = %d, want: %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	cases := []struct{
+		value string
+		want string
+	}{
+		{"/path/to/repo", "/path/to/repo"},
+		{`a"b\c` + "\n", `a\"b\\c\n`},
+	}
+
+	for key, testCase := range cases {
+		if escaped := escapeLabel(testCase.value); escaped != testCase.want {
+			t.Errorf("[%d] escapeLabel(%q) = %q, want: %q", key, testCase.value, escaped, testCase.want)
+		}
+	}
+}
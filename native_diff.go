@@ -0,0 +1,1020 @@
+package vcsview
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// Number of context lines around changes
+	nativeDiffContext = 3
+
+	// Maximum number of edits to search the shortest diff, larger diffs replace all changed lines
+	nativeMaxDiffEdits = 2048
+
+	// Minimum similarity percent of renamed files
+	nativeRenameScore = 50
+
+	// Maximum number of added or deleted files to search inexact renames
+	nativeRenameLimit = 400
+
+	// Length of abbreviated object identifiers in index lines
+	nativeAbbrevLength = 7
+
+	// Number of first bytes checked for NUL to detect binary content
+	nativeBinaryCheckSize = 8000
+
+	// Maximum length of the function name in hunk headers
+	nativeFuncnameLength = 80
+)
+
+// Changed file between two trees
+type nativeChange struct {
+	status FileStatus
+
+	oldPath string
+	newPath string
+
+	oldMode string
+	newMode string
+
+	oldId string
+	newId string
+
+	// Similarity percent of renamed file
+	similarity int
+}
+
+// Returns changed file pathname
+func (c nativeChange) pathname() string {
+	if c.newPath != "" {
+		return c.newPath
+	}
+	return c.oldPath
+}
+
+// Returns true if entries are the same kind of files: regular files, symlinks or submodules
+func isNativeSameKind(a gitTreeEntry, b gitTreeEntry) bool {
+	kind := func(mode string) string {
+		if strings.HasPrefix(mode, "100") {
+			return "100"
+		}
+		return mode
+	}
+
+	return kind(a.mode) == kind(b.mode)
+}
+
+// Collect changed files of two trees, empty tree identifier means empty tree
+// Subtrees with the same identifiers are skipped
+func (r *nativeRepository) diffTrees(oldTree string, newTree string, prefix string, changes *[]nativeChange) error {
+	if oldTree == newTree {
+		return nil
+	}
+
+	entries := func(id string) (map[string]gitTreeEntry, error) {
+		result := make(map[string]gitTreeEntry)
+		if id == "" {
+			return result, nil
+		}
+
+		list, err := r.readTree(id)
+		for _, e := range list {
+			result[e.name] = e
+		}
+
+		return result, err
+	}
+
+	oldEntries, err := entries(oldTree)
+	if err != nil {
+		return err
+	}
+
+	newEntries, err := entries(newTree)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(oldEntries)+len(newEntries))
+	for name := range oldEntries {
+		names = append(names, name)
+	}
+	for name := range newEntries {
+		if _, ok := oldEntries[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pathname := prefix + name
+		o, inOld := oldEntries[name]
+		n, inNew := newEntries[name]
+
+		if inOld && inNew && o.id == n.id && o.mode == n.mode {
+			continue
+		}
+
+		if inOld && inNew && o.isTree() && n.isTree() {
+			if err := r.diffTrees(o.id, n.id, pathname+"/", changes); err != nil {
+				return err
+			}
+			continue
+		}
+
+		modified := inOld && inNew && !o.isTree() && !n.isTree() && isNativeSameKind(o, n)
+
+		if inOld && !modified {
+			if o.isTree() {
+				if err := r.diffTrees(o.id, "", pathname+"/", changes); err != nil {
+					return err
+				}
+			} else {
+				*changes = append(*changes, nativeChange{status: FileDeleted, oldPath: pathname, oldMode: o.mode, oldId: o.id})
+			}
+		}
+
+		if inNew {
+			switch {
+			case modified:
+				*changes = append(*changes, nativeChange{FileModified, pathname, pathname, o.mode, n.mode, o.id, n.id, 0})
+			case n.isTree():
+				if err := r.diffTrees("", n.id, pathname+"/", changes); err != nil {
+					return err
+				}
+			default:
+				*changes = append(*changes, nativeChange{status: FileAdded, newPath: pathname, newMode: n.mode, newId: n.id})
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns similarity percent of two contents by common lines
+// Contents which sizes differ too much are never similar
+func nativeSimilarity(a []byte, b []byte) int {
+	minSize, maxSize := len(a), len(b)
+	if minSize > maxSize {
+		minSize, maxSize = maxSize, minSize
+	}
+
+	if maxSize == 0 || (maxSize-minSize)*100 > maxSize*(100-nativeRenameScore) {
+		return 0
+	}
+
+	lines := make(map[string]int)
+	for _, line := range splitNativeLines(a) {
+		lines[line]++
+	}
+
+	common := 0
+	for _, line := range splitNativeLines(b) {
+		if lines[line] > 0 {
+			lines[line]--
+			common += len(line)
+		}
+	}
+
+	return common * 100 / maxSize
+}
+
+// Pair deleted and added files into renamed ones
+// Exact renames are found by the same blob identifiers, then by content similarity
+// Renamed files take positions of added ones
+func (r *nativeRepository) detectRenames(changes []nativeChange) ([]nativeChange, error) {
+	deleted := make([]int, 0)
+	added := make([]int, 0)
+
+	for i, c := range changes {
+		switch {
+		case c.status == FileDeleted && c.oldMode != "160000":
+			deleted = append(deleted, i)
+		case c.status == FileAdded && c.newMode != "160000":
+			added = append(added, i)
+		}
+	}
+
+	if len(deleted) == 0 || len(added) == 0 {
+		return changes, nil
+	}
+
+	// sources of added files with similarity
+	sources := make(map[int]int)
+	scores := make(map[int]int)
+	used := make(map[int]bool)
+
+	for _, a := range added {
+		for _, d := range deleted {
+			if !used[d] && changes[d].oldId == changes[a].newId {
+				sources[a], scores[a], used[d] = d, 100, true
+				break
+			}
+		}
+	}
+
+	restDeleted := make([]int, 0)
+	for _, d := range deleted {
+		if !used[d] {
+			restDeleted = append(restDeleted, d)
+		}
+	}
+
+	restAdded := make([]int, 0)
+	for _, a := range added {
+		if _, ok := sources[a]; !ok {
+			restAdded = append(restAdded, a)
+		}
+	}
+
+	if len(restDeleted) > 0 && len(restAdded) > 0 && len(restDeleted) <= nativeRenameLimit && len(restAdded) <= nativeRenameLimit {
+		type candidate struct {
+			score int
+			added int
+			deleted int
+		}
+
+		contents := make(map[int][]byte)
+		for _, i := range append(append([]int{}, restDeleted...), restAdded...) {
+			id := changes[i].oldId + changes[i].newId
+			o, err := r.store.read(id)
+			if err != nil {
+				return nil, err
+			}
+			contents[i] = o.data
+		}
+
+		candidates := make([]candidate, 0)
+		for _, a := range restAdded {
+			for _, d := range restDeleted {
+				if score := nativeSimilarity(contents[d], contents[a]); score >= nativeRenameScore {
+					candidates = append(candidates, candidate{score, a, d})
+				}
+			}
+		}
+
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].score > candidates[j].score
+		})
+
+		for _, c := range candidates {
+			if _, ok := sources[c.added]; ok || used[c.deleted] {
+				continue
+			}
+			sources[c.added], scores[c.added], used[c.deleted] = c.deleted, c.score, true
+		}
+	}
+
+	result := make([]nativeChange, 0, len(changes))
+
+	for i, c := range changes {
+		if used[i] {
+			continue
+		}
+
+		if d, ok := sources[i]; ok {
+			source := changes[d]
+			c = nativeChange{FileRenamed, source.oldPath, c.newPath, source.oldMode, c.newMode, source.oldId, c.newId, scores[i]}
+		}
+
+		result = append(result, c)
+	}
+
+	return result, nil
+}
+
+// Split content into lines keeping line endings
+// The last line has no line ending if content doesn't end with it
+func splitNativeLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// Returns true if content has NUL byte at the beginning
+func isNativeBinary(data []byte) bool {
+	if len(data) > nativeBinaryCheckSize {
+		data = data[:nativeBinaryCheckSize]
+	}
+
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// Line edit of the diff
+type nativeEdit struct {
+	// Operation: ' ' for the same line, '-' for deleted and '+' for added one
+	op byte
+
+	// Old and new lines indexes
+	x int
+	y int
+}
+
+// Find the shortest edits script by Myers algorithm
+// Lines are compared by integer codes
+// Returns deletes and inserts of all lines if the shortest script is longer than nativeMaxDiffEdits
+func diffNativeCodes(a []int, b []int, x0 int, y0 int) []nativeEdit {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+
+	v := make([]int, 2*max+3)
+	trace := make([][]int, 0)
+	found := false
+
+	for d := 0; d <= max && d <= nativeMaxDiffEdits && !found; d++ {
+		// keep diagonals -d-1..d+1 of the previous step
+		snapshot := make([]int, 2*d+3)
+		copy(snapshot, v[offset-d-1:offset+d+2])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	edits := make([]nativeEdit, 0, max)
+
+	if !found {
+		for x := 0; x < n; x++ {
+			edits = append(edits, nativeEdit{'-', x0 + x, y0})
+		}
+		for y := 0; y < m; y++ {
+			edits = append(edits, nativeEdit{'+', x0 + n, y0 + y})
+		}
+		return edits
+	}
+
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		snapshot := trace[d]
+		get := func(k int) int {
+			return snapshot[k+d+1]
+		}
+
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && get(k-1) < get(k+1)) {
+			prevK = k + 1
+		}
+
+		prevX := get(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, nativeEdit{' ', x0 + x, y0 + y})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, nativeEdit{'+', x0 + x, y0 + y})
+			} else {
+				x--
+				edits = append(edits, nativeEdit{'-', x0 + x, y0 + y})
+			}
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+
+	return edits
+}
+
+// Find line edits converting old lines to new ones
+// Common prefix and suffix are skipped before the search, changes are compacted like git does
+func diffNativeLines(a []string, b []string) []nativeEdit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	codes := make(map[string]int)
+	encode := func(lines []string) []int {
+		result := make([]int, len(lines))
+		for i, line := range lines {
+			code, ok := codes[line]
+			if !ok {
+				code = len(codes)
+				codes[line] = code
+			}
+			result[i] = code
+		}
+		return result
+	}
+
+	oldSide := &nativeDiffSide{a, make([]bool, len(a)+2)}
+	newSide := &nativeDiffSide{b, make([]bool, len(b)+2)}
+
+	for _, e := range diffNativeCodes(encode(a[prefix:len(a)-suffix]), encode(b[prefix:len(b)-suffix]), prefix, prefix) {
+		switch e.op {
+		case '-':
+			oldSide.setChanged(e.x, true)
+		case '+':
+			newSide.setChanged(e.y, true)
+		}
+	}
+
+	compactNativeDiff(oldSide, newSide)
+	compactNativeDiff(newSide, oldSide)
+
+	edits := make([]nativeEdit, 0, len(a)+len(b))
+
+	for x, y := 0, 0; x < len(a) || y < len(b); {
+		switch {
+		case x < len(a) && oldSide.isChanged(x):
+			edits = append(edits, nativeEdit{'-', x, y})
+			x++
+		case y < len(b) && newSide.isChanged(y):
+			edits = append(edits, nativeEdit{'+', x, y})
+			y++
+		default:
+			edits = append(edits, nativeEdit{' ', x, y})
+			x++
+			y++
+		}
+	}
+
+	return edits
+}
+
+// Lines of the one diff side with changed flags
+type nativeDiffSide struct {
+	lines []string
+
+	// Changed flags with guards at both ends: changed[i+1] is the flag of the line i
+	changed []bool
+}
+
+// Group of changed lines [start, end), groups of both sides are separated by the same unchanged lines
+type nativeDiffGroup struct {
+	start int
+	end int
+}
+
+func (s *nativeDiffSide) isChanged(i int) bool {
+	return s.changed[i+1]
+}
+
+func (s *nativeDiffSide) setChanged(i int, isChanged bool) {
+	s.changed[i+1] = isChanged
+}
+
+// Returns the first group which could be empty
+func (s *nativeDiffSide) firstGroup() nativeDiffGroup {
+	g := nativeDiffGroup{}
+	for s.isChanged(g.end) {
+		g.end++
+	}
+	return g
+}
+
+// Move to the next group, returns false at the end
+func (s *nativeDiffSide) nextGroup(g *nativeDiffGroup) bool {
+	if g.end == len(s.lines) {
+		return false
+	}
+
+	g.start = g.end + 1
+	g.end = g.start
+	for s.isChanged(g.end) {
+		g.end++
+	}
+
+	return true
+}
+
+// Move to the previous group, returns false at the beginning
+func (s *nativeDiffSide) previousGroup(g *nativeDiffGroup) bool {
+	if g.start == 0 {
+		return false
+	}
+
+	g.end = g.start - 1
+	g.start = g.end
+	for s.isChanged(g.start - 1) {
+		g.start--
+	}
+
+	return true
+}
+
+// Slide group down by one line if the line after it equals to its first line
+// The group is merged with the next one if they become adjacent
+func (s *nativeDiffSide) slideDown(g *nativeDiffGroup) bool {
+	if g.end >= len(s.lines) || s.lines[g.start] != s.lines[g.end] {
+		return false
+	}
+
+	s.setChanged(g.start, false)
+	s.setChanged(g.end, true)
+	g.start++
+	g.end++
+
+	for s.isChanged(g.end) {
+		g.end++
+	}
+
+	return true
+}
+
+// Slide group up by one line if the line before it equals to its last line
+// The group is merged with the previous one if they become adjacent
+func (s *nativeDiffSide) slideUp(g *nativeDiffGroup) bool {
+	if g.start == 0 || s.lines[g.start-1] != s.lines[g.end-1] {
+		return false
+	}
+
+	g.start--
+	g.end--
+	s.setChanged(g.start, true)
+	s.setChanged(g.end, false)
+
+	for s.isChanged(g.start - 1) {
+		g.start--
+	}
+
+	return true
+}
+
+// Move groups of changed lines to the positions git prefers:
+// aligned with the changes of the other side, or at the split with the best indentation score
+func compactNativeDiff(s *nativeDiffSide, other *nativeDiffSide) {
+	g, o := s.firstGroup(), other.firstGroup()
+
+	for {
+		if g.end != g.start {
+			var size, earliestEnd int
+
+			endMatchingOther := -1
+
+			for {
+				size = g.end - g.start
+				endMatchingOther = -1
+
+				for s.slideUp(&g) {
+					other.previousGroup(&o)
+				}
+
+				earliestEnd = g.end
+				if o.end > o.start {
+					endMatchingOther = g.end
+				}
+
+				for s.slideDown(&g) {
+					other.nextGroup(&o)
+					if o.end > o.start {
+						endMatchingOther = g.end
+					}
+				}
+
+				if size == g.end-g.start {
+					break
+				}
+			}
+
+			switch {
+			case g.end == earliestEnd:
+				// no shifting was possible
+			case endMatchingOther != -1:
+				for o.end == o.start && s.slideUp(&g) {
+					other.previousGroup(&o)
+				}
+			default:
+				best := s.bestSplit(g, size, earliestEnd)
+				for g.end > best && s.slideUp(&g) {
+					other.previousGroup(&o)
+				}
+			}
+		}
+
+		if !s.nextGroup(&g) || !other.nextGroup(&o) {
+			break
+		}
+	}
+}
+
+const (
+	// Indentation limits of the split heuristic
+	nativeMaxIndent = 200
+	nativeMaxBlanks = 20
+	nativeMaxSliding = 100
+
+	// Split heuristic penalties
+	nativeStartOfFilePenalty = 1
+	nativeEndOfFilePenalty = 21
+	nativeTotalBlankWeight = -30
+	nativePostBlankWeight = 6
+	nativeRelativeIndentPenalty = -4
+	nativeRelativeIndentWithBlankPenalty = 10
+	nativeRelativeOutdentPenalty = 24
+	nativeRelativeOutdentWithBlankPenalty = 17
+	nativeRelativeDedentPenalty = 23
+	nativeRelativeDedentWithBlankPenalty = 17
+	nativeIndentWeight = 60
+)
+
+// Returns line indentation with tabs expanded to 8 columns or -1 for blank line
+func nativeIndent(line string) int {
+	indent := 0
+
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			indent++
+		case '\t':
+			indent += 8 - indent%8
+		case '\n', '\r', '\f', '\v':
+		default:
+			return indent
+		}
+
+		if indent >= nativeMaxIndent {
+			return nativeMaxIndent
+		}
+	}
+
+	return -1
+}
+
+// Returns indentation score and penalty of the split before the line
+func (s *nativeDiffSide) splitScore(split int) (int, int) {
+	endOfFile := split >= len(s.lines)
+
+	indent := -1
+	if !endOfFile {
+		indent = nativeIndent(s.lines[split])
+	}
+
+	preBlank, preIndent := 0, -1
+	for i := split - 1; i >= 0; i-- {
+		if preIndent = nativeIndent(s.lines[i]); preIndent != -1 {
+			break
+		}
+		if preBlank++; preBlank == nativeMaxBlanks {
+			preIndent = 0
+			break
+		}
+	}
+
+	postBlank, postIndent := 0, -1
+	for i := split + 1; i < len(s.lines); i++ {
+		if postIndent = nativeIndent(s.lines[i]); postIndent != -1 {
+			break
+		}
+		if postBlank++; postBlank == nativeMaxBlanks {
+			postIndent = 0
+			break
+		}
+	}
+
+	penalty := 0
+	if preIndent == -1 && preBlank == 0 {
+		penalty += nativeStartOfFilePenalty
+	}
+	if endOfFile {
+		penalty += nativeEndOfFilePenalty
+	}
+
+	blanksAfter := 0
+	if indent == -1 {
+		blanksAfter = 1 + postBlank
+	}
+	totalBlank := preBlank + blanksAfter
+
+	penalty += nativeTotalBlankWeight*totalBlank + nativePostBlankWeight*blanksAfter
+
+	if indent == -1 {
+		indent = postIndent
+	}
+
+	anyBlanks := totalBlank != 0
+
+	penaltyFor := func(withBlank int, withoutBlank int) int {
+		if anyBlanks {
+			return withBlank
+		}
+		return withoutBlank
+	}
+
+	switch {
+	case indent == -1 || preIndent == -1 || indent == preIndent:
+	case indent > preIndent:
+		penalty += penaltyFor(nativeRelativeIndentWithBlankPenalty, nativeRelativeIndentPenalty)
+	case postIndent != -1 && postIndent > indent:
+		penalty += penaltyFor(nativeRelativeOutdentWithBlankPenalty, nativeRelativeOutdentPenalty)
+	default:
+		penalty += penaltyFor(nativeRelativeDedentWithBlankPenalty, nativeRelativeDedentPenalty)
+	}
+
+	return indent, penalty
+}
+
+// Returns the best end of the sliding group by indentation heuristic
+func (s *nativeDiffSide) bestSplit(g nativeDiffGroup, size int, earliestEnd int) int {
+	shift := earliestEnd
+	if g.end-size-1 > shift {
+		shift = g.end - size - 1
+	}
+	if g.end-nativeMaxSliding > shift {
+		shift = g.end - nativeMaxSliding
+	}
+
+	best, bestIndent, bestPenalty := -1, 0, 0
+
+	for ; shift <= g.end; shift++ {
+		indent, penalty := s.splitScore(shift)
+		startIndent, startPenalty := s.splitScore(shift - size)
+		indent += startIndent
+		penalty += startPenalty
+
+		cmp := 0
+		if indent > bestIndent {
+			cmp = 1
+		} else if indent < bestIndent {
+			cmp = -1
+		}
+
+		if best == -1 || nativeIndentWeight*cmp+penalty-bestPenalty <= 0 {
+			best, bestIndent, bestPenalty = shift, indent, penalty
+		}
+	}
+
+	return best
+}
+
+// Format hunk range of 0-based start line and lines count
+func formatNativeRange(start int, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// Find function name for the hunk header: the nearest line before start which begins with a letter, _ or $
+func nativeFuncname(lines []string, start int) string {
+	for i := start - 1; i >= 0; i-- {
+		line := lines[i]
+		if line == "" {
+			continue
+		}
+
+		if c := line[0]; c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			if len(line) > nativeFuncnameLength {
+				line = line[:nativeFuncnameLength]
+			}
+			return strings.TrimRight(line, " \t\r\n")
+		}
+	}
+
+	return ""
+}
+
+// Write unified diff hunks of old and new lines
+func writeNativeHunks(w *bytes.Buffer, a []string, b []string) {
+	edits := diffNativeLines(a, b)
+
+	writeLine := func(op byte, line string) {
+		w.WriteByte(op)
+		w.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			w.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+
+	i := 0
+	for i < len(edits) {
+		for i < len(edits) && edits[i].op == ' ' {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		// merge changes separated by less than two contexts
+		last := i
+		for j := i; j < len(edits); {
+			if edits[j].op != ' ' {
+				last = j
+				j++
+				continue
+			}
+
+			k := j
+			for k < len(edits) && edits[k].op == ' ' {
+				k++
+			}
+			if k == len(edits) || k-j > 2*nativeDiffContext {
+				break
+			}
+			j = k
+		}
+
+		start := i - nativeDiffContext
+		if start < 0 {
+			start = 0
+		}
+		stop := last + nativeDiffContext + 1
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+
+		hunk := edits[start:stop]
+		oldCount, newCount := 0, 0
+		for _, e := range hunk {
+			if e.op != '+' {
+				oldCount++
+			}
+			if e.op != '-' {
+				newCount++
+			}
+		}
+
+		fmt.Fprintf(w, "@@ -%s +%s @@", formatNativeRange(hunk[0].x, oldCount), formatNativeRange(hunk[0].y, newCount))
+		if funcname := nativeFuncname(a, hunk[0].x); funcname != "" {
+			w.WriteString(" " + funcname)
+		}
+		w.WriteByte('\n')
+
+		for _, e := range hunk {
+			switch e.op {
+			case '+':
+				writeLine('+', b[e.y])
+			default:
+				writeLine(e.op, a[e.x])
+			}
+		}
+
+		i = stop
+	}
+}
+
+// Returns content of the tree entry, submodules are represented by their commits
+func (r *nativeRepository) entryContent(id string, mode string) ([]byte, error) {
+	switch {
+	case id == "":
+		return nil, nil
+	case mode == "160000":
+		return []byte("Subproject commit " + id + "\n"), nil
+	}
+
+	o, err := r.store.read(id)
+
+	return o.data, err
+}
+
+// Returns abbreviated object identifier, empty identifier is represented by zeros
+func abbrevNativeId(id string) string {
+	if id == "" {
+		return strings.Repeat("0", nativeAbbrevLength)
+	}
+	if len(id) > nativeAbbrevLength {
+		return id[:nativeAbbrevLength]
+	}
+	return id
+}
+
+// Write git patch of the changed file
+func (r *nativeRepository) writePatch(w *bytes.Buffer, c nativeChange) error {
+	var oldData, newData []byte
+
+	if c.oldId != c.newId {
+		var err error
+
+		if oldData, err = r.entryContent(c.oldId, c.oldMode); err != nil {
+			return err
+		}
+
+		if newData, err = r.entryContent(c.newId, c.newMode); err != nil {
+			return err
+		}
+	}
+
+	writeNativePatch(w, c, oldData, newData)
+
+	return nil
+}
+
+// Write git patch of the changed file with its contents
+// Contents aren't compared if the file identifiers are the same
+func writeNativePatch(w *bytes.Buffer, c nativeChange, oldData []byte, newData []byte) {
+	oldPath, newPath := c.oldPath, c.newPath
+	oldName, newName := "a/"+oldPath, "b/"+newPath
+
+	switch c.status {
+	case FileAdded:
+		oldPath, oldName = newPath, "/dev/null"
+	case FileDeleted:
+		newPath, newName = oldPath, "/dev/null"
+	}
+
+	fmt.Fprintf(w, "diff --git a/%s b/%s\n", oldPath, newPath)
+
+	switch c.status {
+	case FileAdded:
+		fmt.Fprintf(w, "new file mode %s\n", c.newMode)
+	case FileDeleted:
+		fmt.Fprintf(w, "deleted file mode %s\n", c.oldMode)
+	default:
+		if c.oldMode != c.newMode {
+			fmt.Fprintf(w, "old mode %s\nnew mode %s\n", c.oldMode, c.newMode)
+		}
+		if c.status == FileRenamed {
+			fmt.Fprintf(w, "similarity index %d%%\nrename from %s\nrename to %s\n", c.similarity, c.oldPath, c.newPath)
+		}
+	}
+
+	if c.oldId == c.newId {
+		return
+	}
+
+	fmt.Fprintf(w, "index %s..%s", abbrevNativeId(c.oldId), abbrevNativeId(c.newId))
+	if c.oldMode == c.newMode {
+		fmt.Fprintf(w, " %s", c.newMode)
+	}
+	w.WriteByte('\n')
+
+	if isNativeBinary(oldData) || isNativeBinary(newData) {
+		fmt.Fprintf(w, "Binary files %s and %s differ\n", oldName, newName)
+		return
+	}
+
+	var hunks bytes.Buffer
+	writeNativeHunks(&hunks, splitNativeLines(oldData), splitNativeLines(newData))
+
+	if hunks.Len() > 0 {
+		fmt.Fprintf(w, "--- %s\n+++ %s\n", oldName, newName)
+		w.Write(hunks.Bytes())
+	}
+}
+
+// Send changed files of two trees with patches like git show does
+// Empty old tree identifier means the empty tree
+func (r *nativeRepository) diff(oldTree string, newTree string, result chan FileDiff) error {
+	changes := make([]nativeChange, 0)
+	if err := r.diffTrees(oldTree, newTree, "", &changes); err != nil {
+		return err
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].pathname() < changes[j].pathname()
+	})
+
+	changes, err := r.detectRenames(changes)
+	if err != nil {
+		return err
+	}
+
+	var patch bytes.Buffer
+	for _, c := range changes {
+		if err := r.writePatch(&patch, c); err != nil {
+			return err
+		}
+	}
+
+	// patch has the same format as git output, so it's parsed the same way
+	(&Git{}).readDiffPipe(bufio.NewScanner(&patch), result)
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package vcsview
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitNativeLines(t *testing.T) {
+	cases := []struct{
+		data string
+		want []string
+	}{
+		{"", nil},
+		{"a\nb\n", []string{"a\n", "b\n"}},
+		{"a\nb", []string{"a\n", "b"}},
+		{"\n", []string{"\n"}},
+	}
+
+	for key, testCase := range cases {
+		lines := splitNativeLines([]byte(testCase.data))
+		if len(lines) != len(testCase.want) {
+			t.Errorf("[%d] splitNativeLines(%q) = %q, want: %q", key, testCase.data, lines, testCase.want)
+			continue
+		}
+		for i := range lines {
+			if lines[i] != testCase.want[i] {
+				t.Errorf("[%d] splitNativeLines(%q) = %q, want: %q", key, testCase.data, lines, testCase.want)
+			}
+		}
+	}
+}
+
+func TestWriteNativeHunks(t *testing.T) {
+	cases := []struct{
+		old string
+		new string
+		want string
+	}{
+		{"a\nb\nc\n", "a\nb\nc\n", ""},
+		{"", "a\nb\n", "@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"a\nb\n", "", "@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"a\n", "b\n", "@@ -1 +1 @@\n-a\n+b\n"},
+		{"a\nb", "a\nb\n", "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+		{
+			"func a() {\n\t1\n\t2\n\t3\n\t4\n\t5\n}\n",
+			"func a() {\n\t1\n\t2\n\t3\n\t4\n\tx\n}\n",
+			"@@ -3,5 +3,5 @@ func a() {\n \t2\n \t3\n \t4\n-\t5\n+\tx\n }\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"x\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ny\n",
+			"@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+y\n",
+		},
+		{
+			// blocks are inserted after the closing brace like git does
+			"}\n\nfunc b() {\n}\n",
+			"}\n\nfunc a() {\n}\n\nfunc b() {\n}\n",
+			"@@ -1,4 +1,7 @@\n }\n \n+func a() {\n+}\n+\n func b() {\n }\n",
+		},
+	}
+
+	for key, testCase := range cases {
+		var w bytes.Buffer
+		writeNativeHunks(&w, splitNativeLines([]byte(testCase.old)), splitNativeLines([]byte(testCase.new)))
+
+		if hunks := w.String(); hunks != testCase.want {
+			t.Errorf("[%d] writeNativeHunks(%q, %q) = %q, want: %q", key, testCase.old, testCase.new, hunks, testCase.want)
+		}
+	}
+}
+
+func TestNativeSimilarity(t *testing.T) {
+	cases := []struct{
+		a string
+		b string
+		want int
+	}{
+		{"a\nb\nc\nd\n", "a\nb\nc\nd\n", 100},
+		{"a\nb\nc\nd\n", "a\nb\nc\nx\n", 75},
+		{"a\nb\nc\nd\n", "x\ny\nz\nw\n", 0},
+		{"a\nb\nc\nd\n", "a\n", 0},
+		{"", "", 0},
+	}
+
+	for key, testCase := range cases {
+		if score := nativeSimilarity([]byte(testCase.a), []byte(testCase.b)); score != testCase.want {
+			t.Errorf("[%d] nativeSimilarity(%q, %q) = %d, want: %d", key, testCase.a, testCase.b, score, testCase.want)
+		}
+	}
+}
+
+func TestNativeIndent(t *testing.T) {
+	cases := []struct{
+		line string
+		want int
+	}{
+		{"code\n", 0},
+		{"  code\n", 2},
+		{"\t\tcode\n", 16},
+		{"  \tcode\n", 8},
+		{"  \n", -1},
+		{"\n", -1},
+	}
+
+	for key, testCase := range cases {
+		if indent := nativeIndent(testCase.line); indent != testCase.want {
+			t.Errorf("[%d] nativeIndent(%q) = %d, want: %d", key, testCase.line, indent, testCase.want)
+		}
+	}
+}
+
+func TestIsNativeBinary(t *testing.T) {
+	cases := []struct{
+		data []byte
+		want bool
+	}{
+		{[]byte("text\n"), false},
+		{[]byte("te\x00xt"), true},
+		{append(bytes.Repeat([]byte("a"), nativeBinaryCheckSize), 0), false},
+	}
+
+	for key, testCase := range cases {
+		if isBinary := isNativeBinary(testCase.data); isBinary != testCase.want {
+			t.Errorf("[%d] isNativeBinary() = %v, want: %v", key, isBinary, testCase.want)
+		}
+	}
+}
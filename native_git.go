@@ -0,0 +1,1117 @@
+package vcsview
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version of the native reader, it doesn't depend on git binary
+const nativeGitVersion = "native"
+
+// Pure Go reader of git repositories
+// It reads refs, loose objects and packfiles directly without git binary and process spawn overhead
+// Working tree isn't inspected, so StatusRepository returns empty status
+type NativeGit struct {
+	// Opened repositories by project paths
+	repositories *nativeRepositories
+
+	// Debug function which fixes the log messages
+	Debugger DebugFunc
+
+	// Receiver of the execution events (nil if disabled)
+	Hooks Hooks
+}
+
+// Opened repositories shared by NativeGit copies
+type nativeRepositories struct {
+	mu sync.Mutex
+	opened map[string]*nativeRepository
+}
+
+// Create pure Go reader of git repositories
+// Opened repositories (pack indexes and objects cache) are kept between calls
+func NewNativeGit() NativeGit {
+	return NativeGit{repositories: &nativeRepositories{opened: make(map[string]*nativeRepository)}}
+}
+
+// Open repository or get already opened one
+func (g NativeGit) open(projectPath string) (*nativeRepository, error) {
+	if g.repositories == nil {
+		return openNativeRepository(projectPath)
+	}
+
+	g.repositories.mu.Lock()
+	defer g.repositories.mu.Unlock()
+
+	if r, ok := g.repositories.opened[projectPath]; ok {
+		return r, nil
+	}
+
+	r, err := openNativeRepository(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	g.repositories.opened[projectPath] = r
+
+	return r, nil
+}
+
+// Create executor which runs function with opened repository
+func (g NativeGit) executor(ctx context.Context, name string, projectPath string, run func(r *nativeRepository) error) *Executor {
+	return NewFuncExecutor(ctx, "native "+name+" "+projectPath, func() error {
+		r, err := g.open(projectPath)
+		if err != nil {
+			return err
+		}
+
+		return run(r)
+	}, g.Debugger).observe(g.Hooks, "native "+strings.SplitN(name, " ", 2)[0], projectPath)
+}
+
+// Returns native reader version
+func (g NativeGit) Version(ctx context.Context) (string, error) {
+	return nativeGitVersion, nil
+}
+
+// Returns repository settings pathname
+func (g NativeGit) RepositoryPathname() string {
+	return ".git"
+}
+
+// Check project repository
+// Returns error if repository not found at provided projectPath
+func (g NativeGit) CheckRepository(projectPath string) error {
+	_, err := g.open(projectPath)
+	return err
+}
+
+// Check the repository could be read
+// Working tree isn't compared with the index, so status is always empty
+func (g NativeGit) StatusRepository(ctx context.Context, projectPath string) (string, error) {
+	r, err := g.open(projectPath)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := r.readRef("HEAD"); err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
+
+// Parsed commit object
+type gitCommit struct {
+	id string
+	tree string
+	parents []string
+	author Contributor
+	authorDate time.Time
+	commitDate time.Time
+
+	// Full commit message
+	message string
+}
+
+// Parse signature like "Name <email> 1551268305 +0300"
+func parseGitSignature(value string) (Contributor, time.Time) {
+	var c Contributor
+
+	start, end := strings.IndexByte(value, '<'), strings.LastIndexByte(value, '>')
+	if start < 0 || end < start {
+		return Contributor{name: strings.TrimSpace(value)}, time.Time{}
+	}
+
+	c.name = strings.TrimSpace(value[:start])
+	c.email = value[start+1 : end]
+
+	fields := strings.Fields(value[end+1:])
+	if len(fields) != 2 {
+		return c, time.Time{}
+	}
+
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return c, time.Time{}
+	}
+
+	offset := 0
+	if tz := fields[1]; len(tz) == 5 {
+		hours, _ := strconv.Atoi(tz[1:3])
+		minutes, _ := strconv.Atoi(tz[3:5])
+		offset = hours*60*60 + minutes*60
+		if tz[0] == '-' {
+			offset = -offset
+		}
+	}
+
+	return c, time.Unix(seconds, 0).In(time.FixedZone("", offset))
+}
+
+// Returns subject of the message: the first paragraph joined into one line (like %s format placeholder)
+func gitSubject(message string) string {
+	lines := make([]string, 0, 1)
+
+	for _, line := range strings.Split(strings.TrimLeft(message, "\n"), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, " ")
+}
+
+// Split object into headers and message
+// Multi-line header values (like gpgsig) are continued by lines with leading space
+func splitGitObject(data []byte) (map[string][]string, string) {
+	headers := make(map[string][]string)
+
+	text := string(data)
+	body := ""
+	if pos := strings.Index(text, "\n\n"); pos >= 0 {
+		text, body = text[:pos], text[pos+2:]
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, " ") {
+			continue
+		}
+		if pos := strings.IndexByte(line, ' '); pos > 0 {
+			headers[line[:pos]] = append(headers[line[:pos]], line[pos+1:])
+		}
+	}
+
+	return headers, body
+}
+
+// Read commit object by identifier
+func (r *nativeRepository) readCommit(id string) (gitCommit, error) {
+	o, err := r.store.read(id)
+	if err != nil {
+		return gitCommit{}, err
+	}
+
+	if o.kind != "commit" {
+		return gitCommit{}, fmt.Errorf("Object %s is a %s, not a commit", id, o.kind)
+	}
+
+	c := parseGitCommit(id, o.data)
+
+	// parents of the shallow clone boundary aren't fetched
+	if r.isShallow(id) {
+		c.parents = nil
+	}
+
+	return c, nil
+}
+
+// Parse raw commit object
+func parseGitCommit(id string, data []byte) gitCommit {
+	headers, message := splitGitObject(data)
+
+	c := gitCommit{id: id, parents: headers["parent"], message: message}
+
+	if tree := headers["tree"]; len(tree) > 0 {
+		c.tree = tree[0]
+	}
+	if author := headers["author"]; len(author) > 0 {
+		c.author, c.authorDate = parseGitSignature(author[0])
+	}
+	if committer := headers["committer"]; len(committer) > 0 {
+		_, c.commitDate = parseGitSignature(committer[0])
+	}
+
+	return c
+}
+
+// Convert commit object to the commit model
+func (c gitCommit) model() Commit {
+	// root commit has single empty parent like the Git backend returns
+	parents := c.parents
+	if len(parents) == 0 {
+		parents = []string{""}
+	}
+
+	return Commit{
+		id: c.id,
+		date: c.authorDate,
+		author: c.author,
+		message: gitSubject(c.message),
+		parents: parents,
+	}
+}
+
+// Tree object entry
+type gitTreeEntry struct {
+	// Six digits mode like 100644 or 040000
+	mode string
+
+	name string
+	id string
+}
+
+// Returns true if entry is a subtree
+func (e gitTreeEntry) isTree() bool {
+	return e.mode == "040000"
+}
+
+// Read tree object entries in the tree order
+func (r *nativeRepository) readTree(id string) ([]gitTreeEntry, error) {
+	o, err := r.store.read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.kind != "tree" {
+		return nil, fmt.Errorf("Object %s is a %s, not a tree", id, o.kind)
+	}
+
+	return parseGitTree(id, o.data, r.hashLen)
+}
+
+// Parse raw tree object entries
+// HashLen is object identifier length in bytes
+func parseGitTree(id string, data []byte, hashLen int) ([]gitTreeEntry, error) {
+	entries := make([]gitTreeEntry, 0)
+
+	// each entry is "<mode> <name>\0<binary identifier>"
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if space < 0 || nul < space || nul+1+hashLen > len(data) {
+			return nil, fmt.Errorf("Broken tree %s", id)
+		}
+
+		mode := string(data[:space])
+		for len(mode) < 6 {
+			mode = "0" + mode
+		}
+
+		entries = append(entries, gitTreeEntry{mode, string(data[space+1 : nul]), hex.EncodeToString(data[nul+1 : nul+1+hashLen])})
+		data = data[nul+1+hashLen:]
+	}
+
+	return entries, nil
+}
+
+// Find entry by relative pathname in the tree
+// Empty pathname means the tree itself
+func (r *nativeRepository) treeEntry(treeId string, pathname string) (gitTreeEntry, bool, error) {
+	entry := gitTreeEntry{"040000", "", treeId}
+
+	pathname = strings.Trim(pathname, "/")
+	if pathname == "" {
+		return entry, true, nil
+	}
+
+	for _, name := range strings.Split(pathname, "/") {
+		if !entry.isTree() {
+			return entry, false, nil
+		}
+
+		entries, err := r.readTree(entry.id)
+		if err != nil {
+			return entry, false, err
+		}
+
+		found := false
+		for _, e := range entries {
+			if e.name == name {
+				entry, found = e, true
+				break
+			}
+		}
+
+		if !found {
+			return entry, false, nil
+		}
+	}
+
+	return entry, true, nil
+}
+
+// Fetch repository branches with heads (local branches and remote-tracking ones)
+func (g NativeGit) ReadBranches(ctx context.Context, projectPath string, result chan Branch) *Executor {
+	return g.executor(ctx, "branches", projectPath, func(r *nativeRepository) error {
+		refs := r.refs()
+		head := r.headRef()
+
+		names := make([]string, 0, len(refs))
+		for name := range refs {
+			if strings.HasPrefix(name, "refs/heads/") || strings.HasPrefix(name, "refs/remotes/") {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			id := strings.TrimPrefix(name, "refs/heads/")
+			if strings.HasPrefix(name, "refs/remotes/") {
+				id = strings.TrimPrefix(name, "refs/")
+			}
+
+			result <- Branch{id, refs[name], name == head}
+		}
+
+		return nil
+	})
+}
+
+// Fetch repository commit by identifier, short identifier or ref name with ~N and ^N suffixes
+func (g NativeGit) ReadCommit(ctx context.Context, projectPath string, commitId string, result chan Commit) *Executor {
+	return g.executor(ctx, "commit "+commitId, projectPath, func(r *nativeRepository) error {
+		id, err := r.resolveCommit(commitId)
+		if err != nil {
+			return err
+		}
+
+		c, err := r.readCommit(id)
+		if err != nil {
+			return err
+		}
+
+		result <- c.model()
+
+		return nil
+	})
+}
+
+// Fetch repository tags sorted by creation date from the newest one
+func (g NativeGit) ReadTags(ctx context.Context, projectPath string, result chan Tag) *Executor {
+	return g.executor(ctx, "tags", projectPath, func(r *nativeRepository) error {
+		tags := make([]Tag, 0)
+
+		for name, id := range r.refs() {
+			if !strings.HasPrefix(name, "refs/tags/") {
+				continue
+			}
+
+			o, err := r.store.read(id)
+			if err != nil {
+				return err
+			}
+
+			t := Tag{id: strings.TrimPrefix(name, "refs/tags/"), head: id}
+
+			switch o.kind {
+			case "tag":
+				headers, message := splitGitObject(o.data)
+				t.head = r.tagTarget(o.data)
+				t.message = gitSubject(message)
+				if tagger := headers["tagger"]; len(tagger) > 0 {
+					_, t.date = parseGitSignature(tagger[0])
+				}
+			case "commit":
+				c, err := r.readCommit(id)
+				if err != nil {
+					return err
+				}
+				t.date = c.commitDate
+				t.message = gitSubject(c.message)
+			}
+
+			tags = append(tags, t)
+		}
+
+		sort.SliceStable(tags, func(i, j int) bool {
+			if !tags[i].date.Equal(tags[j].date) {
+				return tags[i].date.After(tags[j].date)
+			}
+			return tags[i].id < tags[j].id
+		})
+
+		for _, t := range tags {
+			result <- t
+		}
+
+		return nil
+	})
+}
+
+// Fetch file content at revision
+// Result gets nothing if file not found at revision, not resolved revision fails the executor
+func (g NativeGit) ReadBlob(ctx context.Context, projectPath string, revision string, pathname string, result chan Blob) *Executor {
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	pathname = strings.TrimLeft(pathname, "/")
+
+	return g.executor(ctx, "blob "+revision+":"+pathname, projectPath, func(r *nativeRepository) error {
+		id, err := r.resolveCommit(revision)
+		if err != nil {
+			return err
+		}
+
+		c, err := r.readCommit(id)
+		if err != nil {
+			return err
+		}
+
+		// submodules commits aren't stored in the repository
+		entry, found, err := r.treeEntry(c.tree, pathname)
+		if err != nil || !found || entry.isTree() || entry.mode == "160000" {
+			return err
+		}
+
+		o, err := r.store.read(entry.id)
+		if err != nil || o.kind != "blob" {
+			return err
+		}
+
+		result <- NewBlob(entry.id, pathname, o.data)
+
+		return nil
+	})
+}
+
+// Fetch files tree at revision
+// Path is a relative directory path, empty path means the project root
+// If recursive is true, result gets files of all subdirectories (without directories itself)
+// Result gets nothing if the path is a file, not existent path fails the executor with ErrPathNotFound error
+func (g NativeGit) ReadTree(ctx context.Context, projectPath string, revision string, path string, recursive bool, result chan File) *Executor {
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	path = strings.Trim(path, "/")
+
+	return g.executor(ctx, "tree "+revision+":"+path, projectPath, func(r *nativeRepository) error {
+		id, err := r.resolveCommit(revision)
+		if err != nil {
+			return err
+		}
+
+		c, err := r.readCommit(id)
+		if err != nil {
+			return err
+		}
+
+		entry, found, err := r.treeEntry(c.tree, path)
+		if err != nil || (found && !entry.isTree()) {
+			return err
+		}
+
+		if !found {
+			return newVcsError(ErrPathNotFound, "Path %s not found at %s", path, revision)
+		}
+
+		return r.listTree(ctx, entry.id, path, recursive, result)
+	})
+}
+
+// Send tree entries with sizes to the result
+func (r *nativeRepository) listTree(ctx context.Context, treeId string, path string, recursive bool, result chan File) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	entries, err := r.readTree(treeId)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		pathname := e.name
+		if path != "" {
+			pathname = path + "/" + e.name
+		}
+
+		if e.isTree() && recursive {
+			if err := r.listTree(ctx, e.id, pathname, true, result); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var size int64
+		isDir := e.isTree() || e.mode == "160000"
+
+		if !isDir {
+			if size, err = r.store.size(e.id); err != nil {
+				return err
+			}
+		}
+
+		result <- NewFileFromTree(pathname, isDir, size, gitFileMode(e.mode))
+	}
+
+	return nil
+}
+
+// Match branch name by glob pattern, wildcards match slashes too (like --branches option does)
+func matchNativeGlob(pattern string, name string) bool {
+	expr := "^"
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			expr += ".*"
+		case '?':
+			expr += "."
+		case '[':
+			if end := strings.IndexByte(pattern[i:], ']'); end > 0 {
+				expr += pattern[i : i+end+1]
+				i += end
+				continue
+			}
+			expr += `\[`
+		default:
+			expr += regexp.QuoteMeta(string(c))
+		}
+	}
+
+	matched, err := regexp.MatchString(expr+"$", name)
+
+	return err == nil && matched
+}
+
+// Commits queue ordered by commit date from the newest one
+type nativeCommitsQueue []nativeQueuedCommit
+
+type nativeQueuedCommit struct {
+	commit gitCommit
+
+	// Insertion order to pop commits with the same date in a stable order
+	seq int
+}
+
+func (q nativeCommitsQueue) Len() int {
+	return len(q)
+}
+
+func (q nativeCommitsQueue) Less(i, j int) bool {
+	if !q[i].commit.commitDate.Equal(q[j].commit.commitDate) {
+		return q[i].commit.commitDate.After(q[j].commit.commitDate)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q nativeCommitsQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *nativeCommitsQueue) Push(x interface{}) {
+	*q = append(*q, x.(nativeQueuedCommit))
+}
+
+func (q *nativeCommitsQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// Returns identifier of the path entry in the commit tree or empty string if path doesn't exist
+func (r *nativeRepository) pathId(c gitCommit, path string) (string, error) {
+	entry, found, err := r.treeEntry(c.tree, path)
+	if err != nil || !found {
+		return "", err
+	}
+
+	return entry.id, nil
+}
+
+// Check the commit changes the path and returns parents to follow
+// Commit which has the same path content as one of its parents follows this parent only
+// Empty path means all commits are shown and follow all parents
+func (r *nativeRepository) simplify(c gitCommit, path string) (bool, []string, error) {
+	if path == "" {
+		return true, c.parents, nil
+	}
+
+	id, err := r.pathId(c, path)
+	if err != nil {
+		return false, nil, err
+	}
+
+	show := id != "" && len(c.parents) == 0
+
+	for _, parentId := range c.parents {
+		parent, err := r.readCommit(parentId)
+		if err != nil {
+			return false, nil, err
+		}
+
+		parentPathId, err := r.pathId(parent, path)
+		if err != nil {
+			return false, nil, err
+		}
+
+		if parentPathId == id {
+			// the same content: follow this parent only
+			return false, []string{parentId}, nil
+		}
+
+		show = true
+	}
+
+	return show, c.parents, nil
+}
+
+// Rewrite parents of the commit to the nearest ancestors which change the path (like git log --parents)
+func (r *nativeRepository) rewriteParents(ctx context.Context, c gitCommit, path string) ([]string, error) {
+	_, parents, err := r.simplify(c, path)
+	if err != nil {
+		return nil, err
+	}
+
+	rewritten := make([]string, 0, len(parents))
+	seen := make(map[string]bool)
+
+	for _, id := range parents {
+		for id != "" {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			parent, err := r.readCommit(id)
+			if err != nil {
+				return nil, err
+			}
+
+			show, next, err := r.simplify(parent, path)
+			if err != nil {
+				return nil, err
+			}
+
+			if show {
+				if !seen[id] {
+					seen[id] = true
+					rewritten = append(rewritten, id)
+				}
+				break
+			}
+
+			// not shown commit follows the single parent or has no parents
+			id = ""
+			if len(next) > 0 {
+				id = next[0]
+			}
+		}
+	}
+
+	return rewritten, nil
+}
+
+// Walk commits from the start ones by commit date from the newest one
+// If path isn't empty, commits which don't change the path are skipped
+// and merges follow the parent with the same path content only (like git log history simplification)
+// Visit function returns false to stop walking, context cancellation stops it with the context error
+func (r *nativeRepository) walk(ctx context.Context, starts []string, path string, visit func(c gitCommit) bool) error {
+	queue := make(nativeCommitsQueue, 0)
+	seen := make(map[string]bool)
+	seq := 0
+
+	push := func(id string) error {
+		if seen[id] {
+			return nil
+		}
+		seen[id] = true
+
+		c, err := r.readCommit(id)
+		if err != nil {
+			return err
+		}
+
+		heap.Push(&queue, nativeQueuedCommit{c, seq})
+		seq++
+
+		return nil
+	}
+
+	for _, id := range starts {
+		if err := push(id); err != nil {
+			return err
+		}
+	}
+
+	for queue.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		c := heap.Pop(&queue).(nativeQueuedCommit).commit
+
+		show, parents, err := r.simplify(c, path)
+		if err != nil {
+			return err
+		}
+
+		if show && !visit(c) {
+			return nil
+		}
+
+		for _, parentId := range parents {
+			if err := push(parentId); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Commits queue ordered by generation number from the biggest one
+type nativeTopoQueue []nativeTopoCommit
+
+type nativeTopoCommit struct {
+	commit gitCommit
+
+	// Generation number of the commit, ancestors have smaller numbers
+	generation int
+
+	// Insertion order to pop commits with the same generation in a stable order
+	seq int
+
+	// Parents of the shown commit rewritten to the nearest ancestors which change the path
+	parents []string
+}
+
+func (q nativeTopoQueue) Len() int {
+	return len(q)
+}
+
+func (q nativeTopoQueue) Less(i, j int) bool {
+	if q[i].generation != q[j].generation {
+		return q[i].generation > q[j].generation
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q nativeTopoQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *nativeTopoQueue) Push(x interface{}) {
+	*q = append(*q, x.(nativeTopoCommit))
+}
+
+func (q *nativeTopoQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// Returns generation number of the commit: one for root commits, otherwise the maximum of the parents plus one
+// Numbers are kept by the repository, so ancestors of the commit are read once
+func (r *nativeRepository) generation(ctx context.Context, id string) (int, error) {
+	lookup := func(id string) (int, bool) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		generation, ok := r.generations[id]
+		return generation, ok
+	}
+
+	result := 0
+
+	// depth-first walk to the roots without recursion: commit is numbered after all its parents
+	stack := []string{id}
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		top := stack[len(stack)-1]
+		if generation, ok := lookup(top); ok {
+			if top == id {
+				result = generation
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		c, err := r.readCommit(top)
+		if err != nil {
+			return 0, err
+		}
+
+		generation, isNumbered := 1, true
+		for _, parentId := range c.parents {
+			parent, ok := lookup(parentId)
+			if !ok {
+				stack = append(stack, parentId)
+				isNumbered = false
+			} else if parent + 1 > generation {
+				generation = parent + 1
+			}
+		}
+
+		if !isNumbered {
+			continue
+		}
+
+		r.mu.Lock()
+		if r.generations == nil {
+			r.generations = make(map[string]int)
+		}
+		r.generations[top] = generation
+		r.mu.Unlock()
+
+		if top == id {
+			result = generation
+		}
+		stack = stack[:len(stack)-1]
+	}
+
+	return result, nil
+}
+
+// Walk commits from the start ones in topological order: no commit goes before all its children
+// Commits are explored by generation number from the biggest one and a commit is visited
+// when all commits which could be its children are explored, so the walk doesn't read the whole history to visit first commits
+// If path isn't empty, commits which don't change the path are skipped and parents are rewritten to the nearest ancestors which change it
+// Visit function gets the commit with its parents and returns false to stop walking, context cancellation stops it with the context error
+func (r *nativeRepository) walkTopo(ctx context.Context, starts []string, path string, visit func(c gitCommit, parents []string) bool) error {
+	queue := make(nativeTopoQueue, 0)
+	queued := make(map[string]bool)
+	seq := 0
+
+	// explored shown commits, numbers of their children which aren't visited yet and commits ready to visit
+	shown := make(map[string]nativeTopoCommit)
+	children := make(map[string]int)
+	visited := make(map[string]bool)
+	ready := make([]string, 0)
+
+	push := func(id string) error {
+		if queued[id] {
+			return nil
+		}
+		queued[id] = true
+
+		c, err := r.readCommit(id)
+		if err != nil {
+			return err
+		}
+
+		generation, err := r.generation(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		heap.Push(&queue, nativeTopoCommit{c, generation, seq, nil})
+		seq++
+
+		return nil
+	}
+
+	explore := func() error {
+		item := heap.Pop(&queue).(nativeTopoCommit)
+
+		show, follow, err := r.simplify(item.commit, path)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range follow {
+			if err := push(id); err != nil {
+				return err
+			}
+		}
+
+		if !show {
+			return nil
+		}
+
+		item.parents = item.commit.parents
+		if path != "" {
+			if item.parents, err = r.rewriteParents(ctx, item.commit, path); err != nil {
+				return err
+			}
+		}
+
+		for _, id := range item.parents {
+			children[id]++
+		}
+
+		shown[item.commit.id] = item
+		if children[item.commit.id] == 0 {
+			ready = append(ready, item.commit.id)
+		}
+
+		return nil
+	}
+
+	for _, id := range starts {
+		if err := push(id); err != nil {
+			return err
+		}
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if len(ready) == 0 {
+			if queue.Len() == 0 {
+				return nil
+			}
+			if err := explore(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		item := shown[ready[len(ready)-1]]
+		ready = ready[:len(ready)-1]
+
+		// commits with bigger generation numbers could be children of the commit
+		for queue.Len() > 0 && queue[0].generation > item.generation {
+			if err := explore(); err != nil {
+				return err
+			}
+		}
+
+		// commit with not visited children is ready again after them
+		id := item.commit.id
+		if visited[id] || children[id] > 0 {
+			continue
+		}
+		visited[id] = true
+
+		if !visit(item.commit, item.parents) {
+			return nil
+		}
+
+		for _, parentId := range item.parents {
+			children[parentId]--
+			if _, ok := shown[parentId]; ok && children[parentId] == 0 {
+				ready = append(ready, parentId)
+			}
+		}
+	}
+}
+
+// Read commits history of local branches
+// Path is a relative file or directory path, empty path means whole project
+// Branch filters branches which names contain it, empty branch means all branches
+// Offset is number of skipped commits, limit is number of maximum commits to read (negative limit means no limit)
+func (g NativeGit) ReadHistory(ctx context.Context, projectPath string, path string, branch string, offset int, limit int, result chan Commit) *Executor {
+	path = strings.Trim(path, "/")
+
+	return g.executor(ctx, "history "+branch+":"+path, projectPath, func(r *nativeRepository) error {
+		starts := make([]string, 0)
+		pattern := "*" + branch + "*"
+
+		refs := r.refs()
+		names := make([]string, 0, len(refs))
+		for name := range refs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if strings.HasPrefix(name, "refs/heads/") && matchNativeGlob(pattern, strings.TrimPrefix(name, "refs/heads/")) {
+				id, err := r.peelToCommit(refs[name])
+				if err != nil {
+					return err
+				}
+				starts = append(starts, id)
+			}
+		}
+
+		return r.history(ctx, starts, path, offset, limit, result)
+	})
+}
+
+// Read commits history starting from the commits in topological order
+// The walk stops after limit commits instead of sorting the whole history (see walkTopo)
+// CommitIds are full commit identifiers, limit is number of maximum commits to read (negative limit means no limit)
+// If path isn't empty, parents are rewritten to the nearest ancestors which change the path
+func (g NativeGit) ReadHistoryFrom(ctx context.Context, projectPath string, path string, commitIds []string, limit int, result chan Commit) *Executor {
+	path = strings.Trim(path, "/")
+
+	return g.executor(ctx, "history from "+strings.Join(commitIds, ",")+":"+path, projectPath, func(r *nativeRepository) error {
+		for _, id := range commitIds {
+			if !fullObjectIdPattern.MatchString(id) {
+				return newVcsError(ErrRevisionNotFound, "Invalid commit identifier %s", id)
+			}
+			if _, err := r.readCommit(id); err != nil {
+				return err
+			}
+		}
+
+		if limit == 0 {
+			return nil
+		}
+
+		sent := 0
+
+		return r.walkTopo(ctx, commitIds, path, func(c gitCommit, parents []string) bool {
+			commit := c.model()
+
+			if path != "" {
+				commit.parents = parents
+				if len(parents) == 0 {
+					commit.parents = []string{""}
+				}
+			}
+
+			result <- commit
+			sent++
+
+			return limit < 0 || sent < limit
+		})
+	})
+}
+
+// Walk history from the start commits and send commits after offset
+func (r *nativeRepository) history(ctx context.Context, starts []string, path string, offset int, limit int, result chan Commit) error {
+	if limit == 0 {
+		return nil
+	}
+
+	skipped, sent := 0, 0
+
+	return r.walk(ctx, starts, path, func(c gitCommit) bool {
+		if skipped < offset {
+			skipped++
+			return true
+		}
+
+		result <- c.model()
+		sent++
+
+		return limit < 0 || sent < limit
+	})
+}
+
+// Fetch changes of the commit comparing with its first parent
+func (g NativeGit) ReadDiff(ctx context.Context, projectPath string, commitId string, result chan FileDiff) *Executor {
+	return g.executor(ctx, "diff "+commitId, projectPath, func(r *nativeRepository) error {
+		id, err := r.resolveCommit(commitId)
+		if err != nil {
+			return err
+		}
+
+		c, err := r.readCommit(id)
+		if err != nil {
+			return err
+		}
+
+		oldTree := ""
+		if len(c.parents) > 0 {
+			parent, err := r.readCommit(c.parents[0])
+			if err != nil {
+				return err
+			}
+			oldTree = parent.tree
+		}
+
+		return r.diff(oldTree, c.tree, result)
+	})
+}
@@ -0,0 +1,328 @@
+package vcsview
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Create repositories of the same project with Git and NativeGit
+func makeNativeRepositories(t *testing.T) (Repository, Repository) {
+	want, err := NewRepository(gitRepositoryPath, MakeGitMock(t))
+	if err != nil {
+		t.Fatalf("NewRepository(%s, Git) got error: %v", gitRepositoryPath, err)
+	}
+
+	got, err := NewRepository(gitRepositoryPath, NewNativeGit())
+	if err != nil {
+		t.Fatalf("NewRepository(%s, NativeGit) got error: %v", gitRepositoryPath, err)
+	}
+
+	return want, got
+}
+
+// Compare commits ignoring dates time zones
+func isSameCommit(a Commit, b Commit) bool {
+	return a.Id() == b.Id() && a.Date().Equal(b.Date()) && a.Author() == b.Author() &&
+		a.Message() == b.Message() && reflect.DeepEqual(a.Parents(), b.Parents())
+}
+
+func TestNativeGit_CheckRepository(t *testing.T) {
+	g := NewNativeGit()
+
+	cases := []struct{
+		projectPath string
+		isValid bool
+	}{
+		{gitRepositoryPath, true},
+		{noRepositoryPath, false},
+		{hgRepositoryPath, false},
+	}
+
+	for key, testCase := range cases {
+		if err := g.CheckRepository(testCase.projectPath); (err == nil) != testCase.isValid {
+			t.Errorf("[%d] NativeGit.CheckRepository(%s) = %v, want valid: %v", key, testCase.projectPath, err, testCase.isValid)
+		}
+	}
+
+	if status, err := g.StatusRepository(context.Background(), gitRepositoryPath); err != nil || status != "" {
+		t.Errorf("NativeGit.StatusRepository(%s) = %v, %v, want empty status", gitRepositoryPath, status, err)
+	}
+}
+
+func TestNativeGit_ReadBranches(t *testing.T) {
+	want, got := makeNativeRepositories(t)
+
+	wantBranches, err := want.Branches(context.Background())
+	if err != nil {
+		t.Fatalf("Repository.Branches() got error: %v", err)
+	}
+
+	branches, err := got.Branches(context.Background())
+	if err != nil || len(branches) != len(wantBranches) {
+		t.Fatalf("NativeGit Repository.Branches() = %v, %v, want: %v", branches, err, wantBranches)
+	}
+
+	for key, b := range branches {
+		w := wantBranches[key]
+		if b.Id() != w.Id() || b.IsCurrent() != w.IsCurrent() || !strings.HasPrefix(b.Head(), w.Head()) {
+			t.Errorf("[%d] NativeGit Repository.Branches() got %v, want: %v", key, b, w)
+		}
+	}
+}
+
+func TestNativeGit_ReadTags(t *testing.T) {
+	want, got := makeNativeRepositories(t)
+
+	wantTags, err := want.Tags(context.Background())
+	if err != nil {
+		t.Fatalf("Repository.Tags() got error: %v", err)
+	}
+
+	tags, err := got.Tags(context.Background())
+	if err != nil || len(tags) != len(wantTags) {
+		t.Fatalf("NativeGit Repository.Tags() = %v, %v, want: %v", tags, err, wantTags)
+	}
+
+	for key, tag := range tags {
+		w := wantTags[key]
+		if tag.Id() != w.Id() || tag.Head() != w.Head() || tag.Message() != w.Message() || !tag.Date().Equal(w.Date()) {
+			t.Errorf("[%d] NativeGit Repository.Tags() got %v, want: %v", key, tag, w)
+		}
+	}
+}
+
+func TestNativeGit_ReadCommit(t *testing.T) {
+	want, got := makeNativeRepositories(t)
+
+	parent, err := want.Commit(context.Background(), "HEAD~1")
+	if err != nil {
+		t.Fatalf("Repository.Commit(HEAD~1) got error: %v", err)
+	}
+
+	cases := []string{"HEAD", "master", "branch1", "v1.0", "v1.1", "HEAD~1", "HEAD^", "master~2^1", parent.Id(), parent.Id()[:7]}
+
+	for key, revision := range cases {
+		w, err := want.Commit(context.Background(), revision)
+		if err != nil {
+			t.Fatalf("[%d] Repository.Commit(%s) got error: %v", key, revision, err)
+		}
+
+		if c, err := got.Commit(context.Background(), revision); err != nil || !isSameCommit(c, w) {
+			t.Errorf("[%d] NativeGit Repository.Commit(%s) = %v, %v, want: %v", key, revision, c, err, w)
+		}
+	}
+
+	for key, revision := range []string{"unknown", "0000000000000000000000000000000000000000", "HEAD~1000"} {
+		if c, err := got.Commit(context.Background(), revision); ErrorKind(err) != ErrRevisionNotFound {
+			t.Errorf("[%d] NativeGit Repository.Commit(%s) = %v, %v, want: %v", key, revision, c, err, ErrRevisionNotFound)
+		}
+	}
+}
+
+func TestNativeGit_ReadHistory(t *testing.T) {
+	want, got := makeNativeRepositories(t)
+
+	cases := []struct{
+		path string
+		branch string
+		offset int
+		limit int
+	}{
+		{"", "", 0, -1},
+		{"", "", 1, 2},
+		{"", "master", 0, 100},
+		{"", "branch1", 0, 100},
+		{"", "unknown", 0, 100},
+		{"testpath", "", 0, 100},
+		{"testpath/moved.txt", "", 0, 100},
+		{"README.md", "", 0, 100},
+		{"main.go", "", 1, 100},
+		{"non-existent.txt", "", 0, 100},
+	}
+
+	for key, testCase := range cases {
+		w, err := want.History(context.Background(), testCase.path, testCase.branch, testCase.offset, testCase.limit)
+		if err != nil {
+			t.Fatalf("[%d] Repository.History(%s, %s) got error: %v", key, testCase.path, testCase.branch, err)
+		}
+
+		commits, err := got.History(context.Background(), testCase.path, testCase.branch, testCase.offset, testCase.limit)
+		if err != nil || len(commits) != len(w) {
+			t.Errorf("[%d] NativeGit Repository.History(%s, %s) = %v, %v, want: %v", key, testCase.path, testCase.branch, commits, err, w)
+			continue
+		}
+
+		for i, c := range commits {
+			if !isSameCommit(c, w[i]) {
+				t.Errorf("[%d] NativeGit Repository.History(%s, %s)[%d] = %v, want: %v", key, testCase.path, testCase.branch, i, c, w[i])
+			}
+		}
+	}
+}
+
+func TestNativeGit_HistoryPage(t *testing.T) {
+	want, got := makeNativeRepositories(t)
+
+	for key, path := range []string{"", "testpath", "main.go", "README.md"} {
+		w, _, err := want.HistoryPage(context.Background(), path, "", "", 100)
+		if err != nil {
+			t.Fatalf("[%d] Repository.HistoryPage(%s) got error: %v", key, path, err)
+		}
+
+		commits, _, err := got.HistoryPage(context.Background(), path, "", "", 100)
+		if err != nil || len(commits) != len(w) {
+			t.Errorf("[%d] NativeGit Repository.HistoryPage(%s) = %v, %v, want: %v", key, path, commits, err, w)
+			continue
+		}
+
+		// parents are rewritten the same way
+		for i, c := range commits {
+			if !isSameCommit(c, w[i]) || strings.Join(c.Parents(), ",") != strings.Join(w[i].Parents(), ",") {
+				t.Errorf("[%d] NativeGit Repository.HistoryPage(%s)[%d] = %v, want: %v", key, path, i, c, w[i])
+			}
+		}
+	}
+}
+
+func TestNativeGit_ReadTree(t *testing.T) {
+	want, got := makeNativeRepositories(t)
+
+	cases := []struct{
+		revision string
+		path string
+		recursive bool
+	}{
+		{"HEAD", "", false},
+		{"HEAD", "", true},
+		{"master", "testpath", false},
+		{"branch1", "testpath/", true},
+		{"HEAD", "README.md", false},
+	}
+
+	for key, testCase := range cases {
+		w, err := want.ReadTree(context.Background(), testCase.revision, testCase.path, testCase.recursive)
+		if err != nil {
+			t.Fatalf("[%d] Repository.ReadTree(%s, %s) got error: %v", key, testCase.revision, testCase.path, err)
+		}
+
+		if files, err := got.ReadTree(context.Background(), testCase.revision, testCase.path, testCase.recursive); err != nil || !reflect.DeepEqual(files, w) {
+			t.Errorf("[%d] NativeGit Repository.ReadTree(%s, %s) = %v, %v, want: %v", key, testCase.revision, testCase.path, files, err, w)
+		}
+	}
+
+	if files, err := got.ReadTree(context.Background(), "unknown", "", false); ErrorKind(err) != ErrRevisionNotFound {
+		t.Errorf("NativeGit Repository.ReadTree(unknown) = %v, %v, want: %v", files, err, ErrRevisionNotFound)
+	}
+
+	if files, err := got.ReadTree(context.Background(), "HEAD", "non-existent", false); ErrorKind(err) != ErrPathNotFound {
+		t.Errorf("NativeGit Repository.ReadTree(HEAD, non-existent) = %v, %v, want: %v", files, err, ErrPathNotFound)
+	}
+}
+
+func TestNativeGit_ReadBlob(t *testing.T) {
+	want, got := makeNativeRepositories(t)
+
+	files, err := want.ReadTree(context.Background(), "HEAD", "", true)
+	if err != nil {
+		t.Fatalf("Repository.ReadTree(HEAD) got error: %v", err)
+	}
+
+	for key, f := range files {
+		w, err := want.ReadBlob(context.Background(), "HEAD", f.Pathname())
+		if err != nil {
+			t.Fatalf("[%d] Repository.ReadBlob(HEAD, %s) got error: %v", key, f.Pathname(), err)
+		}
+
+		if blob, err := got.ReadBlob(context.Background(), "HEAD", f.Pathname()); err != nil || !reflect.DeepEqual(blob, w) {
+			t.Errorf("[%d] NativeGit Repository.ReadBlob(HEAD, %s) = %v, %v, want: %v", key, f.Pathname(), blob, err, w)
+		}
+	}
+
+	for key, pathname := range []string{"non-existent.txt", "testpath", ""} {
+		if blob, err := got.ReadBlob(context.Background(), "HEAD", pathname); err == nil {
+			t.Errorf("[%d] NativeGit Repository.ReadBlob(HEAD, %s) = %v, want error", key, pathname, blob)
+		}
+	}
+}
+
+func TestNativeGit_ReadDiff(t *testing.T) {
+	want, got := makeNativeRepositories(t)
+
+	commits, err := want.History(context.Background(), "", "", 0, -1)
+	if err != nil {
+		t.Fatalf("Repository.History() got error: %v", err)
+	}
+
+	for _, c := range commits {
+		w, err := want.Diff(context.Background(), c.Id())
+		if err != nil {
+			t.Fatalf("Repository.Diff(%s) got error: %v", c.Id(), err)
+		}
+
+		if diffs, err := got.Diff(context.Background(), c.Id()); err != nil || !reflect.DeepEqual(diffs, w) {
+			t.Errorf("NativeGit Repository.Diff(%s) = %v, %v, want: %v", c.Id(), diffs, err, w)
+		}
+	}
+}
+
+func TestParseGitSignature(t *testing.T) {
+	cases := []struct{
+		value string
+		contributor Contributor
+		date time.Time
+	}{
+		{"Max Kalyabin <maksim@kalyabin.ru> 1475608047 +0300", Contributor{"Max Kalyabin", "maksim@kalyabin.ru"}, time.Date(2016, 10, 4, 22, 7, 27, 0, time.FixedZone("", 3*60*60))},
+		{"Somebody <> 0 -0130", Contributor{"Somebody", ""}, time.Unix(0, 0)},
+		{"Broken", Contributor{"Broken", ""}, time.Time{}},
+	}
+
+	for key, testCase := range cases {
+		contributor, date := parseGitSignature(testCase.value)
+		if contributor != testCase.contributor || !date.Equal(testCase.date) {
+			t.Errorf("[%d] parseGitSignature(%s) = %v, %v, want: %v, %v", key, testCase.value, contributor, date, testCase.contributor, testCase.date)
+		}
+	}
+}
+
+func TestGitSubject(t *testing.T) {
+	cases := []struct{
+		message string
+		subject string
+	}{
+		{"Subject\n\nBody\n", "Subject"},
+		{"First line\nsecond line\n\nBody", "First line second line"},
+		{"\n\n  Indented  \n", "Indented"},
+		{"", ""},
+	}
+
+	for key, testCase := range cases {
+		if subject := gitSubject(testCase.message); subject != testCase.subject {
+			t.Errorf("[%d] gitSubject(%q) = %q, want: %q", key, testCase.message, subject, testCase.subject)
+		}
+	}
+}
+
+func TestMatchNativeGlob(t *testing.T) {
+	cases := []struct{
+		pattern string
+		name string
+		isMatched bool
+	}{
+		{"**", "master", true},
+		{"*mast*", "master", true},
+		{"*feature*", "feature/one", true},
+		{"*a.b*", "axb", false},
+		{"*b[12]*", "branch1", false},
+		{"*h[12]*", "branch1", true},
+		{"*?*", "", false},
+	}
+
+	for key, testCase := range cases {
+		if isMatched := matchNativeGlob(testCase.pattern, testCase.name); isMatched != testCase.isMatched {
+			t.Errorf("[%d] matchNativeGlob(%s, %s) = %v, want: %v", key, testCase.pattern, testCase.name, isMatched, testCase.isMatched)
+		}
+	}
+}
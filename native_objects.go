@@ -0,0 +1,706 @@
+package vcsview
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Packed object types
+	nativePackCommit = 1
+	nativePackTree = 2
+	nativePackBlob = 3
+	nativePackTag = 4
+	nativePackOfsDelta = 6
+	nativePackRefDelta = 7
+
+	// Maximum length of the deltas chain (git never writes chains longer than 4095)
+	nativeMaxDeltaDepth = 5000
+
+	// Number of cached objects and delta bases
+	nativeObjectsCacheSize = 1024
+
+	// Maximum total size of cached objects and delta bases
+	nativeObjectsCacheBytes = 64 * 1024 * 1024
+
+	// Objects larger than that aren't cached
+	nativeMaxCachedObjectSize = 1024 * 1024
+
+	// Maximum compression ratio of deflate, inflated entry can't be larger than its compressed data multiplied by it
+	nativeMaxDeflateRatio = 1032
+)
+
+// Object names of the packed object types
+var nativePackTypes = map[int]string{
+	nativePackCommit: "commit",
+	nativePackTree: "tree",
+	nativePackBlob: "blob",
+	nativePackTag: "tag",
+}
+
+// Object of the git objects database
+type nativeObject struct {
+	// Object type: commit, tree, blob or tag
+	kind string
+
+	// Object content without header
+	data []byte
+}
+
+// Packfile with its index
+type nativePack struct {
+	// Packfile pathname
+	pathname string
+
+	// Object identifier length in bytes
+	hashLen int
+
+	// Number of objects with the first identifier byte less or equal to the index
+	fanout [256]uint32
+
+	// Sorted objects identifiers
+	ids []byte
+
+	// Objects offsets in the packfile, offsets with MSB set are indexes of large offsets
+	offsets []byte
+
+	// 8-byte offsets for packfiles larger than 2GB
+	largeOffsets []byte
+
+	// Opened packfile
+	file *os.File
+
+	// Packfile size in bytes
+	length int64
+}
+
+// Load pack index version 2 and open its packfile
+func openNativePack(idxPathname string, hashLen int) (*nativePack, error) {
+	data, err := ioutil.ReadFile(idxPathname)
+	if err != nil {
+		return nil, err
+	}
+
+	header := 8 + 256*4
+	if len(data) < header || !bytes.Equal(data[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(data[4:8]) != 2 {
+		return nil, fmt.Errorf("Unsupported pack index %s", idxPathname)
+	}
+
+	p := &nativePack{pathname: strings.TrimSuffix(idxPathname, ".idx") + ".pack", hashLen: hashLen}
+
+	for i := 0; i < 256; i++ {
+		p.fanout[i] = binary.BigEndian.Uint32(data[8+i*4:])
+	}
+
+	count := int(p.fanout[255])
+
+	// identifiers, crc32 checksums, 4-byte offsets, then large offsets and two checksums
+	idsEnd := header + count*hashLen
+	offsetsStart := idsEnd + count*4
+	offsetsEnd := offsetsStart + count*4
+
+	if len(data) < offsetsEnd+2*hashLen {
+		return nil, fmt.Errorf("Broken pack index %s", idxPathname)
+	}
+
+	p.ids = data[header:idsEnd]
+	p.offsets = data[offsetsStart:offsetsEnd]
+	p.largeOffsets = data[offsetsEnd : len(data)-2*hashLen]
+
+	if p.file, err = os.Open(p.pathname); err != nil {
+		return nil, err
+	}
+
+	stat, err := p.file.Stat()
+	if err != nil {
+		p.file.Close()
+		return nil, err
+	}
+	p.length = stat.Size()
+
+	return p, nil
+}
+
+// Find object offset in the packfile by binary identifier
+func (p *nativePack) find(id []byte) (int64, bool) {
+	lo := 0
+	if id[0] > 0 {
+		lo = int(p.fanout[id[0]-1])
+	}
+	hi := int(p.fanout[id[0]])
+
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.ids[(lo+i)*p.hashLen:(lo+i+1)*p.hashLen], id) >= 0
+	})
+
+	if i >= hi || !bytes.Equal(p.ids[i*p.hashLen:(i+1)*p.hashLen], id) {
+		return 0, false
+	}
+
+	offset := binary.BigEndian.Uint32(p.offsets[i*4:])
+	if offset&0x80000000 == 0 {
+		return int64(offset), true
+	}
+
+	pos := int(offset&0x7fffffff) * 8
+	if pos+8 > len(p.largeOffsets) {
+		return 0, false
+	}
+
+	return int64(binary.BigEndian.Uint64(p.largeOffsets[pos:])), true
+}
+
+// Collect identifiers which start with hex prefix (at least 2 characters)
+func (p *nativePack) expand(prefix string, result map[string]bool) {
+	first, err := strconv.ParseUint(prefix[:2], 16, 8)
+	if err != nil {
+		return
+	}
+
+	lo := 0
+	if first > 0 {
+		lo = int(p.fanout[first-1])
+	}
+
+	for i := lo; i < int(p.fanout[first]); i++ {
+		id := hex.EncodeToString(p.ids[i*p.hashLen : (i+1)*p.hashLen])
+		if strings.HasPrefix(id, prefix) {
+			result[id] = true
+		}
+	}
+}
+
+// Read packed entry header at offset
+// Returns entry type, size, delta base (offset for ofs-delta or identifier for ref-delta) and reader of the compressed data
+func (p *nativePack) header(offset int64) (int, int64, int64, []byte, *bufio.Reader, error) {
+	r := bufio.NewReader(io.NewSectionReader(p.file, offset, math.MaxInt64-offset))
+
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, nil, nil, err
+	}
+
+	kind := int(c>>4) & 7
+	size := int64(c & 0x0f)
+	for shift := uint(4); c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil {
+			return 0, 0, 0, nil, nil, err
+		}
+		size |= int64(c&0x7f) << shift
+	}
+
+	var (
+		baseOffset int64
+		baseId []byte
+	)
+
+	switch kind {
+	case nativePackOfsDelta:
+		if c, err = r.ReadByte(); err != nil {
+			return 0, 0, 0, nil, nil, err
+		}
+		distance := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = r.ReadByte(); err != nil {
+				return 0, 0, 0, nil, nil, err
+			}
+			distance = ((distance + 1) << 7) | int64(c&0x7f)
+		}
+		baseOffset = offset - distance
+	case nativePackRefDelta:
+		baseId = make([]byte, p.hashLen)
+		if _, err = io.ReadFull(r, baseId); err != nil {
+			return 0, 0, 0, nil, nil, err
+		}
+	}
+
+	return kind, size, baseOffset, baseId, r, nil
+}
+
+// Read raw packed entry at offset
+// Returns entry type, inflated data and delta base (offset for ofs-delta or identifier for ref-delta)
+func (p *nativePack) entry(offset int64) (int, []byte, int64, []byte, error) {
+	kind, size, baseOffset, baseId, r, err := p.header(offset)
+	if err != nil {
+		return 0, nil, 0, nil, err
+	}
+
+	// broken or crafted header shouldn't allocate more than the rest of the packfile could inflate to
+	if size < 0 || size > (p.length - offset) * nativeMaxDeflateRatio {
+		return 0, nil, 0, nil, fmt.Errorf("Broken entry size %d at %d in %s", size, offset, p.pathname)
+	}
+
+	z, err := zlib.NewReader(r)
+	if err != nil {
+		return 0, nil, 0, nil, err
+	}
+	defer z.Close()
+
+	data := make([]byte, size)
+	if _, err = io.ReadFull(z, data); err != nil {
+		return 0, nil, 0, nil, err
+	}
+
+	return kind, data, baseOffset, baseId, nil
+}
+
+// Returns object size at offset
+// Deltas are inflated only to read the result size of their headers
+func (p *nativePack) size(offset int64) (int64, error) {
+	kind, size, _, _, r, err := p.header(offset)
+	if err != nil {
+		return 0, err
+	}
+
+	if kind != nativePackOfsDelta && kind != nativePackRefDelta {
+		return size, nil
+	}
+
+	z, err := zlib.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer z.Close()
+
+	// two varints of base and result sizes
+	header := make([]byte, 20)
+	n, err := io.ReadFull(z, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return 0, err
+	}
+
+	_, pos := nativeDeltaSize(header[:n], 0)
+	result, _ := nativeDeltaSize(header[:n], pos)
+
+	return int64(result), nil
+}
+
+// Read delta header size
+func nativeDeltaSize(delta []byte, pos int) (int, int) {
+	size, shift := 0, uint(0)
+	for pos < len(delta) {
+		c := delta[pos]
+		pos++
+		size |= int(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			break
+		}
+	}
+
+	return size, pos
+}
+
+// Apply delta instructions to the base object
+func applyNativeDelta(base []byte, delta []byte) ([]byte, error) {
+	baseSize, pos := nativeDeltaSize(delta, 0)
+	if baseSize != len(base) {
+		return nil, fmt.Errorf("Delta base size %d doesn't match %d", baseSize, len(base))
+	}
+
+	size, pos := nativeDeltaSize(delta, pos)
+
+	// result grows by instructions, so the broken header size isn't allocated at once
+	capacity := size
+	if capacity > len(base) + len(delta) {
+		capacity = len(base) + len(delta)
+	}
+	result := make([]byte, 0, capacity)
+
+	for pos < len(delta) {
+		op := delta[pos]
+		pos++
+
+		if op&0x80 == 0 {
+			// insert next op bytes
+			if op == 0 || pos+int(op) > len(delta) {
+				return nil, fmt.Errorf("Broken delta insert instruction")
+			}
+			result = append(result, delta[pos:pos+int(op)]...)
+			pos += int(op)
+			continue
+		}
+
+		// copy from base: offset and size bytes are present by op bits
+		offset, length := 0, 0
+		for i := uint(0); i < 7; i++ {
+			if op&(1<<i) == 0 {
+				continue
+			}
+			if pos >= len(delta) {
+				return nil, fmt.Errorf("Broken delta copy instruction")
+			}
+			if i < 4 {
+				offset |= int(delta[pos]) << (8 * i)
+			} else {
+				length |= int(delta[pos]) << (8 * (i - 4))
+			}
+			pos++
+		}
+
+		if length == 0 {
+			length = 0x10000
+		}
+
+		if offset+length > len(base) {
+			return nil, fmt.Errorf("Delta copy instruction is out of base")
+		}
+
+		result = append(result, base[offset:offset+length]...)
+	}
+
+	if len(result) != size {
+		return nil, fmt.Errorf("Delta result size %d doesn't match %d", len(result), size)
+	}
+
+	return result, nil
+}
+
+// Git objects database reader of loose objects and packfiles
+// Safe for concurrent use
+type nativeStore struct {
+	// Objects directories: own one and alternates
+	dirs []string
+
+	// Object identifier length in bytes (20 for sha1 and 32 for sha256)
+	hashLen int
+
+	mu sync.Mutex
+
+	// Loaded packs by index pathnames
+	packs map[string]*nativePack
+
+	// Recently read objects and delta bases
+	cache *lruCache
+}
+
+// Create objects database reader of the repository config directory
+func newNativeStore(gitDir string, hashLen int) *nativeStore {
+	dirs := []string{filepath.Join(gitDir, "objects")}
+
+	// alternates lists other objects directories line by line (relative to the objects directory)
+	if data, err := ioutil.ReadFile(filepath.Join(dirs[0], "info", "alternates")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(dirs[0], line)
+			}
+			dirs = append(dirs, line)
+		}
+	}
+
+	s := &nativeStore{
+		dirs: dirs,
+		hashLen: hashLen,
+		packs: make(map[string]*nativePack),
+		cache: newLRUCache(nativeObjectsCacheSize, nativeObjectsCacheBytes),
+	}
+	s.loadPacks()
+
+	return s
+}
+
+// Load packs which weren't loaded yet and close packs which were removed (by gc or repack)
+// Returns true if any pack was loaded or closed
+func (s *nativeStore) loadPacks() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	found := make(map[string]bool)
+
+	for _, dir := range s.dirs {
+		pathnames, _ := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+
+		for _, pathname := range pathnames {
+			found[pathname] = true
+
+			if _, ok := s.packs[pathname]; ok {
+				continue
+			}
+
+			if p, err := openNativePack(pathname, s.hashLen); err == nil {
+				s.packs[pathname] = p
+				changed = true
+			}
+		}
+	}
+
+	for pathname, p := range s.packs {
+		if !found[pathname] {
+			p.file.Close()
+			delete(s.packs, pathname)
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// Check the pack was closed after it was removed
+func (s *nativeStore) isClosed(p *nativePack) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.packs[p.pathname] != p
+}
+
+// Get loaded packs
+func (s *nativeStore) loadedPacks() []*nativePack {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	packs := make([]*nativePack, 0, len(s.packs))
+	for _, p := range s.packs {
+		packs = append(packs, p)
+	}
+
+	return packs
+}
+
+// Read object by hex identifier
+func (s *nativeStore) read(id string) (nativeObject, error) {
+	if v, ok := s.cache.get(id); ok {
+		return v.(nativeObject), nil
+	}
+
+	o, err := s.lookup(id, 0)
+	if err != nil {
+		return o, err
+	}
+
+	if len(o.data) <= nativeMaxCachedObjectSize {
+		s.cache.put(id, o, int64(len(o.data)))
+	}
+
+	return o, nil
+}
+
+// Find object by hex identifier in packs and loose objects
+// Depth is the current length of the deltas chain
+func (s *nativeStore) lookup(id string, depth int) (nativeObject, error) {
+	raw, err := hex.DecodeString(id)
+	if err != nil || len(raw) != s.hashLen {
+		return nativeObject{}, fmt.Errorf("Invalid object identifier %s", id)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		isClosed := false
+
+		for _, p := range s.loadedPacks() {
+			if offset, ok := p.find(raw); ok {
+				o, err := s.readPacked(p, offset, depth)
+
+				// the pack could be closed while reading, its objects are in the other packs now
+				if err != nil && s.isClosed(p) {
+					isClosed = true
+					continue
+				}
+
+				return o, err
+			}
+		}
+
+		for _, dir := range s.dirs {
+			if o, err := readNativeLooseObject(filepath.Join(dir, id[:2], id[2:])); err == nil {
+				return o, nil
+			} else if !os.IsNotExist(err) {
+				return o, err
+			}
+		}
+
+		// object could be packed by gc after packs were loaded
+		if !s.loadPacks() && !isClosed {
+			break
+		}
+	}
+
+	return nativeObject{}, fmt.Errorf("Object %s not found", id)
+}
+
+// Read packed object at offset resolving deltas
+func (s *nativeStore) readPacked(p *nativePack, offset int64, depth int) (nativeObject, error) {
+	if depth > nativeMaxDeltaDepth {
+		return nativeObject{}, fmt.Errorf("Deltas chain of %s is too long", p.pathname)
+	}
+
+	key := p.pathname + ":" + strconv.FormatInt(offset, 10)
+	if v, ok := s.cache.get(key); ok {
+		return v.(nativeObject), nil
+	}
+
+	kind, data, baseOffset, baseId, err := p.entry(offset)
+	if err != nil {
+		return nativeObject{}, err
+	}
+
+	var o nativeObject
+
+	switch kind {
+	case nativePackOfsDelta, nativePackRefDelta:
+		var base nativeObject
+		if kind == nativePackOfsDelta {
+			base, err = s.readPacked(p, baseOffset, depth+1)
+		} else {
+			base, err = s.lookup(hex.EncodeToString(baseId), depth+1)
+		}
+		if err != nil {
+			return o, err
+		}
+
+		if data, err = applyNativeDelta(base.data, data); err != nil {
+			return o, err
+		}
+
+		o = nativeObject{base.kind, data}
+	default:
+		name, ok := nativePackTypes[kind]
+		if !ok {
+			return o, fmt.Errorf("Unknown packed object type %d in %s", kind, p.pathname)
+		}
+		o = nativeObject{name, data}
+	}
+
+	if len(o.data) <= nativeMaxCachedObjectSize {
+		s.cache.put(key, o, int64(len(o.data)))
+	}
+
+	return o, nil
+}
+
+// Read zlib compressed loose object file with "<type> <size>\0" header
+func readNativeLooseObject(pathname string) (nativeObject, error) {
+	f, err := os.Open(pathname)
+	if err != nil {
+		return nativeObject{}, err
+	}
+	defer f.Close()
+
+	z, err := zlib.NewReader(f)
+	if err != nil {
+		return nativeObject{}, err
+	}
+	defer z.Close()
+
+	data, err := ioutil.ReadAll(z)
+	if err != nil {
+		return nativeObject{}, err
+	}
+
+	pos := bytes.IndexByte(data, 0)
+	if pos < 0 {
+		return nativeObject{}, fmt.Errorf("Broken object %s", pathname)
+	}
+
+	header := strings.Fields(string(data[:pos]))
+	if len(header) != 2 {
+		return nativeObject{}, fmt.Errorf("Broken object %s", pathname)
+	}
+
+	if size, err := strconv.Atoi(header[1]); err != nil || size != len(data)-pos-1 {
+		return nativeObject{}, fmt.Errorf("Broken object %s", pathname)
+	}
+
+	return nativeObject{header[0], data[pos+1:]}, nil
+}
+
+// Returns object size without reading whole object
+func (s *nativeStore) size(id string) (int64, error) {
+	if v, ok := s.cache.get(id); ok {
+		return int64(len(v.(nativeObject).data)), nil
+	}
+
+	raw, err := hex.DecodeString(id)
+	if err != nil || len(raw) != s.hashLen {
+		return 0, fmt.Errorf("Invalid object identifier %s", id)
+	}
+
+	for _, p := range s.loadedPacks() {
+		if offset, ok := p.find(raw); ok {
+			size, err := p.size(offset)
+
+			// the pack could be closed while reading, so the object is read from the other packs
+			if err != nil && s.isClosed(p) {
+				break
+			}
+
+			return size, err
+		}
+	}
+
+	o, err := s.read(id)
+
+	return int64(len(o.data)), err
+}
+
+// Check object exists
+func (s *nativeStore) has(id string) bool {
+	raw, err := hex.DecodeString(id)
+	if err != nil || len(raw) != s.hashLen {
+		return false
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		for _, p := range s.loadedPacks() {
+			if _, ok := p.find(raw); ok {
+				return true
+			}
+		}
+
+		for _, dir := range s.dirs {
+			if _, err := os.Stat(filepath.Join(dir, id[:2], id[2:])); err == nil {
+				return true
+			}
+		}
+
+		if !s.loadPacks() {
+			break
+		}
+	}
+
+	return false
+}
+
+// Find objects identifiers by hex prefix (at least 4 characters like git requires)
+func (s *nativeStore) expand(prefix string) []string {
+	found := make(map[string]bool)
+
+	if len(prefix) < 4 || len(prefix) > s.hashLen*2 || strings.Trim(prefix, "0123456789abcdef") != "" {
+		return nil
+	}
+
+	for _, p := range s.loadedPacks() {
+		p.expand(prefix, found)
+	}
+
+	for _, dir := range s.dirs {
+		names, _ := filepath.Glob(filepath.Join(dir, prefix[:2], prefix[2:]+"*"))
+		for _, name := range names {
+			found[prefix[:2]+filepath.Base(name)] = true
+		}
+	}
+
+	result := make([]string, 0, len(found))
+	for id := range found {
+		result = append(result, id)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
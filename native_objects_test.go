@@ -0,0 +1,176 @@
+package vcsview
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyNativeDelta(t *testing.T) {
+	base := []byte("hello, world")
+
+	cases := []struct{
+		delta []byte
+		want string
+		isValid bool
+	}{
+		// copy 5 bytes from 0, insert "!"
+		{[]byte{12, 6, 0x90, 5, 1, '!'}, "hello!", true},
+		// copy 5 bytes from 7, insert ", " and copy 5 bytes from 0
+		{[]byte{12, 12, 0x91, 7, 5, 2, ',', ' ', 0x90, 5}, "world, hello", true},
+		// base size mismatch
+		{[]byte{11, 1, 1, 'x'}, "", false},
+		// copy out of the base
+		{[]byte{12, 5, 0x91, 10, 5}, "", false},
+		// result size mismatch
+		{[]byte{12, 2, 1, 'x'}, "", false},
+	}
+
+	for key, testCase := range cases {
+		result, err := applyNativeDelta(base, testCase.delta)
+		if (err == nil) != testCase.isValid || string(result) != testCase.want {
+			t.Errorf("[%d] applyNativeDelta(%v) = %q, %v, want: %q", key, testCase.delta, result, err, testCase.want)
+		}
+	}
+}
+
+// Clone testing repository and pack all objects with deltas
+func makePackedRepository(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "vcsview-native")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() got error: %v", err)
+	}
+
+	source, _ := filepath.Abs(gitRepositoryPath)
+
+	for _, args := range [][]string{
+		{"clone", "-q", "--no-local", source, dir},
+		{"-C", dir, "repack", "-q", "-a", "-d", "-f", "--depth=10"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("git %v got error: %v, %s", args, err, out)
+		}
+	}
+
+	return dir, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestNativeStore(t *testing.T) {
+	dir, cleanup := makePackedRepository(t)
+	defer cleanup()
+
+	packed := newNativeStore(filepath.Join(dir, ".git"), 20)
+	if len(packed.loadedPacks()) == 0 {
+		t.Fatalf("newNativeStore(%s) loaded no packs", dir)
+	}
+
+	out, err := exec.Command("git", "-C", dir, "rev-list", "--objects", "--all").Output()
+	if err != nil {
+		t.Fatalf("git rev-list got error: %v", err)
+	}
+
+	for key, line := range bytes.Split(bytes.TrimSpace(out), []byte("\n")) {
+		id := string(line[:40])
+
+		o, err := packed.read(id)
+		if err != nil {
+			t.Fatalf("[%d] nativeStore.read(%s) got error: %v", key, id, err)
+		}
+
+		kind, _ := exec.Command("git", "-C", dir, "cat-file", "-t", id).Output()
+		data, _ := exec.Command("git", "-C", dir, "cat-file", string(bytes.TrimSpace(kind)), id).Output()
+
+		if o.kind != string(bytes.TrimSpace(kind)) || !bytes.Equal(o.data, data) {
+			t.Errorf("[%d] nativeStore.read(%s) = %s %q, want: %s %q", key, id, o.kind, o.data, kind, data)
+		}
+
+		if size, err := packed.size(id); err != nil || size != int64(len(data)) {
+			t.Errorf("[%d] nativeStore.size(%s) = %d, %v, want: %d", key, id, size, err, len(data))
+		}
+
+		if !packed.has(id) {
+			t.Errorf("[%d] nativeStore.has(%s) = false, want: true", key, id)
+		}
+
+		if ids := packed.expand(id[:10]); len(ids) != 1 || ids[0] != id {
+			t.Errorf("[%d] nativeStore.expand(%s) = %v, want: [%s]", key, id[:10], ids, id)
+		}
+	}
+
+	missing := "0000000000000000000000000000000000000000"
+	if o, err := packed.read(missing); err == nil {
+		t.Errorf("nativeStore.read(%s) = %v, want error", missing, o)
+	}
+	if packed.has(missing) {
+		t.Errorf("nativeStore.has(%s) = true, want: false", missing)
+	}
+	if ids := packed.expand("ab"); ids != nil {
+		t.Errorf("nativeStore.expand(ab) = %v, want nothing for short prefix", ids)
+	}
+}
+
+func TestNativeStore_Repack(t *testing.T) {
+	dir, cleanup := makePackedRepository(t)
+	defer cleanup()
+
+	s := newNativeStore(filepath.Join(dir, ".git"), 20)
+	old := s.loadedPacks()
+	if len(old) != 1 {
+		t.Fatalf("newNativeStore(%s) loaded %d packs, want: 1", dir, len(old))
+	}
+
+	// new commit is packed with all objects to the new pack, the old one is removed
+	for _, args := range [][]string{
+		{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "new"},
+		{"-C", dir, "repack", "-q", "-a", "-d"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v got error: %v, %s", args, err, out)
+		}
+	}
+
+	if !s.loadPacks() {
+		t.Errorf("nativeStore.loadPacks() after repack = false, want: true")
+	}
+
+	packs := s.loadedPacks()
+	if len(packs) != 1 || packs[0].pathname == old[0].pathname {
+		t.Errorf("nativeStore.loadedPacks() after repack = %v, want the new pack only", packs)
+	}
+
+	if err := old[0].file.Close(); err == nil {
+		t.Errorf("removed pack %s isn't closed", old[0].pathname)
+	}
+
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("git rev-parse got error: %v", err)
+	}
+
+	if o, err := s.read(string(bytes.TrimSpace(out))); err != nil || o.kind != "commit" {
+		t.Errorf("nativeStore.read(HEAD) after repack = %v, %v, want commit", o, err)
+	}
+}
+
+func TestNativePack_EntrySize(t *testing.T) {
+	f, err := ioutil.TempFile("", "vcsview-pack")
+	if err != nil {
+		t.Fatalf("ioutil.TempFile() got error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// blob entry header with the size of several terabytes followed by a few bytes of data
+	f.Write([]byte{0x80 | nativePackBlob << 4 | 0x0f, 0xff, 0xff, 0xff, 0xff, 0x7f, 0x78, 0x9c, 0x03, 0x00})
+
+	p := &nativePack{pathname: f.Name(), hashLen: 20, file: f, length: 10}
+	if _, data, _, _, err := p.entry(0); err == nil {
+		t.Errorf("nativePack.entry() with broken size = %d bytes, want error", len(data))
+	}
+}
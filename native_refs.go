@@ -0,0 +1,430 @@
+package vcsview
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Maximum depth of symbolic refs like HEAD -> refs/heads/master
+	nativeMaxSymrefDepth = 5
+
+	// Maximum depth of tags pointing to other tags
+	nativeMaxPeelDepth = 10
+)
+
+// Git repository opened by native reader: refs and objects database
+type nativeRepository struct {
+	// Repository config directory with HEAD
+	gitDir string
+
+	// Directory with refs and objects (differs from gitDir for worktrees)
+	commonDir string
+
+	// Object identifier length in bytes
+	hashLen int
+
+	// Objects database
+	store *nativeStore
+
+	mu sync.Mutex
+
+	// Shallow clone boundary commits and modification time of the shallow file they were read from
+	shallow map[string]bool
+	shallowTime time.Time
+
+	// Generation numbers of the commits: one for root commits, otherwise the maximum of the parents plus one
+	generations map[string]int
+}
+
+// Open git repository of the project
+// Config directory could be a .git directory or a .git file with gitdir: line (worktrees and submodules)
+func openNativeRepository(projectPath string) (*nativeRepository, error) {
+	gitDir := filepath.Join(projectPath, ".git")
+
+	stat, err := os.Stat(gitDir)
+	if err != nil {
+		return nil, &VcsError{ErrRepositoryNotFound, fmt.Sprintf("Git repository not found here: %s", projectPath), "", -1, err}
+	}
+
+	if !stat.IsDir() {
+		data, err := ioutil.ReadFile(gitDir)
+		if err != nil {
+			return nil, err
+		}
+
+		line := strings.TrimSpace(string(data))
+		if !strings.HasPrefix(line, "gitdir:") {
+			return nil, newVcsError(ErrRepositoryNotFound, "Git repository not found here: %s", projectPath)
+		}
+
+		gitDir = strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(projectPath, gitDir)
+		}
+	}
+
+	commonDir := gitDir
+	if data, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(gitDir, "HEAD")); err != nil {
+		return nil, newVcsError(ErrRepositoryNotFound, "Git repository not found here: %s", projectPath)
+	}
+
+	hashLen := 20
+	if nativeObjectFormat(filepath.Join(commonDir, "config")) == "sha256" {
+		hashLen = 32
+	}
+
+	return &nativeRepository{gitDir: gitDir, commonDir: commonDir, hashLen: hashLen, store: newNativeStore(commonDir, hashLen)}, nil
+}
+
+// Read extensions.objectFormat of the repository config
+// Returns empty string for the default sha1 format
+func nativeObjectFormat(configPathname string) string {
+	data, err := ioutil.ReadFile(configPathname)
+	if err != nil {
+		return ""
+	}
+
+	section := ""
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.Trim(line, "[] \t"))
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if section == "extensions" && len(parts) == 2 && strings.EqualFold(strings.TrimSpace(parts[0]), "objectformat") {
+			return strings.ToLower(strings.TrimSpace(parts[1]))
+		}
+	}
+
+	return ""
+}
+
+// Check commit is at the shallow clone boundary, so its parents are missing
+// The shallow file is re-read when it's changed by fetch
+func (r *nativeRepository) isShallow(id string) bool {
+	pathname := filepath.Join(r.commonDir, "shallow")
+
+	stat, err := os.Stat(pathname)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		// unshallowed repository has the missing parents, so generation numbers change
+		if r.shallow != nil {
+			r.shallow, r.generations = nil, nil
+		}
+		return false
+	}
+
+	if r.shallow == nil || !stat.ModTime().Equal(r.shallowTime) {
+		r.shallow = make(map[string]bool)
+		r.shallowTime = stat.ModTime()
+		r.generations = nil
+
+		if data, err := ioutil.ReadFile(pathname); err == nil {
+			for _, line := range strings.Fields(string(data)) {
+				r.shallow[line] = true
+			}
+		}
+	}
+
+	return r.shallow[id]
+}
+
+// Check string is a full hex object identifier
+func (r *nativeRepository) isObjectId(s string) bool {
+	if len(s) != r.hashLen*2 {
+		return false
+	}
+
+	return strings.Trim(s, "0123456789abcdef") == ""
+}
+
+// Read packed-refs file
+// Returns refs identifiers and peeled identifiers of annotated tags
+func (r *nativeRepository) packedRefs() (map[string]string, map[string]string) {
+	refs := make(map[string]string)
+	peeled := make(map[string]string)
+
+	data, err := ioutil.ReadFile(filepath.Join(r.commonDir, "packed-refs"))
+	if err != nil {
+		return refs, peeled
+	}
+
+	last := ""
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := s.Text()
+
+		switch {
+		case strings.HasPrefix(line, "#") || line == "":
+		case strings.HasPrefix(line, "^"):
+			// peeled identifier of the previous tag
+			if last != "" {
+				peeled[last] = line[1:]
+			}
+		default:
+			parts := strings.SplitN(line, " ", 2)
+			if len(parts) == 2 && r.isObjectId(parts[0]) {
+				refs[parts[1]] = parts[0]
+				last = parts[1]
+			}
+		}
+	}
+
+	return refs, peeled
+}
+
+// Read all refs with direct identifiers (symbolic refs are skipped)
+// Loose refs override packed ones
+func (r *nativeRepository) refs() map[string]string {
+	refs, _ := r.packedRefs()
+
+	root := filepath.Join(r.commonDir, "refs")
+
+	filepath.Walk(root, func(pathname string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(pathname)
+		if err != nil {
+			return nil
+		}
+
+		rel, err := filepath.Rel(r.commonDir, pathname)
+		if err != nil {
+			return nil
+		}
+
+		if id := strings.TrimSpace(string(data)); r.isObjectId(id) {
+			refs[filepath.ToSlash(rel)] = id
+		}
+
+		return nil
+	})
+
+	return refs
+}
+
+// Read ref by full name following symbolic refs
+// Returns empty identifier if ref doesn't exist
+func (r *nativeRepository) readRef(name string) (string, error) {
+	for depth := 0; depth < nativeMaxSymrefDepth; depth++ {
+		dir := r.commonDir
+		if !strings.HasPrefix(name, "refs/") {
+			// HEAD and other pseudo refs are per-worktree
+			dir = r.gitDir
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			// loose ref doesn't exist or it's a refs directory
+			refs, _ := r.packedRefs()
+			return refs[name], nil
+		}
+
+		value := strings.TrimSpace(string(data))
+		if !strings.HasPrefix(value, "ref:") {
+			if !r.isObjectId(value) {
+				return "", fmt.Errorf("Broken ref %s", name)
+			}
+			return value, nil
+		}
+
+		name = strings.TrimSpace(strings.TrimPrefix(value, "ref:"))
+	}
+
+	return "", fmt.Errorf("Symbolic ref %s is too deep", name)
+}
+
+// Returns ref name HEAD points to or empty string for detached HEAD
+func (r *nativeRepository) headRef() string {
+	data, err := ioutil.ReadFile(filepath.Join(r.gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+
+	value := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(value, "ref:") {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(value, "ref:"))
+}
+
+// Returns identifier of the tag or commit object which a tag object points to
+func (r *nativeRepository) tagTarget(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "object ") {
+			return strings.TrimPrefix(line, "object ")
+		}
+	}
+
+	return ""
+}
+
+// Peel tags until the commit
+// Returns error if object isn't a commit or a tag of the commit
+func (r *nativeRepository) peelToCommit(id string) (string, error) {
+	for depth := 0; depth < nativeMaxPeelDepth; depth++ {
+		o, err := r.store.read(id)
+		if err != nil {
+			return "", err
+		}
+
+		switch o.kind {
+		case "commit":
+			return id, nil
+		case "tag":
+			id = r.tagTarget(o.data)
+		default:
+			return "", newVcsError(ErrRevisionNotFound, "Object %s is a %s, not a commit", id, o.kind)
+		}
+	}
+
+	return "", fmt.Errorf("Tag %s is too deep", id)
+}
+
+// Resolve revision name without suffixes: HEAD, full or short identifier, or ref name
+// Refs are searched like git does: <name>, refs/<name>, refs/tags/<name>, refs/heads/<name>, refs/remotes/<name> and refs/remotes/<name>/HEAD
+func (r *nativeRepository) resolveName(name string) (string, error) {
+	if name == "@" {
+		name = "HEAD"
+	}
+
+	if r.isObjectId(name) {
+		if !r.store.has(name) {
+			return "", newVcsError(ErrRevisionNotFound, "Object %s not found", name)
+		}
+		return name, nil
+	}
+
+	if name != "" && !strings.Contains(name, "..") {
+		candidates := []string{
+			"refs/" + name,
+			"refs/tags/" + name,
+			"refs/heads/" + name,
+			"refs/remotes/" + name,
+			"refs/remotes/" + name + "/HEAD",
+		}
+
+		if strings.ToUpper(name) == name || strings.HasPrefix(name, "refs/") {
+			// HEAD, FETCH_HEAD and full ref names
+			candidates = append([]string{name}, candidates...)
+		}
+
+		for _, ref := range candidates {
+			id, err := r.readRef(ref)
+			if err != nil {
+				return "", err
+			}
+			if id != "" {
+				return id, nil
+			}
+		}
+	}
+
+	switch ids := r.store.expand(strings.ToLower(name)); len(ids) {
+	case 0:
+	case 1:
+		return ids[0], nil
+	default:
+		return "", newVcsError(ErrAmbiguousRevision, "Short object identifier %s is ambiguous", name)
+	}
+
+	return "", newVcsError(ErrRevisionNotFound, "Unknown revision %s", name)
+}
+
+// Resolve revision to the commit identifier
+// Revision is a name with optional ~N, ^N and ^ suffixes like HEAD~2 or master^2
+// Tags are peeled to commits
+func (r *nativeRepository) resolveCommit(revision string) (string, error) {
+	pos := strings.IndexAny(revision, "~^")
+	if pos < 0 {
+		pos = len(revision)
+	}
+
+	id, err := r.resolveName(revision[:pos])
+	if err != nil {
+		return "", err
+	}
+
+	if id, err = r.peelToCommit(id); err != nil {
+		return "", err
+	}
+
+	suffix := revision[pos:]
+
+	for suffix != "" {
+		op := suffix[0]
+		suffix = suffix[1:]
+
+		if op != '~' && op != '^' {
+			return "", newVcsError(ErrRevisionNotFound, "Unknown revision %s", revision)
+		}
+
+		end := 0
+		for end < len(suffix) && suffix[end] >= '0' && suffix[end] <= '9' {
+			end++
+		}
+
+		n := 1
+		if end > 0 {
+			if n, err = strconv.Atoi(suffix[:end]); err != nil {
+				return "", newVcsError(ErrRevisionNotFound, "Unknown revision %s", revision)
+			}
+		}
+		suffix = suffix[end:]
+
+		// ~N goes N first parents back, ^N selects N-th parent
+		steps, parent := n, 1
+		if op == '^' {
+			steps, parent = 1, n
+		}
+
+		for i := 0; i < steps; i++ {
+			if parent == 0 {
+				break
+			}
+
+			c, err := r.readCommit(id)
+			if err != nil {
+				return "", err
+			}
+
+			if parent > len(c.parents) {
+				return "", newVcsError(ErrRevisionNotFound, "Unknown revision %s", revision)
+			}
+
+			id = c.parents[parent-1]
+		}
+	}
+
+	return id, nil
+}
@@ -0,0 +1,112 @@
+package vcsview
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNativeObjectFormat(t *testing.T) {
+	cases := []struct{
+		config string
+		want string
+	}{
+		{"[core]\n\trepositoryformatversion = 0\n", ""},
+		{"[core]\n\trepositoryformatversion = 1\n[extensions]\n\tobjectFormat = sha256\n", "sha256"},
+		{"[other]\n\tobjectformat = sha256\n", ""},
+	}
+
+	dir, err := ioutil.TempDir("", "vcsview-native")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() got error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pathname := filepath.Join(dir, "config")
+
+	for key, testCase := range cases {
+		ioutil.WriteFile(pathname, []byte(testCase.config), 0644)
+
+		if format := nativeObjectFormat(pathname); format != testCase.want {
+			t.Errorf("[%d] nativeObjectFormat(%q) = %q, want: %q", key, testCase.config, format, testCase.want)
+		}
+	}
+}
+
+func TestOpenNativeRepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcsview-native")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() got error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	gitDir, _ := filepath.Abs(filepath.Join(gitRepositoryPath, ".git"))
+	ioutil.WriteFile(filepath.Join(dir, ".git"), []byte("gitdir: "+gitDir+"\n"), 0644)
+
+	r, err := openNativeRepository(dir)
+	if err != nil {
+		t.Fatalf("openNativeRepository(%s) with .git file got error: %v", dir, err)
+	}
+
+	if r.gitDir != gitDir || r.commonDir != gitDir || r.hashLen != 20 {
+		t.Errorf("openNativeRepository(%s) = %v, want repository at %s", dir, r, gitDir)
+	}
+
+	if _, err := openNativeRepository(noRepositoryPath); err == nil {
+		t.Errorf("openNativeRepository(%s) got no errors, want error", noRepositoryPath)
+	}
+}
+
+func TestNativeRepository_ResolveCommit(t *testing.T) {
+	dir, cleanup := makePackedRepository(t)
+	defer cleanup()
+
+	// packed refs only
+	if out, err := exec.Command("git", "-C", dir, "pack-refs", "--all").CombinedOutput(); err != nil {
+		t.Fatalf("git pack-refs got error: %v, %s", err, out)
+	}
+
+	r, err := openNativeRepository(dir)
+	if err != nil {
+		t.Fatalf("openNativeRepository(%s) got error: %v", dir, err)
+	}
+
+	head, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("git rev-parse got error: %v", err)
+	}
+
+	cases := []string{"HEAD", "@", "master", "origin/branch1", "refs/remotes/origin/branch2", "v1.0", "v1.1", "HEAD~1", "HEAD^1", "HEAD~1^", "HEAD^0", strings.TrimSpace(string(head))[:7]}
+
+	for key, revision := range cases {
+		out, err := exec.Command("git", "-C", dir, "rev-parse", "--verify", "-q", revision+"^{commit}").Output()
+		if err != nil {
+			t.Fatalf("[%d] git rev-parse %s got error: %v", key, revision, err)
+		}
+		want := strings.TrimSpace(string(out))
+
+		if id, err := r.resolveCommit(revision); err != nil || id != want {
+			t.Errorf("[%d] nativeRepository.resolveCommit(%s) = %v, %v, want: %v", key, revision, id, err, want)
+		}
+	}
+
+	for key, revision := range []string{"unknown", "HEAD~1000", "HEAD^3", "v1.0~x", ""} {
+		if id, err := r.resolveCommit(revision); err == nil {
+			t.Errorf("[%d] nativeRepository.resolveCommit(%s) = %v, want error", key, revision, id)
+		}
+	}
+
+	refs := r.refs()
+	for _, name := range []string{"refs/heads/master", "refs/remotes/origin/branch1", "refs/tags/v1.0", "refs/tags/v1.1"} {
+		if !r.isObjectId(refs[name]) {
+			t.Errorf("nativeRepository.refs() got %s = %q, want object identifier", name, refs[name])
+		}
+	}
+
+	if ref := r.headRef(); ref != "refs/heads/master" {
+		t.Errorf("nativeRepository.headRef() = %s, want: refs/heads/master", ref)
+	}
+}
@@ -0,0 +1,88 @@
+package vcsview
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// Maximum length of the subject part of patch file name
+	patchFilenameMaxLength = 64
+)
+
+// Represents a single commit patch in the format-patch (mbox) form
+type Patch struct {
+	// Commit identifier the patch was created from
+	commitId string
+
+	// Patch sequence number in the exported series (starts from 1)
+	number int
+
+	// Patch author
+	author Contributor
+
+	// Commit date and time
+	date time.Time
+
+	// Patch subject without [PATCH] prefix
+	subject string
+
+	// Full patch text including mbox headers
+	content string
+}
+
+// Get commit identifier of the patch
+func (p Patch) CommitId() string {
+	return p.commitId
+}
+
+// Get patch sequence number in the series
+func (p Patch) Number() int {
+	return p.number
+}
+
+// Get patch author
+func (p Patch) Author() Contributor {
+	return p.author
+}
+
+// Get patch commit date time
+func (p Patch) Date() time.Time {
+	return p.date
+}
+
+// Get patch subject
+func (p Patch) Subject() string {
+	return p.subject
+}
+
+// Get full patch text
+func (p Patch) Content() string {
+	return p.content
+}
+
+// Returns patch file name the same way as format-patch does, for example:
+// 0001-fix-the-typo-in-readme.patch
+func (p Patch) Filename() string {
+	slug := make([]rune, 0, len(p.subject))
+	dash := false
+
+	for _, r := range p.subject {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '.' {
+			slug = append(slug, r)
+			dash = false
+		} else if !dash && len(slug) > 0 {
+			slug = append(slug, '-')
+			dash = true
+		}
+
+		if len(slug) >= patchFilenameMaxLength {
+			break
+		}
+	}
+
+	name := strings.TrimRight(string(slug), "-.")
+
+	return fmt.Sprintf("%04d-%s.patch", p.number, name)
+}
@@ -0,0 +1,86 @@
+package vcsview
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPatch_CommitId(t *testing.T) {
+	expectedId := "747ad5712f0ddbb482ebb6e07eb779e70b94687f"
+
+	p := Patch{}
+	p.commitId = expectedId
+
+	if id := p.CommitId(); id != expectedId {
+		t.Errorf("Patch.CommitId() = %v, want: %v", id, expectedId)
+	}
+}
+
+func TestPatch_Number(t *testing.T) {
+	p := Patch{}
+	p.number = 3
+
+	if number := p.Number(); number != 3 {
+		t.Errorf("Patch.Number() = %v, want: %v", number, 3)
+	}
+}
+
+func TestPatch_Author(t *testing.T) {
+	p := Patch{}
+	p.author = Contributor{"name", "test@email.ltd"}
+
+	expectedAuthor := "name <test@email.ltd>"
+
+	if author := p.Author(); author.String() != expectedAuthor {
+		t.Errorf("Patch.Author() = %v, want: %v", author, expectedAuthor)
+	}
+}
+
+func TestPatch_Date(t *testing.T) {
+	expectedDate := time.Date(2019, time.Month(2), 24, 10, 47, 0, 0, time.UTC)
+
+	p := Patch{}
+	p.date = expectedDate
+
+	if date := p.Date(); !date.Equal(expectedDate) {
+		t.Errorf("Patch.Date() = %v, want: %v", date, expectedDate)
+	}
+}
+
+func TestPatch_SubjectAndContent(t *testing.T) {
+	p := Patch{}
+	p.subject = "testing subject"
+	p.content = "testing content"
+
+	if subject := p.Subject(); subject != "testing subject" {
+		t.Errorf("Patch.Subject() = %v, want: %v", subject, "testing subject")
+	}
+
+	if content := p.Content(); content != "testing content" {
+		t.Errorf("Patch.Content() = %v, want: %v", content, "testing content")
+	}
+}
+
+func TestPatch_Filename(t *testing.T) {
+	cases := []struct{
+		number int
+		subject string
+		filename string
+	}{
+		{1, "random commit for random file", "0001-random-commit-for-random-file.patch"},
+		{12, "Fix: the typo in README.md!", "0012-Fix-the-typo-in-README.md.patch"},
+		{3, "  spaces   around  ", "0003-spaces-around.patch"},
+		{1, "", "0001-.patch"},
+		{2, "a very long subject of the commit which exceeds maximum length of the patch file name", "0002-a-very-long-subject-of-the-commit-which-exceeds-maximum-length-o.patch"},
+	}
+
+	for key, testCase := range cases {
+		p := Patch{}
+		p.number = testCase.number
+		p.subject = testCase.subject
+
+		if filename := p.Filename(); filename != testCase.filename {
+			t.Errorf("[%d] Patch.Filename() = %v, want: %v", key, filename, testCase.filename)
+		}
+	}
+}
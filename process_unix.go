@@ -0,0 +1,20 @@
+// +build !windows
+
+package vcsview
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Start the command in its own process group, so its children could be killed together
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// Kill the command process group
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,16 @@
+package vcsview
+
+import (
+	"os/exec"
+)
+
+// Windows has no process groups to kill, children are stopped by closed pipes
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// Kill the command process
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,98 @@
+package vcsview
+
+import (
+	"path"
+	"strings"
+)
+
+// README file extensions by priority, empty extension means file without extension
+var readmeExtensions = []string{".md", ".markdown", ".mdown", ".mkdn", ".mkd", "", ".txt", ".rst", ".adoc", ".org"}
+
+// Extensions of README files which are rendered as Markdown
+var readmeMarkdownExtensions = map[string]bool{
+	".md": true,
+	".markdown": true,
+	".mdown": true,
+	".mkdn": true,
+	".mkd": true,
+}
+
+// Kind of the project link
+type LinkKind string
+
+const(
+	LinkTree LinkKind = "tree"
+	LinkBlob LinkKind = "blob"
+	LinkRaw LinkKind = "raw"
+)
+
+// Function which builds URL of the project path at revision
+// Kind is LinkTree for directories, LinkBlob for files and LinkRaw for images sources
+// Pathname is a relative path separated by slashes, empty pathname means the project root
+type LinkBuilderFunc func(kind LinkKind, revision string, pathname string) string
+
+// Returns README priority of the file name, lower is better
+// Returns -1 if file isn't README
+func readmePriority(name string) int {
+	lower := strings.ToLower(name)
+
+	if !strings.HasPrefix(lower, "readme") {
+		return -1
+	}
+
+	ext := lower[len("readme"):]
+
+	for priority, e := range readmeExtensions {
+		if ext == e {
+			return priority
+		}
+	}
+
+	return -1
+}
+
+// Find README file in directory files list
+// Returns false if README not found
+func findReadme(files []File) (File, bool) {
+	var (
+		readme File
+		found = false
+		best = len(readmeExtensions)
+	)
+
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		if priority := readmePriority(f.Name()); priority >= 0 && priority < best {
+			readme, found, best = f, true, priority
+		}
+	}
+
+	return readme, found
+}
+
+// Returns true if README file should be rendered as Markdown
+func isMarkdownReadme(f File) bool {
+	return readmeMarkdownExtensions[strings.ToLower(path.Ext(f.Name()))]
+}
+
+// Split link destination to path and suffix (query string and fragment)
+func splitLinkDestination(dest string) (string, string) {
+	if pos := strings.IndexAny(dest, "?#"); pos >= 0 {
+		return dest[:pos], dest[pos:]
+	}
+
+	return dest, ""
+}
+
+// Resolve link destination of document located at dir to the project pathname
+// Destination starting with slash is relative to the project root
+func resolveLinkPathname(dir string, dest string) string {
+	if !strings.HasPrefix(dest, "/") {
+		dest = path.Join(dir, dest)
+	}
+
+	return strings.Trim(path.Clean("/"+dest), "/")
+}
@@ -0,0 +1,76 @@
+package vcsview
+
+import "testing"
+
+func TestFindReadme(t *testing.T) {
+	cases := []struct{
+		names []string
+		readme string
+		found bool
+	}{
+		{[]string{"main.go", "empty.txt"}, "", false},
+		{[]string{"README", "main.go"}, "README", true},
+		{[]string{"readme.txt", "README.md"}, "README.md", true},
+		{[]string{"Readme.rst", "readme"}, "readme", true},
+		{[]string{"README.md.orig", "README.rst"}, "README.rst", true},
+	}
+
+	for key, testCase := range cases {
+		files := make([]File, 0)
+		for _, name := range testCase.names {
+			files = append(files, NewFileFromTree(name, false, 0, 0644))
+		}
+
+		readme, found := findReadme(files)
+
+		if found != testCase.found {
+			t.Errorf("[%d] findReadme(%v) found = %v, want: %v", key, testCase.names, found, testCase.found)
+			continue
+		}
+
+		if found && readme.Name() != testCase.readme {
+			t.Errorf("[%d] findReadme(%v) = %v, want: %v", key, testCase.names, readme.Name(), testCase.readme)
+		}
+	}
+}
+
+func TestResolveLinkPathname(t *testing.T) {
+	cases := []struct{
+		dir string
+		dest string
+		pathname string
+	}{
+		{"", "docs/index.md", "docs/index.md"},
+		{"docs", "index.md", "docs/index.md"},
+		{"docs", "../README.md", "README.md"},
+		{"docs", "/src/main.go", "src/main.go"},
+		{"", "../../../etc/passwd", "etc/passwd"},
+		{"docs", "./", "docs"},
+	}
+
+	for key, testCase := range cases {
+		if pathname := resolveLinkPathname(testCase.dir, testCase.dest); pathname != testCase.pathname {
+			t.Errorf("[%d] resolveLinkPathname(%s, %s) = %v, want: %v", key, testCase.dir, testCase.dest, pathname, testCase.pathname)
+		}
+	}
+}
+
+func TestSplitLinkDestination(t *testing.T) {
+	cases := []struct{
+		dest string
+		path string
+		suffix string
+	}{
+		{"docs/index.md", "docs/index.md", ""},
+		{"docs/index.md#usage", "docs/index.md", "#usage"},
+		{"image.png?raw=true", "image.png", "?raw=true"},
+	}
+
+	for key, testCase := range cases {
+		path, suffix := splitLinkDestination(testCase.dest)
+
+		if path != testCase.path || suffix != testCase.suffix {
+			t.Errorf("[%d] splitLinkDestination(%s) = %v, %v, want: %v, %v", key, testCase.dest, path, suffix, testCase.path, testCase.suffix)
+		}
+	}
+}
@@ -1,19 +1,30 @@
 package vcsview
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
+	"html"
+	"io"
 	"io/ioutil"
+	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
 // Structure which provides access control to some project repository
+// Context of the methods cancels them (for example, closed HTTP request): running commands are killed and the context error is returned
 type Repository struct {
 	// Command line interface for specified version control system
 	cmd Vcs
 
 	// Project absolute path (not a path to config directory)
 	projectPath string
+
+	// Persistent cache of the expensive queries (nil if disabled)
+	diskCache *DiskCache
 }
 
 // Repository absolute path (path to config directory, for example, /path/to/project/.git)
@@ -71,22 +82,175 @@ func (r Repository) FilesList(subDir string) ([]File, error) {
 
 	result = make([]File, 0)
 
+	attributes := r.projectAttributes(relativePath)
+
 	for _, i := range files {
 		if i.Name() == r.cmd.RepositoryPathname() && subDir == "" {
 			// list doesn't need to provide repository path
 			continue
 		}
 
-		result = append(result, NewFileFromProjectList(i, relativePath))
+		f := NewFileFromProjectList(i, relativePath)
+
+		if !f.IsDir() {
+			r.detectProjectFileContent(&f, path+pathSeparator+i.Name())
+			f.applyAttributes(attributes)
+		}
+
+		result = append(result, f)
 	}
 
 	return result, nil
 }
 
+// Detect content kind of the project file by its first bytes
+func (r Repository) detectProjectFileContent(f *File, absPath string) {
+	fp, err := os.Open(absPath)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+
+	data := make([]byte, binarySniffLength)
+	n, err := io.ReadFull(fp, data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return
+	}
+
+	f.detectContent(data[:n])
+}
+
+// Returns relative directory and all of its parents starting from the root directory
+func attributesDirs(dir string) []string {
+	dirs := []string{""}
+
+	dir = strings.Trim(path.Clean("/"+filepath.ToSlash(dir)), "/")
+	if dir == "" {
+		return dirs
+	}
+
+	parts := strings.Split(dir, "/")
+	for i := range parts {
+		dirs = append(dirs, strings.Join(parts[:i+1], "/"))
+	}
+
+	return dirs
+}
+
+// Read attributes files of the project directory and its parents from the disk
+func (r Repository) projectAttributes(dir string) Attributes {
+	var a Attributes
+
+	for _, d := range attributesDirs(dir) {
+		content, err := ioutil.ReadFile(filepath.Join(r.projectPath, filepath.FromSlash(d), attributesFilename))
+		if err == nil {
+			a.Add(d, string(content))
+		}
+	}
+
+	return a
+}
+
+// Read blob at revision without attributes overrides
+// Returns error if file not found at revision
+func (r Repository) readBlob(ctx context.Context, revision string, pathname string) (Blob, error) {
+	result := make(chan Blob, 1)
+
+	if err := r.cmd.ReadBlob(ctx, r.projectPath, revision, pathname, result).Run(); err != nil {
+		return Blob{}, err
+	}
+
+	select {
+	case blob := <-result:
+		return blob, nil
+	default:
+		return Blob{}, newVcsError(ErrPathNotFound, "File %s not found at %s", pathname, revision)
+	}
+}
+
+// Vcs which reads the first bytes of the file without loading the whole content
+type blobHeadReader interface {
+	readBlobHead(ctx context.Context, projectPath string, revision string, pathname string, size int64, result chan Blob) *Executor
+}
+
+// Read the first size bytes of the file at revision
+// Returns ErrPathNotFound error if file not found at revision
+func (r Repository) readBlobHead(ctx context.Context, revision string, pathname string, size int64) ([]byte, error) {
+	var (
+		blob Blob
+		err error
+	)
+
+	if reader, ok := r.cmd.(blobHeadReader); ok {
+		result := make(chan Blob, 1)
+
+		if err = reader.readBlobHead(ctx, r.projectPath, revision, pathname, size, result).Run(); err != nil {
+			return nil, err
+		}
+
+		select {
+		case blob = <-result:
+		default:
+			return nil, newVcsError(ErrPathNotFound, "File %s not found at %s", pathname, revision)
+		}
+	} else if blob, err = r.readBlob(ctx, revision, pathname); err != nil {
+		return nil, err
+	}
+
+	content := blob.Content()
+	if int64(len(content)) > size {
+		content = content[:size]
+	}
+
+	return content, nil
+}
+
+// Read attributes files of the directory and its parents at revision
+// Directory is a relative path, empty directory means the project root
+func (r Repository) Attributes(ctx context.Context, revision string, dir string) (Attributes, error) {
+	var a Attributes
+
+	for _, d := range attributesDirs(dir) {
+		result := make(chan Blob, 1)
+
+		if err := r.cmd.ReadBlob(ctx, r.projectPath, revision, path.Join(d, attributesFilename), result).Run(); err != nil {
+			return a, err
+		}
+
+		select {
+		case blob := <-result:
+			a.Add(d, string(blob.Content()))
+		default:
+			// directory has no attributes file
+		}
+	}
+
+	return a, nil
+}
+
+// Read file content at revision
+// Content kind is detected by content and overridden by text, -text and binary attributes
+// Returns error if file not found at revision
+func (r Repository) ReadBlob(ctx context.Context, revision string, pathname string) (Blob, error) {
+	blob, err := r.readBlob(ctx, revision, pathname)
+	if err != nil {
+		return blob, err
+	}
+
+	a, err := r.Attributes(ctx, revision, blob.Path())
+	if err != nil {
+		return blob, err
+	}
+
+	blob.applyAttributes(a)
+
+	return blob, nil
+}
+
 // Check the repository
 // Repository exists and well works if the vcs doesnt throw an error while fetch repository status
-func (r Repository) Check() (err error) {
-	_, err = r.cmd.StatusRepository(r.projectPath)
+func (r Repository) Check(ctx context.Context) (err error) {
+	_, err = r.cmd.StatusRepository(ctx, r.projectPath)
 	return
 }
 
@@ -109,6 +273,673 @@ func NewRepository(projectPath string, vcs Vcs) (Repository, error) {
 		return r, err
 	}
 
-	r = Repository{vcs, projectPath}
+	r = Repository{vcs, projectPath, nil}
 	return r, nil
-}
\ No newline at end of file
+}
+
+// Returns repository copy which uses persistent cache for commits, history pages and languages statistics
+// Nil cache disables caching
+// Cache is used on the best effort basis, so write errors are ignored
+func (r Repository) WithDiskCache(c *DiskCache) Repository {
+	r.diskCache = c
+	return r
+}
+
+// Read files tree at revision
+// Path is a relative directory path, empty path means the project root
+// If recursive is true, returns files of all subdirectories (without directories itself)
+func (r Repository) ReadTree(ctx context.Context, revision string, path string, recursive bool) ([]File, error) {
+	files := make([]File, 0)
+
+	c := r.TreeCursor(ctx, revision, path, recursive)
+	defer c.Close()
+
+	for c.Next() {
+		files = append(files, c.File())
+	}
+
+	return files, c.Err()
+}
+
+// Create cursor of the files tree at revision
+// Path is a relative directory path, empty path means the project root
+// If recursive is true, cursor gets files of all subdirectories (without directories itself)
+func (r Repository) TreeCursor(ctx context.Context, revision string, path string, recursive bool) *FileCursor {
+	return NewFileCursor(ctx, func(ctx context.Context, result chan File) *Executor {
+		return r.cmd.ReadTree(ctx, r.projectPath, revision, path, recursive, result)
+	})
+}
+
+// Compute language breakdown of the project tree at revision
+// Languages are detected by file names, extensions, shebangs and linguist-language attribute
+// Vendored (linguist-vendored attribute or vendor directories) and generated (linguist-generated attribute) files are skipped
+// Returns languages sorted by bytes size from the largest one
+func (r Repository) Languages(ctx context.Context, revision string) ([]LanguageStat, error) {
+	if r.diskCache == nil {
+		return r.languages(ctx, revision)
+	}
+
+	// statistics is cached by commit identifier, so resolve branch names and short identifiers
+	commit, err := r.Commit(ctx, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []LanguageStat
+	if r.diskCache.get(&result, "languages", r.projectPath, commit.Id()) {
+		return result, nil
+	}
+
+	result, err = r.languages(ctx, commit.Id())
+	if err == nil {
+		r.diskCache.put(result, "languages", r.projectPath, commit.Id())
+	}
+
+	return result, err
+}
+
+// Compute language breakdown without cache
+func (r Repository) languages(ctx context.Context, revision string) ([]LanguageStat, error) {
+	files, err := r.ReadTree(ctx, revision, "", true)
+	if err != nil {
+		return nil, err
+	}
+
+	var a Attributes
+
+	for _, f := range files {
+		if f.Name() == attributesFilename {
+			blob, err := r.readBlob(ctx, revision, f.Pathname())
+			if err != nil {
+				return nil, err
+			}
+			a.Add(f.Path(), string(blob.Content()))
+		}
+	}
+
+	stats := make(map[string]*LanguageStat)
+
+	for _, f := range files {
+		pathname := f.Pathname()
+
+		if f.IsDir() || !f.Mode().IsRegular() || isLanguageVendored(pathname, a) || isLanguageGenerated(pathname, a) {
+			continue
+		}
+
+		language := a.Get(pathname, "linguist-language")
+		if language == AttributeSet || language == AttributeUnset {
+			language = ""
+		}
+
+		if language == "" {
+			language = languageByPathname(pathname)
+		}
+
+		if language == "" && filepath.Ext(f.Name()) == "" {
+			head, err := r.readBlobHead(ctx, revision, pathname, shebangMaxLength)
+			if err != nil {
+				return nil, err
+			}
+			language = languageByShebang(head)
+		}
+
+		if language == "" {
+			continue
+		}
+
+		stat, ok := stats[language]
+		if !ok {
+			stat = &LanguageStat{name: language}
+			stats[language] = stat
+		}
+
+		stat.bytes += f.Size()
+		stat.files++
+	}
+
+	result := make([]LanguageStat, 0, len(stats))
+	for _, stat := range stats {
+		result = append(result, *stat)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].bytes != result[j].bytes {
+			return result[i].bytes > result[j].bytes
+		}
+		return result[i].name < result[j].name
+	})
+
+	return result, nil
+}
+
+// Find README file in directory at revision
+// README is searched case-insensitively with any supported extension, Markdown ones are preferred
+// Directory is a relative path, empty directory means the project root
+// Returns error if README not found
+func (r Repository) FindReadme(ctx context.Context, revision string, dir string) (File, error) {
+	files, err := r.ReadTree(ctx, revision, dir, false)
+	if err != nil {
+		return File{}, err
+	}
+
+	readme, ok := findReadme(files)
+	if !ok {
+		return File{}, newVcsError(ErrPathNotFound, "README not found in %s", dir)
+	}
+
+	return readme, nil
+}
+
+// Find README file in directory at revision and render it to HTML
+// Markdown README is rendered with relative links and images rewritten by links builder,
+// other README files are rendered as preformatted text
+// Returns README file and rendered HTML
+func (r Repository) RenderReadme(ctx context.Context, revision string, dir string, links LinkBuilderFunc) (File, string, error) {
+	readme, err := r.FindReadme(ctx, revision, dir)
+	if err != nil {
+		return readme, "", err
+	}
+
+	blob, err := r.readBlob(ctx, revision, readme.Pathname())
+	if err != nil {
+		return readme, "", err
+	}
+
+	if !isMarkdownReadme(readme) {
+		return readme, "<pre>" + html.EscapeString(string(blob.Content())) + "</pre>\n", nil
+	}
+
+	// directories listings of link targets parents
+	trees := make(map[string][]File)
+
+	isDir := func(pathname string) bool {
+		parent := path.Dir(pathname)
+		if parent == "." {
+			parent = ""
+		}
+
+		files, ok := trees[parent]
+		if !ok {
+			files, _ = r.ReadTree(ctx, revision, parent, false)
+			trees[parent] = files
+		}
+
+		for _, f := range files {
+			if f.Pathname() == pathname {
+				return f.IsDir()
+			}
+		}
+
+		return false
+	}
+
+	link := MarkdownLinkFunc(func(dest string, isImage bool) string {
+		if links == nil {
+			return dest
+		}
+
+		destPath, suffix := splitLinkDestination(dest)
+		if destPath == "" {
+			return dest
+		}
+
+		pathname := resolveLinkPathname(readme.Path(), destPath)
+
+		switch {
+		case isImage:
+			return links(LinkRaw, revision, pathname) + suffix
+		case pathname == "" || strings.HasSuffix(destPath, "/") || isDir(pathname):
+			return links(LinkTree, revision, pathname) + suffix
+		}
+
+		return links(LinkBlob, revision, pathname) + suffix
+	})
+
+	return readme, RenderMarkdown(blob.Content(), link), nil
+}
+
+// Read repository branches
+func (r Repository) Branches(ctx context.Context) ([]Branch, error) {
+	branches := make([]Branch, 0)
+
+	c := r.BranchesCursor(ctx)
+	defer c.Close()
+
+	for c.Next() {
+		branches = append(branches, c.Branch())
+	}
+
+	return branches, c.Err()
+}
+
+// Create cursor of the repository branches
+func (r Repository) BranchesCursor(ctx context.Context) *BranchCursor {
+	return NewBranchCursor(ctx, func(ctx context.Context, result chan Branch) *Executor {
+		return r.cmd.ReadBranches(ctx, r.projectPath, result)
+	})
+}
+
+// Read repository tags
+func (r Repository) Tags(ctx context.Context) ([]Tag, error) {
+	tags := make([]Tag, 0)
+
+	c := r.TagsCursor(ctx)
+	defer c.Close()
+
+	for c.Next() {
+		tags = append(tags, c.Tag())
+	}
+
+	return tags, c.Err()
+}
+
+// Create cursor of the repository tags
+func (r Repository) TagsCursor(ctx context.Context) *TagCursor {
+	return NewTagCursor(ctx, func(ctx context.Context, result chan Tag) *Executor {
+		return r.cmd.ReadTags(ctx, r.projectPath, result)
+	})
+}
+
+// Read commit by identifier
+// Returns error if commit not found
+func (r Repository) Commit(ctx context.Context, commitId string) (Commit, error) {
+	isCached := r.diskCache != nil && fullObjectIdPattern.MatchString(commitId)
+
+	var c Commit
+	if isCached && r.diskCache.get(&c, "commit", r.projectPath, commitId) {
+		return c, nil
+	}
+
+	result := make(chan Commit, 1)
+
+	if err := r.cmd.ReadCommit(ctx, r.projectPath, commitId, result).Run(); err != nil {
+		return Commit{}, err
+	}
+
+	select {
+	case c = <-result:
+		if isCached {
+			r.diskCache.put(c, "commit", r.projectPath, commitId)
+		}
+		return c, nil
+	default:
+		return Commit{}, newVcsError(ErrRevisionNotFound, "Commit %s not found", commitId)
+	}
+}
+
+// Read commits history
+// Path is a relative file or directory path, empty path means whole project
+// Branch should contain branch identifier if need get specified branch results
+// Offset is number of skipped commits, limit is number of maximum commits to read
+func (r Repository) History(ctx context.Context, path string, branch string, offset int, limit int) ([]Commit, error) {
+	if r.diskCache == nil {
+		return r.history(ctx, path, branch, offset, limit)
+	}
+
+	// history page depends on the walked branches heads only, so it's keyed by their commit identifiers
+	heads, err := r.historyHeads(ctx, "", branch)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(heads)
+	page := fmt.Sprintf("%s\x00%d\x00%d", path, offset, limit)
+
+	var commits []Commit
+	if r.diskCache.get(&commits, "history", r.projectPath, strings.Join(heads, ","), page) {
+		return commits, nil
+	}
+
+	commits, err = r.history(ctx, path, branch, offset, limit)
+	if err == nil {
+		r.diskCache.put(commits, "history", r.projectPath, strings.Join(heads, ","), page)
+	}
+
+	return commits, err
+}
+
+// Read commits history without cache
+func (r Repository) history(ctx context.Context, path string, branch string, offset int, limit int) ([]Commit, error) {
+	commits := make([]Commit, 0)
+
+	c := r.HistoryCursor(ctx, path, branch, offset, limit)
+	defer c.Close()
+
+	for c.Next() {
+		commits = append(commits, c.Commit())
+	}
+
+	return commits, c.Err()
+}
+
+// Create cursor of the commits history
+// Arguments are the same as History ones, the cursor reads the VCS directly without persistent cache
+func (r Repository) HistoryCursor(ctx context.Context, path string, branch string, offset int, limit int) *CommitCursor {
+	return NewCommitCursor(ctx, func(ctx context.Context, result chan Commit) *Executor {
+		return r.cmd.ReadHistory(ctx, r.projectPath, path, branch, offset, limit, result)
+	})
+}
+
+// Read page of the commits history in topological order
+// Path is a relative file or directory path, empty path means whole project
+// Branch filters branches which names contain it like History does, empty branch means all branches
+// Empty token means the first page, otherwise it's a next page token returned by the previous call with the same path
+// Returns up to limit commits and the next page token (empty for the last page)
+// The token keeps the frontier of the walk: commits which aren't returned yet but whose children are,
+// the next page continues the walk from them, so it reads about limit commits, doesn't change after new pushes and doesn't skip commits
+// Branch argument is used by the first page only
+// Token size doesn't grow with the page number, it depends on the number of the history lines crossing the page border
+// If path isn't empty, parents of the commits are rewritten to the nearest ancestors which change the path
+func (r Repository) HistoryPage(ctx context.Context, path string, branch string, token string, limit int) ([]Commit, string, error) {
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("History page limit should be positive, got %d", limit)
+	}
+
+	var (
+		starts []string
+		err error
+	)
+
+	if token == "" {
+		starts, err = r.historyHeads(ctx, path, branch)
+	} else {
+		starts, err = decodeHistoryToken(token)
+	}
+
+	if err != nil || len(starts) == 0 {
+		return []Commit{}, "", err
+	}
+
+	commits, err := r.historyFrom(ctx, path, starts, limit + 1)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// one more commit means there is the next page
+	if len(commits) <= limit {
+		return commits, "", nil
+	}
+	commits = commits[:limit]
+
+	return commits, encodeHistoryToken(historyFrontier(starts, commits)), nil
+}
+
+// Returns head commits of local branches which names contain the branch argument
+// If path isn't empty, every head is replaced by its nearest ancestor which changes the path,
+// so the heads are the commits of the rewritten history
+func (r Repository) historyHeads(ctx context.Context, path string, branch string) ([]string, error) {
+	branches, err := r.Branches(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	heads := make([]string, 0, len(branches))
+	seen := make(map[string]bool)
+
+	for _, b := range branches {
+		if strings.HasPrefix(b.Id(), "remotes/") || !matchNativeGlob("*" + branch + "*", b.Id()) {
+			continue
+		}
+
+		// branches list has short identifiers
+		c, err := r.Commit(ctx, "refs/heads/" + b.Id())
+		if err != nil {
+			return nil, err
+		}
+
+		id := c.Id()
+		if path != "" {
+			commits, err := r.historyFrom(ctx, path, []string{id}, 1)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(commits) == 0 {
+				continue
+			}
+			id = commits[0].Id()
+		}
+
+		if !seen[id] {
+			seen[id] = true
+			heads = append(heads, id)
+		}
+	}
+
+	return heads, nil
+}
+
+// Read up to limit commits of history starting from the commits in topological order
+func (r Repository) historyFrom(ctx context.Context, path string, commitIds []string, limit int) ([]Commit, error) {
+	c := NewCommitCursor(ctx, func(ctx context.Context, result chan Commit) *Executor {
+		return r.cmd.ReadHistoryFrom(ctx, r.projectPath, path, commitIds, limit, result)
+	})
+	defer c.Close()
+
+	commits := make([]Commit, 0, limit)
+	for c.Next() {
+		commits = append(commits, c.Commit())
+	}
+
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+// Returns commits to continue the walk from the starts after the page: the starts and the parents of the page commits
+// which aren't on the page
+// The rest of the history is the commits reachable from them, because the page has no commits before all their children
+func historyFrontier(starts []string, commits []Commit) []string {
+	seen := make(map[string]bool)
+	for _, c := range commits {
+		seen[c.Id()] = true
+	}
+
+	frontier := make([]string, 0)
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			frontier = append(frontier, id)
+		}
+	}
+
+	for _, id := range starts {
+		add(id)
+	}
+
+	for _, c := range commits {
+		for _, id := range c.Parents() {
+			add(id)
+		}
+	}
+
+	return frontier
+}
+
+// Encode the frontier commits to the opaque history page token
+func encodeHistoryToken(commitIds []string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(commitIds, ",")))
+}
+
+// Decode the frontier commits of the history page token
+func decodeHistoryToken(token string) ([]string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) == 0 {
+		return nil, newVcsError(ErrRevisionNotFound, "Invalid history page token %s", token)
+	}
+
+	commitIds := strings.Split(string(data), ",")
+	for _, id := range commitIds {
+		if !fullObjectIdPattern.MatchString(id) {
+			return nil, newVcsError(ErrRevisionNotFound, "Invalid history page token %s", token)
+		}
+	}
+
+	return commitIds, nil
+}
+
+// Read changes of the commit comparing with its first parent
+func (r Repository) Diff(ctx context.Context, commitId string) ([]FileDiff, error) {
+	diffs := make([]FileDiff, 0)
+
+	c := r.DiffCursor(ctx, commitId)
+	defer c.Close()
+
+	for c.Next() {
+		diffs = append(diffs, c.FileDiff())
+	}
+
+	return diffs, c.Err()
+}
+
+// Create cursor of the commit changes comparing with its first parent
+func (r Repository) DiffCursor(ctx context.Context, commitId string) *FileDiffCursor {
+	return NewFileDiffCursor(ctx, func(ctx context.Context, result chan FileDiff) *Executor {
+		return r.cmd.ReadDiff(ctx, r.projectPath, commitId, result)
+	})
+}
+
+// Vcs which reads file history following renames (Git does)
+type fileHistoryReader interface {
+	ReadFileHistory(ctx context.Context, projectPath string, path string, branch string, offset int, limit int, result chan FileCommit) *Executor
+}
+
+// Vcs which reads history of the line range and the function (Git does)
+type lineHistoryReader interface {
+	ReadLineHistory(ctx context.Context, projectPath string, path string, start int, end int, revision string, offset int, limit int, result chan LineCommit) *Executor
+	ReadFunctionHistory(ctx context.Context, projectPath string, path string, funcname string, revision string, offset int, limit int, result chan LineCommit) *Executor
+}
+
+// Vcs which searches commits by added or removed code (Git does)
+type historySearcher interface {
+	SearchHistory(ctx context.Context, projectPath string, query string, isRegexp bool, path string, branch string, offset int, limit int, result chan MatchedCommit) *Executor
+}
+
+// Vcs which searches the code in the tree (Git does)
+type grepper interface {
+	Grep(ctx context.Context, projectPath string, revision string, pattern string, ignoreCase bool, globs []string, contextLines int, result chan GrepMatch) *Executor
+}
+
+// Vcs which reads patches of the commits (Git does)
+type patchesReader interface {
+	ReadPatches(ctx context.Context, projectPath string, revision string, result chan Patch) *Executor
+}
+
+// Get the vcs of the repository and the vcs wrapped by the cache
+// Optional interfaces are looked up in this order, so the cached vcs serves the operations of the wrapped one
+func (r Repository) vcsLayers() []Vcs {
+	layers := []Vcs{r.cmd}
+
+	for {
+		cached, ok := layers[len(layers)-1].(*CachedVcs)
+		if !ok {
+			return layers
+		}
+
+		layers = append(layers, cached.Vcs)
+	}
+}
+
+// Create executor which fails because the vcs doesn't support the operation
+func (r Repository) unsupported(ctx context.Context, operation string) *Executor {
+	return NewFuncExecutor(ctx, operation, func() error {
+		return fmt.Errorf("Version control system %T doesn't support %s", r.cmd, operation)
+	}, nil)
+}
+
+// Create cursor of the file history following renames
+// Path is a relative file pathname, branch limits the history by the branch (all branches if empty)
+// Each one value contains the commit and the pathname the file had in this commit
+// Cursor fails if the vcs doesn't support file history
+func (r Repository) FileHistoryCursor(ctx context.Context, path string, branch string, offset int, limit int) *FileCommitCursor {
+	return NewFileCommitCursor(ctx, func(ctx context.Context, result chan FileCommit) *Executor {
+		for _, vcs := range r.vcsLayers() {
+			if reader, ok := vcs.(fileHistoryReader); ok {
+				return reader.ReadFileHistory(ctx, r.projectPath, path, branch, offset, limit, result)
+			}
+		}
+
+		return r.unsupported(ctx, "file history")
+	})
+}
+
+// Create cursor of the file line range history
+// Start and end are the line numbers of the range (starting from 1), revision is a branch or commit identifier to start from
+// Each one value contains the commit and the diff hunk of the line range
+// Cursor fails if the vcs doesn't support line history
+func (r Repository) LineHistoryCursor(ctx context.Context, path string, start int, end int, revision string, offset int, limit int) *LineCommitCursor {
+	return NewLineCommitCursor(ctx, func(ctx context.Context, result chan LineCommit) *Executor {
+		for _, vcs := range r.vcsLayers() {
+			if reader, ok := vcs.(lineHistoryReader); ok {
+				return reader.ReadLineHistory(ctx, r.projectPath, path, start, end, revision, offset, limit, result)
+			}
+		}
+
+		return r.unsupported(ctx, "line history")
+	})
+}
+
+// Create cursor of the function history in the file
+// Funcname is a regular expression of the function name, revision is a branch or commit identifier to start from
+// Each one value contains the commit and the diff hunk of the function
+// Cursor fails if the vcs doesn't support line history
+func (r Repository) FunctionHistoryCursor(ctx context.Context, path string, funcname string, revision string, offset int, limit int) *LineCommitCursor {
+	return NewLineCommitCursor(ctx, func(ctx context.Context, result chan LineCommit) *Executor {
+		for _, vcs := range r.vcsLayers() {
+			if reader, ok := vcs.(lineHistoryReader); ok {
+				return reader.ReadFunctionHistory(ctx, r.projectPath, path, funcname, revision, offset, limit, result)
+			}
+		}
+
+		return r.unsupported(ctx, "function history")
+	})
+}
+
+// Create cursor of the commits where the string or regular expression (if isRegexp is true) was added or removed
+// Path limits the search, empty path means whole repository
+// Each one value contains the commit and the pathnames of the matched files
+// Cursor fails if the vcs doesn't support history search
+func (r Repository) SearchHistoryCursor(ctx context.Context, query string, isRegexp bool, path string, branch string, offset int, limit int) *MatchedCommitCursor {
+	return NewMatchedCommitCursor(ctx, func(ctx context.Context, result chan MatchedCommit) *Executor {
+		for _, vcs := range r.vcsLayers() {
+			if searcher, ok := vcs.(historySearcher); ok {
+				return searcher.SearchHistory(ctx, r.projectPath, query, isRegexp, path, branch, offset, limit, result)
+			}
+		}
+
+		return r.unsupported(ctx, "history search")
+	})
+}
+
+// Create cursor of the code search in the tree of revision
+// Pattern is a POSIX extended regular expression, globs limit searched files (whole tree if empty)
+// ContextLines is a number of context lines around each one match, cursor gets nothing if nothing found
+// Cursor fails if the vcs doesn't support code search
+func (r Repository) GrepCursor(ctx context.Context, revision string, pattern string, ignoreCase bool, globs []string, contextLines int) *GrepMatchCursor {
+	return NewGrepMatchCursor(ctx, func(ctx context.Context, result chan GrepMatch) *Executor {
+		for _, vcs := range r.vcsLayers() {
+			if g, ok := vcs.(grepper); ok {
+				return g.Grep(ctx, r.projectPath, revision, pattern, ignoreCase, globs, contextLines, result)
+			}
+		}
+
+		return r.unsupported(ctx, "code search")
+	})
+}
+
+// Create cursor of the patches of the commit or commits range like a..b
+// Cursor gets patches in the format-patch order
+// Cursor fails if the vcs doesn't support patches
+func (r Repository) PatchesCursor(ctx context.Context, revision string) *PatchCursor {
+	return NewPatchCursor(ctx, func(ctx context.Context, result chan Patch) *Executor {
+		for _, vcs := range r.vcsLayers() {
+			if reader, ok := vcs.(patchesReader); ok {
+				return reader.ReadPatches(ctx, r.projectPath, revision, result)
+			}
+		}
+
+		return r.unsupported(ctx, "patches")
+	})
+}
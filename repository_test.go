@@ -1,6 +1,10 @@
 package vcsview
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -198,7 +202,7 @@ func TestRepository_Check(t *testing.T) {
 		r.cmd = testCase.vcs
 		r.projectPath = testCase.projectPath
 
-		err := r.Check()
+		err := r.Check(context.Background())
 
 		if err != nil && !testCase.gotError {
 			t.Errorf("[%d] Repository.Check() = %v, want no errors", key, err)
@@ -209,3 +213,641 @@ func TestRepository_Check(t *testing.T) {
 		}
 	}
 }
+
+func TestRepository_ReadBlob(t *testing.T) {
+	git := MakeGitMock(t)
+
+	r, err := NewRepository(gitRepositoryPath, git)
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+
+	blob, err := r.ReadBlob(context.Background(), "HEAD", "testpath/empty.txt")
+	if err != nil {
+		t.Fatalf("Repository.ReadBlob(HEAD, testpath/empty.txt) got error: %v, want no errors", err)
+	}
+
+	if blob.ContentKind() != ContentText || len(blob.Content()) != 0 {
+		t.Errorf("Repository.ReadBlob(HEAD, testpath/empty.txt) = %v, want empty text blob", blob)
+	}
+
+	if _, err := r.ReadBlob(context.Background(), "HEAD", "non-existent.txt"); ErrorKind(err) != ErrPathNotFound {
+		t.Errorf("Repository.ReadBlob(HEAD, non-existent.txt) got error: %v, want: %v", err, ErrPathNotFound)
+	}
+
+	if _, err := r.ReadBlob(context.Background(), "non-existent-revision", "main.go"); ErrorKind(err) != ErrRevisionNotFound {
+		t.Errorf("Repository.ReadBlob(non-existent-revision, main.go) got error: %v, want: %v", err, ErrRevisionNotFound)
+	}
+}
+
+func TestRepository_ReadBlobHead(t *testing.T) {
+	git := MakeGitMock(t)
+
+	cases := []struct{
+		vcs Vcs
+		pathname string
+		size int64
+		want string
+		wantErr error
+	}{
+		{git, "main.go", 7, "package", nil},
+		{git, "main.go", 1024, "package main\n\nfunc hello() {\n\tprintln(\"v12\")\n}\n", nil},
+		{git, "non-existent.txt", 7, "", ErrPathNotFound},
+		{NewCachedVcs(git, 10), "main.go", 7, "package", nil},
+		{NewNativeGit(), "main.go", 7, "package", nil},
+		{NewNativeGit(), "non-existent.txt", 7, "", ErrPathNotFound},
+	}
+
+	for key, testCase := range cases {
+		r, err := NewRepository(gitRepositoryPath, testCase.vcs)
+		if err != nil {
+			t.Fatalf("[%d] Can't create repository for %s. Got error: %v", key, gitRepositoryPath, err)
+		}
+
+		head, err := r.readBlobHead(context.Background(), "HEAD", testCase.pathname, testCase.size)
+		if string(head) != testCase.want || ErrorKind(err) != testCase.wantErr {
+			t.Errorf("[%d] Repository.readBlobHead(HEAD, %s, %d) = %q, %v, want: %q, %v", key, testCase.pathname, testCase.size, head, err, testCase.want, testCase.wantErr)
+		}
+	}
+}
+
+func TestRepository_FilesListContentKind(t *testing.T) {
+	git := MakeGitMock(t)
+
+	r, err := NewRepository(gitRepositoryPath, git)
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+
+	files, err := r.FilesList("testpath")
+	if err != nil {
+		t.Fatalf("Repository.FilesList(testpath) got error: %v, want no errors", err)
+	}
+
+	for _, f := range files {
+		if f.IsDir() && f.ContentKind() != ContentUnknown {
+			t.Errorf("Repository.FilesList(testpath) directory %s has content kind: %v", f.Pathname(), f.ContentKind())
+		}
+
+		if !f.IsDir() && (f.ContentKind() == ContentUnknown || f.MimeType() == "") {
+			t.Errorf("Repository.FilesList(testpath) file %s has no content kind", f.Pathname())
+		}
+	}
+}
+
+func TestAttributesDirs(t *testing.T) {
+	cases := []struct{
+		dir string
+		dirs string
+	}{
+		{"", ""},
+		{".", ""},
+		{"testpath", ",testpath"},
+		{"/a/b/", ",a,a/b"},
+	}
+
+	for key, testCase := range cases {
+		if dirs := strings.Join(attributesDirs(testCase.dir), ","); dirs != testCase.dirs {
+			t.Errorf("[%d] attributesDirs(%s) = %v, want: %v", key, testCase.dir, dirs, testCase.dirs)
+		}
+	}
+}
+
+func TestRepository_ReadTree(t *testing.T) {
+	git := MakeGitMock(t)
+
+	r, err := NewRepository(gitRepositoryPath, git)
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+
+	files, err := r.ReadTree(context.Background(), "HEAD", "testpath", false)
+	if err != nil {
+		t.Fatalf("Repository.ReadTree(HEAD, testpath, false) got error: %v, want no errors", err)
+	}
+
+	if len(files) == 0 {
+		t.Fatalf("Repository.ReadTree(HEAD, testpath, false) got empty files list")
+	}
+
+	for _, f := range files {
+		if f.Path() != "testpath" {
+			t.Errorf("Repository.ReadTree(HEAD, testpath, false) got file %s out of testpath", f.Pathname())
+		}
+	}
+
+	if _, err := r.ReadTree(context.Background(), "non-existent-revision", "", false); ErrorKind(err) != ErrRevisionNotFound {
+		t.Errorf("Repository.ReadTree(non-existent-revision, , false) got error: %v, want: %v", err, ErrRevisionNotFound)
+	}
+
+	if _, err := r.ReadTree(context.Background(), "HEAD", "non-existent", false); ErrorKind(err) != ErrPathNotFound {
+		t.Errorf("Repository.ReadTree(HEAD, non-existent, false) got error: %v, want: %v", err, ErrPathNotFound)
+	}
+}
+
+func TestRepository_Languages(t *testing.T) {
+	git := MakeGitMock(t)
+
+	r, err := NewRepository(gitRepositoryPath, git)
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+
+	languages, err := r.Languages(context.Background(), "HEAD")
+	if err != nil {
+		t.Fatalf("Repository.Languages(HEAD) got error: %v, want no errors", err)
+	}
+
+	for key, l := range languages {
+		if l.Name() == "" || l.Files() == 0 {
+			t.Errorf("[%d] Repository.Languages(HEAD) got unexpected language: %v", key, l)
+		}
+
+		if key > 0 && languages[key-1].Bytes() < l.Bytes() {
+			t.Errorf("[%d] Repository.Languages(HEAD) languages are not sorted by size", key)
+		}
+	}
+
+	if _, err := r.Languages(context.Background(), "non-existent-revision"); err == nil {
+		t.Errorf("Repository.Languages(non-existent-revision) got no errors, want error")
+	}
+}
+
+func TestRepository_RenderReadme(t *testing.T) {
+	git := MakeGitMock(t)
+
+	r, err := NewRepository(gitRepositoryPath, git)
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+
+	links := LinkBuilderFunc(func(kind LinkKind, revision string, pathname string) string {
+		return "/" + string(kind) + "/" + revision + "/" + pathname
+	})
+
+	readme, html, err := r.RenderReadme(context.Background(), "HEAD", "", links)
+	if err != nil {
+		// testing repository could have no README file
+		if _, findErr := r.FindReadme(context.Background(), "HEAD", ""); findErr == nil {
+			t.Errorf("Repository.RenderReadme(HEAD, ) got error: %v, want no errors", err)
+		}
+		return
+	}
+
+	if readmePriority(readme.Name()) < 0 {
+		t.Errorf("Repository.RenderReadme(HEAD, ) got unexpected README file: %v", readme.Pathname())
+	}
+
+	if html == "" && readme.Size() > 0 {
+		t.Errorf("Repository.RenderReadme(HEAD, ) got empty HTML")
+	}
+
+	if _, _, err := r.RenderReadme(context.Background(), "HEAD", "non-existent-path", links); err == nil {
+		t.Errorf("Repository.RenderReadme(HEAD, non-existent-path) got no errors, want error")
+	}
+}
+
+func TestRepository_Reads(t *testing.T) {
+	git := MakeGitMock(t)
+
+	r, err := NewRepository(gitRepositoryPath, git)
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+
+	branches, err := r.Branches(context.Background())
+	if err != nil || len(branches) == 0 {
+		t.Errorf("Repository.Branches() = %v, %v, want branches", branches, err)
+	}
+
+	if _, err := r.Tags(context.Background()); err != nil {
+		t.Errorf("Repository.Tags() got error: %v, want no errors", err)
+	}
+
+	commits, err := r.History(context.Background(), "", "", 0, 2)
+	if err != nil || len(commits) != 2 {
+		t.Fatalf("Repository.History(, , 0, 2) = %v, %v, want 2 commits", commits, err)
+	}
+
+	commit, err := r.Commit(context.Background(), commits[0].Id())
+	if err != nil || commit.Id() != commits[0].Id() {
+		t.Errorf("Repository.Commit(%s) = %v, %v, want: %v", commits[0].Id(), commit, err, commits[0])
+	}
+
+	if _, err := r.Commit(context.Background(), "non-existent-commit"); err == nil {
+		t.Errorf("Repository.Commit(non-existent-commit) got no errors, want error")
+	}
+
+	if _, err := r.Diff(context.Background(), commits[0].Id()); err != nil {
+		t.Errorf("Repository.Diff(%s) got error: %v, want no errors", commits[0].Id(), err)
+	}
+}
+
+func TestRepository_HistoryPage(t *testing.T) {
+	git := MakeGitMock(t)
+
+	r, err := NewRepository(gitRepositoryPath, git)
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+
+	cases := []struct{
+		path string
+		branch string
+		limit int
+	}{
+		{"", "", 3},
+		{"", "master", 1},
+		{"testpath", "", 2},
+		{"main.go", "", 5},
+		{"non-existent.txt", "", 2},
+	}
+
+	for key, testCase := range cases {
+		want, err := r.History(context.Background(), testCase.path, testCase.branch, 0, 1000)
+		if err != nil {
+			t.Fatalf("[%d] Repository.History(%s, %s) got error: %v", key, testCase.path, testCase.branch, err)
+		}
+
+		// pages together are the whole history
+		got := make([]string, 0)
+		token := ""
+		for pages := 0; pages < 100; pages++ {
+			commits, next, err := r.HistoryPage(context.Background(), testCase.path, testCase.branch, token, testCase.limit)
+			if err != nil || len(commits) > testCase.limit {
+				t.Fatalf("[%d] Repository.HistoryPage(%s, %s, %s, %d) = %v, %v, want up to %d commits", key, testCase.path, testCase.branch, token, testCase.limit, commits, err, testCase.limit)
+			}
+
+			for _, c := range commits {
+				got = append(got, c.Id())
+			}
+
+			if next == "" {
+				break
+			}
+			token = next
+		}
+
+		wantIds := make([]string, 0, len(want))
+		for _, c := range want {
+			wantIds = append(wantIds, c.Id())
+		}
+
+		if strings.Join(got, ",") != strings.Join(wantIds, ",") {
+			t.Errorf("[%d] Repository.HistoryPage(%s, %s) pages = %v, want: %v", key, testCase.path, testCase.branch, got, wantIds)
+		}
+	}
+
+	if _, _, err := r.HistoryPage(context.Background(), "", "", "invalid-token", 10); ErrorKind(err) != ErrRevisionNotFound {
+		t.Errorf("Repository.HistoryPage() with invalid token got error: %v, want: %v", err, ErrRevisionNotFound)
+	}
+
+	if _, _, err := r.HistoryPage(context.Background(), "", "", "", 0); err == nil {
+		t.Errorf("Repository.HistoryPage() with zero limit got no errors, want error")
+	}
+}
+
+// Read all history pages and returns commit identifiers
+func readHistoryPages(t *testing.T, r Repository, path string, limit int) []string {
+	ids := make([]string, 0)
+	token := ""
+
+	for pages := 0; pages < 100; pages++ {
+		commits, next, err := r.HistoryPage(context.Background(), path, "", token, limit)
+		if err != nil {
+			t.Fatalf("Repository.HistoryPage(%s, , %s, %d) got error: %v", path, token, limit, err)
+		}
+
+		for _, c := range commits {
+			ids = append(ids, c.Id())
+		}
+
+		if next == "" {
+			break
+		}
+		token = next
+	}
+
+	return ids
+}
+
+// Check that the commits are all the commits of the history once and no commit goes before its children
+func isTopoHistory(ids []string, parents map[string][]string) bool {
+	if len(ids) != len(parents) {
+		return false
+	}
+
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if _, ok := parents[id]; !ok || seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+
+	// parents go after the commit, so none of them is seen before it
+	seen = make(map[string]bool)
+	for _, id := range ids {
+		for _, p := range parents[id] {
+			if seen[p] {
+				return false
+			}
+		}
+		seen[id] = true
+	}
+
+	return true
+}
+
+func TestRepository_HistoryPageMerges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcsview-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// branches with merges and commits which don't change the directories, commit dates are increasing
+	script := `
+		set -e
+		n=0
+		commit() { n=$((n+1)); mkdir -p "$1"; echo "$n" > "$1/$n"; git add -A; GIT_COMMITTER_DATE="2020-01-01T00:00:$((10+n))" git commit -q -m "$1 $n" --date="2020-01-01T00:00:$((10+n))"; }
+		git init -q .
+		git config user.email test@example.com
+		git config user.name test
+		commit a; commit b
+		git checkout -q -b side
+		commit a; commit c; commit b
+		git checkout -q master
+		commit c; commit a
+		git checkout -q -b other
+		commit b; commit c
+		git checkout -q master
+		n=$((n+1)); GIT_COMMITTER_DATE="2020-01-01T00:00:$((10+n))" GIT_AUTHOR_DATE="2020-01-01T00:00:$((10+n))" git merge -q --no-ff -m merge side
+		commit c; commit b
+		git checkout -q side
+		commit a
+	`
+
+	cmd := exec.Command("sh", "-c", script)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Can't create repository: %v, %s", err, out)
+	}
+
+	r, err := NewRepository(dir, NewGitWithOptions(GitOptions{InheritEnv: true}))
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", dir, err)
+	}
+
+	native, err := NewRepository(dir, NewNativeGit())
+	if err != nil {
+		t.Fatalf("Can't create NativeGit repository for %s. Got error: %v", dir, err)
+	}
+
+	for _, path := range []string{"", "a", "b", "c"} {
+		args := []string{"log", "--topo-order", "--parents", "--format=%H", "--branches", "--"}
+		if path != "" {
+			args = append(args, path)
+		}
+
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git log --topo-order -- %s got error: %v", path, err)
+		}
+
+		// every line is a commit and its rewritten parents
+		parents := make(map[string][]string)
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			ids := strings.Fields(line)
+			parents[ids[0]] = ids[1:]
+		}
+
+		for limit := 1; limit <= 3; limit++ {
+			if got := readHistoryPages(t, r, path, limit); !isTopoHistory(got, parents) {
+				t.Errorf("Repository.HistoryPage(%s, %d) pages = %v, want topological order of: %v", path, limit, got, parents)
+			}
+
+			if got := readHistoryPages(t, native, path, limit); !isTopoHistory(got, parents) {
+				t.Errorf("NativeGit Repository.HistoryPage(%s, %d) pages = %v, want topological order of: %v", path, limit, got, parents)
+			}
+		}
+	}
+
+	// the next page doesn't change after new commits
+	first, token, err := r.HistoryPage(context.Background(), "", "", "", 2)
+	if err != nil || token == "" {
+		t.Fatalf("Repository.HistoryPage(, , , 2) = %v, %q, %v, want the next page", first, token, err)
+	}
+
+	before, _, _ := r.HistoryPage(context.Background(), "", "", token, 3)
+
+	cmd = exec.Command("sh", "-c", "echo new > new && git add -A && git commit -q -m new")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Can't commit: %v, %s", err, out)
+	}
+
+	after, _, err := r.HistoryPage(context.Background(), "", "", token, 3)
+	if err != nil || len(after) != len(before) || after[0].Id() != before[0].Id() {
+		t.Errorf("Repository.HistoryPage(, , %s, 3) after new commit = %v, %v, want: %v", token, after, err, before)
+	}
+}
+
+func TestHistoryToken(t *testing.T) {
+	frontier := []string{strings.Repeat("a", 40), strings.Repeat("b", 64)}
+
+	got, err := decodeHistoryToken(encodeHistoryToken(frontier))
+	if err != nil || strings.Join(got, ",") != strings.Join(frontier, ",") {
+		t.Errorf("decodeHistoryToken(encodeHistoryToken(%v)) = %v, %v, want: %v", frontier, got, err, frontier)
+	}
+
+	for _, token := range []string{"", "!", encodeHistoryToken([]string{frontier[0], "HEAD"}), encodeHistoryToken([]string{"--all"})} {
+		if _, err := decodeHistoryToken(token); err == nil {
+			t.Errorf("decodeHistoryToken(%q) got no errors, want error", token)
+		}
+	}
+}
+
+func TestHistoryFrontier(t *testing.T) {
+	cases := []struct{
+		starts []string
+		commits []Commit
+		want []string
+	}{
+		{[]string{"d"}, []Commit{{id: "d", parents: []string{"c"}}}, []string{"c"}},
+		{[]string{"d", "x"}, []Commit{{id: "d", parents: []string{"b", "c"}}, {id: "c", parents: []string{"a"}}}, []string{"x", "b", "a"}},
+		{[]string{"d"}, []Commit{{id: "d", parents: []string{"c"}}, {id: "c", parents: []string{""}}}, []string{}},
+		{[]string{"d", "b"}, []Commit{{id: "d", parents: []string{"b"}}}, []string{"b"}},
+	}
+
+	for key, testCase := range cases {
+		if got := historyFrontier(testCase.starts, testCase.commits); strings.Join(got, ",") != strings.Join(testCase.want, ",") {
+			t.Errorf("[%d] historyFrontier(%v, %v) = %v, want: %v", key, testCase.starts, testCase.commits, got, testCase.want)
+		}
+	}
+}
+
+// Vcs which records commits read by ReadHistoryFrom
+type historyRecordingVcs struct {
+	Vcs
+	read []string
+}
+
+func (v *historyRecordingVcs) ReadHistoryFrom(ctx context.Context, projectPath string, path string, commitIds []string, limit int, result chan Commit) *Executor {
+	return NewFuncExecutor(ctx, "recorded history", func() error {
+		commits := make(chan Commit)
+		done := make(chan error, 1)
+
+		go func() {
+			done <- v.Vcs.ReadHistoryFrom(ctx, projectPath, path, commitIds, limit, commits).Run()
+			close(commits)
+		}()
+
+		for c := range commits {
+			v.read = append(v.read, c.Id())
+			result <- c
+		}
+
+		return <-done
+	}, nil)
+}
+
+func TestRepository_HistoryPageReads(t *testing.T) {
+	for _, vcs := range []Vcs{NewGit(), NewNativeGit()} {
+		recorder := &historyRecordingVcs{vcs, nil}
+
+		r, err := NewRepository(gitRepositoryPath, recorder)
+		if err != nil {
+			t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+		}
+
+		returned := make(map[string]bool)
+		token := ""
+
+		for pages := 0; pages < 100; pages++ {
+			recorder.read = nil
+
+			commits, next, err := r.HistoryPage(context.Background(), "", "", token, 2)
+			if err != nil {
+				t.Fatalf("%T Repository.HistoryPage(, , %s, 2) got error: %v", vcs, token, err)
+			}
+
+			// a page reads the page commits and one more to know there is the next page
+			if len(recorder.read) > 3 {
+				t.Errorf("%T Repository.HistoryPage(, , %s, 2) read %d commits, want: 3 at most", vcs, token, len(recorder.read))
+			}
+
+			for _, id := range recorder.read {
+				if returned[id] {
+					t.Errorf("%T Repository.HistoryPage(, , %s, 2) read commit %s of the previous pages", vcs, token, id)
+				}
+			}
+
+			for _, c := range commits {
+				returned[c.Id()] = true
+			}
+
+			if next == "" {
+				break
+			}
+			token = next
+		}
+
+		if len(returned) != 17 {
+			t.Errorf("%T Repository.HistoryPage() pages have %d commits, want: 17", vcs, len(returned))
+		}
+	}
+}
+
+func TestRepository_Context(t *testing.T) {
+	git := MakeGitMock(t)
+
+	r, err := NewRepository(gitRepositoryPath, git)
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := r.Branches(ctx); err != context.Canceled {
+		t.Errorf("Repository.Branches() with cancelled context got error: %v, want: %v", err, context.Canceled)
+	}
+	if _, err := r.Commit(ctx, "HEAD"); err != context.Canceled {
+		t.Errorf("Repository.Commit(HEAD) with cancelled context got error: %v, want: %v", err, context.Canceled)
+	}
+	if _, err := r.ReadTree(ctx, "HEAD", "", true); err != context.Canceled {
+		t.Errorf("Repository.ReadTree(HEAD) with cancelled context got error: %v, want: %v", err, context.Canceled)
+	}
+	if err := r.Check(ctx); err != context.Canceled {
+		t.Errorf("Repository.Check() with cancelled context got error: %v, want: %v", err, context.Canceled)
+	}
+
+	if _, err := r.Branches(context.Background()); err != nil {
+		t.Errorf("Repository.Branches() got error: %v, want no errors", err)
+	}
+}
+
+func TestRepository_Cursors(t *testing.T) {
+	ctx := context.Background()
+
+	// count values of the cursor
+	count := func(c interface{ Next() bool; Err() error; Close() error }) (int, error) {
+		defer c.Close()
+
+		n := 0
+		for c.Next() {
+			n++
+		}
+
+		return n, c.Err()
+	}
+
+	cases := []struct{
+		name string
+		read func(r Repository) (int, error)
+		want int
+	}{
+		{"FileHistoryCursor(main.go)", func(r Repository) (int, error) {
+			return count(r.FileHistoryCursor(ctx, "main.go", "", 0, 3))
+		}, 3},
+		{"LineHistoryCursor(main.go, 1, 3)", func(r Repository) (int, error) {
+			return count(r.LineHistoryCursor(ctx, "main.go", 1, 3, "HEAD", 0, 10))
+		}, 1},
+		{"FunctionHistoryCursor(main.go, hello)", func(r Repository) (int, error) {
+			return count(r.FunctionHistoryCursor(ctx, "main.go", "hello", "HEAD", 0, 1))
+		}, 1},
+		{"SearchHistoryCursor(println)", func(r Repository) (int, error) {
+			return count(r.SearchHistoryCursor(ctx, "println", false, "", "", 0, 2))
+		}, 1},
+		{"GrepCursor(hello)", func(r Repository) (int, error) {
+			return count(r.GrepCursor(ctx, "HEAD", "func hello", false, []string{"*.go"}, 0))
+		}, 1},
+		{"PatchesCursor(HEAD)", func(r Repository) (int, error) {
+			return count(r.PatchesCursor(ctx, "HEAD"))
+		}, 1},
+	}
+
+	git := MakeGitMock(t)
+
+	for _, vcs := range []Vcs{git, NewCachedVcs(git, 10), NewNativeGit()} {
+		r, err := NewRepository(gitRepositoryPath, vcs)
+		if err != nil {
+			t.Fatalf("Can't create repository for %s. Got error: %v", gitRepositoryPath, err)
+		}
+
+		_, isNative := vcs.(NativeGit)
+
+		for key, testCase := range cases {
+			n, err := testCase.read(r)
+
+			if isNative && err == nil {
+				t.Errorf("[%d] %T Repository.%s got no errors, want unsupported error", key, vcs, testCase.name)
+			} else if !isNative && (err != nil || n != testCase.want) {
+				t.Errorf("[%d] %T Repository.%s got %d values, error: %v, want: %d", key, vcs, testCase.name, n, err, testCase.want)
+			}
+		}
+	}
+}
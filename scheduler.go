@@ -0,0 +1,231 @@
+package vcsview
+
+import (
+	"container/list"
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Scheduler usage statistics
+type SchedulerStats struct {
+	// Number of running processes
+	running int
+
+	// Number of processes waiting for start
+	queued int
+
+	// Number of processes which waited for start
+	waits uint64
+
+	// Number of processes which context was done while waiting
+	cancellations uint64
+
+	// Total and maximum time of waiting
+	waitTime time.Duration
+	maxWait time.Duration
+}
+
+// Get number of running processes
+func (s SchedulerStats) Running() int {
+	return s.running
+}
+
+// Get number of processes waiting for start
+func (s SchedulerStats) Queued() int {
+	return s.queued
+}
+
+// Get number of processes which waited for start
+func (s SchedulerStats) Waits() uint64 {
+	return s.waits
+}
+
+// Get number of processes which context was done while waiting
+func (s SchedulerStats) Cancellations() uint64 {
+	return s.cancellations
+}
+
+// Get total time of waiting
+func (s SchedulerStats) WaitTime() time.Duration {
+	return s.waitTime
+}
+
+// Get maximum time of waiting
+func (s SchedulerStats) MaxWait() time.Duration {
+	return s.maxWait
+}
+
+// Process waiting for start
+type schedulerWaiter struct {
+	repository string
+
+	// Closed when the process may start
+	ready chan struct{}
+}
+
+// Limiter of concurrent VCS processes shared by Cli instances
+// Processes over the limits wait in the queue and start in order of arrival,
+// the process of the repository which has maximum running processes lets the next ones go before it
+// Persistent cat-file processes of Git are counted until the pool stops them, idle ones hold their slots up to the idle timeout
+// Safe for concurrent use
+type Scheduler struct {
+	// Maximum number of running processes, and running processes of the one repository
+	maxProcesses int
+	maxPerRepository int
+
+	mu sync.Mutex
+
+	// Number of running processes by repository path
+	repositories map[string]int
+
+	// Waiting processes in order of arrival
+	queue *list.List
+
+	stats SchedulerStats
+}
+
+// Create scheduler which runs up to maxProcesses processes and up to maxPerRepository processes of the one repository
+// Non-positive maxPerRepository means no limit per repository
+func NewScheduler(maxProcesses int, maxPerRepository int) *Scheduler {
+	if maxProcesses < 1 {
+		maxProcesses = 1
+	}
+
+	if maxPerRepository < 1 || maxPerRepository > maxProcesses {
+		maxPerRepository = maxProcesses
+	}
+
+	return &Scheduler{
+		maxProcesses: maxProcesses,
+		maxPerRepository: maxPerRepository,
+		repositories: make(map[string]int),
+		queue: list.New(),
+	}
+}
+
+// Get scheduler statistics
+func (s *Scheduler) Stats() SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats
+	stats.queued = s.queue.Len()
+
+	return stats
+}
+
+// Check the process of the repository may start now
+func (s *Scheduler) canStart(repository string) bool {
+	return s.stats.running < s.maxProcesses && s.repositories[repository] < s.maxPerRepository
+}
+
+// Count running process of the repository
+func (s *Scheduler) start(repository string) {
+	s.stats.running++
+	s.repositories[repository]++
+}
+
+// Take the process slot of the repository if it's free without waiting
+// Returns function which frees the slot and true, or false if the process should wait
+func (s *Scheduler) tryAcquire(repository string) (func(), bool) {
+	if s == nil {
+		return func() {}, true
+	}
+
+	repository = filepath.Clean(repository)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.canStart(repository) {
+		return nil, false
+	}
+
+	s.start(repository)
+
+	return func() {
+		s.release(repository)
+	}, true
+}
+
+// Wait for the process slot of the repository
+// Returns function which frees the slot, or the context error if context is done while waiting
+// Nil scheduler has no limits
+func (s *Scheduler) acquire(ctx context.Context, repository string) (func(), time.Duration, error) {
+	if s == nil {
+		return func() {}, 0, nil
+	}
+
+	repository = filepath.Clean(repository)
+	release := func() {
+		s.release(repository)
+	}
+
+	s.mu.Lock()
+
+	if s.canStart(repository) {
+		s.start(repository)
+		s.mu.Unlock()
+		return release, 0, nil
+	}
+
+	w := &schedulerWaiter{repository, make(chan struct{})}
+	element := s.queue.PushBack(w)
+	s.mu.Unlock()
+
+	started := time.Now()
+
+	select {
+	case <-w.ready:
+		wait := time.Since(started)
+		s.mu.Lock()
+		s.stats.waits++
+		s.stats.waitTime += wait
+		if wait > s.stats.maxWait {
+			s.stats.maxWait = wait
+		}
+		s.mu.Unlock()
+		return release, wait, nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	s.stats.cancellations++
+
+	select {
+	case <-w.ready:
+		// the slot was given at the same time, so pass it to the next process
+		s.mu.Unlock()
+		s.release(repository)
+	default:
+		s.queue.Remove(element)
+		s.mu.Unlock()
+	}
+
+	return nil, time.Since(started), ctx.Err()
+}
+
+// Free the process slot of the repository and start waiting processes
+func (s *Scheduler) release(repository string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.running--
+	if s.repositories[repository]--; s.repositories[repository] <= 0 {
+		delete(s.repositories, repository)
+	}
+
+	for e := s.queue.Front(); e != nil && s.stats.running < s.maxProcesses; {
+		next := e.Next()
+
+		if w := e.Value.(*schedulerWaiter); s.canStart(w.repository) {
+			s.start(w.repository)
+			s.queue.Remove(e)
+			close(w.ready)
+		}
+
+		e = next
+	}
+}
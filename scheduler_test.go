@@ -0,0 +1,206 @@
+package vcsview
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Acquire the slot in goroutine, the result channel gets order number of acquired slot
+func acquireAsync(s *Scheduler, ctx context.Context, repository string, number int, result chan int) chan func() {
+	released := make(chan func(), 1)
+
+	go func() {
+		release, _, err := s.acquire(ctx, repository)
+		if err != nil {
+			result <- -number
+			return
+		}
+		result <- number
+		released <- release
+	}()
+
+	return released
+}
+
+// Check no slot is acquired in a short time
+func assertWaiting(t *testing.T, result chan int, message string) {
+	select {
+	case n := <-result:
+		t.Errorf("%s: got slot %d, want waiting", message, n)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestScheduler_Limits(t *testing.T) {
+	s := NewScheduler(2, 1)
+	ctx := context.Background()
+	result := make(chan int, 10)
+
+	releaseA, _, _ := s.acquire(ctx, "/a")
+
+	// the repository has maximum processes
+	secondA := acquireAsync(s, ctx, "/a", 1, result)
+	assertWaiting(t, result, "Scheduler.acquire(/a) over repository limit")
+
+	// other repository goes before
+	releaseB, _, _ := s.acquire(ctx, "/b")
+
+	// global limit
+	thirdC := acquireAsync(s, ctx, "/c", 2, result)
+	assertWaiting(t, result, "Scheduler.acquire(/c) over global limit")
+
+	if stats := s.Stats(); stats.Running() != 2 || stats.Queued() != 2 {
+		t.Errorf("Scheduler.Stats() = %d running, %d queued, want: 2 running, 2 queued", stats.Running(), stats.Queued())
+	}
+
+	// the first waiting process of the released repository starts
+	releaseA()
+	if n := <-result; n != 1 {
+		t.Errorf("Scheduler.release(/a) started %d, want: 1", n)
+	}
+	assertWaiting(t, result, "Scheduler.acquire(/c) after Scheduler.release(/a)")
+
+	releaseB()
+	if n := <-result; n != 2 {
+		t.Errorf("Scheduler.release(/b) started %d, want: 2", n)
+	}
+
+	(<-secondA)()
+	(<-thirdC)()
+
+	stats := s.Stats()
+	if stats.Running() != 0 || stats.Queued() != 0 || stats.Waits() != 2 || stats.WaitTime() <= 0 || stats.MaxWait() <= 0 {
+		t.Errorf("Scheduler.Stats() = %+v, want 2 waits and nothing running", stats)
+	}
+}
+
+func TestScheduler_Order(t *testing.T) {
+	s := NewScheduler(1, 0)
+	ctx := context.Background()
+	result := make(chan int, 10)
+
+	release, _, _ := s.acquire(ctx, "/a")
+
+	releases := make([]chan func(), 0)
+	for i := 1; i <= 3; i++ {
+		releases = append(releases, acquireAsync(s, ctx, "/a", i, result))
+		// waiting processes are queued in this order
+		for s.Stats().Queued() != i {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	release()
+
+	for i := 1; i <= 3; i++ {
+		if n := <-result; n != i {
+			t.Errorf("Scheduler started %d, want: %d", n, i)
+		}
+		(<-releases[i-1])()
+	}
+}
+
+func TestScheduler_Cancel(t *testing.T) {
+	s := NewScheduler(1, 0)
+
+	release, _, _ := s.acquire(context.Background(), "/a")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20 * time.Millisecond)
+	defer cancel()
+
+	if _, wait, err := s.acquire(ctx, "/a"); err != context.DeadlineExceeded || wait <= 0 {
+		t.Errorf("Scheduler.acquire() with deadline = %v, %v, want: %v", wait, err, context.DeadlineExceeded)
+	}
+
+	if stats := s.Stats(); stats.Queued() != 0 || stats.Cancellations() != 1 {
+		t.Errorf("Scheduler.Stats() = %+v, want 1 cancellation and empty queue", stats)
+	}
+
+	release()
+
+	if stats := s.Stats(); stats.Running() != 0 {
+		t.Errorf("Scheduler.Stats() = %+v, want nothing running", stats)
+	}
+
+	// nil scheduler has no limits
+	var unlimited *Scheduler
+	if release, _, err := unlimited.acquire(context.Background(), "/a"); err != nil || release == nil {
+		t.Errorf("nil Scheduler.acquire() = %v, want no errors", err)
+	}
+}
+
+func TestGit_Scheduler(t *testing.T) {
+	g := MakeGitMock(t)
+	g.Scheduler = NewScheduler(2, 1)
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 10)
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- g.ReadHistory(context.Background(), gitRepositoryPath, "", "", 0, 1, make(chan Commit, 1)).Run()
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Git.ReadHistory() with scheduler got error: %v", err)
+		}
+	}
+
+	if stats := g.Scheduler.Stats(); stats.Running() != 0 || stats.Queued() != 0 {
+		t.Errorf("Scheduler.Stats() = %+v, want nothing running", stats)
+	}
+}
+
+func TestGit_SchedulerCatFile(t *testing.T) {
+	g := NewGit()
+	g.Debugger = MakeGitMock(t).Debugger
+	g.Scheduler = NewScheduler(2, 1)
+	defer g.Close()
+
+	// trees are read by two cat-file processes, so the idle one gives its slot to the other one
+	if err := g.ReadTree(context.Background(), gitRepositoryPath, "HEAD", "", false, make(chan File, 100)).Run(); err != nil {
+		t.Fatalf("Git.ReadTree() with scheduler got error: %v", err)
+	}
+
+	if running := g.Scheduler.Stats().Running(); running != 1 {
+		t.Errorf("Scheduler.Stats().Running() with idle cat-file process = %d, want: 1", running)
+	}
+
+	g.Close()
+
+	if running := g.Scheduler.Stats().Running(); running != 0 {
+		t.Errorf("Scheduler.Stats().Running() after Git.Close() = %d, want: 0", running)
+	}
+}
+
+func TestGit_SchedulerIdleCatFile(t *testing.T) {
+	g := NewGit()
+	g.Debugger = MakeGitMock(t).Debugger
+	g.Scheduler = NewScheduler(1, 1)
+	defer g.Close()
+
+	if err := g.catFile(context.Background(), gitRepositoryPath, catFileBatch, func(p *catFileProcess) error { return nil }); err != nil {
+		t.Fatalf("Git.catFile() with scheduler got error: %v", err)
+	}
+
+	if running := g.Scheduler.Stats().Running(); running != 1 {
+		t.Fatalf("Scheduler.Stats().Running() with idle cat-file process = %d, want: 1", running)
+	}
+
+	// the command stops the idle process instead of waiting for its timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+	defer cancel()
+
+	if err := g.ReadHistory(ctx, gitRepositoryPath, "", "", 0, 1, make(chan Commit, 1)).Run(); err != nil {
+		t.Errorf("Git.ReadHistory() with idle cat-file process got error: %v", err)
+	}
+}
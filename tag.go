@@ -0,0 +1,43 @@
+package vcsview
+
+import "time"
+
+// Represents VCS tag model
+type Tag struct {
+	// Tag name
+	id string
+
+	// Tagged commit identifier
+	head string
+
+	// Tag creation date (commit date for lightweight tags)
+	date time.Time
+
+	// Tag message subject (commit message for lightweight tags)
+	message string
+}
+
+// Create tag model
+func NewTag(id string, head string, date time.Time, message string) Tag {
+	return Tag{id, head, date, message}
+}
+
+// Get tag name
+func (t Tag) Id() string {
+	return t.id
+}
+
+// Get tagged commit identifier
+func (t Tag) Head() string {
+	return t.head
+}
+
+// Get tag creation date
+func (t Tag) Date() time.Time {
+	return t.date
+}
+
+// Get tag message
+func (t Tag) Message() string {
+	return t.message
+}
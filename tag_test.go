@@ -0,0 +1,44 @@
+package vcsview
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTag_Id(t *testing.T) {
+	expectedId := "v1.0"
+
+	tag := Tag{}
+	tag.id = expectedId
+
+	if id := tag.Id(); id != expectedId {
+		t.Errorf("Tag.Id() = %v, want: %v", id, expectedId)
+	}
+}
+
+func TestTag_Head(t *testing.T) {
+	expectedHead := "747ad5712f0ddbb482ebb6e07eb779e70b94687f"
+
+	tag := Tag{}
+	tag.head = expectedHead
+
+	if head := tag.Head(); head != expectedHead {
+		t.Errorf("Tag.Head() = %v, want: %v", head, expectedHead)
+	}
+}
+
+func TestTag_DateAndMessage(t *testing.T) {
+	expectedDate := time.Date(2019, time.Month(2), 24, 10, 47, 0, 0, time.UTC)
+
+	tag := Tag{}
+	tag.date = expectedDate
+	tag.message = "release"
+
+	if date := tag.Date(); !date.Equal(expectedDate) {
+		t.Errorf("Tag.Date() = %v, want: %v", date, expectedDate)
+	}
+
+	if message := tag.Message(); message != "release" {
+		t.Errorf("Tag.Message() = %v, want: %v", message, "release")
+	}
+}
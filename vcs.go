@@ -1,9 +1,16 @@
 package vcsview
 
+import (
+	"context"
+)
+
 // Common interfaces for each one version control system like git, mercurial, etc
+// Context of the executor methods cancels the reading: the command is killed and Run returns the context error
+// Result channels belong to the caller: executors never close them, the caller closes the channel after Run returned
+// Cursors (NewBranchCursor, NewCommitCursor, etc.) run the executors, close the channels and return results one-by-one
 type Vcs interface {
 	// check for VCS version
-	Version() (string, error)
+	Version(ctx context.Context) (string, error)
 
 	// Returns pathname for repository config (.git, .hg, etc)
 	RepositoryPathname() string
@@ -16,20 +23,20 @@ type Vcs interface {
 
 	// Fetch repository current status
 	// Returns error if the repository doesn't exists at specified path
-	StatusRepository(projectPath string) (string, error)
+	StatusRepository(ctx context.Context, projectPath string) (string, error)
 
 	// Create the command which reads branches from repository
 	// ProjectPath is a path to project with VCS
 	// Result is a channel, which get branches line-by-line
 	// To start read run executor Run method
-	ReadBranches(projectPath string, result chan Branch) *Executor
+	ReadBranches(ctx context.Context, projectPath string, result chan Branch) *Executor
 
 	// Create the command which reads commit from repository by commit id
 	// ProjectPath is a path to project with VCS
 	// CommitId is a commit identifier
 	// Result is a channel, whic get commit result
 	// To start read run executor Run method
-	ReadCommit(projectPath string, commitId string, result chan Commit) *Executor
+	ReadCommit(ctx context.Context, projectPath string, commitId string, result chan Commit) *Executor
 
 	// Create the command which reads commits history from repository by commit id
 	// ProjectPath is a path to project with VCS
@@ -38,5 +45,46 @@ type Vcs interface {
 	// If need to read history of path in some branch, argument branch should contain branch identifier
 	// Offset is number of skipped commits
 	// Limit is number of maximum commits to read
-	ReadHistory(projectPath string, path string, branch string, offset int, limit int, result chan Commit) *Executor
+	ReadHistory(ctx context.Context, projectPath string, path string, branch string, offset int, limit int, result chan Commit) *Executor
+
+	// Create the command which reads commits history starting from the commits instead of branches
+	// ProjectPath is a path to project with VCS
+	// Path is a relative file or directory path, empty path means whole project
+	// CommitIds are full commit identifiers, result gets them and their ancestors in topological order:
+	// no commit goes before all its children, the order of unrelated commits depends on the backend
+	// If path isn't empty, parents of the commits are rewritten to the nearest ancestors which change the path
+	// Limit is number of maximum commits to read (negative limit means no limit)
+	ReadHistoryFrom(ctx context.Context, projectPath string, path string, commitIds []string, limit int, result chan Commit) *Executor
+
+	// Create the command which reads file content at some revision
+	// ProjectPath is a path to project with VCS
+	// Revision is a branch or commit identifier
+	// Pathname is a relative file pathname
+	// Result is a channel, which get the blob, it gets nothing if file not found at revision
+	// Not resolved revision fails the executor with ErrRevisionNotFound or ErrAmbiguousRevision error
+	// To start read run executor Run method
+	ReadBlob(ctx context.Context, projectPath string, revision string, pathname string, result chan Blob) *Executor
+
+	// Create the command which reads files tree at some revision
+	// ProjectPath is a path to project with VCS
+	// Revision is a branch or commit identifier
+	// Path is a relative directory path, empty path means the project root
+	// If recursive is true, result gets files of all subdirectories (without directories itself)
+	// Result is a channel, which get files one-by-one, it gets nothing if the path is a file
+	// Not resolved revision fails the executor with ErrRevisionNotFound or ErrAmbiguousRevision error, not existent path - with ErrPathNotFound
+	// To start read run executor Run method
+	ReadTree(ctx context.Context, projectPath string, revision string, path string, recursive bool, result chan File) *Executor
+
+	// Create the command which reads tags from repository
+	// ProjectPath is a path to project with VCS
+	// Result is a channel, which get tags one-by-one
+	// To start read run executor Run method
+	ReadTags(ctx context.Context, projectPath string, result chan Tag) *Executor
+
+	// Create the command which reads changes of the commit comparing with its first parent
+	// ProjectPath is a path to project with VCS
+	// CommitId is a commit identifier
+	// Result is a channel, which get changes file-by-file
+	// To start read run executor Run method
+	ReadDiff(ctx context.Context, projectPath string, commitId string, result chan FileDiff) *Executor
 }
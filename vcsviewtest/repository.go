@@ -0,0 +1,629 @@
+package vcsviewtest
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kalyabin/vcsview"
+)
+
+// Date of the first declared commit or tag, next ones are one minute later each
+var startTime = time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Pattern of the revision with ~N and ^N suffixes
+var revisionPattern = regexp.MustCompile(`^(.*?)((?:[~^][0-9]*)*)$`)
+
+// Pattern of the short or full commit identifier
+var commitIdPattern = regexp.MustCompile(`^[0-9a-f]{4,40}$`)
+
+// Files changes of the commit: pathname to the new content, nil content deletes the file
+type Files map[string][]byte
+
+// Declared commit with the whole project files
+type commit struct {
+	model vcsview.Commit
+
+	// File contents by pathnames
+	files map[string][]byte
+}
+
+// Returns state of the path: pathnames and blob identifiers of the files under the path
+// Empty state means the path doesn't exist
+func (c commit) pathState(path string) string {
+	pathnames := make([]string, 0)
+	for pathname := range c.files {
+		if pathname == path || strings.HasPrefix(pathname, path+"/") {
+			pathnames = append(pathnames, pathname)
+		}
+	}
+	sort.Strings(pathnames)
+
+	state := ""
+	for _, pathname := range pathnames {
+		state += pathname + "\x00" + vcsview.GitBlobId(c.files[pathname]) + "\n"
+	}
+
+	return state
+}
+
+// In-memory repository where tests declare branches, commits and tags
+// Declaration methods panic on unknown revisions like regexp.MustCompile does on invalid expressions
+// Safe for concurrent use
+type Repository struct {
+	mu sync.RWMutex
+
+	// Commits by full identifiers
+	commits map[string]commit
+
+	// Branch heads by branch names
+	branches map[string]string
+
+	// Current branch name (the first declared branch by default)
+	current string
+
+	// Tags by names
+	tags map[string]vcsview.Tag
+
+	// Author of the next commits
+	author vcsview.Contributor
+
+	// Date of the next commit or annotated tag
+	now time.Time
+}
+
+// Create empty repository without branches
+func newRepository() *Repository {
+	return &Repository{
+		commits: make(map[string]commit),
+		branches: make(map[string]string),
+		tags: make(map[string]vcsview.Tag),
+		author: vcsview.NewContributor("Test", "test@example.com"),
+		now: startTime,
+	}
+}
+
+// Set author of the next commits
+func (r *Repository) SetAuthor(name string, email string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.author = vcsview.NewContributor(name, email)
+}
+
+// Set date of the next commit or annotated tag, following ones are one minute later each
+func (r *Repository) SetTime(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.now = t
+}
+
+// Returns date of the next declaration and moves the clock
+func (r *Repository) tick() time.Time {
+	t := r.now
+	r.now = r.now.Add(time.Minute)
+
+	return t
+}
+
+// Commit file changes on top of the branch and returns full commit identifier
+// Not existent branch is created with the root commit, the first created branch becomes current
+func (r *Repository) Commit(branch string, message string, files Files) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var parents []string
+	if head, ok := r.branches[branch]; ok {
+		parents = []string{head}
+	}
+
+	return r.commit(branch, message, parents, files)
+}
+
+// Merge the revision into the branch and returns full commit identifier
+// Merge commit takes changes of the revision since the merge base for files which the branch doesn't change,
+// then the file changes are applied (for example, to resolve conflicts)
+func (r *Repository) Merge(branch string, revision string, message string, files Files) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	head, ok := r.branches[branch]
+	if !ok {
+		panic(fmt.Errorf("Branch %s not found", branch))
+	}
+
+	id, err := r.resolve(revision)
+	if err != nil {
+		panic(err)
+	}
+
+	base, ours, theirs := r.commits[r.mergeBase(head, id)].files, r.commits[head].files, r.commits[id].files
+
+	merged := make(Files)
+	for _, side := range []map[string][]byte{ours, theirs} {
+		for pathname := range side {
+			if isSameFile(base, ours, pathname) && !isSameFile(base, theirs, pathname) {
+				merged[pathname] = theirs[pathname]
+			}
+		}
+	}
+	for pathname, content := range files {
+		merged[pathname] = content
+	}
+
+	return r.commit(branch, message, []string{head, id}, merged)
+}
+
+// Returns true if the file has the same content in both snapshots or both snapshots don't have it
+func isSameFile(a map[string][]byte, b map[string][]byte, pathname string) bool {
+	contentA, okA := a[pathname]
+	contentB, okB := b[pathname]
+
+	return okA == okB && string(contentA) == string(contentB)
+}
+
+// Returns the newest common ancestor of two commits or empty string if they have no common history
+func (r *Repository) mergeBase(a string, b string) string {
+	ancestors := make(map[string]bool)
+	r.walk(context.Background(), []string{a}, "", func(c commit) bool {
+		ancestors[c.model.Id()] = true
+		return true
+	})
+
+	base := ""
+	r.walk(context.Background(), []string{b}, "", func(c commit) bool {
+		if ancestors[c.model.Id()] {
+			base = c.model.Id()
+			return false
+		}
+		return true
+	})
+
+	return base
+}
+
+// Create commit with the file changes on top of the first parent and move the branch to it
+func (r *Repository) commit(branch string, message string, parents []string, files Files) string {
+	snapshot := make(map[string][]byte)
+	if len(parents) > 0 {
+		for pathname, content := range r.commits[parents[0]].files {
+			snapshot[pathname] = content
+		}
+	}
+
+	for pathname, content := range files {
+		pathname = strings.Trim(pathname, "/")
+		if content == nil {
+			delete(snapshot, pathname)
+		} else {
+			snapshot[pathname] = append([]byte{}, content...)
+		}
+	}
+
+	c := commit{files: snapshot}
+	date := r.tick()
+
+	// identifier depends on the commit contents like the git one does
+	h := sha1.New()
+	fmt.Fprintf(h, "tree %s\n", c.pathState(""))
+	for _, parent := range parents {
+		fmt.Fprintf(h, "parent %s\n", parent)
+	}
+	fmt.Fprintf(h, "author %s %d\n\n%s", r.author, date.Unix(), message)
+	id := hex.EncodeToString(h.Sum(nil))
+
+	// root commit has single empty parent like the Git backend returns
+	if len(parents) == 0 {
+		parents = []string{""}
+	}
+
+	c.model = vcsview.NewCommit(id, date, r.author, subject(message), parents)
+	r.commits[id] = c
+
+	r.branches[branch] = id
+	if r.current == "" {
+		r.current = branch
+	}
+
+	return id
+}
+
+// Returns subject of the message: the first paragraph joined into one line
+func subject(message string) string {
+	lines := make([]string, 0, 1)
+
+	for _, line := range strings.Split(strings.TrimLeft(message, "\n"), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, " ")
+}
+
+// Create branch or move it to the revision
+func (r *Repository) Branch(name string, revision string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, err := r.resolve(revision)
+	if err != nil {
+		panic(err)
+	}
+
+	r.branches[name] = id
+	if r.current == "" {
+		r.current = name
+	}
+}
+
+// Make the branch current, so HEAD points to it
+func (r *Repository) Checkout(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.branches[name]; !ok {
+		panic(fmt.Errorf("Branch %s not found", name))
+	}
+
+	r.current = name
+}
+
+// Create tag of the revision
+// Empty message means lightweight tag with date and message of the commit
+func (r *Repository) Tag(name string, revision string, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, err := r.resolve(revision)
+	if err != nil {
+		panic(err)
+	}
+
+	c := r.commits[id].model
+	if message == "" {
+		r.tags[name] = vcsview.NewTag(name, id, c.Date(), c.Message())
+		return
+	}
+
+	r.tags[name] = vcsview.NewTag(name, id, r.tick(), subject(message))
+}
+
+// Returns full commit identifier of the revision: commit identifier (or short copy), branch or tag name,
+// HEAD or ref name with ~N and ^N suffixes
+func (r *Repository) resolve(revision string) (string, error) {
+	matches := revisionPattern.FindStringSubmatch(revision)
+
+	id, err := r.resolveName(matches[1])
+	if err != nil {
+		return "", err
+	}
+
+	for suffix := matches[2]; suffix != ""; {
+		op := suffix[0]
+
+		end := 1
+		for end < len(suffix) && suffix[end] != '~' && suffix[end] != '^' {
+			end++
+		}
+
+		n := 1
+		if end > 1 {
+			n, _ = strconv.Atoi(suffix[1:end])
+		}
+		suffix = suffix[end:]
+
+		switch {
+		case op == '~':
+			for ; n > 0 && id != ""; n-- {
+				id = r.commits[id].model.Parents()[0]
+			}
+		case n > 0:
+			parents := r.commits[id].model.Parents()
+			id = ""
+			if n <= len(parents) {
+				id = parents[n-1]
+			}
+		}
+
+		if id == "" {
+			return "", vcsview.ErrRevisionNotFound
+		}
+	}
+
+	return id, nil
+}
+
+// Returns full commit identifier of the revision without suffixes
+func (r *Repository) resolveName(name string) (string, error) {
+	if name == "" || name == "HEAD" {
+		name = "refs/heads/" + r.current
+	}
+
+	if _, ok := r.commits[name]; ok {
+		return name, nil
+	}
+
+	if id, ok := r.branches[strings.TrimPrefix(name, "refs/heads/")]; ok {
+		return id, nil
+	}
+
+	if t, ok := r.tags[strings.TrimPrefix(name, "refs/tags/")]; ok {
+		return t.Head(), nil
+	}
+
+	if !commitIdPattern.MatchString(name) {
+		return "", vcsview.ErrRevisionNotFound
+	}
+
+	found := ""
+	for id := range r.commits {
+		if !strings.HasPrefix(id, name) {
+			continue
+		}
+		if found != "" {
+			return "", vcsview.ErrAmbiguousRevision
+		}
+		found = id
+	}
+
+	if found == "" {
+		return "", vcsview.ErrRevisionNotFound
+	}
+
+	return found, nil
+}
+
+// Returns changes of the commit comparing with its first parent sorted by pathnames
+func (r *Repository) diff(revision string) ([]vcsview.FileDiff, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, err := r.resolve(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	c := r.commits[id]
+
+	return diffFiles(r.commits[c.model.Parents()[0]].files, c.files), nil
+}
+
+// Returns changed files between two snapshots sorted by pathnames
+// Deleted and added files with the same content are renamed ones
+func diffFiles(previousFiles map[string][]byte, files map[string][]byte) []vcsview.FileDiff {
+	var added, deleted []string
+
+	// previous pathnames of the changed files by current ones (deleted files have the same pathnames)
+	changes := make(map[string]string)
+
+	for pathname, content := range files {
+		previousContent, ok := previousFiles[pathname]
+		switch {
+		case !ok:
+			added = append(added, pathname)
+		case string(previousContent) != string(content):
+			changes[pathname] = pathname
+		}
+	}
+
+	for pathname := range previousFiles {
+		if _, ok := files[pathname]; !ok {
+			deleted = append(deleted, pathname)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(deleted)
+
+	for _, pathname := range added {
+		changes[pathname] = ""
+
+		for i, previousPathname := range deleted {
+			if string(previousFiles[previousPathname]) == string(files[pathname]) {
+				changes[pathname] = previousPathname
+				deleted = append(deleted[:i], deleted[i+1:]...)
+				break
+			}
+		}
+	}
+
+	for _, pathname := range deleted {
+		changes[pathname] = pathname
+	}
+
+	pathnames := make([]string, 0, len(changes))
+	for pathname := range changes {
+		pathnames = append(pathnames, pathname)
+	}
+	sort.Strings(pathnames)
+
+	diffs := make([]vcsview.FileDiff, 0, len(pathnames))
+	for _, pathname := range pathnames {
+		previousPathname := changes[pathname]
+		if _, ok := files[pathname]; !ok {
+			pathname = ""
+		}
+
+		diffs = append(diffs, vcsview.NewFileDiffFromContents(previousPathname, pathname, previousFiles[previousPathname], files[pathname]))
+	}
+
+	return diffs
+}
+
+// Check the commit changes the path and returns parents to follow
+// Commit which has the same path state as one of its parents follows this parent only
+// Empty path means all commits are shown and follow all parents
+func (r *Repository) simplify(c commit, path string) (bool, []string) {
+	parents := c.model.Parents()
+	if len(parents) == 1 && parents[0] == "" {
+		parents = nil
+	}
+
+	if path == "" {
+		return true, parents
+	}
+
+	state := c.pathState(path)
+	show := state != "" && len(parents) == 0
+
+	for _, id := range parents {
+		if r.commits[id].pathState(path) == state {
+			// the same path state: follow this parent only
+			return false, []string{id}
+		}
+
+		show = true
+	}
+
+	return show, parents
+}
+
+// Rewrite parents of the commit to the nearest ancestors which change the path (like git log --parents)
+// Commit without such ancestors has single empty parent
+func (r *Repository) rewriteParents(c commit, path string) []string {
+	_, parents := r.simplify(c, path)
+
+	rewritten := make([]string, 0, len(parents))
+	seen := make(map[string]bool)
+
+	for _, id := range parents {
+		for id != "" {
+			show, next := r.simplify(r.commits[id], path)
+
+			if show {
+				if !seen[id] {
+					seen[id] = true
+					rewritten = append(rewritten, id)
+				}
+				break
+			}
+
+			// not shown commit follows the single parent or has no parents
+			id = ""
+			if len(next) > 0 {
+				id = next[0]
+			}
+		}
+	}
+
+	if len(rewritten) == 0 {
+		rewritten = []string{""}
+	}
+
+	return rewritten
+}
+
+// Walk commits from the start ones by commit date from the newest one
+// If path isn't empty, commits which don't change the path are skipped
+// and merges follow the parent with the same path state only (like git log history simplification)
+// Visit function returns false to stop walking, context cancellation stops it with the context error
+func (r *Repository) walk(ctx context.Context, starts []string, path string, visit func(c commit) bool) error {
+	queue := make([]string, 0, len(starts))
+	seen := make(map[string]bool)
+
+	push := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			queue = append(queue, id)
+		}
+	}
+
+	for _, id := range starts {
+		push(id)
+	}
+
+	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// the newest commit, the earlier queued one goes first for the same dates
+		newest := 0
+		for i := range queue {
+			if r.commits[queue[i]].model.Date().After(r.commits[queue[newest]].model.Date()) {
+				newest = i
+			}
+		}
+
+		c := r.commits[queue[newest]]
+		queue = append(queue[:newest], queue[newest+1:]...)
+
+		show, parents := r.simplify(c, path)
+
+		if show && !visit(c) {
+			return nil
+		}
+
+		for _, id := range parents {
+			push(id)
+		}
+	}
+
+	return nil
+}
+
+// Returns heads of the branches which names contain the branch (all branches if it's empty)
+// Branches are sorted by names like refs are
+func (r *Repository) heads(branch string) []string {
+	names := make([]string, 0, len(r.branches))
+	for name := range r.branches {
+		if matchGlob("*"+branch+"*", name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	heads := make([]string, 0, len(names))
+	for _, name := range names {
+		heads = append(heads, r.branches[name])
+	}
+
+	return heads
+}
+
+// Match branch name by glob pattern, wildcards match slashes too (like --branches option does)
+func matchGlob(pattern string, name string) bool {
+	expr := "^"
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			expr += ".*"
+		case '?':
+			expr += "."
+		case '[':
+			if end := strings.IndexByte(pattern[i:], ']'); end > 0 {
+				expr += pattern[i : i+end+1]
+				i += end
+				continue
+			}
+			expr += `\[`
+		default:
+			expr += regexp.QuoteMeta(string(c))
+		}
+	}
+
+	matched, err := regexp.MatchString(expr+"$", name)
+
+	return err == nil && matched
+}
+
+// Returns normalized project path like vcsview.NewRepository does
+func projectKey(projectPath string) string {
+	if abs, err := filepath.Abs(projectPath); err == nil {
+		projectPath = abs
+	}
+
+	return filepath.Clean(projectPath)
+}
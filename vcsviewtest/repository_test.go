@@ -0,0 +1,169 @@
+package vcsviewtest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kalyabin/vcsview"
+)
+
+func TestRepository_Merge(t *testing.T) {
+	r := newRepository()
+
+	initial := r.Commit("master", "Initial commit", Files{"a": []byte("a\n"), "b": []byte("b\n"), "c": []byte("c\n")})
+	r.Branch("side", "master")
+	r.Commit("side", "Side changes", Files{"a": []byte("side\n"), "b": nil, "d": []byte("d\n")})
+	r.Commit("master", "Master changes", Files{"c": []byte("master\n"), "a": []byte("master\n")})
+
+	if base := r.mergeBase(r.branches["master"], r.branches["side"]); base != initial {
+		t.Errorf("Repository.mergeBase(master, side) = %v, want: %v", base, initial)
+	}
+
+	id := r.Merge("master", "side", "Merge side", Files{"e": []byte("e\n")})
+
+	want := map[string]string{"a": "master\n", "c": "master\n", "d": "d\n", "e": "e\n"}
+	files := r.commits[id].files
+
+	if len(files) != len(want) {
+		t.Errorf("Repository.Merge() files = %v, want: %v", files, want)
+	}
+
+	for pathname, content := range want {
+		if string(files[pathname]) != content {
+			t.Errorf("Repository.Merge() file %s = %q, want: %q", pathname, files[pathname], content)
+		}
+	}
+}
+
+func TestRepository_Declarations(t *testing.T) {
+	r := newRepository()
+	r.SetAuthor("Author", "author@example.com")
+	r.SetTime(time.Date(2020, time.May, 1, 10, 0, 0, 0, time.UTC))
+
+	first := r.Commit("develop", "\nFirst\nline\n\nDescription", Files{"/dir/file.txt": []byte("file\n")})
+	second := r.Commit("develop", "Second", nil)
+
+	if first == second || len(first) != 40 {
+		t.Errorf("Repository.Commit() = %v, %v, want different full identifiers", first, second)
+	}
+
+	c := r.commits[first].model
+	if c.Message() != "First line" || c.Author().Email() != "author@example.com" || !c.Date().Equal(time.Date(2020, time.May, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Repository.Commit() = %v, want commit of the declared author, date and subject", c)
+	}
+
+	if _, ok := r.commits[first].files["dir/file.txt"]; !ok || r.current != "develop" {
+		t.Errorf("Repository.Commit() files = %v, current branch = %v, want dir/file.txt at develop", r.commits[first].files, r.current)
+	}
+
+	if d := r.commits[second].model.Date().Sub(c.Date()); d != time.Minute {
+		t.Errorf("Repository.Commit() date difference = %v, want: %v", d, time.Minute)
+	}
+
+	for key, declare := range []func(){
+		func() { r.Branch("feature", "unknown") },
+		func() { r.Checkout("unknown") },
+		func() { r.Tag("v1", "unknown", "") },
+		func() { r.Merge("unknown", "develop", "Merge", nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("[%d] declaration of unknown revision doesn't panic", key)
+				}
+			}()
+
+			declare()
+		}()
+	}
+}
+
+func TestRepository_Resolve(t *testing.T) {
+	r := newRepository()
+
+	if _, err := r.resolve("HEAD"); err != vcsview.ErrRevisionNotFound {
+		t.Errorf("Repository.resolve(HEAD) of empty repository got error: %v, want: %v", err, vcsview.ErrRevisionNotFound)
+	}
+
+	// commits which identifiers have the same first 4 digits
+	prefixes := make(map[string]string)
+	prefix := ""
+
+	for i := 0; prefix == ""; i++ {
+		id := r.Commit("master", strings.Repeat("commit ", i+1), nil)
+		if _, ok := prefixes[id[:4]]; ok {
+			prefix = id[:4]
+		}
+		prefixes[id[:4]] = id
+	}
+
+	cases := []struct{
+		revision string
+		want string
+		wantErr error
+	}{
+		{prefix, "", vcsview.ErrAmbiguousRevision},
+		{prefixes[prefix][:10], prefixes[prefix], nil},
+		{prefixes[prefix][:3], "", vcsview.ErrRevisionNotFound},
+		{"master~1^0~1", r.commits[r.commits[r.branches["master"]].model.Parents()[0]].model.Parents()[0], nil},
+	}
+
+	for key, testCase := range cases {
+		if id, err := r.resolve(testCase.revision); id != testCase.want || err != testCase.wantErr {
+			t.Errorf("[%d] Repository.resolve(%s) = %v, %v, want: %v, %v", key, testCase.revision, id, err, testCase.want, testCase.wantErr)
+		}
+	}
+}
+
+func TestDiffFiles(t *testing.T) {
+	previousFiles := map[string][]byte{
+		"deleted.txt": []byte("deleted\n"),
+		"modified.txt": []byte("first\n"),
+		"moved.txt": []byte("moved\n"),
+		"same.txt": []byte("same\n"),
+	}
+
+	files := map[string][]byte{
+		"added.txt": []byte("added\n"),
+		"dir/moved.txt": []byte("moved\n"),
+		"modified.txt": []byte("second\n"),
+		"same.txt": []byte("same\n"),
+	}
+
+	want := []string{"A added.txt", "D deleted.txt", "R moved.txt dir/moved.txt", "M modified.txt"}
+
+	got := make([]string, 0)
+	for _, d := range diffFiles(previousFiles, files) {
+		s := string(d.Status()) + " "
+		if d.PreviousPathname() != "" {
+			s += d.PreviousPathname() + " "
+		}
+		got = append(got, s+d.Pathname())
+	}
+
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("diffFiles() = %v, want: %v", got, want)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct{
+		pattern string
+		name string
+		want bool
+	}{
+		{"*", "master", true},
+		{"*feature*", "feature/login", true},
+		{"*feature*", "master", false},
+		{"*v1.0*", "v1x0", false},
+		{"*release-[0-9]*", "release-1", true},
+		{"*fix?*", "fix", false},
+	}
+
+	for key, testCase := range cases {
+		if matched := matchGlob(testCase.pattern, testCase.name); matched != testCase.want {
+			t.Errorf("[%d] matchGlob(%q, %q) = %v, want: %v", key, testCase.pattern, testCase.name, matched, testCase.want)
+		}
+	}
+}
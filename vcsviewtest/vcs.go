@@ -0,0 +1,393 @@
+// Package vcsviewtest provides an in-memory fake of vcsview.Vcs for tests of the packages which use vcsview
+// Tests declare repositories with branches, commits, files and tags,
+// then read them by vcsview.Repository, cursors or executors without git binary and repositories on disk
+package vcsviewtest
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kalyabin/vcsview"
+)
+
+// Version of the fake VCS
+const fakeVersion = "fake"
+
+// Length of the branch heads like git branch -v prints them
+const abbrevLength = 7
+
+// In-memory fake VCS with repositories by project paths
+// Executors run functions instead of commands and stream results in the same order and format as the Git backend
+// Errors have the same kinds (vcsview.ErrorKind) as the Git backend ones
+// Renames are detected for files with the same content only
+// Safe for concurrent use
+type Vcs struct {
+	mu sync.Mutex
+
+	// Declared repositories by project paths
+	repositories map[string]*Repository
+
+	// Debug function which fixes the log messages
+	Debugger vcsview.DebugFunc
+}
+
+// Create fake VCS without repositories
+func New() *Vcs {
+	return &Vcs{repositories: make(map[string]*Repository)}
+}
+
+// Get repository at the project path, it's created empty if it doesn't exist
+func (v *Vcs) Repository(projectPath string) *Repository {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key := projectKey(projectPath)
+
+	r, ok := v.repositories[key]
+	if !ok {
+		r = newRepository()
+		v.repositories[key] = r
+	}
+
+	return r
+}
+
+// Get declared repository at the project path
+func (v *Vcs) open(projectPath string) (*Repository, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	r, ok := v.repositories[projectKey(projectPath)]
+	if !ok {
+		return nil, vcsview.ErrRepositoryNotFound
+	}
+
+	return r, nil
+}
+
+// Create executor which runs function with the declared repository locked for reading
+// Results should be sent after unlock, so declarations don't wait for slow readers
+func (v *Vcs) executor(ctx context.Context, name string, projectPath string, run func(r *Repository) error) *vcsview.Executor {
+	return vcsview.NewFuncExecutor(ctx, "fake "+name+" "+projectPath, func() error {
+		r, err := v.open(projectPath)
+		if err != nil {
+			return err
+		}
+
+		return run(r)
+	}, v.Debugger)
+}
+
+// Returns fake VCS version
+func (v *Vcs) Version(ctx context.Context) (string, error) {
+	return fakeVersion, nil
+}
+
+// Returns repository settings pathname
+func (v *Vcs) RepositoryPathname() string {
+	return ".git"
+}
+
+// Returns error if repository isn't declared at provided projectPath
+func (v *Vcs) CheckRepository(projectPath string) error {
+	_, err := v.open(projectPath)
+	return err
+}
+
+// Fake repositories have no working tree, so status is always empty
+func (v *Vcs) StatusRepository(ctx context.Context, projectPath string) (string, error) {
+	_, err := v.open(projectPath)
+	return "", err
+}
+
+// Fetch repository branches sorted by names with abbreviated heads
+func (v *Vcs) ReadBranches(ctx context.Context, projectPath string, result chan vcsview.Branch) *vcsview.Executor {
+	return v.executor(ctx, "branches", projectPath, func(r *Repository) error {
+		r.mu.RLock()
+
+		names := make([]string, 0, len(r.branches))
+		for name := range r.branches {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		branches := make([]vcsview.Branch, 0, len(names))
+		for _, name := range names {
+			branches = append(branches, vcsview.NewBranch(name, r.branches[name][:abbrevLength], name == r.current))
+		}
+
+		r.mu.RUnlock()
+
+		for _, b := range branches {
+			select {
+			case result <- b:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		return nil
+	})
+}
+
+// Fetch repository commit by identifier, short identifier or ref name with ~N and ^N suffixes
+func (v *Vcs) ReadCommit(ctx context.Context, projectPath string, commitId string, result chan vcsview.Commit) *vcsview.Executor {
+	return v.executor(ctx, "commit "+commitId, projectPath, func(r *Repository) error {
+		r.mu.RLock()
+		id, err := r.resolve(commitId)
+		c := r.commits[id]
+		r.mu.RUnlock()
+
+		if err != nil {
+			return err
+		}
+
+		select {
+		case result <- c.model:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Send walked commits to the result skipping offset ones
+// Zero limit means no commits, negative one means all commits
+// Topological order (parents are rewritten if path isn't empty) needs the whole history, so it's walked before skipping
+func (v *Vcs) sendHistory(ctx context.Context, r *Repository, starts []string, path string, offset int, limit int, topo bool, result chan vcsview.Commit) error {
+	if limit == 0 {
+		return nil
+	}
+
+	r.mu.RLock()
+
+	commits := make([]vcsview.Commit, 0)
+	err := r.walk(ctx, starts, path, func(c commit) bool {
+		if topo {
+			model := c.model
+			if path != "" {
+				model = vcsview.NewCommit(model.Id(), model.Date(), model.Author(), model.Message(), r.rewriteParents(c, path))
+			}
+
+			commits = append(commits, model)
+			return true
+		}
+
+		if offset > 0 {
+			offset--
+			return true
+		}
+
+		commits = append(commits, c.model)
+
+		return limit < 0 || len(commits) < limit
+	})
+
+	r.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	if topo {
+		commits = vcsview.SortCommitsTopo(commits)
+		if limit >= 0 && len(commits) > limit {
+			commits = commits[:limit]
+		}
+	}
+
+	for _, c := range commits {
+		select {
+		case result <- c:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Fetch commits of the branches which names contain the branch (all branches if it's empty) from the newest one
+// If path isn't empty, commits which don't change the path are skipped like git log does
+func (v *Vcs) ReadHistory(ctx context.Context, projectPath string, path string, branch string, offset int, limit int, result chan vcsview.Commit) *vcsview.Executor {
+	path = strings.Trim(path, "/")
+
+	return v.executor(ctx, "history "+branch+":"+path, projectPath, func(r *Repository) error {
+		r.mu.RLock()
+		starts := r.heads(branch)
+		r.mu.RUnlock()
+
+		return v.sendHistory(ctx, r, starts, path, offset, limit, false, result)
+	})
+}
+
+// Fetch commits starting from the full commit identifiers in topological order
+// If path isn't empty, parents are rewritten to the nearest ancestors which change the path
+func (v *Vcs) ReadHistoryFrom(ctx context.Context, projectPath string, path string, commitIds []string, limit int, result chan vcsview.Commit) *vcsview.Executor {
+	path = strings.Trim(path, "/")
+
+	return v.executor(ctx, "history "+strings.Join(commitIds, " ")+":"+path, projectPath, func(r *Repository) error {
+		r.mu.RLock()
+		for _, id := range commitIds {
+			if _, ok := r.commits[id]; !ok {
+				r.mu.RUnlock()
+				return vcsview.ErrRevisionNotFound
+			}
+		}
+		r.mu.RUnlock()
+
+		return v.sendHistory(ctx, r, commitIds, path, 0, limit, true, result)
+	})
+}
+
+// Fetch file content at revision
+// Result gets nothing if file not found at revision, not resolved revision fails the executor
+func (v *Vcs) ReadBlob(ctx context.Context, projectPath string, revision string, pathname string, result chan vcsview.Blob) *vcsview.Executor {
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	pathname = strings.TrimLeft(pathname, "/")
+
+	return v.executor(ctx, "blob "+revision+":"+pathname, projectPath, func(r *Repository) error {
+		r.mu.RLock()
+		id, err := r.resolve(revision)
+		content, found := r.commits[id].files[pathname]
+		r.mu.RUnlock()
+
+		if err != nil || !found {
+			return err
+		}
+
+		select {
+		case result <- vcsview.NewBlob(vcsview.GitBlobId(content), pathname, content):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Fetch files tree at revision in the git tree order
+// Path is a relative directory path, empty path means the project root
+// If recursive is true, result gets files of all subdirectories (without directories itself)
+// Result gets nothing if the path is a file, not existent path fails the executor with ErrPathNotFound error
+func (v *Vcs) ReadTree(ctx context.Context, projectPath string, revision string, path string, recursive bool, result chan vcsview.File) *vcsview.Executor {
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	path = strings.Trim(path, "/")
+
+	return v.executor(ctx, "tree "+revision+":"+path, projectPath, func(r *Repository) error {
+		r.mu.RLock()
+		id, err := r.resolve(revision)
+		snapshot := r.commits[id].files
+		r.mu.RUnlock()
+
+		if err != nil {
+			return err
+		}
+
+		prefix := ""
+		if path != "" {
+			prefix = path + "/"
+		}
+
+		// directories are sorted as their names with trailing slash like in git trees
+		keys := make([]string, 0)
+		sizes := make(map[string]int64)
+
+		for pathname, content := range snapshot {
+			if !strings.HasPrefix(pathname, prefix) {
+				continue
+			}
+
+			name := pathname[len(prefix):]
+			if pos := strings.IndexByte(name, '/'); pos >= 0 && !recursive {
+				name = name[:pos+1]
+			}
+
+			if _, ok := sizes[name]; !ok {
+				keys = append(keys, name)
+			}
+			sizes[name] = int64(len(content))
+		}
+		sort.Strings(keys)
+
+		if _, isFile := snapshot[path]; len(keys) == 0 && path != "" && !isFile {
+			return vcsview.ErrPathNotFound
+		}
+
+		for _, key := range keys {
+			isDir := strings.HasSuffix(key, "/")
+
+			f := vcsview.NewFileFromTree(prefix+key, false, sizes[key], 0644)
+			if isDir {
+				f = vcsview.NewFileFromTree(prefix+strings.TrimSuffix(key, "/"), true, 0, os.ModeDir|0755)
+			}
+
+			select {
+			case result <- f:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		return nil
+	})
+}
+
+// Fetch repository tags sorted by creation date from the newest one
+func (v *Vcs) ReadTags(ctx context.Context, projectPath string, result chan vcsview.Tag) *vcsview.Executor {
+	return v.executor(ctx, "tags", projectPath, func(r *Repository) error {
+		r.mu.RLock()
+
+		tags := make([]vcsview.Tag, 0, len(r.tags))
+		for _, t := range r.tags {
+			tags = append(tags, t)
+		}
+
+		r.mu.RUnlock()
+
+		sort.Slice(tags, func(i, j int) bool {
+			if !tags[i].Date().Equal(tags[j].Date()) {
+				return tags[i].Date().After(tags[j].Date())
+			}
+			return tags[i].Id() < tags[j].Id()
+		})
+
+		for _, t := range tags {
+			select {
+			case result <- t:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		return nil
+	})
+}
+
+// Fetch changes of the commit comparing with its first parent sorted by pathnames
+func (v *Vcs) ReadDiff(ctx context.Context, projectPath string, commitId string, result chan vcsview.FileDiff) *vcsview.Executor {
+	return v.executor(ctx, "diff "+commitId, projectPath, func(r *Repository) error {
+		diffs, err := r.diff(commitId)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range diffs {
+			select {
+			case result <- d:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,395 @@
+package vcsviewtest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/kalyabin/vcsview"
+)
+
+const projectPath = "/fake/project"
+
+// Declare repository with feature branch merged into master and tags
+// Returns fake VCS and commit identifiers by messages
+func makeFake(t *testing.T) (*Vcs, map[string]string) {
+	v := New()
+	r := v.Repository(projectPath)
+
+	ids := make(map[string]string)
+
+	ids["Initial commit"] = r.Commit("master", "Initial commit", Files{
+		"README.md": []byte("# Project\n"),
+		"TODO": []byte("docs\n"),
+		"src/main.go": []byte("package main\n"),
+	})
+	r.Tag("v0.1", "master", "")
+
+	ids["Add docs"] = r.Commit("master", "Add docs", Files{"docs/index.md": []byte("Docs\n")})
+	r.Tag("v1.0", "master", "Release 1.0\n\nFirst release")
+
+	r.Branch("feature", "master")
+	ids["Change main"] = r.Commit("feature", "Change main", Files{"src/main.go": []byte("package main\n\nfunc main() {}\n")})
+	ids["Update readme"] = r.Commit("master", "Update readme", Files{"README.md": []byte("# Project\n\nAbout\n"), "TODO": nil})
+	ids["Merge feature"] = r.Merge("master", "feature", "Merge feature", nil)
+
+	return v, ids
+}
+
+// Create repository with the fake VCS
+func makeRepository(t *testing.T, v *Vcs) vcsview.Repository {
+	r, err := vcsview.NewRepository(projectPath, v)
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", projectPath, err)
+	}
+
+	return r
+}
+
+// Returns messages of the commits
+func messages(commits []vcsview.Commit) []string {
+	result := make([]string, 0, len(commits))
+	for _, c := range commits {
+		result = append(result, c.Message())
+	}
+
+	return result
+}
+
+func TestVcs_Repository(t *testing.T) {
+	v, ids := makeFake(t)
+
+	if _, err := vcsview.NewRepository("/fake/unknown", v); vcsview.ErrorKind(err) != vcsview.ErrRepositoryNotFound {
+		t.Errorf("vcsview.NewRepository(/fake/unknown) got error: %v, want: %v", err, vcsview.ErrRepositoryNotFound)
+	}
+
+	r := makeRepository(t, v)
+
+	branches, err := r.Branches(context.Background())
+	want := []vcsview.Branch{
+		vcsview.NewBranch("feature", ids["Change main"][:7], false),
+		vcsview.NewBranch("master", ids["Merge feature"][:7], true),
+	}
+	if err != nil || fmt.Sprint(branches) != fmt.Sprint(want) {
+		t.Errorf("Repository.Branches() = %v, %v, want: %v", branches, err, want)
+	}
+
+	tags, err := r.Tags(context.Background())
+	if err != nil || len(tags) != 2 {
+		t.Fatalf("Repository.Tags() = %v, %v, want 2 tags", tags, err)
+	}
+
+	if tags[0].Id() != "v1.0" || tags[0].Head() != ids["Add docs"] || tags[0].Message() != "Release 1.0" {
+		t.Errorf("Repository.Tags()[0] = %v, want annotated v1.0 tag of %s", tags[0], ids["Add docs"])
+	}
+
+	if tags[1].Id() != "v0.1" || tags[1].Head() != ids["Initial commit"] || tags[1].Message() != "Initial commit" {
+		t.Errorf("Repository.Tags()[1] = %v, want lightweight v0.1 tag of %s", tags[1], ids["Initial commit"])
+	}
+}
+
+func TestVcs_ReadCommit(t *testing.T) {
+	v, ids := makeFake(t)
+	r := makeRepository(t, v)
+
+	cases := []struct{
+		revision string
+		want string
+		wantKind error
+	}{
+		{"HEAD", ids["Merge feature"], nil},
+		{"master~1", ids["Update readme"], nil},
+		{"HEAD^2", ids["Change main"], nil},
+		{"HEAD~3", ids["Initial commit"], nil},
+		{ids["Change main"][:7], ids["Change main"], nil},
+		{"refs/heads/feature", ids["Change main"], nil},
+		{"v1.0", ids["Add docs"], nil},
+		{"HEAD~4", "", vcsview.ErrRevisionNotFound},
+		{"HEAD^3", "", vcsview.ErrRevisionNotFound},
+		{"unknown", "", vcsview.ErrRevisionNotFound},
+	}
+
+	for key, testCase := range cases {
+		c, err := r.Commit(context.Background(), testCase.revision)
+		if c.Id() != testCase.want || vcsview.ErrorKind(err) != testCase.wantKind {
+			t.Errorf("[%d] Repository.Commit(%s) = %v, %v, want: %v, %v", key, testCase.revision, c.Id(), err, testCase.want, testCase.wantKind)
+		}
+	}
+
+	c, _ := r.Commit(context.Background(), "HEAD")
+	if parents := c.Parents(); len(parents) != 2 || parents[0] != ids["Update readme"] || parents[1] != ids["Change main"] {
+		t.Errorf("Repository.Commit(HEAD).Parents() = %v, want: [%s %s]", parents, ids["Update readme"], ids["Change main"])
+	}
+
+	c, _ = r.Commit(context.Background(), ids["Initial commit"])
+	if parents := c.Parents(); len(parents) != 1 || parents[0] != "" || c.Author().String() != "Test <test@example.com>" {
+		t.Errorf("Repository.Commit(%s) = %v, want root commit of the default author", ids["Initial commit"], c)
+	}
+}
+
+func TestVcs_ReadHistory(t *testing.T) {
+	v, _ := makeFake(t)
+	r := makeRepository(t, v)
+
+	cases := []struct{
+		path string
+		branch string
+		offset int
+		limit int
+		want []string
+	}{
+		{"", "", 0, 100, []string{"Merge feature", "Update readme", "Change main", "Add docs", "Initial commit"}},
+		{"", "feature", 0, 100, []string{"Change main", "Add docs", "Initial commit"}},
+		{"", "", 1, 2, []string{"Update readme", "Change main"}},
+		{"", "", 0, 0, []string{}},
+		{"src", "", 0, 100, []string{"Change main", "Initial commit"}},
+		{"/README.md", "", 0, 100, []string{"Update readme", "Initial commit"}},
+		{"docs", "master", 0, 100, []string{"Add docs"}},
+		{"unknown", "", 0, 100, []string{}},
+	}
+
+	for key, testCase := range cases {
+		commits, err := r.History(context.Background(), testCase.path, testCase.branch, testCase.offset, testCase.limit)
+		if got := messages(commits); err != nil || strings.Join(got, ",") != strings.Join(testCase.want, ",") {
+			t.Errorf("[%d] Repository.History(%s, %s, %d, %d) = %v, %v, want: %v", key, testCase.path, testCase.branch, testCase.offset, testCase.limit, got, err, testCase.want)
+		}
+	}
+
+	// pages go in topological order: commits of the merged branch follow the merge
+	pagesCases := []struct{
+		path string
+		want []string
+	}{
+		{"", []string{"Merge feature", "Change main", "Update readme", "Add docs", "Initial commit"}},
+		{"src", []string{"Change main", "Initial commit"}},
+	}
+
+	for key, testCase := range pagesCases {
+		path := testCase.path
+
+		got := make([]vcsview.Commit, 0)
+		token := ""
+
+		for pages := 0; pages < 10; pages++ {
+			commits, next, err := r.HistoryPage(context.Background(), path, "", token, 2)
+			if err != nil {
+				t.Fatalf("Repository.HistoryPage(%s, , %s, 2) got error: %v", path, token, err)
+			}
+
+			got = append(got, commits...)
+
+			if token = next; token == "" {
+				break
+			}
+		}
+
+		if strings.Join(messages(got), ",") != strings.Join(testCase.want, ",") {
+			t.Errorf("[%d] Repository.HistoryPage(%s, , , 2) pages = %v, want: %v", key, path, messages(got), testCase.want)
+		}
+	}
+}
+
+func TestVcs_ReadTree(t *testing.T) {
+	v, _ := makeFake(t)
+	r := makeRepository(t, v)
+
+	cases := []struct{
+		revision string
+		path string
+		recursive bool
+		want []string
+	}{
+		{"", "", false, []string{"README.md", "docs/", "src/"}},
+		{"", "", true, []string{"README.md", "docs/index.md", "src/main.go"}},
+		{"v0.1", "/", false, []string{"README.md", "TODO", "src/"}},
+		{"HEAD", "src", false, []string{"src/main.go"}},
+		{"HEAD", "README.md", false, []string{}},
+	}
+
+	for key, testCase := range cases {
+		files, err := r.ReadTree(context.Background(), testCase.revision, testCase.path, testCase.recursive)
+
+		got := make([]string, 0, len(files))
+		for _, f := range files {
+			if f.IsDir() {
+				got = append(got, f.Pathname()+"/")
+			} else {
+				got = append(got, f.Pathname())
+			}
+		}
+
+		if err != nil || strings.Join(got, ",") != strings.Join(testCase.want, ",") {
+			t.Errorf("[%d] Repository.ReadTree(%s, %s, %v) = %v, %v, want: %v", key, testCase.revision, testCase.path, testCase.recursive, got, err, testCase.want)
+		}
+	}
+
+	if _, err := r.ReadTree(context.Background(), "unknown", "", false); vcsview.ErrorKind(err) != vcsview.ErrRevisionNotFound {
+		t.Errorf("Repository.ReadTree(unknown) got error: %v, want: %v", err, vcsview.ErrRevisionNotFound)
+	}
+
+	if _, err := r.ReadTree(context.Background(), "HEAD", "unknown", true); vcsview.ErrorKind(err) != vcsview.ErrPathNotFound {
+		t.Errorf("Repository.ReadTree(HEAD, unknown) got error: %v, want: %v", err, vcsview.ErrPathNotFound)
+	}
+
+	blob, err := r.ReadBlob(context.Background(), "HEAD~1", "/src/main.go")
+	if err != nil || string(blob.Content()) != "package main\n" || blob.Id() != vcsview.GitBlobId(blob.Content()) || blob.Size() != 13 {
+		t.Errorf("Repository.ReadBlob(HEAD~1, /src/main.go) = %v, %v, want the first main.go version", blob, err)
+	}
+
+	if _, err := r.ReadBlob(context.Background(), "HEAD", "TODO"); !vcsview.IsNotFound(err) {
+		t.Errorf("Repository.ReadBlob(HEAD, TODO) got error: %v, want not found", err)
+	}
+}
+
+func TestVcs_ReadDiff(t *testing.T) {
+	v, ids := makeFake(t)
+	r := makeRepository(t, v)
+
+	cases := []struct{
+		revision string
+		want []string
+	}{
+		{ids["Initial commit"], []string{"A README.md", "A TODO", "A src/main.go"}},
+		{ids["Update readme"], []string{"M README.md", "D TODO"}},
+		{"master", []string{"M src/main.go"}},
+	}
+
+	for key, testCase := range cases {
+		diffs, err := r.Diff(context.Background(), testCase.revision)
+
+		got := make([]string, 0, len(diffs))
+		for _, d := range diffs {
+			got = append(got, string(d.Status())+" "+d.Pathname())
+		}
+
+		if err != nil || strings.Join(got, ",") != strings.Join(testCase.want, ",") {
+			t.Errorf("[%d] Repository.Diff(%s) = %v, %v, want: %v", key, testCase.revision, got, err, testCase.want)
+		}
+	}
+
+	if _, err := r.Diff(context.Background(), "unknown"); vcsview.ErrorKind(err) != vcsview.ErrRevisionNotFound {
+		t.Errorf("Repository.Diff(unknown) got error: %v, want: %v", err, vcsview.ErrRevisionNotFound)
+	}
+}
+
+func TestVcs_Cancel(t *testing.T) {
+	v, _ := makeFake(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := vcsview.NewCommitCursor(ctx, func(ctx context.Context, result chan vcsview.Commit) *vcsview.Executor {
+		return v.ReadHistory(ctx, projectPath, "", "", 0, -1, result)
+	})
+
+	if !c.Next() {
+		t.Fatalf("CommitCursor.Next() = false, want: true, error: %v", c.Err())
+	}
+
+	cancel()
+
+	for c.Next() {
+	}
+
+	if err := c.Err(); err != context.Canceled {
+		t.Errorf("CommitCursor.Err() after cancel = %v, want: %v", err, context.Canceled)
+	}
+}
+
+// Fake repository gives the same history and changes as the Git one declared the same way
+func TestVcs_Git(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcsviewtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	v := New()
+	fake := v.Repository(dir)
+
+	// each commit changes the directory file and adds the new one, commit dates are increasing
+	script := "set -e\ngit init -q .\ngit config user.email test@example.com\ngit config user.name test\n"
+	n := 0
+
+	commit := func(branch string, path string) {
+		n++
+		content := []byte(fmt.Sprintf("%d\n", n))
+		fake.Commit(branch, fmt.Sprintf("%s %d", path, n), Files{path + "/" + path: content, fmt.Sprintf("%s/%d", path, n): content})
+		script += fmt.Sprintf(
+			"git checkout -q %[1]s 2>/dev/null || git checkout -q -b %[1]s\nmkdir -p %[2]s && echo %[3]d > %[2]s/%[2]s && echo %[3]d > %[2]s/%[3]d\ngit add -A\n"+
+				"GIT_COMMITTER_DATE=\"2020-01-01T00:%02[3]d:00\" git commit -q -m \"%[2]s %[3]d\" --date=\"2020-01-01T00:%02[3]d:00\"\n",
+			branch, path, n)
+	}
+
+	commit("master", "a")
+	commit("master", "b")
+	fake.Branch("side", "master")
+	commit("side", "a")
+	commit("side", "c")
+	commit("master", "c")
+	commit("master", "b")
+
+	n++
+	fake.Merge("master", "side", "merge", nil)
+	script += fmt.Sprintf(
+		"git checkout -q master\nGIT_COMMITTER_DATE=\"2020-01-01T00:%02[1]d:00\" GIT_AUTHOR_DATE=\"2020-01-01T00:%02[1]d:00\" git merge -q --no-ff -m merge side -X ours\n",
+		n)
+
+	commit("master", "a")
+
+	cmd := exec.Command("sh", "-c", script)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Can't create repository: %v, %s", err, out)
+	}
+
+	git := vcsview.NewGitWithOptions(vcsview.GitOptions{InheritEnv: true})
+	defer git.Close()
+
+	want, err := vcsview.NewRepository(dir, git)
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", dir, err)
+	}
+
+	got, err := vcsview.NewRepository(dir, v)
+	if err != nil {
+		t.Fatalf("Can't create repository for %s. Got error: %v", dir, err)
+	}
+
+	for _, path := range []string{"", "a", "b", "c", "a/a"} {
+		wantCommits, wantErr := want.History(context.Background(), path, "", 0, 100)
+		gotCommits, err := got.History(context.Background(), path, "", 0, 100)
+
+		if wantErr != nil || err != nil || strings.Join(messages(gotCommits), ",") != strings.Join(messages(wantCommits), ",") {
+			t.Errorf("Vcs.ReadHistory(%s) = %v, %v, want: %v, %v", path, messages(gotCommits), err, messages(wantCommits), wantErr)
+		}
+	}
+
+	gotCommits, _ := got.History(context.Background(), "", "", 0, 100)
+	gotIds := make(map[string]string)
+	for _, c := range gotCommits {
+		gotIds[c.Message()] = c.Id()
+	}
+
+	wantCommits, _ := want.History(context.Background(), "", "", 0, 100)
+	for _, c := range wantCommits {
+		wantDiffs, wantErr := want.Diff(context.Background(), c.Id())
+		gotDiffs, err := got.Diff(context.Background(), gotIds[c.Message()])
+
+		if wantErr != nil || err != nil || fmt.Sprintf("%#v", gotDiffs) != fmt.Sprintf("%#v", wantDiffs) {
+			t.Errorf("Vcs.ReadDiff(%s) = %#v, %v, want: %#v, %v", c.Message(), gotDiffs, err, wantDiffs, wantErr)
+		}
+	}
+
+	for _, recursive := range []bool{false, true} {
+		wantFiles, wantErr := want.ReadTree(context.Background(), "HEAD", "", recursive)
+		gotFiles, err := got.ReadTree(context.Background(), "HEAD", "", recursive)
+
+		if wantErr != nil || err != nil || fmt.Sprint(gotFiles) != fmt.Sprint(wantFiles) {
+			t.Errorf("Vcs.ReadTree(HEAD, , %v) = %v, %v, want: %v, %v", recursive, gotFiles, err, wantFiles, wantErr)
+		}
+	}
+}